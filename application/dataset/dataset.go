@@ -0,0 +1,411 @@
+package dataset
+
+import (
+	"amartha-recon-service/application/recon"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	InjectionMissing     Injection = "MISSING"
+	InjectionAmountDrift Injection = "AMOUNT_DRIFT"
+	InjectionDuplicate   Injection = "DUPLICATE"
+	InjectionDateShift   Injection = "DATE_SHIFT"
+	InjectionSignFlip    Injection = "SIGN_FLIP"
+
+	// Transaction ids and terminal RRNs are 12 digits like the seed procedure,
+	// starting with 1 and 2 so a RRN never equals a unique_id of the bank file
+	transactionIDBase = 100000000000
+	terminalRRNBase   = 200000000000
+	idRange           = 100000000000
+
+	// Amounts are 1.00 to 9999.99 in cents, a drift is 1% to 10% of it
+	minAmountCents   = 100
+	maxAmountCents   = 999999
+	minDriftPercent  = 1
+	maxDriftPercent  = 10
+	driftPercentBase = 100
+)
+
+var (
+	ErrorInvalidOptions = errors.New("opsi dataset tidak valid")
+
+	systemHeader = []string{"transaction_id", "terminal_rrn", "amount", "transaction_type", "bank_code", "transaction_time"}
+	bankHeader   = []string{"unique_id", "amount", "date", "bank_code", "transaction_type"}
+)
+
+type (
+	// Injection is an error put on purpose into a pair of rows.
+	Injection string
+
+	// Rates are the share of the rows given each injection, a row gets one
+	// injection at most so they add up to 1 at most.
+	Rates struct {
+		Missing     float64 `json:"missing"`
+		AmountDrift float64 `json:"amount_drift"`
+		Duplicate   float64 `json:"duplicate"`
+		DateShift   float64 `json:"date_shift"`
+		SignFlip    float64 `json:"sign_flip"`
+	}
+
+	// Options describe the generated files. Rows is the number of system
+	// transactions before the injections, a missing row or a duplicate
+	// changes the rows of one file. A zero Seed is picked from the clock.
+	Options struct {
+		Rows      int
+		BankCodes []string
+		StartDate time.Time
+		EndDate   time.Time
+		Rates     Rates
+		DateShift time.Duration
+		Seed      int64
+	}
+
+	// Dataset is a system file and a bank file of the same transactions, with
+	// the exceptions a recon of both is expected to find.
+	Dataset struct {
+		Transactions   []recon.TransactionUploadFile
+		BankStatements []recon.BankStatementUploadFile
+		Manifest       Manifest
+	}
+
+	// Manifest is the ground truth of a dataset. Config lists the settings
+	// the expected exceptions assume, the other settings are the default ones.
+	Manifest struct {
+		Seed               int64                        `json:"seed"`
+		Rows               int                          `json:"rows"`
+		SystemRows         int                          `json:"system_rows"`
+		BankRows           int                          `json:"bank_rows"`
+		BankCodes          []string                     `json:"bank_codes"`
+		StartDate          string                       `json:"start_date"`
+		EndDate            string                       `json:"end_date"`
+		Rates              Rates                        `json:"rates"`
+		DateShift          string                       `json:"date_shift"`
+		Config             map[string]string            `json:"config"`
+		TotalExceptions    int                          `json:"total_exceptions"`
+		ExceptionsByReason map[recon.MismatchReason]int `json:"exceptions_by_reason"`
+		Exceptions         []ExpectedException          `json:"exceptions"`
+	}
+
+	// ExpectedException is identified like a stored exception, by its bank
+	// code, reason, side and the ids of its rows.
+	ExpectedException struct {
+		Injection        Injection            `json:"injection"`
+		BankCode         string               `json:"bank_code"`
+		Reason           recon.MismatchReason `json:"reason"`
+		Side             recon.ExceptionSide  `json:"side"`
+		TransactionID    string               `json:"transaction_id,omitempty"`
+		UniqueID         string               `json:"unique_id,omitempty"`
+		AmountDifference *decimal.Decimal     `json:"amount_difference,omitempty"`
+	}
+
+	generator struct {
+		options  Options
+		rnd      *rand.Rand
+		ids      map[int64]bool
+		dataset  Dataset
+		duration int64
+	}
+)
+
+// Generate builds the rows of options and injects the errors at their rates.
+// The same options and seed always give the same dataset.
+func Generate(options Options) (Dataset, error) {
+	if err := options.validate(); err != nil {
+		return Dataset{}, err
+	}
+
+	if options.Seed == 0 {
+		options.Seed = time.Now().UnixNano()
+	}
+
+	g := &generator{
+		options:  options,
+		rnd:      rand.New(rand.NewSource(options.Seed)),
+		ids:      make(map[int64]bool),
+		duration: int64(options.EndDate.Sub(options.StartDate) / time.Second),
+	}
+
+	for i := 0; i < options.Rows; i++ {
+		g.add()
+	}
+
+	g.rnd.Shuffle(len(g.dataset.Transactions), func(i, j int) {
+		g.dataset.Transactions[i], g.dataset.Transactions[j] = g.dataset.Transactions[j], g.dataset.Transactions[i]
+	})
+	g.rnd.Shuffle(len(g.dataset.BankStatements), func(i, j int) {
+		g.dataset.BankStatements[i], g.dataset.BankStatements[j] = g.dataset.BankStatements[j], g.dataset.BankStatements[i]
+	})
+
+	g.dataset.Manifest = g.manifest()
+	return g.dataset, nil
+}
+
+func (o Options) validate() error {
+	if o.Rows < 1 {
+		return fmt.Errorf("%w: rows should be at least 1", ErrorInvalidOptions)
+	}
+
+	if len(o.BankCodes) == 0 {
+		return fmt.Errorf("%w: bank codes are required", ErrorInvalidOptions)
+	}
+
+	for _, bankCode := range o.BankCodes {
+		if bankCode == "" || strings.ContainsAny(bankCode, ",\"\r\n") {
+			return fmt.Errorf("%w: bank code %q", ErrorInvalidOptions, bankCode)
+		}
+	}
+
+	if o.EndDate.Before(o.StartDate) {
+		return fmt.Errorf("%w: end date is before start date", ErrorInvalidOptions)
+	}
+
+	total := 0.0
+	for _, rate := range []float64{o.Rates.Missing, o.Rates.AmountDrift, o.Rates.Duplicate, o.Rates.DateShift, o.Rates.SignFlip} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%w: a rate should be between 0 and 1", ErrorInvalidOptions)
+		}
+		total += rate
+	}
+
+	if total > 1 {
+		return fmt.Errorf("%w: the rates add up to more than 1", ErrorInvalidOptions)
+	}
+
+	if o.Rates.DateShift > 0 {
+		if o.DateShift < time.Second {
+			return fmt.Errorf("%w: date shift should be at least 1s", ErrorInvalidOptions)
+		}
+
+		// A shifted row has to stay in the date range, or the recon leaves it out
+		if o.EndDate.Sub(o.StartDate) < o.DateShift {
+			return fmt.Errorf("%w: the date range is shorter than the date shift", ErrorInvalidOptions)
+		}
+	}
+
+	return nil
+}
+
+// add builds a transaction and its bank statement, with one injection at most.
+func (g *generator) add() {
+	transactionType := recon.TransactionTypeCredit
+	if g.rnd.Intn(2) == 0 {
+		transactionType = recon.TransactionTypeDebit
+	}
+
+	tx := recon.TransactionUploadFile{
+		TransactionID:   g.id(transactionIDBase),
+		TerminalRRN:     g.id(terminalRRNBase),
+		Amount:          decimal.New(minAmountCents+g.rnd.Int63n(maxAmountCents-minAmountCents+1), -2),
+		TransactionType: transactionType,
+		BankCode:        g.options.BankCodes[g.rnd.Intn(len(g.options.BankCodes))],
+		TransactionTime: g.options.StartDate.Add(time.Duration(g.rnd.Int63n(g.duration+1)) * time.Second),
+	}
+	bank := recon.BankStatementUploadFile{
+		UniqueID:        tx.TransactionID,
+		Amount:          tx.Amount,
+		TransactionType: tx.TransactionType,
+		Date:            tx.TransactionTime,
+		BankCode:        tx.BankCode,
+	}
+
+	expected := ExpectedException{BankCode: tx.BankCode, Side: recon.ExceptionSideBoth, TransactionID: tx.TransactionID, UniqueID: bank.UniqueID}
+	switch expected.Injection = g.injection(); expected.Injection {
+	case "":
+		g.addPair(tx, bank)
+		return
+	case InjectionMissing:
+		if g.rnd.Intn(2) == 0 {
+			g.dataset.Transactions = append(g.dataset.Transactions, tx)
+			expected.Reason, expected.Side, expected.UniqueID = recon.MismatchReasonMissingInBank, recon.ExceptionSideSystem, ""
+		} else {
+			g.dataset.BankStatements = append(g.dataset.BankStatements, bank)
+			expected.Reason, expected.Side, expected.TransactionID = recon.MismatchReasonMissingInSystem, recon.ExceptionSideBank, ""
+		}
+	case InjectionDuplicate:
+		// One of the copies is paired, the other one is left as the duplicate
+		g.addPair(tx, bank)
+		expected.Reason = recon.MismatchReasonDuplicateID
+		if g.rnd.Intn(2) == 0 {
+			g.dataset.Transactions = append(g.dataset.Transactions, tx)
+			expected.Side, expected.UniqueID = recon.ExceptionSideSystem, ""
+		} else {
+			g.dataset.BankStatements = append(g.dataset.BankStatements, bank)
+			expected.Side, expected.TransactionID = recon.ExceptionSideBank, ""
+		}
+	case InjectionAmountDrift:
+		percent := decimal.NewFromInt(minDriftPercent + g.rnd.Int63n(maxDriftPercent-minDriftPercent+1))
+		difference := decimal.Max(tx.Amount.Mul(percent).Div(decimal.NewFromInt(driftPercentBase)).Round(2), decimal.New(1, -2))
+		if g.rnd.Intn(2) == 0 {
+			difference = difference.Neg()
+		}
+		bank.Amount = tx.Amount.Sub(difference)
+		expected.Reason, expected.AmountDifference = recon.MismatchReasonAmountMismatch, &difference
+		g.addPair(tx, bank)
+	case InjectionDateShift:
+		bank.Date = tx.TransactionTime.Add(g.options.DateShift)
+		if bank.Date.After(g.options.EndDate) {
+			bank.Date = tx.TransactionTime.Add(-g.options.DateShift)
+		}
+		// Both directions leave the range when the transaction is in its middle
+		if bank.Date.Before(g.options.StartDate) {
+			tx.TransactionTime = g.options.StartDate
+			bank.Date = tx.TransactionTime.Add(g.options.DateShift)
+		}
+		expected.Reason = recon.MismatchReasonDateMismatch
+		g.addPair(tx, bank)
+	case InjectionSignFlip:
+		bank.TransactionType = recon.TransactionTypeDebit
+		if tx.TransactionType == recon.TransactionTypeDebit {
+			bank.TransactionType = recon.TransactionTypeCredit
+		}
+		expected.Reason = recon.MismatchReasonTypeMismatch
+		g.addPair(tx, bank)
+	}
+
+	g.dataset.Manifest.Exceptions = append(g.dataset.Manifest.Exceptions, expected)
+}
+
+func (g *generator) addPair(tx recon.TransactionUploadFile, bank recon.BankStatementUploadFile) {
+	g.dataset.Transactions = append(g.dataset.Transactions, tx)
+	g.dataset.BankStatements = append(g.dataset.BankStatements, bank)
+}
+
+// injection picks the injection of a row by the rates, empty for a clean row.
+func (g *generator) injection() Injection {
+	rates := g.options.Rates
+	pick := g.rnd.Float64()
+	for _, candidate := range []struct {
+		injection Injection
+		rate      float64
+	}{
+		{InjectionMissing, rates.Missing},
+		{InjectionAmountDrift, rates.AmountDrift},
+		{InjectionDuplicate, rates.Duplicate},
+		{InjectionDateShift, rates.DateShift},
+		{InjectionSignFlip, rates.SignFlip},
+	} {
+		if pick < candidate.rate {
+			return candidate.injection
+		}
+		pick -= candidate.rate
+	}
+
+	return ""
+}
+
+// id returns a 12 digit id from base, never returned before.
+func (g *generator) id(base int64) string {
+	for {
+		id := base + g.rnd.Int63n(idRange)
+		if !g.ids[id] {
+			g.ids[id] = true
+			return fmt.Sprint(id)
+		}
+	}
+}
+
+func (g *generator) manifest() Manifest {
+	manifest := g.dataset.Manifest
+	manifest.Seed = g.options.Seed
+	manifest.Rows = g.options.Rows
+	manifest.SystemRows = len(g.dataset.Transactions)
+	manifest.BankRows = len(g.dataset.BankStatements)
+	manifest.BankCodes = g.options.BankCodes
+	manifest.StartDate = g.options.StartDate.Format(time.DateOnly)
+	manifest.EndDate = g.options.EndDate.Format(time.DateOnly)
+	manifest.Rates = g.options.Rates
+	manifest.DateShift = g.options.DateShift.String()
+	manifest.Config = map[string]string{
+		"recon.tolerance.amount.default": "0",
+		"recon.bank.sign.default":        string(recon.SignConventionNone),
+		"duplicate_policy":               string(recon.DuplicatePolicyFlag),
+	}
+	// A clean pair has no drift at all, any time tolerance under the shift works
+	if g.options.Rates.DateShift > 0 {
+		manifest.Config["recon.tolerance.time.default"] = (g.options.DateShift / 2).String()
+	}
+
+	if manifest.Exceptions == nil {
+		manifest.Exceptions = []ExpectedException{}
+	}
+
+	sort.Slice(manifest.Exceptions, func(i, j int) bool {
+		a, b := manifest.Exceptions[i], manifest.Exceptions[j]
+		if a.BankCode != b.BankCode {
+			return a.BankCode < b.BankCode
+		}
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		if a.TransactionID != b.TransactionID {
+			return a.TransactionID < b.TransactionID
+		}
+		return a.UniqueID < b.UniqueID
+	})
+
+	manifest.TotalExceptions = len(manifest.Exceptions)
+	manifest.ExceptionsByReason = make(map[recon.MismatchReason]int)
+	for _, exception := range manifest.Exceptions {
+		manifest.ExceptionsByReason[exception.Reason]++
+	}
+
+	return manifest
+}
+
+// WriteSystem writes the transactions as a system file of the default
+// template.
+func WriteSystem(w io.Writer, transactions []recon.TransactionUploadFile) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(systemHeader); err != nil {
+		return err
+	}
+
+	for _, tx := range transactions {
+		if err := writer.Write([]string{
+			tx.TransactionID,
+			tx.TerminalRRN,
+			tx.Amount.StringFixed(2),
+			tx.TransactionType,
+			tx.BankCode,
+			tx.TransactionTime.Format(time.DateTime),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteBank writes the bank statements as a bank file of the default
+// template, with its transaction_type column.
+func WriteBank(w io.Writer, bankStatements []recon.BankStatementUploadFile) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(bankHeader); err != nil {
+		return err
+	}
+
+	for _, b := range bankStatements {
+		if err := writer.Write([]string{
+			b.UniqueID,
+			b.Amount.StringFixed(2),
+			b.Date.Format(time.DateTime),
+			b.BankCode,
+			b.TransactionType,
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,182 @@
+package dataset_test
+
+import (
+	"amartha-recon-service/application/dataset"
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
+	"amartha-recon-service/mocks"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	startDate = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate   = time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+)
+
+func TestGenerate(t *testing.T) {
+	options := dataset.Options{
+		Rows:      200,
+		BankCodes: []string{"014", "008"},
+		StartDate: startDate,
+		EndDate:   endDate,
+		Rates:     dataset.Rates{Missing: 0.1, Duplicate: 0.1},
+		Seed:      7,
+	}
+
+	t.Run("success same seed same dataset", func(t *testing.T) {
+		first, err := dataset.Generate(options)
+		assert.NoError(t, err)
+		second, err := dataset.Generate(options)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second)
+
+		manifest := first.Manifest
+		assert.Equal(t, int64(7), manifest.Seed)
+		assert.Equal(t, len(first.Transactions), manifest.SystemRows)
+		assert.Equal(t, len(first.BankStatements), manifest.BankRows)
+		assert.Equal(t, len(manifest.Exceptions), manifest.TotalExceptions)
+		assert.Equal(t, manifest.TotalExceptions, manifest.ExceptionsByReason[recon.MismatchReasonMissingInBank]+
+			manifest.ExceptionsByReason[recon.MismatchReasonMissingInSystem]+manifest.ExceptionsByReason[recon.MismatchReasonDuplicateID])
+		// A missing row takes one row out, a duplicate adds one
+		missing := manifest.ExceptionsByReason[recon.MismatchReasonMissingInBank] + manifest.ExceptionsByReason[recon.MismatchReasonMissingInSystem]
+		duplicates := manifest.ExceptionsByReason[recon.MismatchReasonDuplicateID]
+		assert.Equal(t, 2*options.Rows-missing+duplicates, manifest.SystemRows+manifest.BankRows)
+
+		for _, tx := range first.Transactions {
+			assert.False(t, tx.TransactionTime.Before(startDate) || tx.TransactionTime.After(endDate))
+			assert.NotEqual(t, tx.TransactionID, tx.TerminalRRN)
+		}
+	})
+
+	t.Run("success without injections", func(t *testing.T) {
+		clean := options
+		clean.Rates = dataset.Rates{}
+		result, err := dataset.Generate(clean)
+		assert.NoError(t, err)
+		assert.Len(t, result.Transactions, clean.Rows)
+		assert.Len(t, result.BankStatements, clean.Rows)
+		assert.Empty(t, result.Manifest.Exceptions)
+	})
+
+	tests := []struct {
+		name   string
+		change func(o *dataset.Options)
+	}{
+		{name: "no rows", change: func(o *dataset.Options) { o.Rows = 0 }},
+		{name: "no bank codes", change: func(o *dataset.Options) { o.BankCodes = nil }},
+		{name: "empty bank code", change: func(o *dataset.Options) { o.BankCodes = []string{"014", ""} }},
+		{name: "end before start", change: func(o *dataset.Options) { o.EndDate = startDate.Add(-time.Hour) }},
+		{name: "negative rate", change: func(o *dataset.Options) { o.Rates.SignFlip = -0.1 }},
+		{name: "rates over 1", change: func(o *dataset.Options) { o.Rates.AmountDrift = 0.9 }},
+		{name: "date shift missing", change: func(o *dataset.Options) { o.Rates.DateShift = 0.1 }},
+		{name: "date shift over the range", change: func(o *dataset.Options) {
+			o.Rates.DateShift, o.DateShift = 0.1, 240*time.Hour
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run("error "+tt.name, func(t *testing.T) {
+			invalid := options
+			tt.change(&invalid)
+			_, err := dataset.Generate(invalid)
+			assert.ErrorIs(t, err, dataset.ErrorInvalidOptions)
+		})
+	}
+}
+
+// TestGenerate_Reconcile reconciles the written files and checks the
+// exceptions found are the ones of the manifest.
+func TestGenerate_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	generated, err := dataset.Generate(dataset.Options{
+		Rows:      1000,
+		BankCodes: []string{"014", "008", "002"},
+		StartDate: startDate,
+		EndDate:   endDate,
+		Rates: dataset.Rates{
+			Missing:     0.05,
+			AmountDrift: 0.05,
+			Duplicate:   0.05,
+			DateShift:   0.05,
+			SignFlip:    0.05,
+		},
+		DateShift: 48 * time.Hour,
+		Seed:      42,
+	})
+	assert.NoError(t, err)
+
+	var system, bank bytes.Buffer
+	assert.NoError(t, dataset.WriteSystem(&system, generated.Transactions))
+	assert.NoError(t, dataset.WriteBank(&bank, generated.BankStatements))
+
+	templates := parser.NewRegistry(nil)
+	systemTemplate, err := templates.Find("", parser.SideSystem)
+	assert.NoError(t, err)
+	bankTemplate, err := templates.Find("", parser.SideBank)
+	assert.NoError(t, err)
+
+	transactions, rowErrors, err := parser.ParseSystemFile(ctx, &system, "system.csv", "", systemTemplate, startDate, endDate, recon.NoProgress)
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrors)
+	assert.Len(t, transactions, generated.Manifest.SystemRows)
+
+	bankStatements, rowErrors, err := parser.ParseBankFile(ctx, &bank, "bank.csv", "", bankTemplate, startDate, endDate, recon.NoProgress)
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrors)
+	assert.Len(t, bankStatements, generated.Manifest.BankRows)
+
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetInt", "max.rows.transactions").Return(int64(10000))
+	cfg.On("GetInt", "max.rows.bank").Return(int64(10000))
+	cfg.On("GetInt", "max.chunk").Return(int64(4))
+	cfg.On("GetArray", "recon.match.rules.default").Return([]string{"TRANSACTION_ID", "TERMINAL_RRN+AMOUNT+DATE"})
+	cfg.On("GetArray", mock.Anything).Return([]string(nil))
+	cfg.On("GetString", mock.Anything).Return(func(key string) string { return generated.Manifest.Config[key] })
+	svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+	result, err := svc.Proceed(ctx, recon.NewUploadFile(transactions, bankStatements, startDate, endDate))
+	assert.NoError(t, err)
+
+	var found []dataset.ExpectedException
+	for _, rr := range result.ResultReconciliation {
+		for _, exception := range rr.ResultReconciliationDetails.Exceptions {
+			actual := dataset.ExpectedException{BankCode: rr.BankCode, Reason: exception.Reason, Side: exception.Side}
+			if exception.Transaction != nil {
+				actual.TransactionID = exception.Transaction.TransactionID
+			}
+			if exception.BankStatement != nil {
+				actual.UniqueID = exception.BankStatement.UniqueID
+			}
+			if exception.Reason == recon.MismatchReasonAmountMismatch {
+				actual.AmountDifference = exception.AmountDifference
+			}
+			found = append(found, actual)
+		}
+	}
+
+	var expected []dataset.ExpectedException
+	for _, exception := range generated.Manifest.Exceptions {
+		exception.Injection = ""
+		expected = append(expected, exception)
+	}
+
+	assert.NotEmpty(t, expected)
+	assert.ElementsMatch(t, expected, found)
+	for _, reason := range []recon.MismatchReason{
+		recon.MismatchReasonMissingInBank,
+		recon.MismatchReasonMissingInSystem,
+		recon.MismatchReasonAmountMismatch,
+		recon.MismatchReasonDateMismatch,
+		recon.MismatchReasonDuplicateID,
+		recon.MismatchReasonTypeMismatch,
+	} {
+		assert.Positive(t, generated.Manifest.ExceptionsByReason[reason], reason)
+	}
+}
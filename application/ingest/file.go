@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"amartha-recon-service/application/recon"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// failureSuffix names the error file written next to a quarantined file
+	failureSuffix = ".error.json"
+
+	maxNameAttempts = 1000
+)
+
+type (
+	// Failure is why a file was quarantined, it is written next to the file.
+	Failure struct {
+		FileName      string                  `json:"file_name"`
+		Rule          string                  `json:"rule,omitempty"`
+		BankCode      string                  `json:"bank_code,omitempty"`
+		Template      string                  `json:"template,omitempty"`
+		Error         string                  `json:"error"`
+		Validation    *recon.ValidationReport `json:"validation,omitempty"`
+		Duplicates    []recon.DuplicateGroup  `json:"duplicates,omitempty"`
+		QuarantinedAt time.Time               `json:"quarantined_at"`
+	}
+)
+
+// moveFile moves the file at path into dir and returns its new path. A file
+// of the same name already there is kept, the moved one gets a "-1", "-2"...
+// suffix on its name.
+func moveFile(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	target, err := freePath(dir, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(path, target)
+	if errors.Is(err, syscall.EXDEV) {
+		// The inbox is a mount of its own
+		err = copyFile(path, target)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+func freePath(dir, name string) (string, error) {
+	stem, ext, hasExt := strings.Cut(name, ".")
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		candidate := name
+		if attempt > 0 {
+			candidate = fmt.Sprintf("%s-%d", stem, attempt)
+			if hasExt {
+				candidate += "." + ext
+			}
+		}
+
+		path := filepath.Join(dir, candidate)
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no free name for %s in %s", name, dir)
+}
+
+// copyFile copies path to target, which must not exist, then removes path.
+func copyFile(path, target string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(dst, src); err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func writeFailure(path string, failure Failure) error {
+	body, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+failureSuffix, append(body, '\n'), 0o644)
+}
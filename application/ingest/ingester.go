@@ -0,0 +1,442 @@
+package ingest
+
+import (
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/schedule"
+	"amartha-recon-service/common"
+	"amartha-recon-service/configuration"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollSeconds   = 10
+	defaultStableSeconds = 30
+	defaultDateLayout    = "20060102"
+
+	groupDate     = "date"
+	groupBankCode = "bank_code"
+
+	// markerSuffix is the empty file a sender drops next to a finished upload
+	markerSuffix = ".done"
+)
+
+var (
+	ErrorInvalidIngest = errors.New("konfigurasi ingest inbox tidak valid")
+	ErrorNoRule        = errors.New("nama file tidak cocok dengan aturan ingest")
+	ErrorFileDate      = errors.New("tanggal pada nama file tidak valid")
+)
+
+type (
+	// Rule tells the bank and template of the files of an inbox by their name.
+	Rule struct {
+		Name  string
+		Inbox string
+		// Pattern matches the whole file name, its "date" group is the
+		// reconciled day and its "bank_code" group the bank when BankCode is
+		// empty
+		Pattern *regexp.Regexp
+		// BankCode of the files, read from the file name when empty
+		BankCode string
+		// Template of the files, detected from their header when empty
+		Template   string
+		DateLayout string
+	}
+
+	// Settings are read from recon.ingest.*, the rules are tried in their
+	// order on recon.ingest.rules.
+	Settings struct {
+		Rules           []Rule
+		ArchiveDir      string
+		QuarantineDir   string
+		PollInterval    time.Duration
+		StableFor       time.Duration
+		DuplicatePolicy recon.DuplicatePolicy
+		ValidationMode  recon.ValidationMode
+	}
+
+	// observation is the size and modification time a file had on the polls
+	// of its inbox since the time in since.
+	observation struct {
+		size    int64
+		modTime time.Time
+		since   time.Time
+	}
+
+	// pendingArchive is a file whose run is stored but which could not be
+	// archived, as long as it is the same file only its move is tried again.
+	pendingArchive struct {
+		date    time.Time
+		runID   string
+		size    int64
+		modTime time.Time
+	}
+
+	ingester struct {
+		settings Settings
+		runner   schedule.Runner
+		generate common.Generate
+
+		// mu serialises the scans, seen and archiving are only used under it
+		mu        sync.Mutex
+		seen      map[string]observation
+		archiving map[string]pendingArchive
+
+		background *schedule.Background
+	}
+
+	Ingester interface {
+		Start()
+		Scan(ctx context.Context)
+		Shutdown(ctx context.Context) error
+	}
+)
+
+// NewSettings reads every rule on recon.ingest.rules.
+func NewSettings(cfg configuration.Configuration) (Settings, error) {
+	settings := Settings{
+		ArchiveDir:    cfg.GetString("recon.ingest.archive.dir"),
+		QuarantineDir: cfg.GetString("recon.ingest.quarantine.dir"),
+		PollInterval:  time.Duration(cfg.GetInt("recon.ingest.poll.seconds")) * time.Second,
+		StableFor:     time.Duration(cfg.GetInt("recon.ingest.stable.seconds")) * time.Second,
+	}
+
+	if settings.ArchiveDir == "" || settings.QuarantineDir == "" {
+		return Settings{}, fmt.Errorf("%w: recon.ingest.archive.dir and recon.ingest.quarantine.dir are required", ErrorInvalidIngest)
+	}
+
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = defaultPollSeconds * time.Second
+	}
+
+	if settings.StableFor <= 0 {
+		settings.StableFor = defaultStableSeconds * time.Second
+	}
+
+	var err error
+	if settings.DuplicatePolicy, err = recon.ParseDuplicatePolicy(cfg.GetString("recon.ingest.duplicate.policy")); err != nil {
+		return Settings{}, err
+	}
+
+	if settings.ValidationMode, err = recon.ParseValidationMode(cfg.GetString("recon.ingest.validation.mode")); err != nil {
+		return Settings{}, err
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range cfg.GetArray("recon.ingest.rules") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return Settings{}, fmt.Errorf("%w: rule %q on recon.ingest.rules", ErrorInvalidIngest, name)
+		}
+		seen[name] = true
+
+		rule, err := loadRule(cfg, name)
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.Rules = append(settings.Rules, rule)
+	}
+
+	if len(settings.Rules) == 0 {
+		return Settings{}, fmt.Errorf("%w: recon.ingest.rules is empty", ErrorInvalidIngest)
+	}
+
+	return settings, nil
+}
+
+func loadRule(cfg configuration.Configuration, name string) (Rule, error) {
+	prefix := "recon.ingest.rule." + name
+	rule := Rule{
+		Name:       name,
+		Inbox:      strings.TrimSpace(cfg.GetString(prefix + ".inbox")),
+		BankCode:   strings.TrimSpace(cfg.GetString(prefix + ".bank.code")),
+		Template:   strings.TrimSpace(cfg.GetString(prefix + ".template")),
+		DateLayout: cfg.GetString(prefix + ".date.layout"),
+	}
+
+	if rule.Inbox == "" {
+		return Rule{}, fmt.Errorf("%w: rule %s has no inbox", ErrorInvalidIngest, name)
+	}
+	rule.Inbox = filepath.Clean(rule.Inbox)
+
+	if rule.DateLayout == "" {
+		rule.DateLayout = defaultDateLayout
+	}
+
+	pattern := cfg.GetString(prefix + ".pattern")
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if pattern == "" || err != nil {
+		return Rule{}, fmt.Errorf("%w: rule %s has pattern %q", ErrorInvalidIngest, name, pattern)
+	}
+	rule.Pattern = compiled
+
+	if compiled.SubexpIndex(groupDate) < 0 {
+		return Rule{}, fmt.Errorf("%w: pattern of rule %s has no (?P<%s>) group", ErrorInvalidIngest, name, groupDate)
+	}
+
+	if rule.BankCode == "" && compiled.SubexpIndex(groupBankCode) < 0 {
+		return Rule{}, fmt.Errorf("%w: rule %s has neither a bank code nor a (?P<%s>) group", ErrorInvalidIngest, name, groupBankCode)
+	}
+
+	return rule, nil
+}
+
+// NewIngester polls the inbox of every rule of settings and reconciles the
+// files through runner once they are complete.
+func NewIngester(settings Settings, runner schedule.Runner, generate common.Generate) Ingester {
+	return &ingester{
+		settings:   settings,
+		runner:     runner,
+		generate:   generate,
+		seen:       make(map[string]observation),
+		archiving:  make(map[string]pendingArchive),
+		background: schedule.NewBackground(),
+	}
+}
+
+// Start polls the inboxes in the background, it is called once.
+func (i *ingester) Start() {
+	i.background.Go(i.loop)
+}
+
+func (i *ingester) loop() {
+	ticker := time.NewTicker(i.settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		i.Scan(i.background.Context())
+
+		select {
+		case <-i.background.Stopped():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan looks once at every inbox. A file is ingested when its .done marker
+// is there, or when its size and modification time did not change for
+// StableFor, an upload still going is left for a later scan.
+func (i *ingester) Scan(ctx context.Context) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.generate.Time()
+	present := make(map[string]bool)
+	for _, inbox := range i.inboxes() {
+		entries, err := os.ReadDir(inbox)
+		if err != nil {
+			log.Printf("[ingest] error reading inbox %s: %v", inbox, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, markerSuffix) {
+				continue
+			}
+
+			path := filepath.Join(inbox, name)
+			present[path] = true
+			if i.retryArchive(path) {
+				continue
+			}
+
+			if !i.stable(path, now) {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			i.ingest(ctx, inbox, path, now)
+		}
+	}
+
+	for path := range i.seen {
+		if !present[path] {
+			delete(i.seen, path)
+		}
+	}
+
+	for path := range i.archiving {
+		if !present[path] {
+			delete(i.archiving, path)
+		}
+	}
+}
+
+func (i *ingester) inboxes() []string {
+	var inboxes []string
+	seen := make(map[string]bool)
+	for _, rule := range i.settings.Rules {
+		if !seen[rule.Inbox] {
+			seen[rule.Inbox] = true
+			inboxes = append(inboxes, rule.Inbox)
+		}
+	}
+
+	return inboxes
+}
+
+func (i *ingester) stable(path string, now time.Time) bool {
+	if _, err := os.Stat(path + markerSuffix); err == nil {
+		return true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	last, ok := i.seen[path]
+	if !ok || last.size != info.Size() || !last.modTime.Equal(info.ModTime()) {
+		i.seen[path] = observation{size: info.Size(), modTime: info.ModTime(), since: now}
+		return false
+	}
+
+	return now.Sub(last.since) >= i.settings.StableFor
+}
+
+// ingest reconciles the file at path and archives it. A file that can not
+// be reconciled as it is goes to the quarantine, on any other error it stays
+// in the inbox and is tried again on the next scan.
+func (i *ingester) ingest(ctx context.Context, inbox, path string, now time.Time) {
+	name := filepath.Base(path)
+	rule, bankCode, date, err := i.identify(inbox, name)
+	if err != nil {
+		i.quarantine(path, Failure{FileName: name, Error: err.Error()}, now)
+		return
+	}
+
+	result, err := i.runner.Run(ctx, bankCode, date, path, rule.Template)
+	switch {
+	case err == nil:
+		i.archive(path, date, result.RunID)
+	case quarantined(err):
+		i.quarantine(path, Failure{
+			FileName:   name,
+			Rule:       rule.Name,
+			BankCode:   bankCode,
+			Template:   rule.Template,
+			Error:      err.Error(),
+			Validation: result.Validation,
+			Duplicates: result.Duplicates,
+		}, now)
+	default:
+		log.Printf("[ingest] recon of %s stopped, it is tried again: %v", path, err)
+	}
+}
+
+// identify returns the first rule of the inbox matching name, with the bank
+// code and day of the file.
+func (i *ingester) identify(inbox, name string) (Rule, string, time.Time, error) {
+	for _, rule := range i.settings.Rules {
+		if rule.Inbox != inbox {
+			continue
+		}
+
+		match := rule.Pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+
+		bankCode := rule.BankCode
+		if bankCode == "" {
+			bankCode = match[rule.Pattern.SubexpIndex(groupBankCode)]
+		}
+
+		value := match[rule.Pattern.SubexpIndex(groupDate)]
+		date, err := time.Parse(rule.DateLayout, value)
+		if err != nil {
+			return Rule{}, "", time.Time{}, fmt.Errorf("%w: %q of rule %s", ErrorFileDate, value, rule.Name)
+		}
+
+		return rule, bankCode, date, nil
+	}
+
+	return Rule{}, "", time.Time{}, ErrorNoRule
+}
+
+func quarantined(err error) bool {
+	return errors.Is(err, schedule.ErrorUnreadableFile) ||
+		errors.Is(err, recon.ErrorInvalidRows) ||
+		errors.Is(err, recon.ErrorDuplicateRows) ||
+		errors.Is(err, recon.ErrorUnexpectedBankCode) ||
+		errors.Is(err, recon.ErrorMaxRows)
+}
+
+// archive moves the file to archive/YYYY/MM of the reconciled day. The run
+// of the file is stored already, a failing move is tried again by the next
+// scans without reconciling the file again.
+func (i *ingester) archive(path string, date time.Time, runID string) {
+	dir := filepath.Join(i.settings.ArchiveDir, date.Format("2006"), date.Format("01"))
+	target, err := moveFile(path, dir)
+	if err != nil {
+		if info, statErr := os.Stat(path); statErr == nil {
+			i.archiving[path] = pendingArchive{date: date, runID: runID, size: info.Size(), modTime: info.ModTime()}
+		}
+		log.Printf("[ingest] error archiving %s of run %s, it is moved on the next scan: %v", path, runID, err)
+		return
+	}
+
+	delete(i.archiving, path)
+	i.done(path)
+	log.Printf("[ingest] %s of run %s is archived to %s", path, runID, target)
+}
+
+// retryArchive moves a file whose run is stored but which is still in the
+// inbox. A file changed since is another upload of the same name, it is
+// reconciled like a new one.
+func (i *ingester) retryArchive(path string) bool {
+	pending, ok := i.archiving[path]
+	if !ok {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != pending.size || !info.ModTime().Equal(pending.modTime) {
+		delete(i.archiving, path)
+		return false
+	}
+
+	i.archive(path, pending.date, pending.runID)
+	return true
+}
+
+func (i *ingester) quarantine(path string, failure Failure, now time.Time) {
+	target, err := moveFile(path, i.settings.QuarantineDir)
+	if err != nil {
+		log.Printf("[ingest] error quarantining %s: %v", path, err)
+		return
+	}
+
+	i.done(path)
+
+	failure.QuarantinedAt = now
+	if err := writeFailure(target, failure); err != nil {
+		log.Printf("[ingest] error writing the error file of %s: %v", target, err)
+	}
+	log.Printf("[ingest] %s is quarantined to %s: %s", path, target, failure.Error)
+}
+
+// done forgets a file which left the inbox, with its marker.
+func (i *ingester) done(path string) {
+	delete(i.seen, path)
+	if err := os.Remove(path + markerSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[ingest] error removing the marker of %s: %v", path, err)
+	}
+}
+
+// Shutdown stops the polling and lets the running recon finish. A recon
+// still going when ctx is done is cancelled, its file stays in the inbox.
+func (i *ingester) Shutdown(ctx context.Context) error {
+	return i.background.Shutdown(ctx)
+}
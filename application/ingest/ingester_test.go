@@ -0,0 +1,358 @@
+package ingest_test
+
+import (
+	"amartha-recon-service/application/ingest"
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/schedule"
+	"amartha-recon-service/common"
+	"amartha-recon-service/infrastructure/repository/transaction"
+	"amartha-recon-service/mocks"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newConfiguration(t *testing.T, values map[string]string, rules []string) *mocks.Configuration {
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetString", mock.Anything).Return(func(key string) string { return values[key] }).Maybe()
+	cfg.On("GetInt", mock.Anything).Return(int64(0)).Maybe()
+	cfg.On("GetArray", "recon.ingest.rules").Return(rules).Maybe()
+	return cfg
+}
+
+func TestNewSettings(t *testing.T) {
+	dirs := map[string]string{
+		"recon.ingest.archive.dir":    "/archive",
+		"recon.ingest.quarantine.dir": "/quarantine",
+	}
+	with := func(values map[string]string) map[string]string {
+		merged := map[string]string{}
+		for key, value := range dirs {
+			merged[key] = value
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		return merged
+	}
+
+	t.Run("success with defaults", func(t *testing.T) {
+		cfg := newConfiguration(t, with(map[string]string{
+			"recon.ingest.rule.bca.inbox":       "/sftp/bca/inbox/",
+			"recon.ingest.rule.bca.pattern":     `BCA_(?P<date>\d{8})\.csv`,
+			"recon.ingest.rule.bca.bank.code":   "014",
+			"recon.ingest.rule.bca.template":    "BCA",
+			"recon.ingest.rule.any.inbox":       "/sftp/other",
+			"recon.ingest.rule.any.pattern":     `(?P<bank_code>\d{3})-(?P<date>\d{4}-\d{2}-\d{2})\..+`,
+			"recon.ingest.rule.any.date.layout": "2006-01-02",
+		}), []string{"bca", " any"})
+
+		settings, err := ingest.NewSettings(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "/archive", settings.ArchiveDir)
+		assert.Equal(t, "/quarantine", settings.QuarantineDir)
+		assert.Equal(t, 10*time.Second, settings.PollInterval)
+		assert.Equal(t, 30*time.Second, settings.StableFor)
+		assert.Equal(t, recon.DuplicatePolicyFlag, settings.DuplicatePolicy)
+		assert.Equal(t, recon.ValidationModeReject, settings.ValidationMode)
+		assert.Len(t, settings.Rules, 2)
+
+		assert.Equal(t, "bca", settings.Rules[0].Name)
+		assert.Equal(t, "/sftp/bca/inbox", settings.Rules[0].Inbox)
+		assert.Equal(t, "014", settings.Rules[0].BankCode)
+		assert.Equal(t, "BCA", settings.Rules[0].Template)
+		assert.Equal(t, "20060102", settings.Rules[0].DateLayout)
+		// The pattern matches the whole name
+		assert.True(t, settings.Rules[0].Pattern.MatchString("BCA_20261017.csv"))
+		assert.False(t, settings.Rules[0].Pattern.MatchString("BCA_20261017.csv.part"))
+
+		assert.Equal(t, "any", settings.Rules[1].Name)
+		assert.Empty(t, settings.Rules[1].BankCode)
+		assert.Equal(t, "2006-01-02", settings.Rules[1].DateLayout)
+	})
+
+	rule := map[string]string{
+		"recon.ingest.rule.bca.inbox":     "/inbox",
+		"recon.ingest.rule.bca.pattern":   `BCA_(?P<date>\d{8})\.csv`,
+		"recon.ingest.rule.bca.bank.code": "014",
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		rules  []string
+		err    error
+	}{
+		{name: "no archive", values: map[string]string{"recon.ingest.quarantine.dir": "/quarantine"}, rules: []string{"bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "no rules", values: with(nil), err: ingest.ErrorInvalidIngest},
+		{name: "same rule twice", values: with(rule), rules: []string{"bca", "bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "no inbox", values: with(map[string]string{"recon.ingest.rule.bca.pattern": `(?P<date>\d{8})`, "recon.ingest.rule.bca.bank.code": "014"}),
+			rules: []string{"bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "pattern", values: with(map[string]string{"recon.ingest.rule.bca.inbox": "/inbox", "recon.ingest.rule.bca.pattern": `(?P<date>[`}),
+			rules: []string{"bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "no date group", values: with(map[string]string{"recon.ingest.rule.bca.inbox": "/inbox", "recon.ingest.rule.bca.pattern": `BCA\.csv`,
+			"recon.ingest.rule.bca.bank.code": "014"}), rules: []string{"bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "no bank code", values: with(map[string]string{"recon.ingest.rule.bca.inbox": "/inbox", "recon.ingest.rule.bca.pattern": `(?P<date>\d{8})`}),
+			rules: []string{"bca"}, err: ingest.ErrorInvalidIngest},
+		{name: "duplicate policy", values: with(map[string]string{"recon.ingest.duplicate.policy": "MERGE"}), rules: []string{"bca"},
+			err: recon.ErrorInvalidDuplicatePolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run("error "+tt.name, func(t *testing.T) {
+			_, err := ingest.NewSettings(newConfiguration(t, tt.values, tt.rules))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestIngester_Scan(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)
+
+	newIngester := func(t *testing.T, inbox, archive, quarantine string, locks *mocks.ReconLockRepository,
+		transactions *mocks.Repository, times ...time.Time) ingest.Ingester {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(100)).Maybe()
+		cfg.On("GetArray", mock.Anything).Return([]string(nil)).Maybe()
+		cfg.On("GetString", mock.Anything).Return("").Maybe()
+		cfg.On("GetBool", mock.Anything).Return(false).Maybe()
+
+		loader := parser.NewLoader(parser.NewRegistry(cfg), parser.NewUploadLimits(cfg), recon.NewSpillSettings(cfg))
+		service := recon.NewService(cfg, transactions, nil, common.NewGenerate())
+
+		generate := mocks.NewGenerate(t)
+		for _, at := range times {
+			generate.On("Time").Return(at).Once()
+		}
+
+		settings := ingest.Settings{
+			Rules: []ingest.Rule{
+				{Name: "bca", Inbox: inbox, Pattern: regexp.MustCompile(`^(?:BCA_(?P<date>\d{8})\.csv)$`), BankCode: "014", DateLayout: "20060102"},
+				{Name: "any", Inbox: inbox, Pattern: regexp.MustCompile(`^(?:(?P<bank_code>\d{3})_(?P<date>\d{8})\.(?:csv|xlsx))$`), DateLayout: "20060102"},
+			},
+			ArchiveDir:      archive,
+			QuarantineDir:   quarantine,
+			PollInterval:    time.Second,
+			StableFor:       30 * time.Second,
+			DuplicatePolicy: recon.DuplicatePolicyFlag,
+			ValidationMode:  recon.ValidationModeReject,
+		}
+		runner := schedule.NewRunner(service, loader, locks, settings.DuplicatePolicy, settings.ValidationMode)
+		return ingest.NewIngester(settings, runner, generate)
+	}
+
+	statement := []byte("unique_id,amount,date,bank_code\n" +
+		"TX1,100,2026-10-17 08:00:00,014\n")
+
+	t.Run("success", func(t *testing.T) {
+		inbox, archive, quarantine := t.TempDir(), t.TempDir(), t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "BCA_20261017.csv"), statement, 0o644))
+		// Done by its marker on the first scan, its rows have no amount nor date
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "008_20261017.csv"), []byte("foo;bar\n1;2\n"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "008_20261017.csv.done"), nil, 0o644))
+		// Not a workbook, the file can not be read at all
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "002_20261017.xlsx"), []byte("corrupt"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "002_20261017.xlsx.done"), nil, 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "notes.txt"), []byte("hello"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, ".BCA_20261017.csv.part"), []byte("TX"), 0o644))
+
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Times(3)
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-002-2026-10-17").Return(lock, nil).Once()
+		locks.On("TryLock", ctx, "recon-schedule-008-2026-10-17").Return(lock, nil).Once()
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(lock, nil).Once()
+
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.Anything).Return([]*transaction.Transaction{
+			{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: date.Add(8 * time.Hour)},
+		}, nil).Once()
+
+		ingester := newIngester(t, inbox, archive, quarantine, locks, transactions,
+			now, now.Add(10*time.Second), now.Add(30*time.Second))
+
+		ingester.Scan(ctx)
+		assert.NoFileExists(t, filepath.Join(inbox, "008_20261017.csv"))
+		assert.NoFileExists(t, filepath.Join(inbox, "008_20261017.csv.done"))
+		assert.FileExists(t, filepath.Join(quarantine, "008_20261017.csv"))
+
+		var failure ingest.Failure
+		body, err := os.ReadFile(filepath.Join(quarantine, "008_20261017.csv.error.json"))
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &failure))
+		assert.Equal(t, "008_20261017.csv", failure.FileName)
+		assert.Equal(t, "any", failure.Rule)
+		assert.Equal(t, "008", failure.BankCode)
+		assert.Equal(t, recon.ErrorInvalidRows.Error(), failure.Error)
+		assert.NotNil(t, failure.Validation)
+		assert.True(t, now.Equal(failure.QuarantinedAt))
+
+		body, err = os.ReadFile(filepath.Join(quarantine, "002_20261017.xlsx.error.json"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), schedule.ErrorUnreadableFile.Error())
+
+		// Not stable for long enough yet
+		ingester.Scan(ctx)
+		assert.FileExists(t, filepath.Join(inbox, "BCA_20261017.csv"))
+		assert.FileExists(t, filepath.Join(inbox, "notes.txt"))
+
+		ingester.Scan(ctx)
+		assert.NoFileExists(t, filepath.Join(inbox, "BCA_20261017.csv"))
+		assert.FileExists(t, filepath.Join(archive, "2026", "10", "BCA_20261017.csv"))
+		assert.FileExists(t, filepath.Join(quarantine, "notes.txt"))
+		assert.FileExists(t, filepath.Join(quarantine, "notes.txt.error.json"))
+		assert.FileExists(t, filepath.Join(inbox, ".BCA_20261017.csv.part"))
+	})
+
+	t.Run("growing file waits", func(t *testing.T) {
+		inbox, archive, quarantine := t.TempDir(), t.TempDir(), t.TempDir()
+		path := filepath.Join(inbox, "BCA_20261017.csv")
+		assert.NoError(t, os.WriteFile(path, statement[:10], 0o644))
+
+		ingester := newIngester(t, inbox, archive, quarantine, mocks.NewReconLockRepository(t), nil,
+			now, now.Add(40*time.Second))
+
+		ingester.Scan(ctx)
+		assert.NoError(t, os.WriteFile(path, statement, 0o644))
+		ingester.Scan(ctx)
+		assert.FileExists(t, path)
+	})
+
+	t.Run("archive keeps the file already there", func(t *testing.T) {
+		inbox, archive, quarantine := t.TempDir(), t.TempDir(), t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "BCA_20261017.csv"), statement, 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(inbox, "BCA_20261017.csv.done"), nil, 0o644))
+		assert.NoError(t, os.MkdirAll(filepath.Join(archive, "2026", "10"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archive, "2026", "10", "BCA_20261017.csv"), []byte("first"), 0o644))
+
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(lock, nil).Once()
+
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.Anything).Return([]*transaction.Transaction{}, nil).Once()
+
+		newIngester(t, inbox, archive, quarantine, locks, transactions, now).Scan(ctx)
+
+		body, err := os.ReadFile(filepath.Join(archive, "2026", "10", "BCA_20261017.csv"))
+		assert.NoError(t, err)
+		assert.Equal(t, "first", string(body))
+		assert.FileExists(t, filepath.Join(archive, "2026", "10", "BCA_20261017-1.csv"))
+		assert.NoFileExists(t, filepath.Join(inbox, "BCA_20261017.csv.done"))
+	})
+
+	t.Run("failed archive is not reconciled again", func(t *testing.T) {
+		inbox, archive, quarantine := t.TempDir(), filepath.Join(t.TempDir(), "archive"), t.TempDir()
+		path := filepath.Join(inbox, "BCA_20261017.csv")
+		assert.NoError(t, os.WriteFile(path, statement, 0o644))
+		assert.NoError(t, os.WriteFile(path+".done", nil, 0o644))
+		// A file where the archive should be, it can not be written to
+		assert.NoError(t, os.WriteFile(archive, nil, 0o644))
+
+		// The run is stored once only
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(lock, nil).Once()
+
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.Anything).Return([]*transaction.Transaction{}, nil).Once()
+
+		ingester := newIngester(t, inbox, archive, quarantine, locks, transactions, now, now, now)
+		ingester.Scan(ctx)
+		assert.FileExists(t, path)
+
+		ingester.Scan(ctx)
+		assert.FileExists(t, path)
+
+		assert.NoError(t, os.Remove(archive))
+		ingester.Scan(ctx)
+		assert.NoFileExists(t, path)
+		assert.NoFileExists(t, path+".done")
+		assert.FileExists(t, filepath.Join(archive, "2026", "10", "BCA_20261017.csv"))
+	})
+
+	t.Run("error stays in the inbox", func(t *testing.T) {
+		inbox, archive, quarantine := t.TempDir(), t.TempDir(), t.TempDir()
+		path := filepath.Join(inbox, "BCA_20261017.csv")
+		assert.NoError(t, os.WriteFile(path, statement, 0o644))
+		assert.NoError(t, os.WriteFile(path+".done", nil, 0o644))
+
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(lock, nil).Once()
+
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.Anything).Return(nil, errors.New("db error")).Once()
+
+		newIngester(t, inbox, archive, quarantine, locks, transactions, now).Scan(ctx)
+		assert.FileExists(t, path)
+		assert.FileExists(t, path+".done")
+
+		entries, err := os.ReadDir(quarantine)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestIngester_Start(t *testing.T) {
+	inbox := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "BCA_20261017.csv"), nil, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "BCA_20261017.csv.done"), nil, 0o644))
+
+	settings := ingest.Settings{
+		Rules: []ingest.Rule{
+			{Name: "bca", Inbox: inbox, Pattern: regexp.MustCompile(`^(?:BCA_(?P<date>\d{8})\.csv)$`), BankCode: "014", DateLayout: "20060102"},
+		},
+		ArchiveDir:    t.TempDir(),
+		QuarantineDir: t.TempDir(),
+		PollInterval:  10 * time.Millisecond,
+		StableFor:     time.Minute,
+	}
+
+	generate := mocks.NewGenerate(t)
+	generate.On("Time").Return(time.Now())
+
+	// The lock can not be taken, the file is tried again on the next poll
+	ran := make(chan string, 2)
+	locks := mocks.NewReconLockRepository(t)
+	locks.On("TryLock", mock.Anything, "recon-schedule-014-2026-10-17").
+		Run(func(args mock.Arguments) {
+			select {
+			case ran <- args.String(1):
+			default:
+			}
+		}).
+		Return(nil, errors.New("lock error"))
+
+	ingester := ingest.NewIngester(settings, schedule.NewRunner(nil, parser.Loader{}, locks, "", ""), generate)
+	ingester.Start()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case name := <-ran:
+			assert.Equal(t, "recon-schedule-014-2026-10-17", name)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the inbox is not polled")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, ingester.Shutdown(ctx))
+	assert.FileExists(t, filepath.Join(inbox, "BCA_20261017.csv"))
+}
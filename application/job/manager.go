@@ -0,0 +1,437 @@
+package job
+
+import (
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
+	"amartha-recon-service/configuration"
+	"amartha-recon-service/infrastructure/repository/reconjob"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers          = 2
+	defaultQueueSize        = 10
+	defaultRetentionMinutes = 60
+)
+
+var (
+	ErrorJobNotFound = errors.New("job rekonsiliasi tidak ditemukan")
+	ErrorQueueFull   = errors.New("antrian job rekonsiliasi penuh")
+	ErrorShutdown    = errors.New("service sedang berhenti, job rekonsiliasi tidak diterima")
+	ErrorJobFinished = errors.New("job rekonsiliasi sudah selesai")
+)
+
+type (
+	job struct {
+		id       string
+		task     Task
+		ctx      context.Context
+		cancel   context.CancelFunc
+		progress Progress
+
+		// release frees what the task reads, releaseOnce calls it once
+		// whether the task ran or not
+		release     func()
+		releaseOnce sync.Once
+
+		// saveMu keeps the stored job in the same order as its transitions
+		saveMu sync.Mutex
+
+		// guarded by manager.mu
+		status     Status
+		stopStatus Status
+		result     *recon.ShowResultReconciliation
+		err        string
+		createdAt  time.Time
+		startedAt  time.Time
+		finishedAt time.Time
+	}
+
+	manager struct {
+		cfg        configuration.Configuration
+		repository reconjob.Repository
+		generate   common.Generate
+
+		mu     sync.Mutex
+		jobs   map[string]*job
+		queue  chan *job
+		closed bool
+		wg     sync.WaitGroup
+	}
+
+	Manager interface {
+		Start()
+		Submit(ctx context.Context, task Task, release func()) (ShowJob, error)
+		Find(ctx context.Context, id string) (ShowJob, error)
+		Cancel(ctx context.Context, id string) (ShowJob, error)
+		Shutdown(ctx context.Context) error
+	}
+)
+
+// NewManager runs reconciliation jobs on a bounded pool of workers. The
+// repository is optional, without it jobs only live in memory.
+func NewManager(
+	cfg configuration.Configuration,
+	repository reconjob.Repository,
+	generate common.Generate) Manager {
+	return &manager{
+		cfg:        cfg,
+		repository: repository,
+		generate:   generate,
+		jobs:       make(map[string]*job),
+	}
+}
+
+// Start launches the workers, it is called once before the first Submit.
+func (m *manager) Start() {
+	workers := int(m.cfg.GetInt("job.workers"))
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+
+	queueSize := int(m.cfg.GetInt("job.queue.size"))
+	if queueSize < 1 {
+		queueSize = defaultQueueSize
+	}
+
+	m.queue = make(chan *job, queueSize)
+	for range workers {
+		m.wg.Add(1)
+		go m.work()
+	}
+}
+
+// Submit queues the task. release, when set, frees what the task reads once
+// the job is over, it is called exactly once whether the task ran or not,
+// and right away when the job is refused.
+func (m *manager) Submit(ctx context.Context, task Task, release func()) (ShowJob, error) {
+	jobCtx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:         m.generate.UUID(),
+		task:       task,
+		release:    release,
+		ctx:        jobCtx,
+		cancel:     cancel,
+		status:     StatusQueued,
+		stopStatus: StatusCancelled,
+		createdAt:  m.generate.Time(),
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cancel()
+		j.done()
+		return ShowJob{}, ErrorShutdown
+	}
+
+	select {
+	case m.queue <- j:
+	default:
+		m.mu.Unlock()
+		cancel()
+		j.done()
+		return ShowJob{}, ErrorQueueFull
+	}
+
+	m.evict(j.createdAt)
+	m.jobs[j.id] = j
+	show := j.show()
+	m.mu.Unlock()
+
+	m.save(ctx, j)
+	return show, nil
+}
+
+func (m *manager) Find(ctx context.Context, id string) (ShowJob, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if ok {
+		show := j.show()
+		m.mu.Unlock()
+		return show, nil
+	}
+	m.mu.Unlock()
+
+	// Jobs of a previous process or evicted from memory are only known by the store
+	if m.repository == nil {
+		return ShowJob{}, ErrorJobNotFound
+	}
+
+	stored, err := m.repository.FindJobByID(ctx, id)
+	if errors.Is(err, reconjob.ErrorJobNotFound) {
+		return ShowJob{}, ErrorJobNotFound
+	}
+
+	if err != nil {
+		return ShowJob{}, err
+	}
+
+	return fromStoredJob(stored), nil
+}
+
+// Cancel stops a queued or running job. A running job keeps its status until
+// its task has returned.
+func (m *manager) Cancel(ctx context.Context, id string) (ShowJob, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		if _, err := m.Find(ctx, id); err != nil {
+			return ShowJob{}, err
+		}
+
+		return ShowJob{}, ErrorJobFinished
+	}
+
+	switch j.status {
+	case StatusQueued:
+		j.status = StatusCancelled
+		j.finishedAt = m.generate.Time()
+	case StatusRunning:
+		j.stopStatus = StatusCancelled
+	default:
+		m.mu.Unlock()
+		return ShowJob{}, ErrorJobFinished
+	}
+
+	queued := j.status == StatusCancelled
+	j.cancel()
+	show := j.show()
+	m.mu.Unlock()
+
+	// A queued job never runs, what it would have read is freed now
+	if queued {
+		j.done()
+	}
+
+	m.save(ctx, j)
+	return show, nil
+}
+
+// Shutdown stops accepting jobs and drains the queue. Jobs still unfinished
+// when ctx is done are cancelled and marked as interrupted.
+func (m *manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		if m.queue != nil {
+			close(m.queue)
+		}
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	var interrupted []*job
+	m.mu.Lock()
+	for _, j := range m.jobs {
+		switch j.status {
+		case StatusQueued:
+			j.status = StatusInterrupted
+			j.finishedAt = m.generate.Time()
+			interrupted = append(interrupted, j)
+		case StatusRunning:
+			j.stopStatus = StatusInterrupted
+		default:
+			continue
+		}
+
+		log.Printf("recon job is interrupted by shutdown: %v", j.id)
+		j.cancel()
+	}
+	m.mu.Unlock()
+
+	for _, j := range interrupted {
+		j.done()
+		m.save(context.Background(), j)
+	}
+
+	// Running tasks watch their context, wait for them to store the interruption
+	<-done
+	return ctx.Err()
+}
+
+func (m *manager) work() {
+	defer m.wg.Done()
+	for j := range m.queue {
+		m.run(j)
+	}
+}
+
+func (m *manager) run(j *job) {
+	m.mu.Lock()
+	if j.status != StatusQueued {
+		// Cancelled or interrupted while it was waiting in the queue
+		m.mu.Unlock()
+		j.done()
+		return
+	}
+
+	j.status = StatusRunning
+	j.startedAt = m.generate.Time()
+	m.mu.Unlock()
+	m.save(context.Background(), j)
+
+	result, err := j.task(j.ctx, &j.progress)
+	j.done()
+
+	m.mu.Lock()
+	j.finishedAt = m.generate.Time()
+	switch {
+	case err == nil:
+		j.status = StatusSucceeded
+		j.result = &result
+	case j.ctx.Err() != nil:
+		j.status = j.stopStatus
+	default:
+		j.status = StatusFailed
+		j.err = err.Error()
+		// Invalid or duplicate rows fail the job, the result tells which rows to fix
+		if result.Validation != nil || len(result.Duplicates) > 0 {
+			j.result = &result
+		}
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		log.Printf("recon job %v stopped: %v", j.id, err)
+	}
+
+	j.cancel()
+	m.save(context.Background(), j)
+}
+
+// evict forgets finished jobs after the retention, they stay in the store.
+func (m *manager) evict(now time.Time) {
+	retentionMinutes := m.cfg.GetInt("job.retention.minutes")
+	if retentionMinutes < 1 {
+		retentionMinutes = defaultRetentionMinutes
+	}
+
+	deadline := now.Add(-time.Duration(retentionMinutes) * time.Minute)
+	for id, j := range m.jobs {
+		if j.status.finished() && j.finishedAt.Before(deadline) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// save stores the current state of the job, a failing store only loses the
+// state for other processes so the job itself goes on.
+func (m *manager) save(ctx context.Context, j *job) {
+	if m.repository == nil {
+		return
+	}
+
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	m.mu.Lock()
+	stored := j.toStoredJob()
+	m.mu.Unlock()
+
+	if err := m.repository.SaveJob(context.WithoutCancel(ctx), stored); err != nil {
+		log.Printf("error saving recon job %v: %v", j.id, err)
+	}
+}
+
+// done calls the release of the job, once.
+func (j *job) done() {
+	j.releaseOnce.Do(func() {
+		if j.release != nil {
+			j.release()
+		}
+	})
+}
+
+func (j *job) show() ShowJob {
+	show := ShowJob{
+		JobID:     j.id,
+		Status:    j.status,
+		Progress:  j.progress.show(),
+		Error:     j.err,
+		CreatedAt: j.createdAt,
+		Result:    j.result,
+	}
+
+	if !j.startedAt.IsZero() {
+		startedAt := j.startedAt
+		show.StartedAt = &startedAt
+	}
+
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		show.FinishedAt = &finishedAt
+	}
+
+	if j.result != nil {
+		show.RunID = j.result.RunID
+	}
+
+	return show
+}
+
+func (j *job) toStoredJob() *reconjob.Job {
+	show := j.show()
+	stored := &reconjob.Job{
+		ID:         show.JobID,
+		Status:     string(show.Status),
+		RowsParsed: show.Progress.RowsParsed,
+		BanksTotal: show.Progress.BanksTotal,
+		BanksDone:  show.Progress.BanksDone,
+		RunID:      show.RunID,
+		Error:      show.Error,
+		CreatedAt:  show.CreatedAt,
+	}
+
+	if show.StartedAt != nil {
+		stored.StartedAt = sql.NullTime{Time: *show.StartedAt, Valid: true}
+	}
+
+	if show.FinishedAt != nil {
+		stored.FinishedAt = sql.NullTime{Time: *show.FinishedAt, Valid: true}
+	}
+
+	return stored
+}
+
+func fromStoredJob(stored *reconjob.Job) ShowJob {
+	show := ShowJob{
+		JobID:  stored.ID,
+		Status: Status(stored.Status),
+		Progress: ShowProgress{
+			RowsParsed: stored.RowsParsed,
+			BanksTotal: stored.BanksTotal,
+			BanksDone:  stored.BanksDone,
+		},
+		RunID:     stored.RunID,
+		Error:     stored.Error,
+		CreatedAt: stored.CreatedAt,
+	}
+
+	if stored.StartedAt.Valid {
+		show.StartedAt = &stored.StartedAt.Time
+	}
+
+	if stored.FinishedAt.Valid {
+		show.FinishedAt = &stored.FinishedAt.Time
+	}
+
+	return show
+}
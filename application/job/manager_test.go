@@ -0,0 +1,362 @@
+package job_test
+
+import (
+	"amartha-recon-service/application/job"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
+	"amartha-recon-service/infrastructure/repository/reconjob"
+	"amartha-recon-service/mocks"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newConfiguration(t *testing.T, workers, queueSize int64) *mocks.Configuration {
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetInt", "job.workers").Return(workers).Maybe()
+	cfg.On("GetInt", "job.queue.size").Return(queueSize).Maybe()
+	cfg.On("GetInt", "job.retention.minutes").Return(int64(60)).Maybe()
+	return cfg
+}
+
+// waitFor polls the job until it reaches the status or the test times out.
+func waitFor(t *testing.T, manager job.Manager, id string, status job.Status) job.ShowJob {
+	var show job.ShowJob
+	assert.Eventually(t, func() bool {
+		var err error
+		show, err = manager.Find(context.Background(), id)
+		return err == nil && show.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+	return show
+}
+
+// blockingTask runs until it is released or its context is done.
+func blockingTask(started chan<- struct{}, release <-chan struct{}) job.Task {
+	return func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return recon.ShowResultReconciliation{}, nil
+		case <-ctx.Done():
+			return recon.ShowResultReconciliation{}, ctx.Err()
+		}
+	}
+}
+
+func TestManager_Submit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success with progress and result", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		defer manager.Shutdown(ctx)
+
+		submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+			progress.AddRowsParsed(10)
+			progress.SetBanksTotal(2)
+			progress.AddBanksDone(2)
+			return recon.ShowResultReconciliation{RunID: "run-1"}, nil
+		}, nil)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, submitted.JobID)
+
+		show := waitFor(t, manager, submitted.JobID, job.StatusSucceeded)
+		assert.Equal(t, job.ShowProgress{RowsParsed: 10, BanksTotal: 2, BanksDone: 2}, show.Progress)
+		assert.Equal(t, "run-1", show.RunID)
+		assert.NotNil(t, show.Result)
+		assert.NotNil(t, show.StartedAt)
+		assert.NotNil(t, show.FinishedAt)
+	})
+
+	t.Run("failed task", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		defer manager.Shutdown(ctx)
+
+		submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+			return recon.ShowResultReconciliation{}, errors.New("parse error")
+		}, nil)
+		assert.NoError(t, err)
+
+		show := waitFor(t, manager, submitted.JobID, job.StatusFailed)
+		assert.Equal(t, "parse error", show.Error)
+		assert.Nil(t, show.Result)
+	})
+
+	t.Run("failed task keeps validation report", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		defer manager.Shutdown(ctx)
+
+		submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+			return recon.ShowResultReconciliation{
+				Validation: &recon.ValidationReport{TotalNumberOfRejectedRows: 1},
+			}, recon.ErrorInvalidRows
+		}, nil)
+		assert.NoError(t, err)
+
+		show := waitFor(t, manager, submitted.JobID, job.StatusFailed)
+		assert.Equal(t, recon.ErrorInvalidRows.Error(), show.Error)
+		assert.Equal(t, 1, show.Result.Validation.TotalNumberOfRejectedRows)
+	})
+
+	t.Run("failed task keeps duplicate rows", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		defer manager.Shutdown(ctx)
+
+		submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+			return recon.ShowResultReconciliation{
+				Duplicates: []recon.DuplicateGroup{{Side: recon.ExceptionSideBank, ID: "TX1"}},
+			}, recon.ErrorDuplicateRows
+		}, nil)
+		assert.NoError(t, err)
+
+		show := waitFor(t, manager, submitted.JobID, job.StatusFailed)
+		assert.Equal(t, recon.ErrorDuplicateRows.Error(), show.Error)
+		assert.Equal(t, "TX1", show.Result.Duplicates[0].ID)
+	})
+
+	t.Run("error queue full", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		_, err := manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.NoError(t, err)
+		<-started
+
+		// One job is running and one is waiting, the queue holds a single job
+		_, err = manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.NoError(t, err)
+		_, err = manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.ErrorIs(t, err, job.ErrorQueueFull)
+
+		close(release)
+		assert.NoError(t, manager.Shutdown(ctx))
+	})
+
+	t.Run("error after shutdown", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		assert.NoError(t, manager.Shutdown(ctx))
+
+		_, err := manager.Submit(ctx, blockingTask(make(chan struct{}, 1), nil), nil)
+		assert.ErrorIs(t, err, job.ErrorShutdown)
+	})
+
+	t.Run("stored transitions", func(t *testing.T) {
+		repository := mocks.NewReconJobRepository(t)
+		var statuses []string
+		repository.On("SaveJob", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			statuses = append(statuses, args.Get(1).(*reconjob.Job).Status)
+		}).Return(nil)
+
+		manager := job.NewManager(newConfiguration(t, 1, 1), repository, common.NewGenerate())
+		manager.Start()
+
+		submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+			return recon.ShowResultReconciliation{RunID: "run-1"}, nil
+		}, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, manager.Shutdown(ctx))
+
+		show, err := manager.Find(ctx, submitted.JobID)
+		assert.NoError(t, err)
+		assert.Equal(t, job.StatusSucceeded, show.Status)
+		assert.Equal(t, []string{"QUEUED", "RUNNING", "SUCCEEDED"}, statuses)
+	})
+}
+
+func TestManager_Cancel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cancel running and queued job", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		manager.Start()
+		defer manager.Shutdown(ctx)
+
+		started, release := make(chan struct{}, 2), make(chan struct{})
+		running, err := manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.NoError(t, err)
+		<-started
+		queued, err := manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.NoError(t, err)
+
+		show, err := manager.Cancel(ctx, queued.JobID)
+		assert.NoError(t, err)
+		assert.Equal(t, job.StatusCancelled, show.Status)
+
+		show, err = manager.Cancel(ctx, running.JobID)
+		assert.NoError(t, err)
+		assert.Equal(t, job.StatusRunning, show.Status)
+		waitFor(t, manager, running.JobID, job.StatusCancelled)
+
+		_, err = manager.Cancel(ctx, running.JobID)
+		assert.ErrorIs(t, err, job.ErrorJobFinished)
+	})
+
+	t.Run("release spooled files", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 2), nil, common.NewGenerate())
+		manager.Start()
+
+		// Stands for the uploads the controller spools for the task
+		spool := func() (string, func()) {
+			path := filepath.Join(t.TempDir(), "recon-upload")
+			assert.NoError(t, os.WriteFile(path, []byte("unique_id,amount"), 0o600))
+			return path, func() { assert.NoError(t, os.Remove(path)) }
+		}
+
+		started, release := make(chan struct{}, 2), make(chan struct{})
+		runningPath, runningRelease := spool()
+		running, err := manager.Submit(ctx, blockingTask(started, release), runningRelease)
+		assert.NoError(t, err)
+		<-started
+
+		queuedPath, queuedRelease := spool()
+		queued, err := manager.Submit(ctx, blockingTask(started, release), queuedRelease)
+		assert.NoError(t, err)
+
+		// The queued job never runs, its files are gone once it is cancelled
+		_, err = manager.Cancel(ctx, queued.JobID)
+		assert.NoError(t, err)
+		assert.NoFileExists(t, queuedPath)
+		assert.FileExists(t, runningPath)
+
+		// The cancelled job still holds its place in the queue
+		refusedPath, refusedRelease := spool()
+		_, err = manager.Submit(ctx, blockingTask(started, release), nil)
+		assert.NoError(t, err)
+		_, err = manager.Submit(ctx, blockingTask(started, release), refusedRelease)
+		assert.ErrorIs(t, err, job.ErrorQueueFull)
+		assert.NoFileExists(t, refusedPath)
+
+		close(release)
+		waitFor(t, manager, running.JobID, job.StatusSucceeded)
+		assert.NoFileExists(t, runningPath)
+		// The release of the skipped job is not called a second time
+		assert.NoError(t, manager.Shutdown(ctx))
+	})
+
+	t.Run("error not found", func(t *testing.T) {
+		repository := mocks.NewReconJobRepository(t)
+		repository.On("FindJobByID", ctx, "job-1").Return(nil, reconjob.ErrorJobNotFound)
+
+		manager := job.NewManager(newConfiguration(t, 1, 1), repository, common.NewGenerate())
+		_, err := manager.Cancel(ctx, "job-1")
+		assert.ErrorIs(t, err, job.ErrorJobNotFound)
+	})
+
+	t.Run("error job of a previous process", func(t *testing.T) {
+		repository := mocks.NewReconJobRepository(t)
+		repository.On("FindJobByID", ctx, "job-1").Return(&reconjob.Job{ID: "job-1", Status: "INTERRUPTED"}, nil)
+
+		manager := job.NewManager(newConfiguration(t, 1, 1), repository, common.NewGenerate())
+		_, err := manager.Cancel(ctx, "job-1")
+		assert.ErrorIs(t, err, job.ErrorJobFinished)
+	})
+}
+
+func TestManager_Find(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("from repository", func(t *testing.T) {
+		now := time.Now()
+		repository := mocks.NewReconJobRepository(t)
+		repository.On("FindJobByID", ctx, "job-1").Return(&reconjob.Job{
+			ID:         "job-1",
+			Status:     "SUCCEEDED",
+			RowsParsed: 5,
+			RunID:      "run-1",
+			CreatedAt:  now,
+			FinishedAt: sql.NullTime{Time: now, Valid: true},
+		}, nil)
+
+		manager := job.NewManager(newConfiguration(t, 1, 1), repository, common.NewGenerate())
+		show, err := manager.Find(ctx, "job-1")
+		assert.NoError(t, err)
+		assert.Equal(t, job.StatusSucceeded, show.Status)
+		assert.Equal(t, "run-1", show.RunID)
+		assert.Equal(t, int64(5), show.Progress.RowsParsed)
+		assert.Nil(t, show.StartedAt)
+		assert.NotNil(t, show.FinishedAt)
+	})
+
+	t.Run("error not found without repository", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 1), nil, common.NewGenerate())
+		_, err := manager.Find(ctx, "job-1")
+		assert.ErrorIs(t, err, job.ErrorJobNotFound)
+	})
+
+	t.Run("error repository", func(t *testing.T) {
+		repository := mocks.NewReconJobRepository(t)
+		repository.On("FindJobByID", ctx, "job-1").Return(nil, errors.New("db error"))
+
+		manager := job.NewManager(newConfiguration(t, 1, 1), repository, common.NewGenerate())
+		_, err := manager.Find(ctx, "job-1")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, job.ErrorJobNotFound)
+	})
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drain queued jobs", func(t *testing.T) {
+		manager := job.NewManager(newConfiguration(t, 1, 5), nil, common.NewGenerate())
+		manager.Start()
+
+		var ids []string
+		for range 3 {
+			submitted, err := manager.Submit(ctx, func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+				time.Sleep(5 * time.Millisecond)
+				return recon.ShowResultReconciliation{}, nil
+			}, nil)
+			assert.NoError(t, err)
+			ids = append(ids, submitted.JobID)
+		}
+
+		assert.NoError(t, manager.Shutdown(ctx))
+		for _, id := range ids {
+			show, err := manager.Find(ctx, id)
+			assert.NoError(t, err)
+			assert.Equal(t, job.StatusSucceeded, show.Status)
+		}
+	})
+
+	t.Run("interrupt after deadline", func(t *testing.T) {
+		repository := mocks.NewReconJobRepository(t)
+		repository.On("SaveJob", mock.Anything, mock.Anything).Return(nil)
+
+		manager := job.NewManager(newConfiguration(t, 1, 5), repository, common.NewGenerate())
+		manager.Start()
+
+		started := make(chan struct{}, 2)
+		running, err := manager.Submit(ctx, blockingTask(started, nil), nil)
+		assert.NoError(t, err)
+		<-started
+		queued, err := manager.Submit(ctx, blockingTask(started, nil), nil)
+		assert.NoError(t, err)
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, manager.Shutdown(shutdownCtx), context.DeadlineExceeded)
+
+		for _, id := range []string{running.JobID, queued.JobID} {
+			show, err := manager.Find(ctx, id)
+			assert.NoError(t, err)
+			assert.Equal(t, job.StatusInterrupted, show.Status)
+		}
+
+		repository.AssertCalled(t, "SaveJob", mock.Anything, mock.MatchedBy(func(stored *reconjob.Job) bool {
+			return stored.ID == running.JobID && stored.Status == "INTERRUPTED" && stored.FinishedAt.Valid
+		}))
+	})
+}
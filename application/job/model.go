@@ -0,0 +1,74 @@
+package job
+
+import (
+	"amartha-recon-service/application/recon"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusQueued      Status = "QUEUED"
+	StatusRunning     Status = "RUNNING"
+	StatusSucceeded   Status = "SUCCEEDED"
+	StatusFailed      Status = "FAILED"
+	StatusCancelled   Status = "CANCELLED"
+	StatusInterrupted Status = "INTERRUPTED"
+)
+
+type (
+	Status string
+
+	// Task is the work of one job, it should stop once ctx is done.
+	Task func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error)
+
+	// Progress counts how far a job is, it is updated by the task while the job runs.
+	Progress struct {
+		rowsParsed atomic.Int64
+		banksTotal atomic.Int64
+		banksDone  atomic.Int64
+	}
+
+	ShowProgress struct {
+		RowsParsed int64 `json:"rows_parsed"`
+		BanksTotal int   `json:"banks_total"`
+		BanksDone  int   `json:"banks_done"`
+	}
+
+	ShowJob struct {
+		JobID      string                          `json:"job_id"`
+		Status     Status                          `json:"status"`
+		Progress   ShowProgress                    `json:"progress"`
+		RunID      string                          `json:"run_id,omitempty"`
+		Error      string                          `json:"error,omitempty"`
+		CreatedAt  time.Time                       `json:"created_at"`
+		StartedAt  *time.Time                      `json:"started_at,omitempty"`
+		FinishedAt *time.Time                      `json:"finished_at,omitempty"`
+		Result     *recon.ShowResultReconciliation `json:"result,omitempty"`
+	}
+)
+
+func (p *Progress) AddRowsParsed(rows int) {
+	p.rowsParsed.Add(int64(rows))
+}
+
+func (p *Progress) SetBanksTotal(banks int) {
+	p.banksTotal.Store(int64(banks))
+}
+
+func (p *Progress) AddBanksDone(banks int) {
+	p.banksDone.Add(int64(banks))
+}
+
+func (p *Progress) show() ShowProgress {
+	return ShowProgress{
+		RowsParsed: p.rowsParsed.Load(),
+		BanksTotal: int(p.banksTotal.Load()),
+		BanksDone:  int(p.banksDone.Load()),
+	}
+}
+
+// finished tells whether the job will not change anymore.
+func (s Status) finished() bool {
+	return s != StatusQueued && s != StatusRunning
+}
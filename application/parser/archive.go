@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"amartha-recon-service/configuration"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	CompressionGzip Compression = "GZIP"
+	CompressionZip  Compression = "ZIP"
+
+	defaultMaxUncompressedMB = 2048
+	defaultMaxMembers        = 100
+)
+
+var (
+	ErrorUploadTooLarge = errors.New("ukuran file setelah diekstrak melebihi batas")
+	ErrorTooManyMembers = errors.New("jumlah file di dalam arsip melebihi batas")
+	ErrorEmptyArchive   = errors.New("arsip tidak berisi file")
+
+	gzipContentTypes = []string{"application/gzip", "application/x-gzip"}
+	zipContentTypes  = []string{"application/zip", "application/x-zip-compressed"}
+)
+
+type (
+	// Compression is how an upload is packed, empty when it is not.
+	Compression string
+
+	// UploadLimits guard the expansion of an upload against archives which
+	// are much bigger once uncompressed than uploaded.
+	UploadLimits struct {
+		// MaxUncompressedBytes bounds all members of an upload together
+		MaxUncompressedBytes int64
+		MaxMembers           int
+	}
+
+	// limitedReader fails once more than remaining bytes are read, unlike
+	// io.LimitReader which ends the file silently.
+	limitedReader struct {
+		reader    io.Reader
+		remaining *int64
+	}
+)
+
+// NewUploadLimits reads upload.max.uncompressed.mb and upload.max.members.
+func NewUploadLimits(cfg configuration.Configuration) UploadLimits {
+	maxUncompressedMB := cfg.GetInt("upload.max.uncompressed.mb")
+	if maxUncompressedMB < 1 {
+		maxUncompressedMB = defaultMaxUncompressedMB
+	}
+
+	maxMembers := int(cfg.GetInt("upload.max.members"))
+	if maxMembers < 1 {
+		maxMembers = defaultMaxMembers
+	}
+
+	return UploadLimits{MaxUncompressedBytes: maxUncompressedMB << 20, MaxMembers: maxMembers}
+}
+
+// DetectCompression tells how an upload is packed by its file name or
+// content type. An XLSX is a zip too, but it is read as a whole.
+func DetectCompression(fileName, contentType string) Compression {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zip":
+		return CompressionZip
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case slices.Contains(gzipContentTypes, mediaType):
+		return CompressionGzip
+	case slices.Contains(zipContentTypes, mediaType):
+		return CompressionZip
+	default:
+		return ""
+	}
+}
+
+// ExpandUpload hands every file of an upload to parse with its name. A plain
+// upload is a single file, a gzip is the file it packs and a zip is each of
+// its members ordered by name.
+func ExpandUpload(
+	r io.Reader,
+	fileName string,
+	compression Compression,
+	limits UploadLimits,
+	parse func(name string, r io.Reader) error) error {
+	remaining := limits.MaxUncompressedBytes
+	switch compression {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		return parse(name, &limitedReader{reader: reader, remaining: &remaining})
+	case CompressionZip:
+		return expandZip(r, limits, &remaining, parse)
+	default:
+		return parse(fileName, r)
+	}
+}
+
+// expandZip keeps the zip in a temporary file, its members are listed at
+// the end of the archive.
+func expandZip(r io.Reader, limits UploadLimits, remaining *int64, parse func(name string, r io.Reader) error) error {
+	spool, err := os.CreateTemp("", "recon-archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(spool, size)
+	if err != nil {
+		return err
+	}
+
+	var members []*zip.File
+	var declared uint64
+	for _, member := range archive.File {
+		if !isDataMember(member) {
+			continue
+		}
+
+		members = append(members, member)
+		declared += member.UncompressedSize64
+	}
+
+	if len(members) == 0 {
+		return ErrorEmptyArchive
+	}
+
+	if len(members) > limits.MaxMembers {
+		return ErrorTooManyMembers
+	}
+
+	// The declared sizes can lie, they only spare reading an archive which
+	// admits it is too large. The bytes read are counted anyway.
+	if declared > uint64(limits.MaxUncompressedBytes) {
+		return ErrorUploadTooLarge
+	}
+
+	slices.SortFunc(members, func(a, b *zip.File) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, member := range members {
+		if err := parseMember(member, remaining, parse); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseMember(member *zip.File, remaining *int64, parse func(name string, r io.Reader) error) error {
+	reader, err := member.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return parse(member.Name, &limitedReader{reader: reader, remaining: remaining})
+}
+
+// isDataMember leaves out folders and the metadata macOS adds to a zip.
+func isDataMember(member *zip.File) bool {
+	if member.FileInfo().IsDir() || strings.HasPrefix(member.Name, "__MACOSX/") {
+		return false
+	}
+
+	return !strings.HasPrefix(path.Base(member.Name), ".")
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if *l.remaining < 0 {
+		return 0, ErrorUploadTooLarge
+	}
+
+	// One byte over the limit tells a file ending right at it from a larger one
+	if int64(len(p)) > *l.remaining+1 {
+		p = p[:*l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+	*l.remaining -= int64(n)
+	if *l.remaining < 0 {
+		return n, ErrorUploadTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,143 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newZip packs the members in the given order.
+func newZip(t *testing.T, members [][2]string) *bytes.Buffer {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, member := range members {
+		w, err := writer.Create(member[0])
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(member[1]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	return &buffer
+}
+
+func newGzip(t *testing.T, content string) *bytes.Buffer {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	return &buffer
+}
+
+// readAll collects the files of an upload by name.
+func readAll(upload io.Reader, fileName string, compression parser.Compression, limits parser.UploadLimits) ([]string, map[string]string, error) {
+	var names []string
+	contents := make(map[string]string)
+	err := parser.ExpandUpload(upload, fileName, compression, limits, func(name string, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, name)
+		contents[name] = string(content)
+		return nil
+	})
+
+	return names, contents, err
+}
+
+func TestExpandUpload(t *testing.T) {
+	limits := parser.UploadLimits{MaxUncompressedBytes: 1 << 20, MaxMembers: 3}
+
+	t.Run("success plain file", func(t *testing.T) {
+		names, contents, err := readAll(strings.NewReader("a,b\n"), "bank.csv", "", limits)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"bank.csv"}, names)
+		assert.Equal(t, "a,b\n", contents["bank.csv"])
+	})
+
+	t.Run("success gzip", func(t *testing.T) {
+		names, contents, err := readAll(newGzip(t, "a,b\n"), "bank.csv.gz", parser.CompressionGzip, limits)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"bank.csv"}, names)
+		assert.Equal(t, "a,b\n", contents["bank.csv"])
+	})
+
+	t.Run("success zip members by name", func(t *testing.T) {
+		archive := newZip(t, [][2]string{
+			{"daily/2026-01-02.csv", "2\n"},
+			{"daily/", ""},
+			{"__MACOSX/daily/._2026-01-01.csv", "metadata"},
+			{"daily/.DS_Store", "metadata"},
+			{"daily/2026-01-01.csv", "1\n"},
+		})
+
+		names, contents, err := readAll(archive, "bank.zip", parser.CompressionZip, limits)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"daily/2026-01-01.csv", "daily/2026-01-02.csv"}, names)
+		assert.Equal(t, "1\n", contents["daily/2026-01-01.csv"])
+	})
+
+	t.Run("error too many members", func(t *testing.T) {
+		archive := newZip(t, [][2]string{{"1.csv", "1"}, {"2.csv", "2"}, {"3.csv", "3"}, {"4.csv", "4"}})
+
+		_, _, err := readAll(archive, "bank.zip", parser.CompressionZip, limits)
+		assert.ErrorIs(t, err, parser.ErrorTooManyMembers)
+	})
+
+	t.Run("error empty zip", func(t *testing.T) {
+		_, _, err := readAll(newZip(t, [][2]string{{"daily/", ""}}), "bank.zip", parser.CompressionZip, limits)
+		assert.ErrorIs(t, err, parser.ErrorEmptyArchive)
+	})
+
+	t.Run("error uncompressed size of all members", func(t *testing.T) {
+		small := parser.UploadLimits{MaxUncompressedBytes: 10, MaxMembers: 3}
+		archive := newZip(t, [][2]string{{"1.csv", "123456"}, {"2.csv", "123456"}})
+
+		_, _, err := readAll(archive, "bank.zip", parser.CompressionZip, small)
+		assert.ErrorIs(t, err, parser.ErrorUploadTooLarge)
+	})
+
+	t.Run("success gzip right at the limit", func(t *testing.T) {
+		exact := parser.UploadLimits{MaxUncompressedBytes: 4, MaxMembers: 1}
+
+		_, contents, err := readAll(newGzip(t, "a,b\n"), "bank.csv.gz", parser.CompressionGzip, exact)
+		assert.NoError(t, err)
+		assert.Equal(t, "a,b\n", contents["bank.csv"])
+	})
+
+	t.Run("error gzip bomb while parsing", func(t *testing.T) {
+		bomb := newGzip(t, "transaction_id,terminal_rrn,amount,type,bank_code,time\n"+strings.Repeat("TX1,RRN1,100,DEBIT,014,2026-01-02 10:00:00\n", 10000))
+		registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+		template, err := registry.Find("", parser.SideSystem)
+		assert.NoError(t, err)
+
+		err = parser.ExpandUpload(bomb, "amartha.csv.gz", parser.CompressionGzip, parser.UploadLimits{MaxUncompressedBytes: 1 << 10, MaxMembers: 1}, func(name string, r io.Reader) error {
+			_, _, err := parser.ParseTransactions(context.Background(), r, name, template, time.Time{}, time.Now(), recon.NoProgress)
+			return err
+		})
+		assert.ErrorIs(t, err, parser.ErrorUploadTooLarge)
+	})
+
+	t.Run("error not a gzip", func(t *testing.T) {
+		_, _, err := readAll(strings.NewReader("a,b\n"), "bank.csv.gz", parser.CompressionGzip, limits)
+		assert.Error(t, err)
+	})
+}
+
+func TestDetectCompression(t *testing.T) {
+	assert.Equal(t, parser.CompressionGzip, parser.DetectCompression("bank.csv.GZ", ""))
+	assert.Equal(t, parser.CompressionZip, parser.DetectCompression("bank.zip", "application/octet-stream"))
+	assert.Equal(t, parser.CompressionZip, parser.DetectCompression("bank", "application/x-zip-compressed"))
+	assert.Empty(t, parser.DetectCompression("bank.xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"))
+}
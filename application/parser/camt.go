@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"amartha-recon-service/application/recon"
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	camtEntryElement = "Ntry"
+	camtBooked       = "BOOK"
+	camtCredit       = "CRDT"
+	camtDebit        = "DBIT"
+	// camtNotProvided is what banks send when there is no end to end id
+	camtNotProvided = "NOTPROVIDED"
+)
+
+var (
+	camtDateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+)
+
+type (
+	camtAmount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	}
+
+	camtDate struct {
+		Date     string `xml:"Dt"`
+		DateTime string `xml:"DtTm"`
+	}
+
+	// camtStatus is a code up to camt.053.001.07 and a Cd element after it.
+	camtStatus struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	}
+
+	camtReferences struct {
+		AccountServicerReference string `xml:"AcctSvcrRef"`
+		EndToEndID               string `xml:"EndToEndId"`
+		TransactionID            string `xml:"TxId"`
+	}
+
+	camtTransaction struct {
+		References        camtReferences `xml:"Refs"`
+		Amount            camtAmount     `xml:"Amt"`
+		TransactionAmount camtAmount     `xml:"AmtDtls>TxAmt>Amt"`
+		CreditDebit       string         `xml:"CdtDbtInd"`
+	}
+
+	// camtRow collects the errors of the bank statements of one entry.
+	camtRow struct {
+		file   string
+		line   int
+		errors []recon.RowError
+	}
+
+	camtEntry struct {
+		EntryReference           string            `xml:"NtryRef"`
+		Amount                   camtAmount        `xml:"Amt"`
+		CreditDebit              string            `xml:"CdtDbtInd"`
+		Status                   camtStatus        `xml:"Sts"`
+		BookingDate              camtDate          `xml:"BookgDt"`
+		ValueDate                camtDate          `xml:"ValDt"`
+		AccountServicerReference string            `xml:"AcctSvcrRef"`
+		Transactions             []camtTransaction `xml:"NtryDtls>TxDtls"`
+	}
+)
+
+// ParseCAMT reads the booked entries of an ISO 20022 camt.053 statement or
+// camt.054 notification within startDate..endDate, one entry at a time so
+// the file is never held in memory. A batch booking with the amount of each
+// of its transactions is split into one bank statement per transaction. The
+// reference is the end to end id, else the reference of the bank. Like
+// MT940 the bank code comes from the template.
+func ParseCAMT(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.BankStatementUploadFile, []recon.RowError, error) {
+	var bankStatements []recon.BankStatementUploadFile
+	rowErrors, err := streamCAMT(ctx, r, file, template, startDate, endDate, progress, collect(&bankStatements))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bankStatements, rowErrors, nil
+}
+
+func streamCAMT(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.BankStatementUploadFile) error) ([]recon.RowError, error) {
+	var rowErrors []recon.RowError
+
+	decoder := xml.NewDecoder(r)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != camtEntryElement {
+			continue
+		}
+
+		line, _ := decoder.InputPos()
+		var entry camtEntry
+		if err := decoder.DecodeElement(&entry, &start); err != nil {
+			return nil, err
+		}
+
+		// Pending and information only entries are not on the account yet
+		if status := entry.Status.status(); status != "" && status != camtBooked {
+			continue
+		}
+
+		for _, record := range entry.records() {
+			progress.AddRowsParsed(1)
+			row := &camtRow{file: file, line: line}
+			bankStatement := row.bankStatement(entry, record, template)
+			rowErrors = append(rowErrors, row.errors...)
+			if len(row.errors) == 0 && inDateRange(bankStatement.Date, startDate, endDate) {
+				if err := emit(bankStatement); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return rowErrors, nil
+}
+
+func (s camtStatus) status() string {
+	if s.Code != "" {
+		return strings.TrimSpace(s.Code)
+	}
+
+	return strings.TrimSpace(s.Value)
+}
+
+func (t camtTransaction) amount() camtAmount {
+	if strings.TrimSpace(t.Amount.Value) != "" {
+		return t.Amount
+	}
+
+	return t.TransactionAmount
+}
+
+// records is what the entry turns into: every transaction of a batch when
+// each of them has its amount, else the entry itself with the references of
+// its only transaction, if any.
+func (e camtEntry) records() []camtTransaction {
+	if len(e.Transactions) > 1 {
+		split := true
+		for _, transaction := range e.Transactions {
+			split = split && strings.TrimSpace(transaction.amount().Value) != ""
+		}
+
+		if split {
+			return e.Transactions
+		}
+	}
+
+	record := camtTransaction{Amount: e.Amount, CreditDebit: e.CreditDebit}
+	if len(e.Transactions) == 1 {
+		record.References = e.Transactions[0].References
+	}
+
+	return []camtTransaction{record}
+}
+
+func (r *camtRow) reject(column, value string, reason recon.RowErrorReason) {
+	r.errors = append(r.errors, recon.RowError{File: r.file, Line: r.line, Column: column, Value: value, Reason: reason})
+}
+
+func (r *camtRow) bankStatement(entry camtEntry, record camtTransaction, template Template) recon.BankStatementUploadFile {
+	bankStatement := recon.BankStatementUploadFile{
+		EndToEndID:               strings.TrimSpace(record.References.EndToEndID),
+		AccountServicerReference: strings.TrimSpace(record.References.AccountServicerReference),
+		BankCode:                 template.BankCode,
+	}
+
+	if bankStatement.EndToEndID == camtNotProvided {
+		bankStatement.EndToEndID = ""
+	}
+
+	if bankStatement.AccountServicerReference == "" {
+		bankStatement.AccountServicerReference = strings.TrimSpace(entry.AccountServicerReference)
+	}
+
+	for _, reference := range []string{
+		bankStatement.EndToEndID,
+		bankStatement.AccountServicerReference,
+		strings.TrimSpace(record.References.TransactionID),
+		strings.TrimSpace(entry.EntryReference),
+	} {
+		if reference != "" {
+			bankStatement.UniqueID = reference
+			break
+		}
+	}
+
+	if bankStatement.UniqueID == "" {
+		r.reject("Refs", "", recon.RowErrorMissingValue)
+	}
+
+	amount := record.amount()
+	bankStatement.Currency = amount.Currency
+	if value := strings.TrimSpace(amount.Value); value == "" {
+		r.reject("Amt", value, recon.RowErrorMissingValue)
+	} else if parsed, err := decimal.NewFromString(value); err != nil {
+		r.reject("Amt", value, recon.RowErrorInvalidAmount)
+	} else {
+		bankStatement.Amount = parsed
+	}
+
+	// A transaction of a batch may go the other way than the batch
+	creditDebit := strings.TrimSpace(record.CreditDebit)
+	if creditDebit == "" {
+		creditDebit = strings.TrimSpace(entry.CreditDebit)
+	}
+
+	switch creditDebit {
+	case camtCredit:
+		bankStatement.TransactionType = recon.TransactionTypeCredit
+	case camtDebit:
+		bankStatement.TransactionType = recon.TransactionTypeDebit
+	case "":
+		r.reject("CdtDbtInd", creditDebit, recon.RowErrorMissingValue)
+	default:
+		r.reject("CdtDbtInd", creditDebit, recon.RowErrorMalformedRow)
+	}
+
+	bankStatement.BookingDate = r.date("BookgDt", entry.BookingDate)
+	bankStatement.Date = r.date("ValDt", entry.ValueDate)
+	if bankStatement.Date.IsZero() {
+		bankStatement.Date = bankStatement.BookingDate
+	}
+
+	if entry.ValueDate.empty() && entry.BookingDate.empty() {
+		r.reject("ValDt", "", recon.RowErrorMissingValue)
+	}
+
+	if bankStatement.BankCode == "" {
+		r.reject(string(ColumnBankCode), "", recon.RowErrorMissingValue)
+	}
+
+	return bankStatement
+}
+
+func (d camtDate) empty() bool {
+	return strings.TrimSpace(d.Date) == "" && strings.TrimSpace(d.DateTime) == ""
+}
+
+// date reads a Dt or DtTm element, zero when there is none.
+func (r *camtRow) date(column string, date camtDate) time.Time {
+	if value := strings.TrimSpace(date.Date); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			r.reject(column, value, recon.RowErrorInvalidDate)
+		}
+		return parsed
+	}
+
+	value := strings.TrimSpace(date.DateTime)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range camtDateTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+
+	r.reject(column, value, recon.RowErrorInvalidDate)
+	return time.Time{}
+}
@@ -0,0 +1,117 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT1</Id>
+      <Ntry>
+        <NtryRef>N1</NtryRef>
+        <Amt Ccy="IDR">100.50</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2026-01-02</Dt></BookgDt>
+        <ValDt><Dt>2026-01-03</Dt></ValDt>
+        <AcctSvcrRef>BANKREF1</AcctSvcrRef>
+        <NtryDtls><TxDtls><Refs><EndToEndId>TX1</EndToEndId></Refs></TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="IDR">300</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2026-01-02T10:00:00+07:00</DtTm></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><AcctSvcrRef>B2</AcctSvcrRef><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+            <AmtDtls><TxAmt><Amt Ccy="IDR">100</Amt></TxAmt></AmtDtls>
+          </TxDtls>
+          <TxDtls>
+            <Refs><EndToEndId>TX3</EndToEndId></Refs>
+            <Amt Ccy="IDR">200</Amt>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="IDR">999</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>PDNG</Cd></Sts>
+        <BookgDt><Dt>2026-01-02</Dt></BookgDt>
+        <AcctSvcrRef>PENDING</AcctSvcrRef>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCAMT(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	template := parser.Template{Name: "mandiri", Side: parser.SideBank, Format: parser.FormatCAMT053, BankCode: "008"}
+
+	t.Run("success with camt.053", func(t *testing.T) {
+		banks, rowErrors, err := parser.ParseCAMT(ctx, strings.NewReader(camt053), "mandiri.xml", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+
+		bookedAt := time.Date(2026, 1, 2, 10, 0, 0, 0, time.FixedZone("", 7*60*60))
+		assert.Len(t, banks, 3)
+		assert.Equal(t, recon.BankStatementUploadFile{
+			UniqueID: "TX1", EndToEndID: "TX1", AccountServicerReference: "BANKREF1", Currency: "IDR",
+			Amount: decimal.RequireFromString("100.50"), TransactionType: recon.TransactionTypeCredit,
+			Date: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), BookingDate: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "008",
+		}, banks[0])
+		assert.Equal(t, "B2", banks[1].UniqueID)
+		assert.Empty(t, banks[1].EndToEndID)
+		assert.True(t, decimal.NewFromInt(100).Equal(banks[1].Amount))
+		assert.Equal(t, recon.TransactionTypeDebit, banks[1].TransactionType)
+		assert.True(t, bookedAt.Equal(banks[1].Date))
+		assert.Equal(t, "TX3", banks[2].UniqueID)
+		assert.True(t, decimal.NewFromInt(200).Equal(banks[2].Amount))
+	})
+
+	t.Run("success with camt.054 and row errors", func(t *testing.T) {
+		file := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.02"><BkToCstmrDbtCdtNtfctn><Ntfctn>
+<Ntry><Amt Ccy="IDR">50</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2026-01-02</Dt></BookgDt><AcctSvcrRef>R1</AcctSvcrRef></Ntry>
+<Ntry><Amt Ccy="IDR">abc</Amt><CdtDbtInd>XX</CdtDbtInd><BookgDt><Dt>02-01-2026</Dt></BookgDt></Ntry>
+<Ntry><Amt Ccy="IDR"></Amt><AcctSvcrRef>R3</AcctSvcrRef></Ntry>
+</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`
+
+		banks, rowErrors, err := parser.ParseCAMT(ctx, strings.NewReader(file), "notification.xml", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, banks, 1)
+		assert.Equal(t, "R1", banks[0].UniqueID)
+		assert.Equal(t, []recon.RowError{
+			{File: "notification.xml", Line: 3, Column: "Refs", Reason: recon.RowErrorMissingValue},
+			{File: "notification.xml", Line: 3, Column: "Amt", Value: "abc", Reason: recon.RowErrorInvalidAmount},
+			{File: "notification.xml", Line: 3, Column: "CdtDbtInd", Value: "XX", Reason: recon.RowErrorMalformedRow},
+			{File: "notification.xml", Line: 3, Column: "BookgDt", Value: "02-01-2026", Reason: recon.RowErrorInvalidDate},
+			{File: "notification.xml", Line: 4, Column: "Amt", Reason: recon.RowErrorMissingValue},
+			{File: "notification.xml", Line: 4, Column: "CdtDbtInd", Reason: recon.RowErrorMissingValue},
+			{File: "notification.xml", Line: 4, Column: "ValDt", Reason: recon.RowErrorMissingValue},
+		}, rowErrors)
+	})
+
+	t.Run("success through bank file format", func(t *testing.T) {
+		banks, _, err := parser.ParseBankFile(ctx, strings.NewReader(camt053), "mandiri.xml", parser.FormatCAMT054, parser.Template{BankCode: "008"}, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, banks, 3)
+	})
+
+	t.Run("error malformed xml", func(t *testing.T) {
+		_, _, err := parser.ParseCAMT(ctx, strings.NewReader("<Document><Ntry><Amt>1</Ntry>"), "broken.xml", template, startDate, endDate, recon.NoProgress)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,477 @@
+package parser
+
+import (
+	"amartha-recon-service/application/recon"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	cellText cellKind = iota
+	// cellNumber is a spreadsheet number, dates are stored as serial numbers
+	cellNumber
+	// cellDate is a spreadsheet date stored in ISO 8601
+	cellDate
+)
+
+type (
+	// cellKind is how a cell is stored, a CSV only carries text.
+	cellKind int
+
+	// rowSource hands the rows of a file one by one.
+	rowSource interface {
+		// read returns the next row and its line, io.EOF after the last row
+		read() ([]string, int, error)
+		// kind tells how a cell of the last row read is stored
+		kind(index int) cellKind
+		// serialDate turns a cellNumber into a date
+		serialDate(value string) (time.Time, error)
+	}
+
+	csvSource struct {
+		reader *csv.Reader
+	}
+
+	// rowParser reads the columns of one row laid out by a template, every
+	// field which can not be read is kept as a row error.
+	rowParser struct {
+		template  Template
+		source    rowSource
+		positions map[Column]int
+		file      string
+		line      int
+		row       []string
+		errors    []recon.RowError
+	}
+)
+
+// ParseTransactions reads the valid system transactions within
+// startDate..endDate, and the errors of the rows which are not valid.
+func ParseTransactions(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.TransactionUploadFile, []recon.RowError, error) {
+	var transactions []recon.TransactionUploadFile
+	rowErrors, err := streamTransactions(ctx, newCSVSource(r, template), file, template, startDate, endDate, progress, collect(&transactions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return transactions, rowErrors, nil
+}
+
+func streamTransactions(
+	ctx context.Context,
+	source rowSource,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.TransactionUploadFile) error) ([]recon.RowError, error) {
+	return readRows(ctx, source, file, template, progress, func(row *rowParser) error {
+		parseRow := recon.TransactionUploadFile{
+			TransactionID:   row.required(ColumnTransactionID),
+			TerminalRRN:     row.text(ColumnTerminalRRN),
+			Amount:          row.amount(ColumnAmount),
+			TransactionType: row.text(ColumnTransactionType),
+			BankCode:        row.bankCode(),
+			TransactionTime: row.time(ColumnTransactionTime),
+		}
+
+		if len(row.errors) == 0 && inDateRange(parseRow.TransactionTime, startDate, endDate) {
+			return emit(parseRow)
+		}
+
+		return nil
+	})
+}
+
+// ParseBankStatements reads the valid bank statements within
+// startDate..endDate, and the errors of the rows which are not valid.
+func ParseBankStatements(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.BankStatementUploadFile, []recon.RowError, error) {
+	var bankStatements []recon.BankStatementUploadFile
+	rowErrors, err := streamBankStatements(ctx, newCSVSource(r, template), file, template, startDate, endDate, progress, collect(&bankStatements))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bankStatements, rowErrors, nil
+}
+
+func streamBankStatements(
+	ctx context.Context,
+	source rowSource,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.BankStatementUploadFile) error) ([]recon.RowError, error) {
+	return readRows(ctx, source, file, template, progress, func(row *rowParser) error {
+		parseRow := recon.BankStatementUploadFile{
+			UniqueID: row.required(ColumnUniqueID),
+			Amount:   row.amount(ColumnAmount),
+			Date:     row.time(ColumnDate),
+			BankCode: row.bankCode(),
+			// Optional D/C column, used by banks with the DC_COLUMN sign convention
+			TransactionType: row.text(ColumnTransactionType),
+		}
+
+		if len(row.errors) == 0 && inDateRange(parseRow.Date, startDate, endDate) {
+			return emit(parseRow)
+		}
+
+		return nil
+	})
+}
+
+// ParseSystemFile reads a system file in format, or in the format of the
+// template when format is empty.
+func ParseSystemFile(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	format Format,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.TransactionUploadFile, []recon.RowError, error) {
+	var transactions []recon.TransactionUploadFile
+	rowErrors, err := StreamSystemFile(ctx, r, file, format, template, startDate, endDate, progress, collect(&transactions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return transactions, rowErrors, nil
+}
+
+// StreamSystemFile is ParseSystemFile handing every valid row to emit as it
+// is read, instead of keeping the rows. An error of emit stops the file.
+func StreamSystemFile(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	format Format,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.TransactionUploadFile) error) ([]recon.RowError, error) {
+	if format == "" {
+		format = template.Format
+	}
+
+	switch format {
+	case FormatXLSX:
+		source, err := openSheet(r, template.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		defer source.Close()
+
+		return streamTransactions(ctx, source, file, template, startDate, endDate, progress, emit)
+	case FormatCSV, "":
+		return streamTransactions(ctx, newCSVSource(r, template), file, template, startDate, endDate, progress, emit)
+	default:
+		return nil, ErrorInvalidFormat
+	}
+}
+
+// ParseBankFile reads a bank file in format, or in the format of the
+// template when format is empty.
+func ParseBankFile(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	format Format,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.BankStatementUploadFile, []recon.RowError, error) {
+	var bankStatements []recon.BankStatementUploadFile
+	rowErrors, err := StreamBankFile(ctx, r, file, format, template, startDate, endDate, progress, collect(&bankStatements))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bankStatements, rowErrors, nil
+}
+
+// StreamBankFile is ParseBankFile handing every valid row to emit.
+func StreamBankFile(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	format Format,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.BankStatementUploadFile) error) ([]recon.RowError, error) {
+	if format == "" {
+		format = template.Format
+	}
+
+	switch format {
+	case FormatMT940:
+		return streamMT940(ctx, r, file, template, startDate, endDate, progress, emit)
+	case FormatCAMT053, FormatCAMT054:
+		return streamCAMT(ctx, r, file, template, startDate, endDate, progress, emit)
+	case FormatXLSX:
+		source, err := openSheet(r, template.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		defer source.Close()
+
+		return streamBankStatements(ctx, source, file, template, startDate, endDate, progress, emit)
+	case FormatCSV, "":
+		return streamBankStatements(ctx, newCSVSource(r, template), file, template, startDate, endDate, progress, emit)
+	default:
+		return nil, ErrorInvalidFormat
+	}
+}
+
+// inDateRange tells whether t falls on a day from startDate to endDate. The
+// range ends with the day after endDate, so a row dated at any time on
+// endDate is kept, like the date(transaction_time) of the transactions table.
+func inDateRange(t, startDate, endDate time.Time) bool {
+	dayAfter := time.Date(endDate.Year(), endDate.Month(), endDate.Day()+1, 0, 0, 0, 0, endDate.Location())
+	return !t.Before(startDate) && t.Before(dayAfter)
+}
+
+// collect is an emit keeping the rows in memory.
+func collect[T any](rows *[]T) func(T) error {
+	return func(row T) error {
+		*rows = append(*rows, row)
+		return nil
+	}
+}
+
+func newCSVSource(r io.Reader, template Template) *csvSource {
+	reader := csv.NewReader(r)
+	reader.Comma = template.Delimiter
+	// Preamble rows of a bank rarely have as many fields as the data
+	reader.FieldsPerRecord = -1
+
+	return &csvSource{reader: reader}
+}
+
+func (s *csvSource) read() ([]string, int, error) {
+	row, err := s.reader.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	return row, line, nil
+}
+
+func (s *csvSource) kind(int) cellKind {
+	return cellText
+}
+
+func (s *csvSource) serialDate(value string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("csv has no serial date %q", value)
+}
+
+// readRows skips the rows before the data, resolves the columns of the
+// template on the header row and hands every data row to parse. A row the
+// CSV reader can not read is a row error, the file itself is still read.
+func readRows(
+	ctx context.Context,
+	source rowSource,
+	file string,
+	template Template,
+	progress recon.Progress,
+	parse func(row *rowParser) error) ([]recon.RowError, error) {
+	var header []string
+	for range template.SkipRows {
+		row, _, err := source.read()
+		if err == io.EOF {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		header = row
+	}
+
+	positions, err := template.resolve(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rowErrors []recon.RowError
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		row, line, err := source.read()
+		if err == io.EOF {
+			return rowErrors, nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			progress.AddRowsParsed(1)
+			rowErrors = append(rowErrors, recon.RowError{
+				File:   file,
+				Line:   parseErr.StartLine,
+				Value:  parseErr.Err.Error(),
+				Reason: recon.RowErrorMalformedRow,
+			})
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		progress.AddRowsParsed(1)
+		rowParser := &rowParser{template: template, source: source, positions: positions, file: file, line: line, row: row}
+		if err := parse(rowParser); err != nil {
+			return nil, err
+		}
+		rowErrors = append(rowErrors, rowParser.errors...)
+	}
+}
+
+func (p *rowParser) reject(column Column, value string, reason recon.RowErrorReason) {
+	p.errors = append(p.errors, recon.RowError{
+		File:   p.file,
+		Line:   p.line,
+		Column: string(column),
+		Value:  value,
+		Reason: reason,
+	})
+}
+
+// cell returns the value of column and how it is stored.
+func (p *rowParser) cell(column Column) (string, cellKind) {
+	index, ok := p.positions[column]
+	if !ok || index >= len(p.row) {
+		return "", cellText
+	}
+
+	value := strings.TrimSpace(p.row[index])
+	if value == "" {
+		return "", cellText
+	}
+
+	return value, p.source.kind(index)
+}
+
+func (p *rowParser) text(column Column) string {
+	value, kind := p.cell(column)
+	if kind == cellNumber {
+		// A reference typed into a spreadsheet is kept as a number
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(number, 'f', -1, 64)
+		}
+	}
+
+	return value
+}
+
+func (p *rowParser) required(column Column) string {
+	value := p.text(column)
+	if value == "" {
+		p.reject(column, value, recon.RowErrorMissingValue)
+	}
+
+	return value
+}
+
+func (p *rowParser) bankCode() string {
+	if bankCode := p.text(ColumnBankCode); bankCode != "" {
+		return bankCode
+	}
+
+	if p.template.BankCode != "" {
+		return p.template.BankCode
+	}
+
+	p.reject(ColumnBankCode, "", recon.RowErrorMissingValue)
+	return ""
+}
+
+func (p *rowParser) amount(column Column) decimal.Decimal {
+	value, kind := p.cell(column)
+	if value == "" {
+		p.reject(column, value, recon.RowErrorMissingValue)
+		return decimal.Zero
+	}
+
+	if kind == cellNumber {
+		amount, err := spreadsheetAmount(value)
+		if err != nil {
+			p.reject(column, value, recon.RowErrorInvalidAmount)
+			return decimal.Zero
+		}
+
+		return amount
+	}
+
+	amount, err := decimal.NewFromString(p.template.normalizeAmount(value))
+	if err != nil {
+		p.reject(column, value, recon.RowErrorInvalidAmount)
+		return decimal.Zero
+	}
+
+	return amount
+}
+
+func (p *rowParser) time(column Column) time.Time {
+	value, kind := p.cell(column)
+	if value == "" {
+		p.reject(column, value, recon.RowErrorMissingValue)
+		return time.Time{}
+	}
+
+	var (
+		parsed time.Time
+		err    error
+	)
+	switch kind {
+	case cellNumber:
+		parsed, err = p.source.serialDate(value)
+	case cellDate:
+		parsed, err = time.Parse(spreadsheetDateLayout, value)
+	default:
+		parsed, err = time.Parse(p.template.DateLayout, value)
+	}
+	if err != nil {
+		p.reject(column, value, recon.RowErrorInvalidDate)
+		return time.Time{}
+	}
+
+	return parsed
+}
+
+// normalizeAmount drops the thousand separators and turns the decimal
+// separator of the template into a dot.
+func (t Template) normalizeAmount(value string) string {
+	value = strings.ReplaceAll(value, " ", "")
+	if t.DecimalSeparator == "," {
+		return strings.ReplaceAll(strings.ReplaceAll(value, ".", ""), ",", ".")
+	}
+
+	return strings.ReplaceAll(value, ",", "")
+}
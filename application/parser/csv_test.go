@@ -0,0 +1,148 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransactions(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+	template, err := registry.Find("", parser.SideSystem)
+	assert.NoError(t, err)
+
+	t.Run("success with default template", func(t *testing.T) {
+		file := "transaction_id,terminal_rrn,amount,type,bank_code,time\n" +
+			"TX1,RRN1,100.50,DEBIT,014,2026-01-02 10:00:00\n" +
+			"TX2,RRN2,200,CREDIT,002,2026-02-02 10:00:00\n"
+
+		txs, rowErrors, err := parser.ParseTransactions(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, []recon.TransactionUploadFile{{
+			TransactionID:   "TX1",
+			TerminalRRN:     "RRN1",
+			Amount:          decimal.RequireFromString("100.50"),
+			TransactionType: "DEBIT",
+			BankCode:        "014",
+			TransactionTime: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC),
+		}}, txs)
+	})
+
+	t.Run("success with a time on the end date", func(t *testing.T) {
+		file := "transaction_id,terminal_rrn,amount,type,bank_code,time\n" +
+			"TX1,RRN1,100,DEBIT,014,2026-01-03 23:59:59\n" +
+			"TX2,RRN2,200,DEBIT,014,2026-01-04 00:00:00\n"
+
+		txs, _, err := parser.ParseTransactions(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, txs, 1)
+		assert.Equal(t, "TX1", txs[0].TransactionID)
+	})
+
+	t.Run("success with row errors", func(t *testing.T) {
+		file := "transaction_id,terminal_rrn,amount,type,bank_code,time\n" +
+			"TX1,RRN1,abc,DEBIT,014,2026-01-02 10:00:00\n" +
+			",RRN2,200,CREDIT,,2026-13-02 10:00:00\n" +
+			"TX3,RRN3,300,CREDIT,002,2026-01-02 10:00:00\n" +
+			"TX4,RRN4,400,CREDIT,002\n" +
+			"TX5,RRN5,\"500,CREDIT,002,2026-01-02 10:00:00\n"
+
+		txs, rowErrors, err := parser.ParseTransactions(ctx, strings.NewReader(file), "system.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Equal(t, []recon.RowError{
+			{File: "system.csv", Line: 2, Column: "amount", Value: "abc", Reason: recon.RowErrorInvalidAmount},
+			{File: "system.csv", Line: 3, Column: "transaction_id", Reason: recon.RowErrorMissingValue},
+			{File: "system.csv", Line: 3, Column: "bank_code", Reason: recon.RowErrorMissingValue},
+			{File: "system.csv", Line: 3, Column: "transaction_time", Value: "2026-13-02 10:00:00", Reason: recon.RowErrorInvalidDate},
+			{File: "system.csv", Line: 5, Column: "transaction_time", Reason: recon.RowErrorMissingValue},
+			{File: "system.csv", Line: 6, Value: "extraneous or missing \" in quoted-field", Reason: recon.RowErrorMalformedRow},
+		}, rowErrors)
+		assert.Len(t, txs, 1)
+		assert.Equal(t, "TX3", txs[0].TransactionID)
+	})
+
+	t.Run("success with empty file", func(t *testing.T) {
+		txs, _, err := parser.ParseTransactions(ctx, strings.NewReader(""), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, txs)
+	})
+
+	t.Run("error cancelled context", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, _, err := parser.ParseTransactions(cancelledCtx, strings.NewReader("h\nTX1\n"), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestParseBankStatements(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success with named columns", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+		template, err := registry.Find("bca", parser.SideBank)
+		assert.NoError(t, err)
+
+		file := "Statement BCA;;;\n" +
+			"Reference No;Value Date;D/C;Amount\n" +
+			"REF1;02/01/2026;D;1.000,50\n" +
+			"REF2;03/01/2026;C;25\n"
+
+		banks, _, err := parser.ParseBankStatements(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Equal(t, []recon.BankStatementUploadFile{
+			{UniqueID: "REF1", Amount: decimal.RequireFromString("1000.50"), TransactionType: "D", Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "014"},
+			{UniqueID: "REF2", Amount: decimal.RequireFromString("25"), TransactionType: "C", Date: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), BankCode: "014"},
+		}, banks)
+	})
+
+	t.Run("success with thousand separators on default template", func(t *testing.T) {
+		registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+		template, err := registry.Find("", parser.SideBank)
+		assert.NoError(t, err)
+
+		file := "id,amount,date,bank_code\n\"TX1\",\"1,250.75\",2026-01-02 00:00:00,002\n"
+		banks, _, err := parser.ParseBankStatements(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, banks, 1)
+		assert.True(t, decimal.RequireFromString("1250.75").Equal(banks[0].Amount))
+		assert.Equal(t, "002", banks[0].BankCode)
+	})
+
+	t.Run("success with a time on the end date", func(t *testing.T) {
+		registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+		template, err := registry.Find("", parser.SideBank)
+		assert.NoError(t, err)
+
+		file := "id,amount,date,bank_code\n" +
+			"TX1,100,2026-01-03 18:30:00,014\n" +
+			"TX2,200,2026-01-04 00:00:00,014\n"
+		banks, _, err := parser.ParseBankStatements(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, banks, 1)
+		assert.Equal(t, "TX1", banks[0].UniqueID)
+	})
+
+	t.Run("error column missing on header row", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+		template, err := registry.Find("bca", parser.SideBank)
+		assert.NoError(t, err)
+
+		file := "Statement BCA\nReference No;Amount\nREF1;10\n"
+		_, _, err = parser.ParseBankStatements(ctx, strings.NewReader(file), "file.csv", template, startDate, endDate, recon.NoProgress)
+		assert.ErrorIs(t, err, parser.ErrorInvalidTemplate)
+	})
+}
@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"amartha-recon-service/application/recon"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	mt940TagStatement = "20"
+	mt940TagEntry     = "61"
+	mt940TagInfo      = "86"
+
+	// maxMT940LineSize bounds a single line, SWIFT lines are 65 characters
+	maxMT940LineSize = 64 * 1024
+)
+
+var (
+	mt940TagPattern = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+	// value date, entry date, D/C mark, funds code, amount, type, owner reference, bank reference
+	mt940EntryPattern   = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)([A-Z][A-Z0-9]{3})([^/]*?)(?://(.*))?$`)
+	mt940BalancePattern = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+)
+
+type (
+	mt940Field struct {
+		tag   string
+		line  int
+		value string
+	}
+
+	// mt940Statement keeps the running balance of one statement of the file.
+	mt940Statement struct {
+		opening    decimal.Decimal
+		hasOpening bool
+		movement   decimal.Decimal
+	}
+
+	mt940Entry struct {
+		line      int
+		field     string
+		info      []string
+		statement recon.BankStatementUploadFile
+		errors    []recon.RowError
+	}
+)
+
+// ParseMT940 reads the :61: statement lines of a SWIFT MT940 file within
+// startDate..endDate. The value date and D/C mark come from :61:, the
+// reference from the :86: following it, or from the reference of the
+// account owner on :61: when there is none. A file may carry several
+// statements, each of them is checked to add up from its opening (:60F:,
+// :60M:) to its closing (:62F:, :62M:) balance. MT940 carries no bank code,
+// it comes from the template.
+func ParseMT940(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress) ([]recon.BankStatementUploadFile, []recon.RowError, error) {
+	var bankStatements []recon.BankStatementUploadFile
+	rowErrors, err := streamMT940(ctx, r, file, template, startDate, endDate, progress, collect(&bankStatements))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bankStatements, rowErrors, nil
+}
+
+func streamMT940(
+	ctx context.Context,
+	r io.Reader,
+	file string,
+	template Template,
+	startDate, endDate time.Time,
+	progress recon.Progress,
+	emit func(recon.BankStatementUploadFile) error) ([]recon.RowError, error) {
+	var (
+		rowErrors []recon.RowError
+		statement mt940Statement
+		entry     *mt940Entry
+		// emitErr stops the file once a row could not be handed over
+		emitErr error
+	)
+
+	reject := func(line int, column, value string, reason recon.RowErrorReason) {
+		rowErrors = append(rowErrors, recon.RowError{File: file, Line: line, Column: column, Value: value, Reason: reason})
+	}
+
+	flushEntry := func() {
+		if entry == nil {
+			return
+		}
+
+		progress.AddRowsParsed(1)
+		entry.finish(file, template)
+		rowErrors = append(rowErrors, entry.errors...)
+		date := entry.statement.Date
+		if len(entry.errors) == 0 && inDateRange(date, startDate, endDate) && emitErr == nil {
+			emitErr = emit(entry.statement)
+		}
+		entry = nil
+	}
+
+	handle := func(field mt940Field) {
+		switch {
+		case field.tag == mt940TagStatement:
+			flushEntry()
+			statement = mt940Statement{}
+		case field.tag == mt940TagEntry:
+			flushEntry()
+			entry = newMT940Entry(file, field)
+			if len(entry.errors) == 0 {
+				statement.movement = statement.movement.Add(entry.signedAmount())
+			}
+		case field.tag == mt940TagInfo:
+			// Information of the statement itself follows :62F:, not :61:
+			if entry != nil {
+				entry.info = append(entry.info, field.value)
+			}
+		case field.tag == "60F" || field.tag == "60M":
+			flushEntry()
+			balance, err := parseMT940Balance(field.value)
+			if err != nil {
+				reject(field.line, ":"+field.tag+":", field.value, recon.RowErrorMalformedRow)
+				return
+			}
+			statement = mt940Statement{opening: balance, hasOpening: true}
+		case field.tag == "62F" || field.tag == "62M":
+			flushEntry()
+			balance, err := parseMT940Balance(field.value)
+			if err != nil {
+				reject(field.line, ":"+field.tag+":", field.value, recon.RowErrorMalformedRow)
+				return
+			}
+
+			if expected := statement.opening.Add(statement.movement); statement.hasOpening && !expected.Equal(balance) {
+				reject(field.line, ":"+field.tag+":", fmt.Sprintf("expected %s, got %s", expected, balance), recon.RowErrorBalanceMismatch)
+			}
+			// An intermediate closing balance is the opening of the next page
+			statement = mt940Statement{opening: balance, hasOpening: true}
+		default:
+			flushEntry()
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxMT940LineSize)
+	var (
+		field   *mt940Field
+		lineNum int
+	)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if emitErr != nil {
+			return nil, emitErr
+		}
+
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// The header blocks come before the text block {4: of each message
+		if strings.HasPrefix(line, "{") {
+			index := strings.Index(line, "{4:")
+			if index < 0 {
+				continue
+			}
+			line = line[index+len("{4:"):]
+		}
+
+		if strings.HasPrefix(line, "-") {
+			// End of the text block of a message
+			if field != nil {
+				handle(*field)
+				field = nil
+			}
+			flushEntry()
+			continue
+		}
+
+		if match := mt940TagPattern.FindStringSubmatch(line); match != nil {
+			if field != nil {
+				handle(*field)
+			}
+			field = &mt940Field{tag: match[1], line: lineNum, value: match[2]}
+			continue
+		}
+
+		// A line without a tag continues the field before it
+		if field != nil && line != "" {
+			field.value += "\n" + line
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if field != nil {
+		handle(*field)
+	}
+	flushEntry()
+
+	if emitErr != nil {
+		return nil, emitErr
+	}
+
+	return rowErrors, nil
+}
+
+func newMT940Entry(file string, field mt940Field) *mt940Entry {
+	entry := &mt940Entry{line: field.line, field: field.value}
+
+	// The first line is the entry, the next one the supplementary details
+	first, _, _ := strings.Cut(field.value, "\n")
+	match := mt940EntryPattern.FindStringSubmatch(first)
+	if match == nil {
+		entry.reject(file, ":61:", first, recon.RowErrorMalformedRow)
+		return entry
+	}
+
+	valueDate, err := time.Parse("060102", match[1])
+	if err != nil {
+		entry.reject(file, ":61:", match[1], recon.RowErrorInvalidDate)
+	}
+
+	amount, err := decimal.NewFromString(strings.Replace(match[5], ",", ".", 1))
+	if err != nil {
+		entry.reject(file, ":61:", match[5], recon.RowErrorInvalidAmount)
+	}
+
+	// A reversal of a credit takes money out, a reversal of a debit puts it back
+	transactionType := recon.TransactionTypeCredit
+	if match[3] == "D" || match[3] == "RC" {
+		transactionType = recon.TransactionTypeDebit
+	}
+
+	entry.statement = recon.BankStatementUploadFile{
+		UniqueID:        strings.TrimSpace(match[7]),
+		Amount:          amount,
+		TransactionType: transactionType,
+		Date:            valueDate,
+	}
+
+	return entry
+}
+
+func (e *mt940Entry) reject(file, column, value string, reason recon.RowErrorReason) {
+	e.errors = append(e.errors, recon.RowError{File: file, Line: e.line, Column: column, Value: value, Reason: reason})
+}
+
+func (e *mt940Entry) signedAmount() decimal.Decimal {
+	if e.statement.TransactionType == recon.TransactionTypeDebit {
+		return e.statement.Amount.Neg()
+	}
+
+	return e.statement.Amount
+}
+
+// finish picks the reference and the bank code once the :86: of the entry,
+// if any, is known.
+func (e *mt940Entry) finish(file string, template Template) {
+	if len(e.errors) > 0 {
+		return
+	}
+
+	// SWIFT wraps :86: at 65 characters, the lines are one text
+	info := strings.ReplaceAll(strings.Join(e.info, ""), "\n", "")
+	if info != "" {
+		e.statement.UniqueID = strings.TrimSpace(info)
+		if template.ReferencePattern != nil {
+			e.statement.UniqueID = ""
+			if match := template.ReferencePattern.FindStringSubmatch(info); match != nil {
+				e.statement.UniqueID = strings.TrimSpace(match[min(1, len(match)-1)])
+			}
+		}
+	}
+
+	if e.statement.UniqueID == "" || e.statement.UniqueID == "NONREF" {
+		e.statement.UniqueID = ""
+		e.reject(file, ":86:", info, recon.RowErrorMissingValue)
+	}
+
+	e.statement.BankCode = template.BankCode
+	if e.statement.BankCode == "" {
+		e.reject(file, string(ColumnBankCode), "", recon.RowErrorMissingValue)
+	}
+}
+
+func parseMT940Balance(value string) (decimal.Decimal, error) {
+	match := mt940BalancePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return decimal.Zero, fmt.Errorf("malformed balance %q", value)
+	}
+
+	balance, err := decimal.NewFromString(strings.Replace(match[4], ",", ".", 1))
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if match[1] == "D" {
+		return balance.Neg(), nil
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,126 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMT940(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	template := parser.Template{Name: "bca", Side: parser.SideBank, Format: parser.FormatMT940, BankCode: "014"}
+
+	t.Run("success with multiple statements", func(t *testing.T) {
+		file := strings.Join([]string{
+			"{1:F01BCAIIDJAXXXX0000000000}{2:I940AMARTHAXXXXN}{4:",
+			":20:STMT0001",
+			":25:1234567890",
+			":28C:00001/001",
+			":60F:C260101IDR1000000,00",
+			":61:2601020102D150000,00NTRFNONREF//B1",
+			":86:TX1",
+			":61:2601020102C50000,5NTRFTX2",
+			":62F:C260102IDR900000,50",
+			"-}",
+			"{1:F01BCAIIDJAXXXX0000000000}{2:I940AMARTHAXXXXN}{4:",
+			":20:STMT0002",
+			":25:1234567890",
+			":60F:C260102IDR900000,50",
+			":61:260103RC1000,NTRFTX3",
+			":86:REVERSAL OF",
+			"TX3 RETURNED",
+			":61:260205C200,NTRFTX4",
+			":62F:C260205IDR899200,50",
+			":86:closing information of the statement",
+			"-}",
+		}, "\r\n")
+
+		banks, rowErrors, err := parser.ParseMT940(ctx, strings.NewReader(file), "bca.sta", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, []recon.BankStatementUploadFile{
+			{UniqueID: "TX1", Amount: decimal.RequireFromString("150000.00"), TransactionType: recon.TransactionTypeDebit, Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "014"},
+			{UniqueID: "TX2", Amount: decimal.RequireFromString("50000.5"), TransactionType: recon.TransactionTypeCredit, Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "014"},
+			{UniqueID: "REVERSAL OFTX3 RETURNED", Amount: decimal.RequireFromString("1000"), TransactionType: recon.TransactionTypeDebit, Date: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), BankCode: "014"},
+		}, banks)
+	})
+
+	t.Run("success with reference pattern", func(t *testing.T) {
+		template := template
+		template.ReferencePattern = regexp.MustCompile(`REF:(\w+)`)
+		file := ":20:STMT\n:61:260102C100,NTRFX\n:86:TRANSFER REF:TX9 FROM AMARTHA\n"
+
+		banks, rowErrors, err := parser.ParseMT940(ctx, strings.NewReader(file), "bca.sta", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, "TX9", banks[0].UniqueID)
+	})
+
+	t.Run("success with row errors", func(t *testing.T) {
+		template := template
+		template.BankCode = ""
+		file := strings.Join([]string{
+			":20:STMT",
+			":60F:C260101IDR100,00",
+			":61:260102X100,NTRFTX1",
+			":61:260102C100,NTRFNONREF",
+			":61:260102C100,NTRFTX3",
+			":62F:C260102IDR150,00",
+			":62M:X",
+		}, "\n")
+
+		banks, rowErrors, err := parser.ParseMT940(ctx, strings.NewReader(file), "bca.sta", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, banks)
+		assert.Equal(t, []recon.RowError{
+			{File: "bca.sta", Line: 3, Column: ":61:", Value: "260102X100,NTRFTX1", Reason: recon.RowErrorMalformedRow},
+			{File: "bca.sta", Line: 4, Column: ":86:", Reason: recon.RowErrorMissingValue},
+			{File: "bca.sta", Line: 4, Column: "bank_code", Reason: recon.RowErrorMissingValue},
+			{File: "bca.sta", Line: 5, Column: "bank_code", Reason: recon.RowErrorMissingValue},
+			{File: "bca.sta", Line: 6, Column: ":62F:", Value: "expected 300, got 150", Reason: recon.RowErrorBalanceMismatch},
+			{File: "bca.sta", Line: 7, Column: ":62M:", Value: "X", Reason: recon.RowErrorMalformedRow},
+		}, rowErrors)
+	})
+
+	t.Run("success through bank file format", func(t *testing.T) {
+		file := ":20:STMT\n:61:260102C100,NTRFTX1\n"
+
+		banks, _, err := parser.ParseBankFile(ctx, strings.NewReader(file), "bca.sta", "", template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Len(t, banks, 1)
+	})
+
+	t.Run("error cancelled context", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, _, err := parser.ParseMT940(cancelledCtx, strings.NewReader(":20:STMT\n"), "bca.sta", template, startDate, endDate, recon.NoProgress)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := parser.ParseFormat("")
+	assert.NoError(t, err)
+	assert.Empty(t, format)
+
+	format, err = parser.ParseFormat("mt940")
+	assert.NoError(t, err)
+	assert.Equal(t, parser.FormatMT940, format)
+
+	format, err = parser.ParseFormat("xlsx")
+	assert.NoError(t, err)
+	assert.Equal(t, parser.FormatXLSX, format)
+
+	_, err = parser.ParseFormat("pdf")
+	assert.ErrorIs(t, err, parser.ErrorInvalidFormat)
+}
@@ -0,0 +1,410 @@
+package parser
+
+import (
+	"amartha-recon-service/configuration"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	SideSystem Side = "SYSTEM"
+	SideBank   Side = "BANK"
+
+	FormatCSV     Format = "CSV"
+	FormatMT940   Format = "MT940"
+	FormatCAMT053 Format = "CAMT053"
+	FormatCAMT054 Format = "CAMT054"
+	FormatXLSX    Format = "XLSX"
+
+	// DefaultTemplate is the layout used when no template is chosen nor detected
+	DefaultTemplate = "DEFAULT"
+
+	ColumnTransactionID   Column = "transaction_id"
+	ColumnTerminalRRN     Column = "terminal_rrn"
+	ColumnAmount          Column = "amount"
+	ColumnTransactionType Column = "transaction_type"
+	ColumnBankCode        Column = "bank_code"
+	ColumnTransactionTime Column = "transaction_time"
+	ColumnUniqueID        Column = "unique_id"
+	ColumnDate            Column = "date"
+
+	// maxDetectRows bounds how far detection looks for a header row
+	maxDetectRows = 20
+)
+
+var (
+	ErrorTemplateNotFound = errors.New("template file tidak ditemukan")
+	ErrorInvalidTemplate  = errors.New("template file tidak valid")
+	ErrorInvalidFormat    = errors.New("format file tidak dikenal")
+
+	sideColumns = map[Side][]Column{
+		SideSystem: {ColumnTransactionID, ColumnTerminalRRN, ColumnAmount, ColumnTransactionType, ColumnBankCode, ColumnTransactionTime},
+		SideBank:   {ColumnUniqueID, ColumnAmount, ColumnDate, ColumnBankCode, ColumnTransactionType},
+	}
+)
+
+type (
+	// Side is the file a template is meant for.
+	Side string
+
+	// Format is how an uploaded file is encoded.
+	Format string
+
+	// Column is a field of TransactionUploadFile or BankStatementUploadFile.
+	Column string
+
+	// ColumnRef points to a column by its position, or by its name on the
+	// header row when Name is set.
+	ColumnRef struct {
+		Index int
+		Name  string
+	}
+
+	// Template describes how the CSV of a bank or of the system is laid out.
+	Template struct {
+		Name    string
+		Side    Side
+		Format  Format
+		Columns map[Column]ColumnRef
+		// Delimiter separates the fields, a comma by default
+		Delimiter rune
+		// SkipRows is the number of rows before the data, the last of them is
+		// the header row named columns are looked up on
+		SkipRows         int
+		DateLayout       string
+		DecimalSeparator string
+		// BankCode fills the bank code of rows on files without that column
+		BankCode string
+		// ReferencePattern picks the reference out of the free text of a
+		// statement line, its first group when it has one
+		ReferencePattern *regexp.Regexp
+		// Sheet is the sheet of an XLSX read, the first sheet when empty
+		Sheet string
+	}
+
+	registry struct {
+		cfg configuration.Configuration
+	}
+
+	// Registry finds the templates configured under recon.templates.
+	Registry interface {
+		Find(key string, side Side) (Template, error)
+		Resolve(key string, side Side, r io.Reader) (Template, io.Reader, error)
+	}
+)
+
+func NewRegistry(cfg configuration.Configuration) Registry {
+	return &registry{cfg: cfg}
+}
+
+// defaultTemplate is the layout the recon endpoint always read, see README.
+func defaultTemplate(side Side) Template {
+	columns := make(map[Column]ColumnRef)
+	for i, column := range sideColumns[side] {
+		columns[column] = ColumnRef{Index: i}
+	}
+
+	return Template{
+		Name:             DefaultTemplate,
+		Side:             side,
+		Format:           FormatCSV,
+		Columns:          columns,
+		Delimiter:        ',',
+		SkipRows:         1,
+		DateLayout:       time.DateTime,
+		DecimalSeparator: ".",
+	}
+}
+
+// Find looks a template up by its name, or by the bank code it is set for.
+// An empty key is the default template.
+func (r *registry) Find(key string, side Side) (Template, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || strings.EqualFold(key, DefaultTemplate) {
+		return defaultTemplate(side), nil
+	}
+
+	templates, err := r.templates(side)
+	if err != nil {
+		return Template{}, err
+	}
+
+	for _, template := range templates {
+		if template.Name == key {
+			return template, nil
+		}
+	}
+
+	for _, template := range templates {
+		if template.BankCode != "" && template.BankCode == key {
+			return template, nil
+		}
+	}
+
+	return Template{}, ErrorTemplateNotFound
+}
+
+// Resolve finds the template by key, or detects it from the header row of r
+// when key is empty. The returned reader still starts at the first byte of r.
+func (r *registry) Resolve(key string, side Side, reader io.Reader) (Template, io.Reader, error) {
+	if strings.TrimSpace(key) != "" {
+		template, err := r.Find(key, side)
+		return template, reader, err
+	}
+
+	templates, err := r.templates(side)
+	if err != nil {
+		return Template{}, reader, err
+	}
+
+	buffered := bufio.NewReader(reader)
+	if signature, _ := buffered.Peek(len(xlsxSignature)); string(signature) == xlsxSignature {
+		return detectSheet(templates, side, buffered)
+	}
+
+	var head bytes.Buffer
+	var lines []string
+	for len(lines) < maxDetectRows {
+		line, err := buffered.ReadString('\n')
+		head.WriteString(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	replay := io.MultiReader(&head, buffered)
+	for _, template := range templates {
+		if template.matchHeader(lines) {
+			return template, replay, nil
+		}
+	}
+
+	return defaultTemplate(side), replay, nil
+}
+
+// detectSheet matches the first rows of the sheet of every template, a
+// workbook is read as a whole so it is kept in memory to be read again.
+func detectSheet(templates []Template, side Side, reader io.Reader) (Template, io.Reader, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return Template{}, nil, err
+	}
+
+	replay := bytes.NewReader(content)
+	file, err := excelize.OpenReader(replay)
+	if err != nil {
+		return Template{}, nil, err
+	}
+	defer file.Close()
+
+	heads := make(map[string][][]string)
+	for _, template := range templates {
+		rows, ok := heads[template.Sheet]
+		if !ok {
+			// A template on a sheet the workbook does not have does not match
+			if source, err := newSheetSource(file, template.Sheet); err == nil {
+				rows, err = source.head(maxDetectRows)
+				source.rows.Close()
+				if err != nil {
+					return Template{}, nil, err
+				}
+			}
+			heads[template.Sheet] = rows
+		}
+
+		if template.matchRows(rows) {
+			return template, bytes.NewReader(content), nil
+		}
+	}
+
+	return defaultTemplate(side), bytes.NewReader(content), nil
+}
+
+// templates loads every configured template of side, ordered by name so
+// detection does not depend on the configuration order.
+func (r *registry) templates(side Side) ([]Template, error) {
+	var templates []Template
+	for _, name := range r.cfg.GetArray("recon.templates") {
+		template, err := r.load(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		if template.Side == side {
+			templates = append(templates, template)
+		}
+	}
+
+	slices.SortFunc(templates, func(a, b Template) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return templates, nil
+}
+
+func (r *registry) load(name string) (Template, error) {
+	prefix := "recon.template." + name
+	side := Side(strings.ToUpper(strings.TrimSpace(r.cfg.GetString(prefix + ".side"))))
+	if _, ok := sideColumns[side]; !ok {
+		return Template{}, fmt.Errorf("%w: %s has unknown side %q", ErrorInvalidTemplate, name, side)
+	}
+
+	template := defaultTemplate(side)
+	template.Name = name
+	template.Columns = make(map[Column]ColumnRef)
+	for key, value := range r.cfg.GetMap(prefix + ".columns") {
+		column := Column(strings.ToLower(strings.TrimSpace(key)))
+		if !slices.Contains(sideColumns[side], column) {
+			return Template{}, fmt.Errorf("%w: %s has unknown column %q", ErrorInvalidTemplate, name, key)
+		}
+
+		value = strings.TrimSpace(value)
+		if index, err := strconv.Atoi(value); err == nil && index >= 0 {
+			template.Columns[column] = ColumnRef{Index: index}
+		} else {
+			template.Columns[column] = ColumnRef{Name: value}
+		}
+	}
+
+	format, err := ParseFormat(r.cfg.GetString(prefix + ".format"))
+	if err != nil {
+		return Template{}, fmt.Errorf("%w: %s has format %q", ErrorInvalidTemplate, name, r.cfg.GetString(prefix+".format"))
+	}
+	if format != "" {
+		template.Format = format
+	}
+
+	// Only a CSV or a sheet is laid out in columns
+	if len(template.Columns) == 0 && (template.Format == FormatCSV || template.Format == FormatXLSX) {
+		return Template{}, fmt.Errorf("%w: %s has no columns", ErrorInvalidTemplate, name)
+	}
+
+	if delimiter := r.cfg.GetString(prefix + ".delimiter"); delimiter != "" {
+		d, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return Template{}, fmt.Errorf("%w: %s has delimiter %q", ErrorInvalidTemplate, name, delimiter)
+		}
+		template.Delimiter = d
+	}
+
+	if skipRows := r.cfg.GetString(prefix + ".skip.rows"); skipRows != "" {
+		rows, err := strconv.Atoi(skipRows)
+		if err != nil || rows < 0 {
+			return Template{}, fmt.Errorf("%w: %s has skip rows %q", ErrorInvalidTemplate, name, skipRows)
+		}
+		template.SkipRows = rows
+	}
+
+	if layout := r.cfg.GetString(prefix + ".date.layout"); layout != "" {
+		template.DateLayout = layout
+	}
+
+	switch separator := r.cfg.GetString(prefix + ".decimal.separator"); separator {
+	case "":
+	case ".", ",":
+		template.DecimalSeparator = separator
+	default:
+		return Template{}, fmt.Errorf("%w: %s has decimal separator %q", ErrorInvalidTemplate, name, separator)
+	}
+
+	template.BankCode = strings.TrimSpace(r.cfg.GetString(prefix + ".bank.code"))
+	template.Sheet = strings.TrimSpace(r.cfg.GetString(prefix + ".sheet"))
+	if pattern := r.cfg.GetString(prefix + ".reference.pattern"); pattern != "" {
+		if template.ReferencePattern, err = regexp.Compile(pattern); err != nil {
+			return Template{}, fmt.Errorf("%w: %s has reference pattern %q", ErrorInvalidTemplate, name, pattern)
+		}
+	}
+
+	if template.hasNamedColumns() && template.SkipRows == 0 {
+		return Template{}, fmt.Errorf("%w: %s names its columns without a header row", ErrorInvalidTemplate, name)
+	}
+
+	return template, nil
+}
+
+// ParseFormat parses the format of an uploaded file, empty when not chosen.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToUpper(strings.TrimSpace(value))); format {
+	case "", FormatCSV, FormatMT940, FormatCAMT053, FormatCAMT054, FormatXLSX:
+		return format, nil
+	default:
+		return "", ErrorInvalidFormat
+	}
+}
+
+func (t Template) hasNamedColumns() bool {
+	for _, ref := range t.Columns {
+		if ref.Name != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchHeader tells whether the header row of the first lines of a file
+// carries every named column of the template. Templates using positions
+// only are never detected, they have to be chosen.
+func (t Template) matchHeader(lines []string) bool {
+	if !t.hasNamedColumns() || t.SkipRows > len(lines) {
+		return false
+	}
+
+	reader := csv.NewReader(strings.NewReader(lines[t.SkipRows-1]))
+	reader.Comma = t.Delimiter
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return false
+	}
+
+	_, err = t.resolve(header)
+	return err == nil
+}
+
+// matchRows is matchHeader on the first rows of a sheet.
+func (t Template) matchRows(rows [][]string) bool {
+	if !t.hasNamedColumns() || t.SkipRows > len(rows) {
+		return false
+	}
+
+	_, err := t.resolve(rows[t.SkipRows-1])
+	return err == nil
+}
+
+// resolve turns the columns of the template into positions on a row.
+func (t Template) resolve(header []string) (map[Column]int, error) {
+	positions := make(map[Column]int, len(t.Columns))
+	for column, ref := range t.Columns {
+		if ref.Name == "" {
+			positions[column] = ref.Index
+			continue
+		}
+
+		index := slices.IndexFunc(header, func(name string) bool {
+			return strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")), ref.Name)
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("%w: column %q is not on the header row", ErrorInvalidTemplate, ref.Name)
+		}
+		positions[column] = index
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,189 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/mocks"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newConfiguration configures the templates, settings hold the
+// recon.template.<name>.* strings and columns the column maps.
+func newConfiguration(
+	t *testing.T,
+	templates []string,
+	settings map[string]string,
+	columns map[string]map[string]string) *mocks.Configuration {
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetArray", "recon.templates").Return(templates).Maybe()
+	for key, value := range settings {
+		cfg.On("GetString", key).Return(value).Maybe()
+	}
+	for key, value := range columns {
+		cfg.On("GetMap", key).Return(value).Maybe()
+	}
+	cfg.On("GetString", mock.Anything).Return("").Maybe()
+	cfg.On("GetMap", mock.Anything).Return(map[string]string(nil)).Maybe()
+	return cfg
+}
+
+func newBCAConfiguration(t *testing.T) *mocks.Configuration {
+	return newConfiguration(t,
+		[]string{"bca", "mandiri"},
+		map[string]string{
+			"recon.template.bca.side":                  "BANK",
+			"recon.template.bca.delimiter":             ";",
+			"recon.template.bca.skip.rows":             "2",
+			"recon.template.bca.date.layout":           "02/01/2006",
+			"recon.template.bca.decimal.separator":     ",",
+			"recon.template.bca.bank.code":             "014",
+			"recon.template.mandiri.side":              "BANK",
+			"recon.template.mandiri.bank.code":         "008",
+			"recon.template.mandiri.decimal.separator": ".",
+		},
+		map[string]map[string]string{
+			"recon.template.bca.columns": {
+				"unique_id":        "Reference No",
+				"amount":           "Amount",
+				"date":             "Value Date",
+				"transaction_type": "D/C",
+			},
+			"recon.template.mandiri.columns": {
+				"unique_id": "0",
+				"amount":    "1",
+				"date":      "2",
+			},
+		},
+	)
+}
+
+func TestRegistry_Find(t *testing.T) {
+	t.Run("default template", func(t *testing.T) {
+		registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+
+		template, err := registry.Find("", parser.SideBank)
+		assert.NoError(t, err)
+		assert.Equal(t, parser.DefaultTemplate, template.Name)
+		assert.Equal(t, parser.ColumnRef{Index: 3}, template.Columns[parser.ColumnBankCode])
+		assert.Equal(t, time.DateTime, template.DateLayout)
+	})
+
+	t.Run("by name", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		template, err := registry.Find("bca", parser.SideBank)
+		assert.NoError(t, err)
+		assert.Equal(t, ';', template.Delimiter)
+		assert.Equal(t, 2, template.SkipRows)
+		assert.Equal(t, ",", template.DecimalSeparator)
+		assert.Equal(t, parser.ColumnRef{Name: "Reference No"}, template.Columns[parser.ColumnUniqueID])
+	})
+
+	t.Run("by bank code", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		template, err := registry.Find("008", parser.SideBank)
+		assert.NoError(t, err)
+		assert.Equal(t, "mandiri", template.Name)
+		assert.Equal(t, parser.ColumnRef{Index: 1}, template.Columns[parser.ColumnAmount])
+	})
+
+	t.Run("mt940 template without columns", func(t *testing.T) {
+		cfg := newConfiguration(t,
+			[]string{"bni"},
+			map[string]string{
+				"recon.template.bni.side":              "BANK",
+				"recon.template.bni.format":            "mt940",
+				"recon.template.bni.bank.code":         "009",
+				"recon.template.bni.reference.pattern": `REF:(\w+)`,
+			},
+			nil,
+		)
+		registry := parser.NewRegistry(cfg)
+
+		template, err := registry.Find("009", parser.SideBank)
+		assert.NoError(t, err)
+		assert.Equal(t, parser.FormatMT940, template.Format)
+		assert.Equal(t, "TX1", template.ReferencePattern.FindStringSubmatch("REF:TX1")[1])
+	})
+
+	t.Run("error template of the other side", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		_, err := registry.Find("bca", parser.SideSystem)
+		assert.ErrorIs(t, err, parser.ErrorTemplateNotFound)
+	})
+
+	t.Run("error invalid template", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			settings map[string]string
+			columns  map[string]string
+		}{
+			{name: "unknown side", settings: map[string]string{"recon.template.x.side": "OTHER"}, columns: map[string]string{"amount": "1"}},
+			{name: "unknown column", settings: map[string]string{"recon.template.x.side": "BANK"}, columns: map[string]string{"terminal_rrn": "1"}},
+			{name: "no columns", settings: map[string]string{"recon.template.x.side": "BANK"}},
+			{name: "delimiter", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.delimiter": ";;"}, columns: map[string]string{"amount": "1"}},
+			{name: "skip rows", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.skip.rows": "-1"}, columns: map[string]string{"amount": "1"}},
+			{name: "decimal separator", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.decimal.separator": "'"}, columns: map[string]string{"amount": "1"}},
+			{name: "format", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.format": "PDF"}, columns: map[string]string{"amount": "1"}},
+			{name: "reference pattern", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.reference.pattern": "("}, columns: map[string]string{"amount": "1"}},
+			{name: "named columns without header", settings: map[string]string{"recon.template.x.side": "BANK", "recon.template.x.skip.rows": "0"}, columns: map[string]string{"amount": "Amount"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				cfg := newConfiguration(t, []string{"x"}, tt.settings, map[string]map[string]string{"recon.template.x.columns": tt.columns})
+				registry := parser.NewRegistry(cfg)
+
+				_, err := registry.Find("x", parser.SideBank)
+				assert.ErrorIs(t, err, parser.ErrorInvalidTemplate)
+			})
+		}
+	})
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	file := "Statement BCA;;;\nReference No;Value Date;D/C;Amount\nREF1;02/01/2026;D;1.000,50\n"
+
+	t.Run("detect from header row", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		template, reader, err := registry.Resolve("", parser.SideBank, strings.NewReader(file))
+		assert.NoError(t, err)
+		assert.Equal(t, "bca", template.Name)
+
+		// Detection must not swallow the rows it looked at
+		replay, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, file, string(replay))
+	})
+
+	t.Run("default when nothing matches", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		template, _, err := registry.Resolve("", parser.SideBank, strings.NewReader("id,amount,date,bank_code\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, parser.DefaultTemplate, template.Name)
+	})
+
+	t.Run("chosen by key", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		template, _, err := registry.Resolve("mandiri", parser.SideBank, strings.NewReader(file))
+		assert.NoError(t, err)
+		assert.Equal(t, "mandiri", template.Name)
+	})
+
+	t.Run("error unknown key", func(t *testing.T) {
+		registry := parser.NewRegistry(newBCAConfiguration(t))
+
+		_, _, err := registry.Resolve("bri", parser.SideBank, strings.NewReader(file))
+		assert.ErrorIs(t, err, parser.ErrorTemplateNotFound)
+	})
+}
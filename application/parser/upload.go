@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"amartha-recon-service/application/recon"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"time"
+)
+
+type (
+	// Source is one uploaded file of a side. Template and Sheet are chosen
+	// over the detected template and the sheet of the template when set, an
+	// empty Format is detected from the file name and content type.
+	Source struct {
+		Reader      io.Reader
+		FileName    string
+		ContentType string
+		Template    string
+		Sheet       string
+		Format      Format
+	}
+
+	// LoadOptions are how the rows of a recon are read and checked. The rows
+	// of every day from StartDate to EndDate are read, whatever their time on
+	// EndDate. BankCodes are the bank codes reconciled against the
+	// transactions table, the ones found on the bank file when empty.
+	LoadOptions struct {
+		StartDate       time.Time
+		EndDate         time.Time
+		BankCodes       []string
+		DuplicatePolicy recon.DuplicatePolicy
+		ValidationMode  recon.ValidationMode
+	}
+
+	// Loader turns the uploaded files of a recon into its UploadFile, the
+	// same for the HTTP upload and the offline tools.
+	Loader struct {
+		templates Registry
+		limits    UploadLimits
+		spill     recon.SpillSettings
+	}
+)
+
+func NewLoader(templates Registry, limits UploadLimits, spill recon.SpillSettings) Loader {
+	return Loader{templates: templates, limits: limits, spill: spill}
+}
+
+// Load parses the uploaded files, system is nil when the system transactions
+// are loaded from the database. With recon.spill.enabled the rows are
+// spilled to disk while parsed. The caller closes the upload.
+func (l Loader) Load(
+	ctx context.Context,
+	system *Source,
+	bank Source,
+	options LoadOptions,
+	progress recon.Progress) (*recon.UploadFile, error) {
+	var (
+		transactionUploadFiles   []recon.TransactionUploadFile
+		bankStatementUploadFiles []recon.BankStatementUploadFile
+	)
+	addTransaction := collect(&transactionUploadFiles)
+	addBankStatement := collect(&bankStatementUploadFiles)
+
+	var spill *recon.Spill
+	if l.spill.Enabled {
+		var err error
+		if spill, err = recon.NewSpill(l.spill.Dir); err != nil {
+			return nil, err
+		}
+		addTransaction, addBankStatement = spill.AddTransaction, spill.AddBankStatement
+	}
+
+	bankChecksum := sha256.New()
+	bank.Reader = io.TeeReader(bank.Reader, bankChecksum)
+	rowErrors, err := l.expand(bank, SideBank, func(name string, r io.Reader, format Format, template Template, file string) ([]recon.RowError, error) {
+		return StreamBankFile(ctx, r, name, format, template, options.StartDate, options.EndDate, progress,
+			func(row recon.BankStatementUploadFile) error {
+				row.File = file
+				return addBankStatement(row)
+			})
+	})
+	if err != nil {
+		closeSpill(spill)
+		return nil, err
+	}
+
+	// The transactions of the database have no file, so no checksum either
+	var systemChecksum string
+	if system != nil {
+		checksum := sha256.New()
+		source := *system
+		source.Reader = io.TeeReader(source.Reader, checksum)
+		systemRowErrors, err := l.expand(source, SideSystem, func(name string, r io.Reader, format Format, template Template, file string) ([]recon.RowError, error) {
+			return StreamSystemFile(ctx, r, name, format, template, options.StartDate, options.EndDate, progress,
+				func(row recon.TransactionUploadFile) error {
+					row.File = file
+					return addTransaction(row)
+				})
+		})
+		if err != nil {
+			closeSpill(spill)
+			return nil, err
+		}
+		rowErrors = append(systemRowErrors, rowErrors...)
+		systemChecksum = hex.EncodeToString(checksum.Sum(nil))
+	}
+
+	var uploadFile *recon.UploadFile
+	switch {
+	case spill != nil && system == nil:
+		uploadFile = recon.NewSpillBankUploadFile(spill, options.StartDate, options.EndDate)
+	case spill != nil:
+		uploadFile = recon.NewSpillUploadFile(spill, options.StartDate, options.EndDate)
+	case system == nil:
+		uploadFile = recon.NewBankUploadFile(bankStatementUploadFiles, options.StartDate, options.EndDate)
+	default:
+		uploadFile = recon.NewUploadFile(transactionUploadFiles, bankStatementUploadFiles, options.StartDate, options.EndDate)
+	}
+
+	return uploadFile.WithDuplicatePolicy(options.DuplicatePolicy).
+		WithBankCodes(options.BankCodes).
+		WithRowErrors(options.ValidationMode, rowErrors).
+		WithChecksums(systemChecksum, hex.EncodeToString(bankChecksum.Sum(nil))), nil
+}
+
+// expand parses every file of the upload of side with its template and
+// format. The rows of a zip member are marked with the name of the member,
+// file is empty otherwise.
+func (l Loader) expand(
+	source Source,
+	side Side,
+	parse func(name string, r io.Reader, format Format, template Template, file string) ([]recon.RowError, error)) ([]recon.RowError, error) {
+	compression := DetectCompression(source.FileName, source.ContentType)
+	format := source.Format
+	if format == "" && compression == "" {
+		format = DetectFormat(source.FileName, source.ContentType)
+	}
+
+	var rowErrors []recon.RowError
+	err := ExpandUpload(source.Reader, source.FileName, compression, l.limits, func(name string, r io.Reader) error {
+		template, reader, err := l.templates.Resolve(source.Template, side, r)
+		if err != nil {
+			return err
+		}
+
+		if source.Sheet != "" {
+			template.Sheet = source.Sheet
+		}
+
+		memberFormat := format
+		if memberFormat == "" && compression != "" {
+			memberFormat = DetectFormat(name, "")
+		}
+
+		var file string
+		if compression == CompressionZip {
+			file = name
+		}
+
+		memberRowErrors, err := parse(name, reader, memberFormat, template, file)
+		if err != nil {
+			return err
+		}
+
+		rowErrors = append(rowErrors, memberRowErrors...)
+		return nil
+	})
+
+	return rowErrors, err
+}
+
+func closeSpill(spill *recon.Spill) {
+	if spill == nil {
+		return
+	}
+
+	if err := spill.Close(); err != nil {
+		log.Printf("error removing spill: %v", err)
+	}
+}
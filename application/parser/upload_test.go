@@ -0,0 +1,132 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"amartha-recon-service/infrastructure/repository/transaction"
+	"amartha-recon-service/mocks"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLoader_Load(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	limits := parser.UploadLimits{MaxUncompressedBytes: 1 << 20, MaxMembers: 3}
+	options := parser.LoadOptions{StartDate: startDate, EndDate: endDate, DuplicatePolicy: recon.DuplicatePolicyFlag, ValidationMode: recon.ValidationModeSkip}
+
+	system := "transaction_id,terminal_rrn,amount,transaction_type,bank_code,transaction_time\n" +
+		"TX1,RRN1,100,DEBIT,014,2026-01-02 10:00:00\n" +
+		"TX2,RRN2,200,DEBIT,014,2026-01-02 11:00:00\n"
+
+	proceed := func(t *testing.T, uploadFile *recon.UploadFile) recon.ShowResultReconciliation {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(100))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		cfg.On("GetBool", "recon.spill.omit.matched").Return(false).Maybe()
+
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(startDate)
+
+		result, err := recon.NewService(cfg, nil, nil, generate).Proceed(ctx, uploadFile)
+		assert.NoError(t, err)
+		return result
+	}
+
+	t.Run("success zip members are marked", func(t *testing.T) {
+		bank := newZip(t, [][2]string{
+			{"2026-01-01.csv", "unique_id,amount,date,bank_code\nTX1,100,2026-01-02 10:00:00,014\n"},
+			{"2026-01-02.csv", "unique_id,amount,date,bank_code\nTX3,300,2026-01-02 10:00:00,014\nTX4,bad,2026-01-02 10:00:00,014\n"},
+		})
+		loader := parser.NewLoader(parser.NewRegistry(newConfiguration(t, nil, nil, nil)), limits, recon.SpillSettings{})
+
+		uploadFile, err := loader.Load(ctx,
+			&parser.Source{Reader: strings.NewReader(system), FileName: "amartha.csv"},
+			parser.Source{Reader: bank, FileName: "bank.zip"},
+			options, recon.NoProgress)
+		assert.NoError(t, err)
+		defer uploadFile.Close()
+
+		result := proceed(t, uploadFile)
+		assert.Len(t, result.ResultReconciliation, 1)
+		assert.Equal(t, 1, result.ResultReconciliation[0].TotalNumberOfExactMatches)
+		assert.Equal(t, 1, result.Validation.TotalNumberOfRejectedRows)
+		assert.Equal(t, "2026-01-02.csv", result.Validation.RowErrors[0].File)
+
+		var bankFiles []string
+		for _, exception := range result.ResultReconciliation[0].ResultReconciliationDetails.Exceptions {
+			if exception.BankStatement != nil {
+				bankFiles = append(bankFiles, exception.BankStatement.File)
+			}
+		}
+		assert.Equal(t, []string{"2026-01-02.csv"}, bankFiles)
+	})
+
+	t.Run("success gzip with spill", func(t *testing.T) {
+		loader := parser.NewLoader(parser.NewRegistry(newConfiguration(t, nil, nil, nil)), limits, recon.SpillSettings{Enabled: true, Dir: t.TempDir()})
+
+		uploadFile, err := loader.Load(ctx,
+			&parser.Source{Reader: newGzip(t, system), FileName: "amartha.csv.gz"},
+			parser.Source{Reader: strings.NewReader("unique_id,amount,date,bank_code\nTX2,200,2026-01-02 11:00:00,014\n"), FileName: "bank.csv"},
+			options, recon.NoProgress)
+		assert.NoError(t, err)
+		defer uploadFile.Close()
+
+		result := proceed(t, uploadFile)
+		assert.Equal(t, 1, result.ResultReconciliation[0].TotalNumberOfExactMatches)
+		assert.Equal(t, 1, result.ResultReconciliation[0].TotalNumberOfUnmatchedTransactions)
+	})
+
+	t.Run("success database run has no system checksum", func(t *testing.T) {
+		loader := parser.NewLoader(parser.NewRegistry(newConfiguration(t, nil, nil, nil)), limits, recon.SpillSettings{})
+
+		uploadFile, err := loader.Load(ctx, nil,
+			parser.Source{Reader: strings.NewReader("unique_id,amount,date,bank_code\nTX1,100,2026-01-02 10:00:00,014\n"), FileName: "bank.csv"},
+			options, recon.NoProgress)
+		assert.NoError(t, err)
+		defer uploadFile.Close()
+
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(100))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(startDate)
+		generate.On("UUID").Return("run-1")
+
+		repository := mocks.NewRepository(t)
+		repository.On("FindTransaction", ctx, mock.Anything).Return([]*transaction.Transaction{
+			{TransactionID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "DEBIT", BankCode: "014", TransactionTime: startDate.Add(34 * time.Hour)},
+		}, nil)
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("SaveRun", ctx,
+			mock.MatchedBy(func(run *reconciliation.Run) bool {
+				return run.SystemSource == recon.SystemSourceDatabase && run.SystemChecksum == "" && len(run.BankChecksum) == 64
+			}), mock.Anything, mock.Anything).Return(nil)
+
+		result, err := recon.NewService(cfg, repository, runRepository, generate).Proceed(ctx, uploadFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "run-1", result.RunID)
+		assert.Equal(t, 1, result.ResultReconciliation[0].TotalNumberOfExactMatches)
+	})
+
+	t.Run("error unknown template", func(t *testing.T) {
+		loader := parser.NewLoader(parser.NewRegistry(newConfiguration(t, nil, nil, nil)), limits, recon.SpillSettings{})
+
+		_, err := loader.Load(ctx, nil,
+			parser.Source{Reader: strings.NewReader("a,b\n"), FileName: "bank.csv", Template: "unknown"},
+			options, recon.NoProgress)
+		assert.Error(t, err)
+	})
+}
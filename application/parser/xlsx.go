@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// spreadsheetDateLayout is how a date cell is stored, without its zone
+	spreadsheetDateLayout = "2006-01-02T15:04:05.999999999"
+
+	xlsxExtension   = ".xlsx"
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	// xlsxSignature starts every XLSX, it is a zip archive
+	xlsxSignature = "PK\x03\x04"
+)
+
+var (
+	ErrorSheetNotFound = errors.New("sheet tidak ditemukan")
+)
+
+type (
+	// sheetSource reads the rows of a sheet with the values as they are
+	// stored, not as Excel displays them, so number formats do not change
+	// amounts nor dates.
+	sheetSource struct {
+		file     *excelize.File
+		sheet    string
+		rows     *excelize.Rows
+		line     int
+		date1904 bool
+	}
+)
+
+// DetectFormat tells the format of an upload by its file name or content
+// type, empty when the format is left to the template.
+func DetectFormat(fileName, contentType string) Format {
+	if strings.EqualFold(filepath.Ext(fileName), xlsxExtension) {
+		return FormatXLSX
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == xlsxContentType {
+		return FormatXLSX
+	}
+
+	return ""
+}
+
+// openSheet opens sheet of the workbook in r, the first sheet when sheet is
+// empty. The caller closes it.
+func openSheet(r io.Reader, sheet string) (*sheetSource, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := newSheetSource(file, sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
+
+func newSheetSource(file *excelize.File, sheet string) (*sheetSource, error) {
+	sheets := file.GetSheetList()
+	if sheet == "" && len(sheets) > 0 {
+		sheet = sheets[0]
+	}
+
+	if !slices.Contains(sheets, sheet) {
+		return nil, ErrorSheetNotFound
+	}
+
+	props, err := file.GetWorkbookProps()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := file.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sheetSource{
+		file:     file,
+		sheet:    sheet,
+		rows:     rows,
+		date1904: props.Date1904 != nil && *props.Date1904,
+	}, nil
+}
+
+// read skips blank rows, like a CSV reader skips empty lines. The line is
+// the row number shown by Excel.
+func (s *sheetSource) read() ([]string, int, error) {
+	for s.rows.Next() {
+		s.line++
+		row, err := s.rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if slices.ContainsFunc(row, func(value string) bool { return strings.TrimSpace(value) != "" }) {
+			return row, s.line, nil
+		}
+	}
+
+	if err := s.rows.Error(); err != nil {
+		return nil, 0, err
+	}
+
+	return nil, 0, io.EOF
+}
+
+// kind looks the cell up on the workbook, it is only asked for the non empty
+// cells the template reads.
+func (s *sheetSource) kind(index int) cellKind {
+	cell, err := excelize.CoordinatesToCellName(index+1, s.line)
+	if err != nil {
+		return cellText
+	}
+
+	cellType, err := s.file.GetCellType(s.sheet, cell)
+	if err != nil {
+		return cellText
+	}
+
+	switch cellType {
+	// A cell without a type is a number
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		return cellNumber
+	case excelize.CellTypeDate:
+		return cellDate
+	default:
+		return cellText
+	}
+}
+
+func (s *sheetSource) serialDate(value string) (time.Time, error) {
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return excelize.ExcelDateToTime(serial, s.date1904)
+}
+
+func (s *sheetSource) Close() error {
+	rowsErr := s.rows.Close()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	return rowsErr
+}
+
+// head returns the first n rows of a sheet, for the detection of a template.
+func (s *sheetSource) head(n int) ([][]string, error) {
+	var rows [][]string
+	for len(rows) < n {
+		row, _, err := s.read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// spreadsheetAmount reads a number cell to the 15 significant digits Excel
+// keeps, so 0.1+0.2 is 0.3 like Excel shows it.
+func spreadsheetAmount(value string) (decimal.Decimal, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return decimal.NewFromString(strconv.FormatFloat(number, 'G', 15, 64))
+}
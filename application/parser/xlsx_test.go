@@ -0,0 +1,155 @@
+package parser_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+// newWorkbook writes the rows of every sheet, in order, to an XLSX.
+func newWorkbook(t *testing.T, sheets []string, rows map[string][][]any) *bytes.Buffer {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			assert.NoError(t, file.SetSheetName("Sheet1", sheet))
+		} else {
+			_, err := file.NewSheet(sheet)
+			assert.NoError(t, err)
+		}
+
+		for r, row := range rows[sheet] {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			assert.NoError(t, err)
+			assert.NoError(t, file.SetSheetRow(sheet, cell, &row))
+		}
+	}
+
+	buffer, err := file.WriteToBuffer()
+	assert.NoError(t, err)
+	return buffer
+}
+
+func TestParseSystemFile_XLSX(t *testing.T) {
+	ctx := context.Background()
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	registry := parser.NewRegistry(newConfiguration(t, nil, nil, nil))
+	defaultTemplate, err := registry.Find("", parser.SideSystem)
+	assert.NoError(t, err)
+
+	t.Run("success with numbers and serial dates", func(t *testing.T) {
+		workbook := newWorkbook(t, []string{"Summary", "Transactions"}, map[string][][]any{
+			"Summary": {{"Generated", "2026-01-04"}},
+			"Transactions": {
+				{"trxID", "rrn", "amount", "type", "bank_code", "transactionTime"},
+				{"TX1", 123456789012, 0.1 + 0.2, "CREDIT", "014", time.Date(2026, 1, 2, 10, 30, 0, 0, time.UTC)},
+				{},
+				// Dates typed as text still follow the layout of the template
+				{"TX2", "", "1,000.50", "DEBIT", "014", "2026-01-02 11:00:00"},
+			},
+		})
+
+		template := defaultTemplate
+		template.Sheet = "Transactions"
+		transactions, rowErrors, err := parser.ParseSystemFile(ctx, workbook, "amartha.xlsx", parser.FormatXLSX, template, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, []recon.TransactionUploadFile{
+			{
+				TransactionID:   "TX1",
+				TerminalRRN:     "123456789012",
+				Amount:          decimal.RequireFromString("0.3"),
+				TransactionType: "CREDIT",
+				BankCode:        "014",
+				TransactionTime: time.Date(2026, 1, 2, 10, 30, 0, 0, time.UTC),
+			},
+			{
+				TransactionID:   "TX2",
+				Amount:          decimal.RequireFromString("1000.50"),
+				TransactionType: "DEBIT",
+				BankCode:        "014",
+				TransactionTime: time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC),
+			},
+		}, transactions)
+	})
+
+	t.Run("row errors with excel line numbers", func(t *testing.T) {
+		workbook := newWorkbook(t, []string{"Sheet1"}, map[string][][]any{
+			"Sheet1": {
+				{"trxID", "rrn", "amount", "type", "bank_code", "transactionTime"},
+				{},
+				{"TX1", "", "abc", "CREDIT", "014", "02/01/2026"},
+			},
+		})
+
+		_, rowErrors, err := parser.ParseSystemFile(ctx, workbook, "amartha.xlsx", parser.FormatXLSX, defaultTemplate, startDate, endDate, recon.NoProgress)
+		assert.NoError(t, err)
+		assert.Equal(t, []recon.RowError{
+			{File: "amartha.xlsx", Line: 3, Column: "amount", Value: "abc", Reason: recon.RowErrorInvalidAmount},
+			{File: "amartha.xlsx", Line: 3, Column: "transaction_time", Value: "02/01/2026", Reason: recon.RowErrorInvalidDate},
+		}, rowErrors)
+	})
+
+	t.Run("error sheet not found", func(t *testing.T) {
+		workbook := newWorkbook(t, []string{"Sheet1"}, nil)
+
+		template := defaultTemplate
+		template.Sheet = "Transactions"
+		_, _, err := parser.ParseSystemFile(ctx, workbook, "amartha.xlsx", parser.FormatXLSX, template, startDate, endDate, recon.NoProgress)
+		assert.ErrorIs(t, err, parser.ErrorSheetNotFound)
+	})
+
+	t.Run("error not a workbook", func(t *testing.T) {
+		_, _, err := parser.ParseSystemFile(ctx, bytes.NewBufferString("trxID,amount\n"), "amartha.xlsx", parser.FormatXLSX, defaultTemplate, startDate, endDate, recon.NoProgress)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseBankFile_XLSX(t *testing.T) {
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	workbook := newWorkbook(t, []string{"Mutasi"}, map[string][][]any{
+		"Mutasi": {
+			{"Statement BCA"},
+			{"Reference No", "Value Date", "D/C", "Amount"},
+			{"REF1", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), "D", 1000.5},
+			// Text amounts follow the decimal separator of the template
+			{"REF2", "02/01/2026", "C", "2.000,25"},
+		},
+	})
+	content := workbook.Bytes()
+
+	registry := parser.NewRegistry(newBCAConfiguration(t))
+	template, reader, err := registry.Resolve("", parser.SideBank, bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, "bca", template.Name)
+
+	// Detection must not swallow the workbook
+	replay, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, content, replay)
+
+	banks, rowErrors, err := parser.ParseBankFile(context.Background(), bytes.NewReader(replay), "bca.xlsx", parser.FormatXLSX, template, startDate, endDate, recon.NoProgress)
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrors)
+	assert.Equal(t, []recon.BankStatementUploadFile{
+		{UniqueID: "REF1", Amount: decimal.RequireFromString("1000.5"), Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "014", TransactionType: "D"},
+		{UniqueID: "REF2", Amount: decimal.RequireFromString("2000.25"), Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), BankCode: "014", TransactionType: "C"},
+	}, banks)
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, parser.FormatXLSX, parser.DetectFormat("bca.XLSX", "application/octet-stream"))
+	assert.Equal(t, parser.FormatXLSX, parser.DetectFormat("bca", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"))
+	assert.Empty(t, parser.DetectFormat("bca.csv", "text/csv"))
+}
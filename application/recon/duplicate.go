@@ -0,0 +1,66 @@
+package recon
+
+import (
+	"strings"
+)
+
+const (
+	DuplicatePolicyFlag   DuplicatePolicy = "FLAG"
+	DuplicatePolicyReject DuplicatePolicy = "REJECT"
+)
+
+type (
+	// DuplicatePolicy decides what happens to an upload with duplicate rows,
+	// either they are only flagged and still reconciled, or the upload fails.
+	DuplicatePolicy string
+)
+
+// ParseDuplicatePolicy parses the policy sent on the request, FLAG by default.
+func ParseDuplicatePolicy(value string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToUpper(strings.TrimSpace(value))); policy {
+	case "":
+		return DuplicatePolicyFlag, nil
+	case DuplicatePolicyFlag, DuplicatePolicyReject:
+		return policy, nil
+	default:
+		return "", ErrorInvalidDuplicatePolicy
+	}
+}
+
+// detectDuplicates finds the ids showing up more than once on each side,
+// with all of their rows, in the order the ids first show up.
+func detectDuplicates(txs []TransactionUploadFile, banks []BankStatementUploadFile) []DuplicateGroup {
+	var groups []DuplicateGroup
+
+	var txOrder []string
+	txRows := make(map[string][]TransactionUploadFile)
+	for _, tx := range txs {
+		if _, ok := txRows[tx.TransactionID]; !ok {
+			txOrder = append(txOrder, tx.TransactionID)
+		}
+		txRows[tx.TransactionID] = append(txRows[tx.TransactionID], tx)
+	}
+
+	for _, id := range txOrder {
+		if len(txRows[id]) > 1 {
+			groups = append(groups, DuplicateGroup{Side: ExceptionSideSystem, ID: id, Transactions: txRows[id]})
+		}
+	}
+
+	var bankOrder []string
+	bankRows := make(map[string][]BankStatementUploadFile)
+	for _, b := range banks {
+		if _, ok := bankRows[b.UniqueID]; !ok {
+			bankOrder = append(bankOrder, b.UniqueID)
+		}
+		bankRows[b.UniqueID] = append(bankRows[b.UniqueID], b)
+	}
+
+	for _, id := range bankOrder {
+		if len(bankRows[id]) > 1 {
+			groups = append(groups, DuplicateGroup{Side: ExceptionSideBank, ID: id, BankStatements: bankRows[id]})
+		}
+	}
+
+	return groups
+}
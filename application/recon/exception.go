@@ -0,0 +1,217 @@
+package recon
+
+import (
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SortOrderAsc  = "ASC"
+	SortOrderDesc = "DESC"
+
+	defaultPageSize = 50
+	defaultMaxPage  = 500
+)
+
+var (
+	ErrorInvalidExceptionQuery = errors.New("parameter pencarian pengecualian tidak valid")
+
+	mismatchReasons = []MismatchReason{
+		MismatchReasonMissingInBank,
+		MismatchReasonMissingInSystem,
+		MismatchReasonAmountMismatch,
+		MismatchReasonDateMismatch,
+		MismatchReasonDuplicateID,
+		MismatchReasonTypeMismatch,
+	}
+)
+
+type (
+	// ExceptionQuery lists the exceptions of a stored run a page at a time.
+	// Empty filters match every exception, SortBy is amount, time or empty
+	// for the order they were found in.
+	ExceptionQuery struct {
+		RunID    string
+		BankCode string
+		Reason   string
+		Side     string
+		Status   string
+		SortBy   string
+		Order    string
+		Page     int
+		Size     int
+	}
+
+	// RunException is a stored exception with the id it is known by and
+	// where its follow up is.
+	RunException struct {
+		ID               uint64          `json:"id"`
+		RunID            string          `json:"run_id"`
+		BankCode         string          `json:"bank_code"`
+		Status           ExceptionStatus `json:"status"`
+		Assignee         string          `json:"assignee,omitempty"`
+		ResolutionReason string          `json:"resolution_reason,omitempty"`
+		StatusUpdatedAt  *time.Time      `json:"status_updated_at,omitempty"`
+		// OriginID is the exception of an earlier run the status was carried from
+		OriginID *uint64 `json:"origin_id,omitempty"`
+		Exception
+	}
+
+	Pagination struct {
+		Page       int `json:"page"`
+		Size       int `json:"size"`
+		TotalItems int `json:"total_items"`
+		TotalPages int `json:"total_pages"`
+	}
+
+	ExceptionPage struct {
+		Pagination Pagination
+		Exceptions []RunException
+	}
+)
+
+// FindExceptions returns a page of the exceptions of a stored run, with the
+// number of exceptions matching the query.
+func (s *service) FindExceptions(ctx context.Context, query ExceptionQuery) (ExceptionPage, error) {
+	criteria, err := s.exceptionCriteria(query)
+	if err != nil {
+		return ExceptionPage{}, err
+	}
+
+	if s.runRepository == nil {
+		return ExceptionPage{}, ErrorRunNotFound
+	}
+
+	if _, err := s.runRepository.FindRunByID(ctx, query.RunID); err != nil {
+		if errors.Is(err, reconciliation.ErrorRunNotFound) {
+			return ExceptionPage{}, ErrorRunNotFound
+		}
+		return ExceptionPage{}, err
+	}
+
+	total, err := s.runRepository.CountExceptions(ctx, criteria)
+	if err != nil {
+		return ExceptionPage{}, err
+	}
+
+	page := ExceptionPage{
+		Pagination: Pagination{
+			Page:       criteria.Offset/criteria.Limit + 1,
+			Size:       criteria.Limit,
+			TotalItems: total,
+			TotalPages: (total + criteria.Limit - 1) / criteria.Limit,
+		},
+		Exceptions: []RunException{},
+	}
+
+	// A page past the last one is empty, it is not worth a query
+	if criteria.Offset >= total {
+		return page, nil
+	}
+
+	stored, err := s.runRepository.FindExceptionPage(ctx, criteria)
+	if err != nil {
+		return ExceptionPage{}, err
+	}
+
+	for _, exception := range stored {
+		page.Exceptions = append(page.Exceptions, toRunException(exception))
+	}
+
+	return page, nil
+}
+
+// exceptionCriteria checks the query, a page holds defaultPageSize
+// exceptions unless asked otherwise and recon.exceptions.page.size.max at
+// most.
+func (s *service) exceptionCriteria(query ExceptionQuery) (*reconciliation.ExceptionCriteria, error) {
+	criteria := &reconciliation.ExceptionCriteria{
+		RunID:    query.RunID,
+		BankCode: strings.TrimSpace(query.BankCode),
+		Reason:   strings.ToUpper(strings.TrimSpace(query.Reason)),
+		Side:     strings.ToUpper(strings.TrimSpace(query.Side)),
+		Status:   query.Status,
+		Sort:     reconciliation.ExceptionSort(strings.ToLower(strings.TrimSpace(query.SortBy))),
+	}
+
+	if criteria.Reason != "" && !slices.Contains(mismatchReasons, MismatchReason(criteria.Reason)) {
+		return nil, ErrorInvalidExceptionQuery
+	}
+
+	switch ExceptionSide(criteria.Side) {
+	case "", ExceptionSideSystem, ExceptionSideBank, ExceptionSideBoth:
+	default:
+		return nil, ErrorInvalidExceptionQuery
+	}
+
+	if criteria.Status != "" {
+		status, err := ParseExceptionStatus(criteria.Status)
+		if err != nil {
+			return nil, ErrorInvalidExceptionQuery
+		}
+		criteria.Status = string(status)
+	}
+
+	switch criteria.Sort {
+	case "", reconciliation.ExceptionSortAmount, reconciliation.ExceptionSortTime:
+	default:
+		return nil, ErrorInvalidExceptionQuery
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(query.Order)) {
+	case "", SortOrderAsc:
+	case SortOrderDesc:
+		criteria.Desc = true
+	default:
+		return nil, ErrorInvalidExceptionQuery
+	}
+
+	maxSize := int(s.cfg.GetInt("recon.exceptions.page.size.max"))
+	if maxSize < 1 {
+		maxSize = defaultMaxPage
+	}
+
+	page, size := query.Page, query.Size
+	if page == 0 {
+		page = 1
+	}
+
+	if size == 0 {
+		size = min(defaultPageSize, maxSize)
+	}
+
+	if page < 1 || size < 1 || size > maxSize {
+		return nil, ErrorInvalidExceptionQuery
+	}
+
+	criteria.Limit = size
+	criteria.Offset = (page - 1) * size
+	return criteria, nil
+}
+
+func toRunException(stored *reconciliation.Exception) RunException {
+	exception := RunException{
+		ID:               stored.ID,
+		RunID:            stored.RunID,
+		BankCode:         stored.BankCode,
+		Status:           ExceptionStatus(stored.Status),
+		Assignee:         stored.Assignee,
+		ResolutionReason: stored.ResolutionReason,
+		Exception:        fromStoredException(stored),
+	}
+
+	if stored.StatusUpdatedAt.Valid {
+		exception.StatusUpdatedAt = &stored.StatusUpdatedAt.Time
+	}
+
+	if stored.OriginID.Valid {
+		originID := uint64(stored.OriginID.Int64)
+		exception.OriginID = &originID
+	}
+
+	return exception
+}
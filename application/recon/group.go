@@ -0,0 +1,85 @@
+package recon
+
+// reconcileGroup matches settlement groups. Rows sharing the same key of the
+// group rule form a group on each side, and a group is matched when one side
+// is a single row and both sums agree within tolerance. Single pairs and N:M
+// groups are left for the other steps.
+func (s *service) reconcileGroup(
+	txs []TransactionUploadFile,
+	txIdx []int,
+	banks []BankStatementUploadFile,
+	bankIdx []int,
+	bc string,
+	rule MatchRule,
+	tolerance Tolerance) chunkOutcome {
+	outcome := chunkOutcome{result: newResultReconciliation(bc)}
+	result := &outcome.result
+
+	var keys []string
+	txGroups := make(map[string][]int)
+	for _, i := range txIdx {
+		key := rule.transactionKey(txs[i])
+		if key == "" {
+			outcome.txLeft = append(outcome.txLeft, i)
+			continue
+		}
+
+		if _, ok := txGroups[key]; !ok {
+			keys = append(keys, key)
+		}
+		txGroups[key] = append(txGroups[key], i)
+	}
+
+	bankGroups := make(map[string][]int)
+	for _, i := range bankIdx {
+		if key := rule.bankKey(banks[i]); key != "" {
+			bankGroups[key] = append(bankGroups[key], i)
+		}
+	}
+
+	matchedBank := make(map[int]bool)
+	for _, key := range keys {
+		groupTx, groupBank := txGroups[key], bankGroups[key]
+
+		oneToMany := len(groupTx) == 1 && len(groupBank) > 1
+		manyToOne := len(groupTx) > 1 && len(groupBank) == 1
+		if !oneToMany && !manyToOne {
+			outcome.txLeft = append(outcome.txLeft, groupTx...)
+			continue
+		}
+
+		groupTxs := pickTransactions(txs, groupTx)
+		groupBanks := pickBankStatements(banks, groupBank)
+
+		class, _ := tolerance.classifyGroup(groupTxs, groupBanks)
+		if class == MatchClassMismatched {
+			outcome.txLeft = append(outcome.txLeft, groupTx...)
+			continue
+		}
+
+		for _, i := range groupBank {
+			matchedBank[i] = true
+		}
+
+		difference := sumTransactions(groupTxs).Sub(sumBankStatements(groupBanks))
+		result.TotalAmountDiscrepancies = result.TotalAmountDiscrepancies.Add(difference.Abs())
+		result.TotalNumberOfGroupMatches++
+		result.countMatch(class, len(groupTxs), difference.Abs())
+		result.ResultReconciliationDetails.GroupMatched =
+			append(result.ResultReconciliationDetails.GroupMatched, MatchedGroup{
+				Rule:             rule.Name,
+				Class:            class,
+				AmountDifference: difference,
+				Transactions:     groupTxs,
+				BankStatements:   groupBanks,
+			})
+	}
+
+	for _, i := range bankIdx {
+		if !matchedBank[i] {
+			outcome.bankLeft = append(outcome.bankLeft, i)
+		}
+	}
+
+	return outcome
+}
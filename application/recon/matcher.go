@@ -0,0 +1,191 @@
+package recon
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	MatchFieldTransactionID MatchField = "TRANSACTION_ID"
+	MatchFieldTerminalRRN   MatchField = "TERMINAL_RRN"
+	MatchFieldAmount        MatchField = "AMOUNT"
+	MatchFieldDate          MatchField = "DATE"
+
+	BankReferenceUniqueID                 BankReference = "UNIQUE_ID"
+	BankReferenceEndToEndID               BankReference = "END_TO_END_ID"
+	BankReferenceAccountServicerReference BankReference = "ACCOUNT_SERVICER_REFERENCE"
+
+	matchRuleFieldSeparator     = "+"
+	matchRuleReferenceSeparator = "="
+	matchKeySeparator           = "|"
+)
+
+var (
+	defaultMatchRules = []MatchRule{
+		{Name: string(MatchFieldTransactionID), Fields: []MatchField{MatchFieldTransactionID}, BankReference: BankReferenceUniqueID},
+	}
+)
+
+type (
+	MatchField string
+
+	// BankReference is the reference of a bank statement an identifier field
+	// is compared with.
+	BankReference string
+
+	// MatchRule links a system transaction with a bank statement when all of
+	// its fields produce the same value on both sides. Both identifier fields
+	// are compared with BankStatementUploadFile.UniqueID on the bank side,
+	// unless the rule names another reference.
+	MatchRule struct {
+		Name          string
+		Fields        []MatchField
+		BankReference BankReference
+	}
+)
+
+// ParseMatchRule parses an expression such as "TERMINAL_RRN+AMOUNT+DATE".
+// The identifier may name the bank reference it is compared with, such as
+// "TERMINAL_RRN=END_TO_END_ID+AMOUNT".
+func ParseMatchRule(expr string) (MatchRule, error) {
+	rule := MatchRule{Name: strings.ToUpper(strings.TrimSpace(expr)), BankReference: BankReferenceUniqueID}
+	if rule.Name == "" {
+		return MatchRule{}, ErrorInvalidMatchRule
+	}
+
+	hasIdentifier := false
+	seen := make(map[MatchField]bool)
+	for _, part := range strings.Split(rule.Name, matchRuleFieldSeparator) {
+		part, reference, hasReference := strings.Cut(part, matchRuleReferenceSeparator)
+		field := MatchField(strings.TrimSpace(part))
+		switch field {
+		case MatchFieldTransactionID, MatchFieldTerminalRRN:
+			// Bank side is compared on one reference, both can not be used at once
+			if hasIdentifier {
+				return MatchRule{}, ErrorInvalidMatchRule
+			}
+			hasIdentifier = true
+
+			if hasReference {
+				switch bankReference := BankReference(strings.TrimSpace(reference)); bankReference {
+				case BankReferenceUniqueID, BankReferenceEndToEndID, BankReferenceAccountServicerReference:
+					rule.BankReference = bankReference
+				default:
+					return MatchRule{}, ErrorInvalidMatchRule
+				}
+			}
+		case MatchFieldAmount, MatchFieldDate:
+			if hasReference {
+				return MatchRule{}, ErrorInvalidMatchRule
+			}
+		default:
+			return MatchRule{}, ErrorInvalidMatchRule
+		}
+
+		if seen[field] {
+			return MatchRule{}, ErrorInvalidMatchRule
+		}
+		seen[field] = true
+		rule.Fields = append(rule.Fields, field)
+	}
+
+	return rule, nil
+}
+
+// ParseGroupRules parses the keys used to build settlement groups, such as
+// "TERMINAL_RRN" for a shared reference or "DATE" for a date window. The
+// amount can not be a part of the key, it is what the group is checked on.
+func ParseGroupRules(exprs []string) ([]MatchRule, error) {
+	rules := make([]MatchRule, 0, len(exprs))
+	for _, expr := range exprs {
+		rule, err := ParseMatchRule(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range rule.Fields {
+			if field == MatchFieldAmount {
+				return nil, ErrorInvalidMatchRule
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ParseMatchRules parses rules in priority order, the first rule is tried first.
+func ParseMatchRules(exprs []string) ([]MatchRule, error) {
+	if len(exprs) == 0 {
+		return defaultMatchRules, nil
+	}
+
+	rules := make([]MatchRule, 0, len(exprs))
+	for _, expr := range exprs {
+		rule, err := ParseMatchRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// transactionKey is the value compared by the rule, empty when the
+// identifier of the rule is empty as an unknown reference links nothing.
+func (r MatchRule) transactionKey(tx TransactionUploadFile) string {
+	values := make([]string, 0, len(r.Fields))
+	for _, field := range r.Fields {
+		switch field {
+		case MatchFieldTransactionID:
+			if tx.TransactionID == "" {
+				return ""
+			}
+			values = append(values, tx.TransactionID)
+		case MatchFieldTerminalRRN:
+			if tx.TerminalRRN == "" {
+				return ""
+			}
+			values = append(values, tx.TerminalRRN)
+		case MatchFieldAmount:
+			values = append(values, tx.Amount.String())
+		case MatchFieldDate:
+			values = append(values, tx.TransactionTime.Format(time.DateOnly))
+		}
+	}
+
+	return strings.Join(values, matchKeySeparator)
+}
+
+// bankKey is the value compared by the rule, empty like transactionKey.
+func (r MatchRule) bankKey(b BankStatementUploadFile) string {
+	values := make([]string, 0, len(r.Fields))
+	for _, field := range r.Fields {
+		switch field {
+		case MatchFieldTransactionID, MatchFieldTerminalRRN:
+			reference := b.reference(r.BankReference)
+			if reference == "" {
+				return ""
+			}
+			values = append(values, reference)
+		case MatchFieldAmount:
+			values = append(values, b.Amount.String())
+		case MatchFieldDate:
+			values = append(values, b.Date.Format(time.DateOnly))
+		}
+	}
+
+	return strings.Join(values, matchKeySeparator)
+}
+
+func (b BankStatementUploadFile) reference(reference BankReference) string {
+	switch reference {
+	case BankReferenceEndToEndID:
+		return b.EndToEndID
+	case BankReferenceAccountServicerReference:
+		return b.AccountServicerReference
+	default:
+		return b.UniqueID
+	}
+}
@@ -1,17 +1,51 @@
 package recon
 
 import (
+	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+const (
+	MismatchReasonMissingInBank   MismatchReason = "MISSING_IN_BANK"
+	MismatchReasonMissingInSystem MismatchReason = "MISSING_IN_SYSTEM"
+	MismatchReasonAmountMismatch  MismatchReason = "AMOUNT_MISMATCH"
+	MismatchReasonDateMismatch    MismatchReason = "DATE_MISMATCH"
+	MismatchReasonDuplicateID     MismatchReason = "DUPLICATE_ID"
+	MismatchReasonTypeMismatch    MismatchReason = "TYPE_MISMATCH"
+
+	ExceptionSideSystem ExceptionSide = "SYSTEM"
+	ExceptionSideBank   ExceptionSide = "BANK"
+	ExceptionSideBoth   ExceptionSide = "BOTH"
+)
+
 type (
+	MismatchReason string
+
+	ExceptionSide string
+
 	UploadFile struct {
 		transactionFile []TransactionUploadFile
 		bankFile        []BankStatementUploadFile
 		startDate       time.Time
 		endDate         time.Time
+		duplicatePolicy DuplicatePolicy
+		// systemFromStore loads the system transactions from the transactions
+		// table, transactionFile is then ignored
+		systemFromStore bool
+		// bankCodes are the bank codes of the transactions table reconciled,
+		// the ones found on the bank file when empty
+		bankCodes []string
+		// spill holds the rows of both files on disk instead, the transaction
+		// and bank file are then ignored
+		spill          *Spill
+		systemChecksum string
+		bankChecksum   string
+		progress       Progress
+		validationMode ValidationMode
+		rowErrors      []RowError
 	}
 
 	TransactionUploadFile struct {
@@ -21,30 +55,120 @@ type (
 		TransactionType string          `json:"transaction_type"`
 		BankCode        string          `json:"bank_code"`
 		TransactionTime time.Time       `json:"transaction_time"`
+		// File is the member of an uploaded archive the row was read from
+		File string `json:"file,omitempty"`
 	}
 
 	BankStatementUploadFile struct {
-		UniqueID string          `json:"unique_id"`
-		Amount   decimal.Decimal `json:"amount"`
-		Date     time.Time       `json:"date"`
-		BankCode string          `json:"bank_code"`
+		UniqueID        string          `json:"unique_id"`
+		Amount          decimal.Decimal `json:"amount"`
+		TransactionType string          `json:"transaction_type,omitempty"`
+		Date            time.Time       `json:"date"`
+		BankCode        string          `json:"bank_code"`
+		// The references below are only known for ISO 20022 statements, a
+		// match rule can compare them instead of UniqueID
+		EndToEndID               string    `json:"end_to_end_id,omitempty"`
+		AccountServicerReference string    `json:"account_servicer_reference,omitempty"`
+		Currency                 string    `json:"currency,omitempty"`
+		BookingDate              time.Time `json:"booking_date,omitzero"`
+		// File is the member of an uploaded archive the row was read from
+		File string `json:"file,omitempty"`
 	}
 
 	ResultReconciliation struct {
-		TotalNumberOfTransactions          int                         `json:"total_number_of_transactions"`
-		TotalNumberOfMatchesTransactions   int                         `json:"total_number_of_matches_transactions"`
-		TotalNumberOfUnmatchedTransactions int                         `json:"total_number_of_unmatched_transactions"`
-		ResultReconciliationDetails        ResultReconciliationDetails `json:"result_reconciliation_details"`
-		TotalAmountDiscrepancies           decimal.Decimal             `json:"total_amount_discrepancies"`
-		BankCode                           string                      `json:"bank_code"`
+		TotalNumberOfTransactions               int                         `json:"total_number_of_transactions"`
+		TotalNumberOfMatchesTransactions        int                         `json:"total_number_of_matches_transactions"`
+		TotalNumberOfUnmatchedTransactions      int                         `json:"total_number_of_unmatched_transactions"`
+		TotalNumberOfExactMatches               int                         `json:"total_number_of_exact_matches"`
+		TotalNumberOfMatchesWithinTolerance     int                         `json:"total_number_of_matches_within_tolerance"`
+		TotalNumberOfMismatchedPairs            int                         `json:"total_number_of_mismatched_pairs"`
+		TotalNumberOfGroupMatches               int                         `json:"total_number_of_group_matches"`
+		TotalNumberOfDuplicateRows              int                         `json:"total_number_of_duplicate_rows"`
+		TotalNumberOfMatchesByRule              map[string]int              `json:"total_number_of_matches_by_rule"`
+		TotalNumberOfExceptionsByReason         map[MismatchReason]int      `json:"total_number_of_exceptions_by_reason"`
+		TotalsByTransactionType                 map[string]TypeTotal        `json:"totals_by_transaction_type"`
+		ResultReconciliationDetails             ResultReconciliationDetails `json:"result_reconciliation_details"`
+		TotalAmountDiscrepancies                decimal.Decimal             `json:"total_amount_discrepancies"`
+		TotalAmountDiscrepanciesWithinTolerance decimal.Decimal             `json:"total_amount_discrepancies_within_tolerance"`
+		TotalAmountDiscrepanciesMismatched      decimal.Decimal             `json:"total_amount_discrepancies_mismatched"`
+		BankCode                                string                      `json:"bank_code"`
+	}
+
+	// TypeTotal sums the rows of one direction (DEBIT or CREDIT) on both sides.
+	TypeTotal struct {
+		TotalNumberOfTransactions   int             `json:"total_number_of_transactions"`
+		TotalAmountTransactions     decimal.Decimal `json:"total_amount_transactions"`
+		TotalNumberOfBankStatements int             `json:"total_number_of_bank_statements"`
+		TotalAmountBankStatements   decimal.Decimal `json:"total_amount_bank_statements"`
 	}
 
 	ResultReconciliationDetails struct {
+		TransactionMatched      []MatchedTransaction      `json:"transaction_matched"`
+		GroupMatched            []MatchedGroup            `json:"group_matched"`
 		TransactionMismatched   []TransactionUploadFile   `json:"transaction_mismatched"`
 		BankStatementMismatched []BankStatementUploadFile `json:"bank_statement_mismatched"`
+		Exceptions              []Exception               `json:"exceptions"`
+		Duplicates              []DuplicateGroup          `json:"duplicates"`
+	}
+
+	// DuplicateGroup lists all rows of one side sharing the same id.
+	DuplicateGroup struct {
+		Side           ExceptionSide             `json:"side"`
+		ID             string                    `json:"id"`
+		Transactions   []TransactionUploadFile   `json:"transactions,omitempty"`
+		BankStatements []BankStatementUploadFile `json:"bank_statements,omitempty"`
+	}
+
+	// Exception explains why a row was not reconciled. A pair found by a rule
+	// but out of tolerance carries both records and the signed difference
+	// (system amount minus bank amount).
+	Exception struct {
+		Reason           MismatchReason           `json:"reason"`
+		Side             ExceptionSide            `json:"side"`
+		Rule             string                   `json:"rule,omitempty"`
+		Transaction      *TransactionUploadFile   `json:"transaction,omitempty"`
+		BankStatement    *BankStatementUploadFile `json:"bank_statement,omitempty"`
+		AmountDifference *decimal.Decimal         `json:"amount_difference,omitempty"`
+	}
+
+	MatchedTransaction struct {
+		Rule             string                  `json:"rule"`
+		Class            MatchClass              `json:"class"`
+		AmountDifference decimal.Decimal         `json:"amount_difference"`
+		Transaction      TransactionUploadFile   `json:"transaction"`
+		BankStatement    BankStatementUploadFile `json:"bank_statement"`
+	}
+
+	// MatchedGroup is a settlement where several transactions are paid by one
+	// bank line, or one transaction is paid by several bank lines.
+	MatchedGroup struct {
+		Rule             string                    `json:"rule"`
+		Class            MatchClass                `json:"class"`
+		AmountDifference decimal.Decimal           `json:"amount_difference"`
+		Transactions     []TransactionUploadFile   `json:"transactions"`
+		BankStatements   []BankStatementUploadFile `json:"bank_statements"`
 	}
 
 	ShowResultReconciliation struct {
+		RunID                string                 `json:"run_id,omitempty"`
+		ResultReconciliation []ResultReconciliation `json:"result_reconciliation"`
+		Validation           *ValidationReport      `json:"validation,omitempty"`
+		// Duplicates are the duplicate rows refused by DuplicatePolicyReject
+		Duplicates []DuplicateGroup `json:"duplicates,omitempty"`
+	}
+
+	// ShowRun is a stored run, the details only carry its exceptions.
+	ShowRun struct {
+		RunID                string                 `json:"run_id"`
+		SystemSource         string                 `json:"system_source"`
+		SystemChecksum       string                 `json:"system_checksum"`
+		BankChecksum         string                 `json:"bank_checksum"`
+		StartDate            time.Time              `json:"start_date"`
+		EndDate              time.Time              `json:"end_date"`
+		ConfigSnapshot       json.RawMessage        `json:"config_snapshot"`
+		StartedAt            time.Time              `json:"started_at"`
+		FinishedAt           time.Time              `json:"finished_at"`
+		DurationMillis       int64                  `json:"duration_millis"`
 		ResultReconciliation []ResultReconciliation `json:"result_reconciliation"`
 	}
 )
@@ -58,5 +182,102 @@ func NewUploadFile(
 		bankFile:        bankFile,
 		startDate:       startDate,
 		endDate:         endDate,
+		duplicatePolicy: DuplicatePolicyFlag,
+		progress:        NoProgress,
+		validationMode:  ValidationModeReject,
 	}
 }
+
+// NewBankUploadFile is an upload with the bank file only. The system
+// transactions are loaded from the transactions table for the date range and
+// the bank codes found on the bank file.
+func NewBankUploadFile(
+	bankFile []BankStatementUploadFile,
+	startDate, endDate time.Time) *UploadFile {
+	return &UploadFile{
+		bankFile:        bankFile,
+		startDate:       startDate,
+		endDate:         endDate,
+		duplicatePolicy: DuplicatePolicyFlag,
+		systemFromStore: true,
+		progress:        NoProgress,
+		validationMode:  ValidationModeReject,
+	}
+}
+
+// NewSpillUploadFile is an upload read into spill while it was parsed. It is
+// reconciled a partition at a time rather than in memory, so max.rows.* do
+// not bound it.
+func NewSpillUploadFile(spill *Spill, startDate, endDate time.Time) *UploadFile {
+	file := NewUploadFile(nil, nil, startDate, endDate)
+	file.spill = spill
+	return file
+}
+
+// NewSpillBankUploadFile is NewBankUploadFile for a bank file read into
+// spill. The system transactions are loaded into it before reconciling.
+func NewSpillBankUploadFile(spill *Spill, startDate, endDate time.Time) *UploadFile {
+	file := NewBankUploadFile(nil, startDate, endDate)
+	file.spill = spill
+	return file
+}
+
+// Close removes the spill files of the upload, if any.
+func (u *UploadFile) Close() error {
+	if u.spill == nil {
+		return nil
+	}
+
+	return u.spill.Close()
+}
+
+// WithChecksums keeps the sha256 of the uploaded files, stored with the run.
+func (u *UploadFile) WithChecksums(systemChecksum, bankChecksum string) *UploadFile {
+	u.systemChecksum = systemChecksum
+	u.bankChecksum = bankChecksum
+	return u
+}
+
+// WithRowErrors sets the invalid rows left out of the files, the mode decides
+// whether they fail the reconciliation or are only reported.
+func (u *UploadFile) WithRowErrors(mode ValidationMode, rowErrors []RowError) *UploadFile {
+	u.validationMode = mode
+	u.rowErrors = rowErrors
+	return u
+}
+
+// WithProgress reports the banks done to progress while reconciling.
+func (u *UploadFile) WithProgress(progress Progress) *UploadFile {
+	u.progress = progress
+	return u
+}
+
+// WithBankCodes reconciles the system transactions of bankCodes, even the
+// ones of a bank code without any row on the bank file. A bank file with
+// rows of another bank code is then refused.
+func (u *UploadFile) WithBankCodes(bankCodes []string) *UploadFile {
+	u.bankCodes = bankCodes
+	return u
+}
+
+// storeBankCodes are the bank codes whose system transactions are loaded
+// for a bank file with rows of found.
+func (u *UploadFile) storeBankCodes(found []string) ([]string, error) {
+	if len(u.bankCodes) == 0 {
+		return found, nil
+	}
+
+	for _, code := range found {
+		if !slices.Contains(u.bankCodes, code) {
+			return nil, ErrorUnexpectedBankCode
+		}
+	}
+
+	return u.bankCodes, nil
+}
+
+// WithDuplicatePolicy sets what happens when a file has duplicate rows.
+func (u *UploadFile) WithDuplicatePolicy(policy DuplicatePolicy) *UploadFile {
+	u.duplicatePolicy = policy
+	return u
+}
@@ -0,0 +1,62 @@
+package recon
+
+import (
+	"hash/fnv"
+)
+
+// partitionOf maps a match key onto one of n partitions. The same key always
+// lands in the same partition, so a transaction and its bank line are
+// reconciled by the same routine no matter where they sit in the files.
+func partitionOf(key string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// splitPartitionOf maps a key onto one of n partitions of a partition hashed
+// again, at depth. Whatever is added to two keys, FNV keeps their low bits
+// alike, so the hash is mixed with depth to spread the keys which shared a
+// partition.
+func splitPartitionOf(key string, depth, n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	// The finalizer of MurmurHash3
+	x := h.Sum64() ^ uint64(depth)*0x9e3779b97f4a7c15
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb93fe1a85ec3
+	x ^= x >> 33
+	return int(x % uint64(n))
+}
+
+// partitionTransactions returns, per partition, the positions in txs that
+// belong to it. Positions keep their input order inside each partition.
+func partitionTransactions(txs []TransactionUploadFile, n int, key func(TransactionUploadFile) string) [][]int {
+	partitions := make([][]int, n)
+	for i, tx := range txs {
+		idx := partitionOf(key(tx), n)
+		partitions[idx] = append(partitions[idx], i)
+	}
+
+	return partitions
+}
+
+func partitionBankStatements(banks []BankStatementUploadFile, n int, key func(BankStatementUploadFile) string) [][]int {
+	partitions := make([][]int, n)
+	for i, b := range banks {
+		idx := partitionOf(key(b), n)
+		partitions[idx] = append(partitions[idx], i)
+	}
+
+	return partitions
+}
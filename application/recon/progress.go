@@ -0,0 +1,23 @@
+package recon
+
+var (
+	// NoProgress is used when nobody follows the progress of a reconciliation.
+	NoProgress Progress = noProgress{}
+)
+
+type (
+	// Progress receives how far a reconciliation is, it is called concurrently.
+	Progress interface {
+		AddRowsParsed(rows int)
+		SetBanksTotal(banks int)
+		AddBanksDone(banks int)
+	}
+
+	noProgress struct{}
+)
+
+func (noProgress) AddRowsParsed(int) {}
+
+func (noProgress) SetBanksTotal(int) {}
+
+func (noProgress) AddBanksDone(int) {}
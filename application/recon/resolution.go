@@ -0,0 +1,291 @@
+package recon
+
+import (
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ExceptionStatusOpen          ExceptionStatus = "OPEN"
+	ExceptionStatusInvestigating ExceptionStatus = "INVESTIGATING"
+	ExceptionStatusResolved      ExceptionStatus = "RESOLVED"
+	ExceptionStatusWrittenOff    ExceptionStatus = "WRITTEN_OFF"
+)
+
+var (
+	ErrorExceptionNotFound         = errors.New("pengecualian tidak ditemukan")
+	ErrorInvalidExceptionStatus    = errors.New("status pengecualian tidak dikenal")
+	ErrorInvalidStatusTransition   = errors.New("status pengecualian tidak dapat diubah ke status tersebut")
+	ErrorExceptionChanged          = errors.New("pengecualian sudah diubah oleh pengguna lain")
+	ErrorIncompleteExceptionUpdate = errors.New("data perubahan pengecualian tidak lengkap")
+
+	// statusTransitions are the statuses an exception can move to. A closed
+	// exception is reopened before it is worked on again.
+	statusTransitions = map[ExceptionStatus][]ExceptionStatus{
+		ExceptionStatusOpen:          {ExceptionStatusInvestigating, ExceptionStatusResolved, ExceptionStatusWrittenOff},
+		ExceptionStatusInvestigating: {ExceptionStatusOpen, ExceptionStatusResolved, ExceptionStatusWrittenOff},
+		ExceptionStatusResolved:      {ExceptionStatusOpen},
+		ExceptionStatusWrittenOff:    {ExceptionStatusOpen},
+	}
+)
+
+type (
+	// ExceptionStatus is where the follow up of a stored exception is.
+	ExceptionStatus string
+
+	// ExceptionUpdate moves an exception to Status, the current one when
+	// empty, and hands it to Assignee when set. Every update is written on the
+	// comment thread by Author, with Comment as its body.
+	ExceptionUpdate struct {
+		ID               uint64
+		Status           string
+		Assignee         *string
+		ResolutionReason string
+		Author           string
+		Comment          string
+	}
+
+	Comment struct {
+		ID        uint64          `json:"id"`
+		Author    string          `json:"author"`
+		Status    ExceptionStatus `json:"status"`
+		Body      string          `json:"body"`
+		CreatedAt time.Time       `json:"created_at"`
+	}
+
+	// ExceptionDetail is a stored exception with the comment thread of its
+	// pair, across every run it was found in.
+	ExceptionDetail struct {
+		RunException
+		Comments []Comment `json:"comments"`
+	}
+)
+
+// ParseExceptionStatus parses an exception status, case insensitive.
+func ParseExceptionStatus(value string) (ExceptionStatus, error) {
+	status := ExceptionStatus(strings.ToUpper(strings.TrimSpace(value)))
+	if _, ok := statusTransitions[status]; !ok {
+		return "", ErrorInvalidExceptionStatus
+	}
+
+	return status, nil
+}
+
+// closed is whether the exception needs no follow up, it then has a
+// resolution reason.
+func (e ExceptionStatus) closed() bool {
+	return e == ExceptionStatusResolved || e == ExceptionStatusWrittenOff
+}
+
+// FindException returns a stored exception with its comment thread.
+func (s *service) FindException(ctx context.Context, id uint64) (ExceptionDetail, error) {
+	stored, err := s.findStoredException(ctx, id)
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	return s.exceptionDetail(ctx, stored)
+}
+
+// UpdateException moves an exception along its lifecycle, see
+// statusTransitions, and records the change on its thread.
+func (s *service) UpdateException(ctx context.Context, update ExceptionUpdate) (ExceptionDetail, error) {
+	author := strings.TrimSpace(update.Author)
+	if author == "" {
+		return ExceptionDetail{}, ErrorIncompleteExceptionUpdate
+	}
+
+	stored, err := s.findStoredException(ctx, update.ID)
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	current := ExceptionStatus(stored.Status)
+	target := current
+	if strings.TrimSpace(update.Status) != "" {
+		if target, err = ParseExceptionStatus(update.Status); err != nil {
+			return ExceptionDetail{}, err
+		}
+	}
+
+	if target != current && !slices.Contains(statusTransitions[current], target) {
+		return ExceptionDetail{}, ErrorInvalidStatusTransition
+	}
+
+	comment := strings.TrimSpace(update.Comment)
+	if target == current && update.Assignee == nil && comment == "" && update.ResolutionReason == "" {
+		return ExceptionDetail{}, ErrorIncompleteExceptionUpdate
+	}
+
+	// A closed exception keeps its reason until it is reopened
+	reason := strings.TrimSpace(update.ResolutionReason)
+	switch {
+	case !target.closed():
+		stored.ResolutionReason = ""
+	case reason != "":
+		stored.ResolutionReason = reason
+	case target != current:
+		return ExceptionDetail{}, ErrorIncompleteExceptionUpdate
+	}
+
+	if update.Assignee != nil {
+		stored.Assignee = strings.TrimSpace(*update.Assignee)
+	}
+
+	now := s.generate.Time()
+	stored.Status = string(target)
+	stored.StatusUpdatedAt = sql.NullTime{Time: now, Valid: true}
+
+	err = s.runRepository.UpdateExceptionStatus(ctx, stored, string(current), &reconciliation.Comment{
+		ExceptionID: stored.ID,
+		Author:      author,
+		Status:      stored.Status,
+		Body:        comment,
+		CreatedAt:   now,
+	})
+	if errors.Is(err, reconciliation.ErrorExceptionChanged) {
+		return ExceptionDetail{}, ErrorExceptionChanged
+	}
+
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	return s.exceptionDetail(ctx, stored)
+}
+
+// CommentException adds a comment to the thread of an exception, whatever
+// its status.
+func (s *service) CommentException(ctx context.Context, id uint64, author, body string) (ExceptionDetail, error) {
+	author, body = strings.TrimSpace(author), strings.TrimSpace(body)
+	if author == "" || body == "" {
+		return ExceptionDetail{}, ErrorIncompleteExceptionUpdate
+	}
+
+	stored, err := s.findStoredException(ctx, id)
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	err = s.runRepository.SaveComment(ctx, &reconciliation.Comment{
+		ExceptionID: stored.ID,
+		Author:      author,
+		Status:      stored.Status,
+		Body:        body,
+		CreatedAt:   s.generate.Time(),
+	})
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	return s.exceptionDetail(ctx, stored)
+}
+
+func (s *service) findStoredException(ctx context.Context, id uint64) (*reconciliation.Exception, error) {
+	if s.runRepository == nil {
+		return nil, ErrorExceptionNotFound
+	}
+
+	stored, err := s.runRepository.FindExceptionByID(ctx, id)
+	if errors.Is(err, reconciliation.ErrorExceptionNotFound) {
+		return nil, ErrorExceptionNotFound
+	}
+
+	return stored, err
+}
+
+func (s *service) exceptionDetail(ctx context.Context, stored *reconciliation.Exception) (ExceptionDetail, error) {
+	originID := stored.ID
+	if stored.OriginID.Valid {
+		originID = uint64(stored.OriginID.Int64)
+	}
+
+	comments, err := s.runRepository.FindComments(ctx, originID)
+	if err != nil {
+		return ExceptionDetail{}, err
+	}
+
+	detail := ExceptionDetail{
+		RunException: toRunException(stored),
+		Comments:     []Comment{},
+	}
+
+	for _, comment := range comments {
+		detail.Comments = append(detail.Comments, Comment{
+			ID:        comment.ID,
+			Author:    comment.Author,
+			Status:    ExceptionStatus(comment.Status),
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	return detail, nil
+}
+
+// carryStatuses gives the exceptions of a run what was last decided on the
+// same pair in an earlier run, so a resolved exception does not come back as
+// a new one. They join the comment thread of the pair.
+func (s *service) carryStatuses(ctx context.Context, exceptions []*reconciliation.Exception) error {
+	var fingerprints []string
+	seen := make(map[string]bool)
+	for _, exception := range exceptions {
+		if !seen[exception.Fingerprint] {
+			seen[exception.Fingerprint] = true
+			fingerprints = append(fingerprints, exception.Fingerprint)
+		}
+	}
+
+	if len(fingerprints) == 0 {
+		return nil
+	}
+
+	latest, err := s.runRepository.FindLatestExceptions(ctx, fingerprints)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]*reconciliation.Exception, len(latest))
+	for _, exception := range latest {
+		previous[exception.Fingerprint] = exception
+	}
+
+	for _, exception := range exceptions {
+		last, ok := previous[exception.Fingerprint]
+		if !ok {
+			continue
+		}
+
+		exception.Status = last.Status
+		exception.Assignee = last.Assignee
+		exception.ResolutionReason = last.ResolutionReason
+		exception.StatusUpdatedAt = last.StatusUpdatedAt
+		exception.OriginID = last.OriginID
+		if !exception.OriginID.Valid {
+			exception.OriginID = sql.NullInt64{Int64: int64(last.ID), Valid: true}
+		}
+	}
+
+	return nil
+}
+
+// fingerprint identifies the pair of an exception across runs, the same as
+// the backfill of the migration adding it.
+func fingerprint(stored *reconciliation.Exception) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		stored.BankCode,
+		stored.Reason,
+		stored.Side,
+		stored.TransactionID,
+		stored.UniqueID,
+	}, "|")))
+
+	return hex.EncodeToString(sum[:])
+}
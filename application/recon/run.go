@@ -0,0 +1,339 @@
+package recon
+
+import (
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	SystemSourceFile     = "FILE"
+	SystemSourceDatabase = "DATABASE"
+)
+
+type (
+	// configSnapshot is the configuration a run was reconciled with, stored
+	// with the run so it can be explained after the configuration changes.
+	configSnapshot struct {
+		MaxRowsTransactions int                           `json:"max_rows_transactions"`
+		MaxRowsBank         int                           `json:"max_rows_bank"`
+		MaxChunk            int                           `json:"max_chunk"`
+		SpillPartitionRows  int                           `json:"spill_partition_rows,omitempty"`
+		SpillOmitMatched    bool                          `json:"spill_omit_matched,omitempty"`
+		DuplicatePolicy     DuplicatePolicy               `json:"duplicate_policy"`
+		Banks               map[string]bankPolicySnapshot `json:"banks"`
+	}
+
+	bankPolicySnapshot struct {
+		MatchRules      []string       `json:"match_rules"`
+		GroupRules      []string       `json:"group_rules"`
+		AmountTolerance string         `json:"amount_tolerance"`
+		TimeTolerance   string         `json:"time_tolerance"`
+		SignConvention  SignConvention `json:"sign_convention"`
+	}
+
+	// runBreakdown is stored as JSON on every bank summary of a run.
+	runBreakdown struct {
+		MatchesByRule           map[string]int         `json:"matches_by_rule"`
+		ExceptionsByReason      map[MismatchReason]int `json:"exceptions_by_reason"`
+		TotalsByTransactionType map[string]TypeTotal   `json:"totals_by_transaction_type"`
+	}
+)
+
+func (u *UploadFile) systemSource() string {
+	if u.systemFromStore {
+		return SystemSourceDatabase
+	}
+
+	return SystemSourceFile
+}
+
+func newConfigSnapshot(
+	file *UploadFile,
+	policies map[string]bankPolicy,
+	maxRowsTransaction, maxRowsBank, maxChunk int) configSnapshot {
+	snapshot := configSnapshot{
+		MaxRowsTransactions: maxRowsTransaction,
+		MaxRowsBank:         maxRowsBank,
+		MaxChunk:            maxChunk,
+		DuplicatePolicy:     file.duplicatePolicy,
+		Banks:               make(map[string]bankPolicySnapshot),
+	}
+
+	for code, policy := range policies {
+		bank := bankPolicySnapshot{
+			MatchRules:     ruleNames(policy.rules),
+			GroupRules:     ruleNames(policy.groupRules),
+			SignConvention: policy.sign,
+		}
+
+		bank.AmountTolerance = policy.tolerance.Amount.String()
+		if policy.tolerance.AmountPercent {
+			bank.AmountTolerance += percentSuffix
+		}
+
+		if policy.tolerance.CheckTime {
+			bank.TimeTolerance = policy.tolerance.TimeDrift.String()
+		}
+
+		snapshot.Banks[code] = bank
+	}
+
+	return snapshot
+}
+
+func ruleNames(rules []MatchRule) []string {
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+
+	return names
+}
+
+// saveRun stores the run with a summary per bank code and all of its
+// exceptions, and returns the id of the run.
+func (s *service) saveRun(
+	ctx context.Context,
+	file *UploadFile,
+	result ShowResultReconciliation,
+	snapshot configSnapshot,
+	startedAt time.Time) (string, error) {
+	rawSnapshot, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	finishedAt := s.generate.Time()
+	run := &reconciliation.Run{
+		ID:             s.generate.UUID(),
+		SystemSource:   file.systemSource(),
+		SystemChecksum: file.systemChecksum,
+		BankChecksum:   file.bankChecksum,
+		StartDate:      file.startDate,
+		EndDate:        file.endDate,
+		ConfigSnapshot: string(rawSnapshot),
+		StartedAt:      startedAt,
+		FinishedAt:     finishedAt,
+		DurationMillis: finishedAt.Sub(startedAt).Milliseconds(),
+	}
+
+	var summaries []*reconciliation.BankSummary
+	var exceptions []*reconciliation.Exception
+	for _, rr := range result.ResultReconciliation {
+		breakdown, err := json.Marshal(runBreakdown{
+			MatchesByRule:           rr.TotalNumberOfMatchesByRule,
+			ExceptionsByReason:      rr.TotalNumberOfExceptionsByReason,
+			TotalsByTransactionType: rr.TotalsByTransactionType,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		summaries = append(summaries, &reconciliation.BankSummary{
+			RunID:                                   run.ID,
+			BankCode:                                rr.BankCode,
+			TotalNumberOfTransactions:               rr.TotalNumberOfTransactions,
+			TotalNumberOfMatchesTransactions:        rr.TotalNumberOfMatchesTransactions,
+			TotalNumberOfUnmatchedTransactions:      rr.TotalNumberOfUnmatchedTransactions,
+			TotalNumberOfExactMatches:               rr.TotalNumberOfExactMatches,
+			TotalNumberOfMatchesWithinTolerance:     rr.TotalNumberOfMatchesWithinTolerance,
+			TotalNumberOfMismatchedPairs:            rr.TotalNumberOfMismatchedPairs,
+			TotalNumberOfGroupMatches:               rr.TotalNumberOfGroupMatches,
+			TotalNumberOfDuplicateRows:              rr.TotalNumberOfDuplicateRows,
+			TotalAmountDiscrepancies:                rr.TotalAmountDiscrepancies,
+			TotalAmountDiscrepanciesWithinTolerance: rr.TotalAmountDiscrepanciesWithinTolerance,
+			TotalAmountDiscrepanciesMismatched:      rr.TotalAmountDiscrepanciesMismatched,
+			Breakdown:                               string(breakdown),
+		})
+
+		for _, exception := range rr.ResultReconciliationDetails.Exceptions {
+			exceptions = append(exceptions, toStoredException(run.ID, rr.BankCode, exception))
+		}
+	}
+
+	if err := s.carryStatuses(ctx, exceptions); err != nil {
+		return "", err
+	}
+
+	if err := s.runRepository.SaveRun(ctx, run, summaries, exceptions); err != nil {
+		return "", err
+	}
+
+	return run.ID, nil
+}
+
+// FindRun returns a stored run with the summary and exceptions per bank code.
+// Matched pairs and groups are not stored, only what needs a follow up.
+func (s *service) FindRun(ctx context.Context, id string) (ShowRun, error) {
+	if s.runRepository == nil {
+		return ShowRun{}, ErrorRunNotFound
+	}
+
+	run, err := s.runRepository.FindRunByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, reconciliation.ErrorRunNotFound) {
+			return ShowRun{}, ErrorRunNotFound
+		}
+		return ShowRun{}, err
+	}
+
+	summaries, err := s.runRepository.FindBankSummaries(ctx, id)
+	if err != nil {
+		return ShowRun{}, err
+	}
+
+	exceptions, err := s.runRepository.FindExceptions(ctx, id)
+	if err != nil {
+		return ShowRun{}, err
+	}
+
+	results := make(map[string]*ResultReconciliation)
+	showRun := ShowRun{
+		RunID:          run.ID,
+		SystemSource:   run.SystemSource,
+		SystemChecksum: run.SystemChecksum,
+		BankChecksum:   run.BankChecksum,
+		StartDate:      run.StartDate,
+		EndDate:        run.EndDate,
+		ConfigSnapshot: json.RawMessage(run.ConfigSnapshot),
+		StartedAt:      run.StartedAt,
+		FinishedAt:     run.FinishedAt,
+		DurationMillis: run.DurationMillis,
+	}
+
+	for _, summary := range summaries {
+		rr := newResultReconciliation(summary.BankCode)
+		rr.TotalNumberOfTransactions = summary.TotalNumberOfTransactions
+		rr.TotalNumberOfMatchesTransactions = summary.TotalNumberOfMatchesTransactions
+		rr.TotalNumberOfUnmatchedTransactions = summary.TotalNumberOfUnmatchedTransactions
+		rr.TotalNumberOfExactMatches = summary.TotalNumberOfExactMatches
+		rr.TotalNumberOfMatchesWithinTolerance = summary.TotalNumberOfMatchesWithinTolerance
+		rr.TotalNumberOfMismatchedPairs = summary.TotalNumberOfMismatchedPairs
+		rr.TotalNumberOfGroupMatches = summary.TotalNumberOfGroupMatches
+		rr.TotalNumberOfDuplicateRows = summary.TotalNumberOfDuplicateRows
+		rr.TotalAmountDiscrepancies = summary.TotalAmountDiscrepancies
+		rr.TotalAmountDiscrepanciesWithinTolerance = summary.TotalAmountDiscrepanciesWithinTolerance
+		rr.TotalAmountDiscrepanciesMismatched = summary.TotalAmountDiscrepanciesMismatched
+
+		var breakdown runBreakdown
+		if err := json.Unmarshal([]byte(summary.Breakdown), &breakdown); err != nil {
+			return ShowRun{}, err
+		}
+
+		if breakdown.MatchesByRule != nil {
+			rr.TotalNumberOfMatchesByRule = breakdown.MatchesByRule
+		}
+
+		if breakdown.ExceptionsByReason != nil {
+			rr.TotalNumberOfExceptionsByReason = breakdown.ExceptionsByReason
+		}
+
+		if breakdown.TotalsByTransactionType != nil {
+			rr.TotalsByTransactionType = breakdown.TotalsByTransactionType
+		}
+
+		results[summary.BankCode] = &rr
+	}
+
+	for _, stored := range exceptions {
+		rr, ok := results[stored.BankCode]
+		if !ok {
+			continue
+		}
+
+		exception := fromStoredException(stored)
+		details := &rr.ResultReconciliationDetails
+		details.Exceptions = append(details.Exceptions, exception)
+
+		if exception.Transaction != nil {
+			details.TransactionMismatched = append(details.TransactionMismatched, *exception.Transaction)
+		} else if exception.BankStatement != nil {
+			details.BankStatementMismatched = append(details.BankStatementMismatched, *exception.BankStatement)
+		}
+	}
+
+	for _, summary := range summaries {
+		showRun.ResultReconciliation = append(showRun.ResultReconciliation, *results[summary.BankCode])
+	}
+
+	return showRun, nil
+}
+
+func toStoredException(runID, bankCode string, exception Exception) *reconciliation.Exception {
+	stored := &reconciliation.Exception{
+		RunID:    runID,
+		BankCode: bankCode,
+		Reason:   string(exception.Reason),
+		Side:     string(exception.Side),
+		Rule:     exception.Rule,
+	}
+
+	if tx := exception.Transaction; tx != nil {
+		stored.TransactionID = tx.TransactionID
+		stored.TerminalRRN = tx.TerminalRRN
+		stored.SystemAmount = decimal.NewNullDecimal(tx.Amount)
+		stored.TransactionType = tx.TransactionType
+		stored.TransactionTime = sql.NullTime{Time: tx.TransactionTime, Valid: true}
+		stored.TransactionFile = tx.File
+	}
+
+	if b := exception.BankStatement; b != nil {
+		stored.UniqueID = b.UniqueID
+		stored.BankAmount = decimal.NewNullDecimal(b.Amount)
+		stored.BankTransactionType = b.TransactionType
+		stored.BankDate = sql.NullTime{Time: b.Date, Valid: true}
+		stored.BankFile = b.File
+	}
+
+	if exception.AmountDifference != nil {
+		stored.AmountDifference = decimal.NewNullDecimal(*exception.AmountDifference)
+	}
+
+	stored.Fingerprint = fingerprint(stored)
+	stored.Status = string(ExceptionStatusOpen)
+	return stored
+}
+
+func fromStoredException(stored *reconciliation.Exception) Exception {
+	exception := Exception{
+		Reason: MismatchReason(stored.Reason),
+		Side:   ExceptionSide(stored.Side),
+		Rule:   stored.Rule,
+	}
+
+	if exception.Side == ExceptionSideSystem || exception.Side == ExceptionSideBoth {
+		exception.Transaction = &TransactionUploadFile{
+			TransactionID:   stored.TransactionID,
+			TerminalRRN:     stored.TerminalRRN,
+			Amount:          stored.SystemAmount.Decimal,
+			TransactionType: stored.TransactionType,
+			BankCode:        stored.BankCode,
+			TransactionTime: stored.TransactionTime.Time,
+			File:            stored.TransactionFile,
+		}
+	}
+
+	if exception.Side == ExceptionSideBank || exception.Side == ExceptionSideBoth {
+		exception.BankStatement = &BankStatementUploadFile{
+			UniqueID:        stored.UniqueID,
+			Amount:          stored.BankAmount.Decimal,
+			TransactionType: stored.BankTransactionType,
+			Date:            stored.BankDate.Time,
+			BankCode:        stored.BankCode,
+			File:            stored.BankFile,
+		}
+	}
+
+	if stored.AmountDifference.Valid {
+		difference := stored.AmountDifference.Decimal
+		exception.AmountDifference = &difference
+	}
+
+	return exception
+}
@@ -1,42 +1,124 @@
 package recon
 
 import (
+	"amartha-recon-service/common"
 	"amartha-recon-service/configuration"
+	"amartha-recon-service/infrastructure/repository/reconciliation"
 	"amartha-recon-service/infrastructure/repository/transaction"
+	"cmp"
 	"context"
 	"errors"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 var (
-	ErrorMaxRows = errors.New("file yang diupload terlalu besar")
+	ErrorMaxRows          = errors.New("file yang diupload terlalu besar")
+	ErrorInvalidMatchRule = errors.New("aturan pencocokan tidak dikenal")
+	ErrorInvalidTolerance = errors.New("toleransi tidak valid")
+	ErrorDuplicateRows    = errors.New("file yang diupload memiliki data duplikat")
+	ErrorRunNotFound      = errors.New("hasil rekonsiliasi tidak ditemukan")
+
+	ErrorInvalidDuplicatePolicy = errors.New("kebijakan data duplikat tidak dikenal")
+	ErrorInvalidSignConvention  = errors.New("konvensi tanda debit/kredit tidak dikenal")
+	ErrorInvalidValidationMode  = errors.New("mode validasi tidak dikenal")
+	ErrorInvalidRows            = errors.New("file yang diupload memiliki baris tidak valid")
+	ErrorUnexpectedBankCode     = errors.New("file bank memiliki kode bank yang tidak direkonsiliasi")
 )
 
 type (
 	service struct {
-		cfg        configuration.Configuration
-		repository transaction.Repository
+		cfg           configuration.Configuration
+		repository    transaction.Repository
+		runRepository reconciliation.Repository
+		generate      common.Generate
 	}
 
 	Service interface {
 		Proceed(ctx context.Context, file *UploadFile) (ShowResultReconciliation, error)
+		FindRun(ctx context.Context, id string) (ShowRun, error)
+		FindExceptions(ctx context.Context, query ExceptionQuery) (ExceptionPage, error)
+		FindException(ctx context.Context, id uint64) (ExceptionDetail, error)
+		UpdateException(ctx context.Context, update ExceptionUpdate) (ExceptionDetail, error)
+		CommentException(ctx context.Context, id uint64, author, body string) (ExceptionDetail, error)
+	}
+
+	// bankPolicy is how the transactions of one bank code are paired and compared
+	bankPolicy struct {
+		rules      []MatchRule
+		groupRules []MatchRule
+		tolerance  Tolerance
+		sign       SignConvention
+	}
+
+	// chunkOutcome is what a single routine produces for one partition of one
+	// match rule. Leftovers are positions of the rows it could not pair.
+	chunkOutcome struct {
+		result   ResultReconciliation
+		txLeft   []int
+		bankLeft []int
+	}
+
+	// round pairs the given positions of txs and banks by the key of rule.
+	round struct {
+		rule  MatchRule
+		match func(txs []TransactionUploadFile, txIdx []int, banks []BankStatementUploadFile, bankIdx []int) chunkOutcome
 	}
 )
 
+// NewService builds the recon service. Without runRepository the runs are
+// not stored, which is what the offline tools want.
 func NewService(
 	cfg configuration.Configuration,
-	repository transaction.Repository) Service {
+	repository transaction.Repository,
+	runRepository reconciliation.Repository,
+	generate common.Generate) Service {
 	return &service{
-		cfg:        cfg,
-		repository: repository,
+		cfg:           cfg,
+		repository:    repository,
+		runRepository: runRepository,
+		generate:      generate,
 	}
 }
 
 func (s *service) Proceed(ctx context.Context, file *UploadFile) (ShowResultReconciliation, error) {
-	lengthTransaction := len(file.transactionFile)
+	startedAt := s.generate.Time()
+
+	// The report is handed back with the error, it is what the uploader has to fix
+	var validation *ValidationReport
+	if len(file.rowErrors) > 0 {
+		validation = newValidationReport(file.validationMode, file.rowErrors)
+		if file.validationMode != ValidationModeSkip {
+			return ShowResultReconciliation{Validation: validation}, ErrorInvalidRows
+		}
+	}
+
+	if file.spill != nil {
+		return s.proceedSpill(ctx, file, validation, startedAt)
+	}
+
 	maxRowsTransaction := int(s.cfg.GetInt("max.rows.transactions"))
 
+	transactionFile := file.transactionFile
+	if file.systemFromStore {
+		bankCodes, err := file.storeBankCodes(bankCodesOf(file.bankFile))
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+
+		transactions, err := s.findTransactions(ctx, file, bankCodes, maxRowsTransaction)
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+		transactionFile = transactions
+	}
+
+	lengthTransaction := len(transactionFile)
 	if lengthTransaction > maxRowsTransaction {
 		return ShowResultReconciliation{}, ErrorMaxRows
 	}
@@ -48,14 +130,9 @@ func (s *service) Proceed(ctx context.Context, file *UploadFile) (ShowResultReco
 		return ShowResultReconciliation{}, ErrorMaxRows
 	}
 
-	maxLen := lengthTransaction
-	if lengthBank > maxLen {
-		maxLen = lengthBank
-	}
-
 	// 1. Group transactions and bank statements by BankCode
 	transactionsByBank := make(map[string][]TransactionUploadFile)
-	for _, tx := range file.transactionFile {
+	for _, tx := range transactionFile {
 		transactionsByBank[tx.BankCode] = append(transactionsByBank[tx.BankCode], tx)
 	}
 
@@ -64,69 +141,68 @@ func (s *service) Proceed(ctx context.Context, file *UploadFile) (ShowResultReco
 		bankByBank[b.BankCode] = append(bankByBank[b.BankCode], b)
 	}
 
-	// 2. Identify all unique bank codes
-	uniqueBanks := make(map[string]struct{})
+	// 2. Identify all unique bank codes, and the policy of each of them
+	uniqueBanks := make(map[string]bankPolicy)
 	for code := range transactionsByBank {
-		uniqueBanks[code] = struct{}{}
+		uniqueBanks[code] = bankPolicy{}
 	}
 
 	for code := range bankByBank {
-		uniqueBanks[code] = struct{}{}
+		uniqueBanks[code] = bankPolicy{}
 	}
 
-	// 3. Create a channel to collect results and use a WaitGroup to manage goroutines
-	var wg sync.WaitGroup
-	maxChunk := int(s.cfg.GetInt("max.chunk"))
-	resultsChan := make(chan ResultReconciliation)
-
-	for bankCode := range uniqueBanks {
-		txs := transactionsByBank[bankCode]
-		banks := bankByBank[bankCode]
-
-		// Determine the largest slice to calculate chunk size
-		maxLen := len(txs)
-		if len(banks) > maxLen {
-			maxLen = len(banks)
+	for code := range uniqueBanks {
+		policy, err := s.bankPolicy(code)
+		if err != nil {
+			return ShowResultReconciliation{}, err
 		}
+		uniqueBanks[code] = policy
+	}
 
-		if maxLen == 0 {
-			continue
+	// 3. Normalize the direction of both sides, following the bank convention
+	for code, policy := range uniqueBanks {
+		for i, tx := range transactionsByBank[code] {
+			transactionsByBank[code][i] = normalizeTransaction(tx)
 		}
 
-		// Calculate how many rows per chunk
-		chunkSize := (maxLen + maxChunk - 1) / maxChunk
-
-		for i := 0; i < maxLen; i += chunkSize {
-			end := i + chunkSize
+		for i, b := range bankByBank[code] {
+			bankByBank[code][i] = policy.sign.normalizeBankStatement(b)
+		}
+	}
 
-			// Safe slicing for transactions
-			txEnd := end
-			if txEnd > len(txs) {
-				txEnd = len(txs)
-			}
+	// 4. Detect duplicate rows before matching, either fail or flag them
+	duplicatesByBank := make(map[string][]DuplicateGroup)
+	for code := range uniqueBanks {
+		duplicatesByBank[code] = detectDuplicates(transactionsByBank[code], bankByBank[code])
+	}
 
-			var txChunk []TransactionUploadFile
-			if i < len(txs) {
-				txChunk = txs[i:txEnd]
-			}
+	if file.duplicatePolicy == DuplicatePolicyReject {
+		if duplicates := rejectedDuplicates(duplicatesByBank); len(duplicates) > 0 {
+			return ShowResultReconciliation{Duplicates: duplicates}, ErrorDuplicateRows
+		}
+	}
 
-			// Safe slicing for bank statements
-			bankEnd := end
-			if bankEnd > len(banks) {
-				bankEnd = len(banks)
-			}
+	// 5. Create a channel to collect results and use a WaitGroup to manage goroutines
+	var wg sync.WaitGroup
+	maxChunk := int(s.cfg.GetInt("max.chunk"))
+	if maxChunk < 1 {
+		maxChunk = 1
+	}
+	resultsChan := make(chan ResultReconciliation)
 
-			var bankChunk []BankStatementUploadFile
-			if i < len(banks) {
-				bankChunk = banks[i:bankEnd]
-			}
+	// A cancelled request or job should not start matching at all
+	if err := ctx.Err(); err != nil {
+		return ShowResultReconciliation{}, err
+	}
 
-			wg.Add(1)
-			go func(tx []TransactionUploadFile, bx []BankStatementUploadFile, bc string) {
-				defer wg.Done()
-				resultsChan <- s.reconcile(tx, bx, bc)
-			}(txChunk, bankChunk, bankCode)
-		}
+	file.progress.SetBanksTotal(len(uniqueBanks))
+	for bankCode, policy := range uniqueBanks {
+		wg.Add(1)
+		go func(bc string, policy bankPolicy) {
+			defer wg.Done()
+			resultsChan <- s.reconcileBank(transactionsByBank[bc], bankByBank[bc], bc, policy, duplicatesByBank[bc], maxChunk)
+			file.progress.AddBanksDone(1)
+		}(bankCode, policy)
 	}
 
 	go func() {
@@ -143,65 +219,438 @@ func (s *service) Proceed(ctx context.Context, file *UploadFile) (ShowResultReco
 		return finalResults[i].BankCode < finalResults[j].BankCode
 	})
 
-	return s.showResultReconciliation(finalResults), nil
+	// Nor store the run of a reconciliation which was cancelled meanwhile
+	if err := ctx.Err(); err != nil {
+		return ShowResultReconciliation{}, err
+	}
+
+	snapshot := newConfigSnapshot(file, uniqueBanks, maxRowsTransaction, maxRowsBank, maxChunk)
+	return s.complete(ctx, file, finalResults, validation, snapshot, startedAt)
 }
 
-func (s *service) reconcile(
+// complete builds the response out of the result of every bank code, and
+// stores it as a run.
+func (s *service) complete(
+	ctx context.Context,
+	file *UploadFile,
+	finalResults []ResultReconciliation,
+	validation *ValidationReport,
+	snapshot configSnapshot,
+	startedAt time.Time) (ShowResultReconciliation, error) {
+	result := s.showResultReconciliation(finalResults)
+	result.Validation = validation
+	if s.runRepository != nil {
+		runID, err := s.saveRun(ctx, file, result, snapshot, startedAt)
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+		result.RunID = runID
+	}
+
+	return result, nil
+}
+
+// rejectedDuplicates lists the duplicates of every bank code, by bank code,
+// they are handed back with ErrorDuplicateRows so the uploader knows which
+// rows to fix.
+func rejectedDuplicates(duplicatesByBank map[string][]DuplicateGroup) []DuplicateGroup {
+	codes := make([]string, 0, len(duplicatesByBank))
+	for code := range duplicatesByBank {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var duplicates []DuplicateGroup
+	for _, code := range codes {
+		duplicates = append(duplicates, duplicatesByBank[code]...)
+	}
+
+	return duplicates
+}
+
+// bankCodesOf lists the bank codes found on the bank file, sorted.
+func bankCodesOf(banks []BankStatementUploadFile) []string {
+	seen := make(map[string]bool)
+	var bankCodes []string
+	for _, b := range banks {
+		if !seen[b.BankCode] {
+			seen[b.BankCode] = true
+			bankCodes = append(bankCodes, b.BankCode)
+		}
+	}
+	sort.Strings(bankCodes)
+
+	return bankCodes
+}
+
+// findTransactions loads the system transactions of bankCodes. One row more
+// than allowed is asked, so an oversize range is still rejected without
+// loading all of it.
+func (s *service) findTransactions(
+	ctx context.Context,
+	file *UploadFile,
+	bankCodes []string,
+	maxRows int) ([]TransactionUploadFile, error) {
+	if len(bankCodes) == 0 {
+		return nil, nil
+	}
+
+	transactions, err := s.repository.FindTransaction(ctx, &transaction.Criteria{
+		StartDate: file.startDate,
+		EndDate:   file.endDate,
+		BankCodes: bankCodes,
+		Limit:     maxRows + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TransactionUploadFile, 0, len(transactions))
+	for _, t := range transactions {
+		result = append(result, fromStoredTransaction(t))
+	}
+
+	return result, nil
+}
+
+// eachTransaction reads the system transactions of bankCodes into add, a row
+// at a time, for an upload too large to hold them.
+func (s *service) eachTransaction(
+	ctx context.Context,
+	file *UploadFile,
+	bankCodes []string,
+	add func(TransactionUploadFile) error) error {
+	if len(bankCodes) == 0 {
+		return nil
+	}
+
+	return s.repository.EachTransaction(ctx, &transaction.Criteria{
+		StartDate: file.startDate,
+		EndDate:   file.endDate,
+		BankCodes: bankCodes,
+	}, func(t *transaction.Transaction) error {
+		return add(fromStoredTransaction(t))
+	})
+}
+
+func fromStoredTransaction(t *transaction.Transaction) TransactionUploadFile {
+	return TransactionUploadFile{
+		TransactionID:   t.TransactionID,
+		TerminalRRN:     t.TerminalRRN,
+		Amount:          t.Amount,
+		TransactionType: string(t.TransactionType),
+		BankCode:        t.BankCode,
+		TransactionTime: t.TransactionTime,
+	}
+}
+
+// bankPolicy reads the match rules, the group rules, the tolerance and the
+// sign convention of a bank code. A bank without its own settings falls back to the default ones.
+func (s *service) bankPolicy(bankCode string) (bankPolicy, error) {
+	exprs := s.cfg.GetArray("recon.match.rules." + bankCode)
+	if len(exprs) == 0 {
+		exprs = s.cfg.GetArray("recon.match.rules.default")
+	}
+
+	rules, err := ParseMatchRules(exprs)
+	if err != nil {
+		return bankPolicy{}, err
+	}
+
+	groupExprs := s.cfg.GetArray("recon.group.rules." + bankCode)
+	if len(groupExprs) == 0 {
+		groupExprs = s.cfg.GetArray("recon.group.rules.default")
+	}
+
+	groupRules, err := ParseGroupRules(groupExprs)
+	if err != nil {
+		return bankPolicy{}, err
+	}
+
+	tolerance, err := ParseTolerance(
+		s.bankSetting("recon.tolerance.amount.", bankCode),
+		s.bankSetting("recon.tolerance.time.", bankCode),
+	)
+	if err != nil {
+		return bankPolicy{}, err
+	}
+
+	sign, err := ParseSignConvention(s.bankSetting("recon.bank.sign.", bankCode))
+	if err != nil {
+		return bankPolicy{}, err
+	}
+
+	return bankPolicy{rules: rules, groupRules: groupRules, tolerance: tolerance, sign: sign}, nil
+}
+
+func (s *service) bankSetting(prefix, bankCode string) string {
+	if value := s.cfg.GetString(prefix + bankCode); value != "" {
+		return value
+	}
+
+	return s.cfg.GetString(prefix + "default")
+}
+
+// reconcileBank reconciles one bank code in three steps:
+//  1. pair rows by the match rules, only pairs within tolerance are taken
+//  2. match settlement groups (N:1 or 1:N) on what is left, by the group rules
+//  3. pair the rest by the match rules again, now reporting them as mismatched
+//
+// Every rule partitions the rows left over by the previous rule by the hash
+// of its own key, so the result is the same regardless of max.chunk.
+func (s *service) reconcileBank(
 	txs []TransactionUploadFile,
 	banks []BankStatementUploadFile,
-	bc string) ResultReconciliation {
-	result := ResultReconciliation{
-		ResultReconciliationDetails: ResultReconciliationDetails{
-			TransactionMismatched:   []TransactionUploadFile{},
-			BankStatementMismatched: []BankStatementUploadFile{},
-		},
+	bc string,
+	policy bankPolicy,
+	duplicates []DuplicateGroup,
+	maxChunk int) ResultReconciliation {
+	result := newResultReconciliation(bc)
+	result.TotalNumberOfTransactions = len(txs)
+	result.TotalsByTransactionType = totalsByType(txs, banks)
+	txIDs, bankIDs := result.addDuplicates(duplicates)
+
+	for _, round := range s.rounds(bc, policy) {
+		txs, banks = s.reconcileRound(&result, txs, banks, maxChunk, round.rule,
+			func(txIdx, bankIdx []int) chunkOutcome {
+				return round.match(txs, txIdx, banks, bankIdx)
+			})
+	}
+
+	result.addLeftovers(txs, banks, txIDs, bankIDs)
+	sortDetails(&result.ResultReconciliationDetails)
+	return result
+}
+
+// rounds are the steps of reconcileBank, each of them pairs rows by the key
+// of its rule.
+func (s *service) rounds(bc string, policy bankPolicy) []round {
+	var rounds []round
+	for _, rule := range policy.rules {
+		rounds = append(rounds, round{rule: rule, match: func(txs []TransactionUploadFile, txIdx []int, banks []BankStatementUploadFile, bankIdx []int) chunkOutcome {
+			return s.reconcile(txs, txIdx, banks, bankIdx, bc, rule, policy.tolerance, false)
+		}})
+	}
+
+	for _, rule := range policy.groupRules {
+		rounds = append(rounds, round{rule: rule, match: func(txs []TransactionUploadFile, txIdx []int, banks []BankStatementUploadFile, bankIdx []int) chunkOutcome {
+			return s.reconcileGroup(txs, txIdx, banks, bankIdx, bc, rule, policy.tolerance)
+		}})
+	}
+
+	for _, rule := range policy.rules {
+		rounds = append(rounds, round{rule: rule, match: func(txs []TransactionUploadFile, txIdx []int, banks []BankStatementUploadFile, bankIdx []int) chunkOutcome {
+			return s.reconcile(txs, txIdx, banks, bankIdx, bc, rule, policy.tolerance, true)
+		}})
+	}
+
+	return rounds
+}
+
+// addDuplicates lists the duplicate groups and returns their ids per side.
+// Duplicate rows are still reconciled, the ones left unpaired are reported
+// as duplicates instead of missing.
+func (r *ResultReconciliation) addDuplicates(duplicates []DuplicateGroup) (map[string]bool, map[string]bool) {
+	txIDs := make(map[string]bool)
+	bankIDs := make(map[string]bool)
+	for _, duplicate := range duplicates {
+		r.TotalNumberOfDuplicateRows += len(duplicate.Transactions) + len(duplicate.BankStatements)
+		if duplicate.Side == ExceptionSideSystem {
+			txIDs[duplicate.ID] = true
+		} else {
+			bankIDs[duplicate.ID] = true
+		}
+	}
+	r.ResultReconciliationDetails.Duplicates = append(r.ResultReconciliationDetails.Duplicates, duplicates...)
+
+	return txIDs, bankIDs
+}
+
+// addLeftovers reports the rows no round paired.
+func (r *ResultReconciliation) addLeftovers(
+	txs []TransactionUploadFile,
+	banks []BankStatementUploadFile,
+	txIDs, bankIDs map[string]bool) {
+	r.TotalNumberOfUnmatchedTransactions += len(txs)
+	r.ResultReconciliationDetails.TransactionMismatched =
+		append(r.ResultReconciliationDetails.TransactionMismatched, txs...)
+	r.ResultReconciliationDetails.BankStatementMismatched =
+		append(r.ResultReconciliationDetails.BankStatementMismatched, banks...)
+
+	for _, tx := range txs {
+		reason := MismatchReasonMissingInBank
+		if txIDs[tx.TransactionID] {
+			reason = MismatchReasonDuplicateID
+		}
+		r.addException(Exception{Reason: reason, Side: ExceptionSideSystem, Transaction: &tx})
 	}
 
-	// 1. Store bank data into a Map for fast lookup (O(1))
-	// Use UniqueID or RRN as the key
-	bankMap := make(map[string]BankStatementUploadFile)
 	for _, b := range banks {
-		bankMap[b.UniqueID] = b
+		reason := MismatchReasonMissingInSystem
+		if bankIDs[b.UniqueID] {
+			reason = MismatchReasonDuplicateID
+		}
+		r.addException(Exception{Reason: reason, Side: ExceptionSideBank, BankStatement: &b})
+	}
+}
+
+// reconcileRound partitions the rows by the key of a rule, runs match on every
+// partition concurrently, and returns the rows none of the partitions took.
+func (s *service) reconcileRound(
+	result *ResultReconciliation,
+	txs []TransactionUploadFile,
+	banks []BankStatementUploadFile,
+	maxChunk int,
+	rule MatchRule,
+	match func(txIdx, bankIdx []int) chunkOutcome) ([]TransactionUploadFile, []BankStatementUploadFile) {
+	if len(txs) == 0 || len(banks) == 0 {
+		return txs, banks
+	}
+
+	txParts := partitionTransactions(txs, maxChunk, rule.transactionKey)
+	bankParts := partitionBankStatements(banks, maxChunk, rule.bankKey)
+
+	var wg sync.WaitGroup
+	outcomes := make([]chunkOutcome, maxChunk)
+	for i := 0; i < maxChunk; i++ {
+		if len(txParts[i]) == 0 || len(bankParts[i]) == 0 {
+			// Nothing to pair with, carry the rows over to the next rule
+			outcomes[i] = chunkOutcome{txLeft: txParts[i], bankLeft: bankParts[i]}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outcomes[i] = match(txParts[i], bankParts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var txLeft, bankLeft []int
+	for _, outcome := range outcomes {
+		result.merge(outcome.result)
+		txLeft = append(txLeft, outcome.txLeft...)
+		bankLeft = append(bankLeft, outcome.bankLeft...)
+	}
+
+	// Keep the input order, so the next rule sees the rows the same way
+	// whatever the number of chunks is
+	sort.Ints(txLeft)
+	sort.Ints(bankLeft)
+	return pickTransactions(txs, txLeft), pickBankStatements(banks, bankLeft)
+}
+
+// reconcile pairs transactions with bank statements one to one. Unless
+// acceptMismatched is set, only pairs within tolerance are taken, the rest
+// is left for the next steps.
+func (s *service) reconcile(
+	txs []TransactionUploadFile,
+	txIdx []int,
+	banks []BankStatementUploadFile,
+	bankIdx []int,
+	bc string,
+	rule MatchRule,
+	tolerance Tolerance,
+	acceptMismatched bool) chunkOutcome {
+	outcome := chunkOutcome{result: newResultReconciliation(bc)}
+	result := &outcome.result
+
+	// 1. Store bank data into a Map for fast lookup (O(1))
+	// Rows sharing the same key are queued, each of them can be paired once
+	bankMap := make(map[string][]int)
+	for _, i := range bankIdx {
+		if key := rule.bankKey(banks[i]); key != "" {
+			bankMap[key] = append(bankMap[key], i)
+		}
 	}
 
 	// 2. Iterate through system transactions and look them up in the bank map
-	matchedBankIDs := make(map[string]bool)
-	for _, tx := range txs {
-		bankEntry, found := bankMap[tx.TransactionID]
-
-		if found {
-			matchedBankIDs[tx.TransactionID] = true
-			if tx.Amount.Equal(bankEntry.Amount) {
-				result.TotalNumberOfMatchesTransactions++
-			} else {
-				// If ID matches but amount differs: calculate absolute discrepancy
-				diff := tx.Amount.Sub(bankEntry.Amount).Abs()
-				result.TotalAmountDiscrepancies = result.TotalAmountDiscrepancies.Add(diff)
-
-				// Add to mismatched because the amount is not an exact match
-				result.TotalNumberOfUnmatchedTransactions++
-				result.ResultReconciliationDetails.TransactionMismatched =
-					append(result.ResultReconciliationDetails.TransactionMismatched, tx)
+	matchedBank := make(map[int]bool)
+	for _, i := range txIdx {
+		tx := txs[i]
+		key := rule.transactionKey(tx)
+		queue := bankMap[key]
+
+		pos, class, reason := -1, MatchClassMismatched, MismatchReason("")
+		for p, b := range queue {
+			if class, reason = tolerance.classify(tx, banks[b]); class != MatchClassMismatched || acceptMismatched {
+				pos = p
+				break
 			}
-		} else {
-			// If ID is not found in the bank data at all
+		}
+
+		if pos < 0 {
+			outcome.txLeft = append(outcome.txLeft, i)
+			continue
+		}
+
+		bankEntry := banks[queue[pos]]
+		matchedBank[queue[pos]] = true
+		bankMap[key] = append(queue[:pos:pos], queue[pos+1:]...)
+
+		// Absolute discrepancy of the pair, counted for every class
+		diff := tx.Amount.Sub(bankEntry.Amount).Abs()
+		result.TotalAmountDiscrepancies = result.TotalAmountDiscrepancies.Add(diff)
+
+		switch class {
+		case MatchClassExact, MatchClassWithinTolerance:
+			result.countMatch(class, 1, diff)
+			result.TotalNumberOfMatchesByRule[rule.Name]++
+			result.ResultReconciliationDetails.TransactionMatched =
+				append(result.ResultReconciliationDetails.TransactionMatched, MatchedTransaction{
+					Rule:             rule.Name,
+					Class:            class,
+					AmountDifference: tx.Amount.Sub(bankEntry.Amount),
+					Transaction:      tx,
+					BankStatement:    bankEntry,
+				})
+		default:
+			// Add to mismatched because the pair is out of tolerance
+			result.TotalNumberOfMismatchedPairs++
+			result.TotalAmountDiscrepanciesMismatched = result.TotalAmountDiscrepanciesMismatched.Add(diff)
 			result.TotalNumberOfUnmatchedTransactions++
 			result.ResultReconciliationDetails.TransactionMismatched =
 				append(result.ResultReconciliationDetails.TransactionMismatched, tx)
+
+			difference := tx.Amount.Sub(bankEntry.Amount)
+			result.addException(Exception{
+				Reason:           reason,
+				Side:             ExceptionSideBoth,
+				Rule:             rule.Name,
+				Transaction:      &tx,
+				BankStatement:    &bankEntry,
+				AmountDifference: &difference,
+			})
 		}
 	}
 
-	// 3. Find bank data that DOES NOT EXIST in system transactions
-	for _, b := range banks {
-		if !matchedBankIDs[b.UniqueID] {
-			result.ResultReconciliationDetails.BankStatementMismatched =
-				append(result.ResultReconciliationDetails.BankStatementMismatched, b)
+	// 3. Bank data that was not paired is handed over to the next rule
+	for _, i := range bankIdx {
+		if !matchedBank[i] {
+			outcome.bankLeft = append(outcome.bankLeft, i)
 		}
 	}
 
-	result.TotalNumberOfTransactions = len(txs)
-	result.BankCode = bc
-	return result
+	return outcome
+}
+
+// addException lists an exception and counts it by its reason.
+func (r *ResultReconciliation) addException(exception Exception) {
+	r.TotalNumberOfExceptionsByReason[exception.Reason]++
+	r.ResultReconciliationDetails.Exceptions = append(r.ResultReconciliationDetails.Exceptions, exception)
+}
+
+// countMatch adds matched transactions of a class to the summary.
+func (r *ResultReconciliation) countMatch(class MatchClass, transactions int, diff decimal.Decimal) {
+	r.TotalNumberOfMatchesTransactions += transactions
+	if class == MatchClassExact {
+		r.TotalNumberOfExactMatches += transactions
+		return
+	}
+
+	r.TotalNumberOfMatchesWithinTolerance += transactions
+	r.TotalAmountDiscrepanciesWithinTolerance = r.TotalAmountDiscrepanciesWithinTolerance.Add(diff)
 }
 
 func (s *service) showResultReconciliation(finalResult []ResultReconciliation) ShowResultReconciliation {
@@ -209,20 +658,7 @@ func (s *service) showResultReconciliation(finalResult []ResultReconciliation) S
 
 	for _, fr := range finalResult {
 		if existing, ok := mergedMap[fr.BankCode]; ok {
-			existing.TotalNumberOfTransactions += fr.TotalNumberOfTransactions
-			existing.TotalNumberOfMatchesTransactions += fr.TotalNumberOfMatchesTransactions
-			existing.TotalNumberOfUnmatchedTransactions += fr.TotalNumberOfUnmatchedTransactions
-			existing.TotalAmountDiscrepancies = existing.TotalAmountDiscrepancies.Add(fr.TotalAmountDiscrepancies)
-
-			existing.ResultReconciliationDetails.TransactionMismatched = append(
-				existing.ResultReconciliationDetails.TransactionMismatched,
-				fr.ResultReconciliationDetails.TransactionMismatched...,
-			)
-
-			existing.ResultReconciliationDetails.BankStatementMismatched = append(
-				existing.ResultReconciliationDetails.BankStatementMismatched,
-				fr.ResultReconciliationDetails.BankStatementMismatched...,
-			)
+			existing.merge(fr)
 		} else {
 			// Create a copy to avoid mutating original slice elements if needed
 			item := fr
@@ -232,6 +668,8 @@ func (s *service) showResultReconciliation(finalResult []ResultReconciliation) S
 
 	var result []ResultReconciliation
 	for _, v := range mergedMap {
+		// Chunks finish in any order, keep the details stable for the caller
+		sortDetails(&v.ResultReconciliationDetails)
 		result = append(result, *v)
 	}
 
@@ -243,3 +681,195 @@ func (s *service) showResultReconciliation(finalResult []ResultReconciliation) S
 		ResultReconciliation: result,
 	}
 }
+
+func newResultReconciliation(bc string) ResultReconciliation {
+	return ResultReconciliation{
+		BankCode:                        bc,
+		TotalNumberOfMatchesByRule:      make(map[string]int),
+		TotalNumberOfExceptionsByReason: make(map[MismatchReason]int),
+		TotalsByTransactionType:         make(map[string]TypeTotal),
+		ResultReconciliationDetails: ResultReconciliationDetails{
+			TransactionMatched:      []MatchedTransaction{},
+			GroupMatched:            []MatchedGroup{},
+			TransactionMismatched:   []TransactionUploadFile{},
+			BankStatementMismatched: []BankStatementUploadFile{},
+			Exceptions:              []Exception{},
+			Duplicates:              []DuplicateGroup{},
+		},
+	}
+}
+
+func (r *ResultReconciliation) merge(other ResultReconciliation) {
+	r.TotalNumberOfTransactions += other.TotalNumberOfTransactions
+	r.TotalNumberOfMatchesTransactions += other.TotalNumberOfMatchesTransactions
+	r.TotalNumberOfUnmatchedTransactions += other.TotalNumberOfUnmatchedTransactions
+	r.TotalNumberOfExactMatches += other.TotalNumberOfExactMatches
+	r.TotalNumberOfMatchesWithinTolerance += other.TotalNumberOfMatchesWithinTolerance
+	r.TotalNumberOfMismatchedPairs += other.TotalNumberOfMismatchedPairs
+	r.TotalNumberOfGroupMatches += other.TotalNumberOfGroupMatches
+	r.TotalNumberOfDuplicateRows += other.TotalNumberOfDuplicateRows
+	r.TotalAmountDiscrepancies = r.TotalAmountDiscrepancies.Add(other.TotalAmountDiscrepancies)
+	r.TotalAmountDiscrepanciesWithinTolerance = r.TotalAmountDiscrepanciesWithinTolerance.Add(other.TotalAmountDiscrepanciesWithinTolerance)
+	r.TotalAmountDiscrepanciesMismatched = r.TotalAmountDiscrepanciesMismatched.Add(other.TotalAmountDiscrepanciesMismatched)
+
+	if r.TotalNumberOfMatchesByRule == nil {
+		r.TotalNumberOfMatchesByRule = make(map[string]int)
+	}
+	for rule, total := range other.TotalNumberOfMatchesByRule {
+		r.TotalNumberOfMatchesByRule[rule] += total
+	}
+
+	if r.TotalsByTransactionType == nil {
+		r.TotalsByTransactionType = make(map[string]TypeTotal)
+	}
+	for key, other := range other.TotalsByTransactionType {
+		total := r.TotalsByTransactionType[key]
+		total.TotalNumberOfTransactions += other.TotalNumberOfTransactions
+		total.TotalAmountTransactions = total.TotalAmountTransactions.Add(other.TotalAmountTransactions)
+		total.TotalNumberOfBankStatements += other.TotalNumberOfBankStatements
+		total.TotalAmountBankStatements = total.TotalAmountBankStatements.Add(other.TotalAmountBankStatements)
+		r.TotalsByTransactionType[key] = total
+	}
+
+	if r.TotalNumberOfExceptionsByReason == nil {
+		r.TotalNumberOfExceptionsByReason = make(map[MismatchReason]int)
+	}
+	for reason, total := range other.TotalNumberOfExceptionsByReason {
+		r.TotalNumberOfExceptionsByReason[reason] += total
+	}
+
+	r.ResultReconciliationDetails.TransactionMatched = append(
+		r.ResultReconciliationDetails.TransactionMatched,
+		other.ResultReconciliationDetails.TransactionMatched...,
+	)
+
+	r.ResultReconciliationDetails.GroupMatched = append(
+		r.ResultReconciliationDetails.GroupMatched,
+		other.ResultReconciliationDetails.GroupMatched...,
+	)
+
+	r.ResultReconciliationDetails.TransactionMismatched = append(
+		r.ResultReconciliationDetails.TransactionMismatched,
+		other.ResultReconciliationDetails.TransactionMismatched...,
+	)
+
+	r.ResultReconciliationDetails.BankStatementMismatched = append(
+		r.ResultReconciliationDetails.BankStatementMismatched,
+		other.ResultReconciliationDetails.BankStatementMismatched...,
+	)
+
+	r.ResultReconciliationDetails.Exceptions = append(
+		r.ResultReconciliationDetails.Exceptions,
+		other.ResultReconciliationDetails.Exceptions...,
+	)
+
+	r.ResultReconciliationDetails.Duplicates = append(
+		r.ResultReconciliationDetails.Duplicates,
+		other.ResultReconciliationDetails.Duplicates...,
+	)
+}
+
+func pickTransactions(txs []TransactionUploadFile, idx []int) []TransactionUploadFile {
+	picked := make([]TransactionUploadFile, 0, len(idx))
+	for _, i := range idx {
+		picked = append(picked, txs[i])
+	}
+
+	return picked
+}
+
+func pickBankStatements(banks []BankStatementUploadFile, idx []int) []BankStatementUploadFile {
+	picked := make([]BankStatementUploadFile, 0, len(idx))
+	for _, i := range idx {
+		picked = append(picked, banks[i])
+	}
+
+	return picked
+}
+
+// sortDetails orders the details by reference. Rows sharing a reference are
+// ordered by the rest of their fields, so the details do not depend on the
+// partitions the rows were reconciled in.
+func sortDetails(details *ResultReconciliationDetails) {
+	slices.SortStableFunc(details.TransactionMatched, func(a, b MatchedTransaction) int {
+		return cmp.Or(
+			compareTransactions(a.Transaction, b.Transaction),
+			compareBankStatements(a.BankStatement, b.BankStatement),
+			strings.Compare(a.Rule, b.Rule),
+		)
+	})
+
+	slices.SortStableFunc(details.GroupMatched, func(a, b MatchedGroup) int {
+		return cmp.Or(
+			compareTransactions(a.Transactions[0], b.Transactions[0]),
+			compareBankStatements(a.BankStatements[0], b.BankStatements[0]),
+			strings.Compare(a.Rule, b.Rule),
+		)
+	})
+
+	slices.SortStableFunc(details.TransactionMismatched, compareTransactions)
+	slices.SortStableFunc(details.BankStatementMismatched, compareBankStatements)
+
+	slices.SortStableFunc(details.Exceptions, func(a, b Exception) int {
+		return cmp.Or(
+			strings.Compare(a.reference(), b.reference()),
+			strings.Compare(string(a.Reason), string(b.Reason)),
+			strings.Compare(string(a.Side), string(b.Side)),
+			strings.Compare(a.Rule, b.Rule),
+			compareOptional(a.Transaction, b.Transaction, compareTransactions),
+			compareOptional(a.BankStatement, b.BankStatement, compareBankStatements),
+		)
+	})
+}
+
+func compareTransactions(a, b TransactionUploadFile) int {
+	return cmp.Or(
+		strings.Compare(a.TransactionID, b.TransactionID),
+		strings.Compare(a.TerminalRRN, b.TerminalRRN),
+		a.TransactionTime.Compare(b.TransactionTime),
+		a.Amount.Cmp(b.Amount),
+		strings.Compare(a.TransactionType, b.TransactionType),
+		strings.Compare(a.File, b.File),
+	)
+}
+
+func compareBankStatements(a, b BankStatementUploadFile) int {
+	return cmp.Or(
+		strings.Compare(a.UniqueID, b.UniqueID),
+		a.Date.Compare(b.Date),
+		a.Amount.Cmp(b.Amount),
+		strings.Compare(a.TransactionType, b.TransactionType),
+		strings.Compare(a.EndToEndID, b.EndToEndID),
+		strings.Compare(a.AccountServicerReference, b.AccountServicerReference),
+		a.BookingDate.Compare(b.BookingDate),
+		strings.Compare(a.Currency, b.Currency),
+		strings.Compare(a.File, b.File),
+	)
+}
+
+// compareOptional puts a missing row first.
+func compareOptional[T any](a, b *T, compare func(a, b T) int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return compare(*a, *b)
+	}
+}
+
+// reference is the id the exception is known by, the system one first.
+func (e Exception) reference() string {
+	if e.Transaction != nil {
+		return e.Transaction.TransactionID
+	}
+
+	if e.BankStatement != nil {
+		return e.BankStatement.UniqueID
+	}
+
+	return ""
+}
@@ -2,15 +2,44 @@ package recon_test
 
 import (
 	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"amartha-recon-service/infrastructure/repository/transaction"
 	"amartha-recon-service/mocks"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
+type countingProgress struct {
+	rowsParsed atomic.Int64
+	banksTotal atomic.Int64
+	banksDone  atomic.Int64
+}
+
+func (p *countingProgress) AddRowsParsed(rows int) {
+	p.rowsParsed.Add(int64(rows))
+}
+
+func (p *countingProgress) SetBanksTotal(banks int) {
+	p.banksTotal.Store(int64(banks))
+}
+
+func (p *countingProgress) AddBanksDone(banks int) {
+	p.banksDone.Add(int64(banks))
+}
+
 func TestService_Proceed(t *testing.T) {
 	ctx := context.Background()
 	now := time.Now()
@@ -20,7 +49,7 @@ func TestService_Proceed(t *testing.T) {
 	t.Run("error max rows transaction", func(t *testing.T) {
 		cfg := mocks.NewConfiguration(t)
 		cfg.On("GetInt", "max.rows.transactions").Return(int64(1))
-		svc := recon.NewService(cfg, nil)
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
 
 		file := recon.NewUploadFile(
 			[]recon.TransactionUploadFile{
@@ -42,7 +71,7 @@ func TestService_Proceed(t *testing.T) {
 		cfg := mocks.NewConfiguration(t)
 		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
 		cfg.On("GetInt", "max.rows.bank").Return(int64(1))
-		svc := recon.NewService(cfg, nil)
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
 
 		file := recon.NewUploadFile(
 			[]recon.TransactionUploadFile{
@@ -67,7 +96,9 @@ func TestService_Proceed(t *testing.T) {
 		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
 		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
 		cfg.On("GetInt", "max.chunk").Return(int64(2))
-		svc := recon.NewService(cfg, nil)
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
 
 		file := recon.NewUploadFile(
 			[]recon.TransactionUploadFile{
@@ -96,7 +127,9 @@ func TestService_Proceed(t *testing.T) {
 		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
 		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
 		cfg.On("GetInt", "max.chunk").Return(int64(2))
-		svc := recon.NewService(cfg, nil)
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
 
 		file := recon.NewUploadFile(
 			[]recon.TransactionUploadFile{
@@ -114,8 +147,1290 @@ func TestService_Proceed(t *testing.T) {
 		assert.Len(t, res.ResultReconciliation, 2)
 	})
 
+	t.Run("success with match rules in priority order", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(3))
+		cfg.On("GetArray", "recon.match.rules.014").Return([]string{"terminal_rrn+amount+date", "TRANSACTION_ID"})
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", TerminalRRN: "RRN1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX2", TerminalRRN: "RRN2", Amount: decimal.NewFromInt(200), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX3", TerminalRRN: "RRN3", Amount: decimal.NewFromInt(300), BankCode: "014", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "RRN1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now},
+				{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", Date: now},
+				{UniqueID: "RRN9", Amount: decimal.NewFromInt(300), BankCode: "014", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Len(t, res.ResultReconciliation, 1)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, 2, result.TotalNumberOfMatchesTransactions)
+		assert.Equal(t, 1, result.TotalNumberOfUnmatchedTransactions)
+		assert.Equal(t, map[string]int{"TERMINAL_RRN+AMOUNT+DATE": 1, "TRANSACTION_ID": 1}, result.TotalNumberOfMatchesByRule)
+
+		matched := result.ResultReconciliationDetails.TransactionMatched
+		assert.Len(t, matched, 2)
+		assert.Equal(t, "TERMINAL_RRN+AMOUNT+DATE", matched[0].Rule)
+		assert.Equal(t, "RRN1", matched[0].BankStatement.UniqueID)
+		assert.Equal(t, "TRANSACTION_ID", matched[1].Rule)
+		assert.Equal(t, "TX2", matched[1].BankStatement.UniqueID)
+
+		assert.Len(t, result.ResultReconciliationDetails.TransactionMismatched, 1)
+		assert.Len(t, result.ResultReconciliationDetails.BankStatementMismatched, 1)
+	})
+
+	t.Run("success with tolerance classes", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", "recon.tolerance.amount.014").Return("1%")
+		cfg.On("GetString", "recon.tolerance.time.014").Return("")
+		cfg.On("GetString", "recon.tolerance.time.default").Return("24h")
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX4", Amount: decimal.NewFromInt(400), BankCode: "014", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now},
+				{UniqueID: "TX2", Amount: decimal.RequireFromString("198.5"), BankCode: "014", Date: now.Add(24 * time.Hour)},
+				{UniqueID: "TX3", Amount: decimal.NewFromInt(310), BankCode: "014", Date: now},
+				{UniqueID: "TX4", Amount: decimal.NewFromInt(400), BankCode: "014", Date: now.Add(48 * time.Hour)},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, 2, result.TotalNumberOfMatchesTransactions)
+		assert.Equal(t, 1, result.TotalNumberOfExactMatches)
+		assert.Equal(t, 1, result.TotalNumberOfMatchesWithinTolerance)
+		assert.Equal(t, 2, result.TotalNumberOfMismatchedPairs)
+		assert.Equal(t, 2, result.TotalNumberOfUnmatchedTransactions)
+		assert.Equal(t, "1.5", result.TotalAmountDiscrepanciesWithinTolerance.String())
+		assert.Equal(t, "10", result.TotalAmountDiscrepanciesMismatched.String())
+		assert.Equal(t, "11.5", result.TotalAmountDiscrepancies.String())
+
+		matched := result.ResultReconciliationDetails.TransactionMatched
+		assert.Equal(t, recon.MatchClassExact, matched[0].Class)
+		assert.Equal(t, recon.MatchClassWithinTolerance, matched[1].Class)
+		assert.Equal(t, "1.5", matched[1].AmountDifference.String())
+
+		exceptions := result.ResultReconciliationDetails.Exceptions
+		assert.Len(t, exceptions, 2)
+		assert.Equal(t, recon.MismatchReasonAmountMismatch, exceptions[0].Reason)
+		assert.Equal(t, recon.ExceptionSideBoth, exceptions[0].Side)
+		assert.Equal(t, "TX3", exceptions[0].BankStatement.UniqueID)
+		assert.Equal(t, "-10", exceptions[0].AmountDifference.String())
+		assert.Equal(t, recon.MismatchReasonDateMismatch, exceptions[1].Reason)
+		assert.Equal(t, "TX4", exceptions[1].Transaction.TransactionID)
+	})
+
+	t.Run("success with mismatch reasons", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(3))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "DEBIT", BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "002", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "CREDIT", BankCode: "002", Date: now},
+				{UniqueID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "002", Date: now},
+				{UniqueID: "TX9", Amount: decimal.NewFromInt(900), BankCode: "002", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, map[recon.MismatchReason]int{
+			recon.MismatchReasonTypeMismatch:    1,
+			recon.MismatchReasonMissingInBank:   1,
+			recon.MismatchReasonDuplicateID:     1,
+			recon.MismatchReasonMissingInSystem: 1,
+		}, result.TotalNumberOfExceptionsByReason)
+
+		exceptions := result.ResultReconciliationDetails.Exceptions
+		assert.Len(t, exceptions, 4)
+		assert.Equal(t, recon.MismatchReasonTypeMismatch, exceptions[0].Reason)
+		assert.Equal(t, recon.MismatchReasonMissingInBank, exceptions[1].Reason)
+		assert.Equal(t, recon.ExceptionSideSystem, exceptions[1].Side)
+		assert.Nil(t, exceptions[1].BankStatement)
+		assert.Equal(t, recon.MismatchReasonDuplicateID, exceptions[2].Reason)
+		assert.Equal(t, recon.MismatchReasonMissingInSystem, exceptions[3].Reason)
+		assert.Equal(t, recon.ExceptionSideBank, exceptions[3].Side)
+
+		assert.Equal(t, 2, result.TotalNumberOfDuplicateRows)
+		assert.Len(t, result.ResultReconciliationDetails.Duplicates, 1)
+		assert.Equal(t, "TX3", result.ResultReconciliationDetails.Duplicates[0].ID)
+		assert.Equal(t, recon.ExceptionSideSystem, result.ResultReconciliationDetails.Duplicates[0].Side)
+		assert.Len(t, result.ResultReconciliationDetails.Duplicates[0].Transactions, 2)
+	})
+
+	t.Run("success with sign conventions", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", "recon.bank.sign.002").Return("SIGNED")
+		cfg.On("GetString", "recon.bank.sign.008").Return("dc_column")
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "DEBIT", BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX2", Amount: decimal.NewFromInt(200), TransactionType: "CREDIT", BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX3", Amount: decimal.NewFromInt(300), TransactionType: "DEBIT", BankCode: "008", TransactionTime: now},
+				{TransactionID: "TX4", Amount: decimal.NewFromInt(400), TransactionType: "DEBIT", BankCode: "008", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", Amount: decimal.NewFromInt(-100), BankCode: "002", Date: now},
+				{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "002", Date: now},
+				{UniqueID: "TX3", Amount: decimal.NewFromInt(300), TransactionType: "D", BankCode: "008", Date: now},
+				{UniqueID: "TX4", Amount: decimal.NewFromInt(400), TransactionType: "C", BankCode: "008", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Len(t, res.ResultReconciliation, 2)
+
+		signed := res.ResultReconciliation[0]
+		assert.Equal(t, 2, signed.TotalNumberOfExactMatches)
+		assert.Equal(t, "100", signed.TotalsByTransactionType[recon.TransactionTypeDebit].TotalAmountBankStatements.String())
+		assert.Equal(t, "200", signed.TotalsByTransactionType[recon.TransactionTypeCredit].TotalAmountBankStatements.String())
+
+		dcColumn := res.ResultReconciliation[1]
+		assert.Equal(t, 1, dcColumn.TotalNumberOfExactMatches)
+		assert.Equal(t, 1, dcColumn.TotalNumberOfExceptionsByReason[recon.MismatchReasonTypeMismatch])
+		assert.Equal(t, 2, dcColumn.TotalsByTransactionType[recon.TransactionTypeDebit].TotalNumberOfTransactions)
+		assert.Equal(t, 1, dcColumn.TotalsByTransactionType[recon.TransactionTypeDebit].TotalNumberOfBankStatements)
+		assert.Equal(t, 1, dcColumn.TotalsByTransactionType[recon.TransactionTypeCredit].TotalNumberOfBankStatements)
+	})
+
+	t.Run("error duplicate rows with reject policy", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", BankCode: "002"}},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", BankCode: "002"},
+				{UniqueID: "TX1", BankCode: "002"},
+			},
+			startDate,
+			endDate,
+		).WithDuplicatePolicy(recon.DuplicatePolicyReject)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.Equal(t, recon.ErrorDuplicateRows, err)
+		assert.Empty(t, res.ResultReconciliation)
+		// The rows to fix are handed back
+		assert.Equal(t, []recon.DuplicateGroup{{
+			Side:           recon.ExceptionSideBank,
+			ID:             "TX1",
+			BankStatements: []recon.BankStatementUploadFile{{UniqueID: "TX1", BankCode: "002"}, {UniqueID: "TX1", BankCode: "002"}},
+		}}, res.Duplicates)
+	})
+
+	t.Run("success with settlement groups", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(4))
+		cfg.On("GetArray", "recon.group.rules.008").Return([]string{"TERMINAL_RRN", "TRANSACTION_ID", "DATE"})
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		later := now.Add(72 * time.Hour)
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", TerminalRRN: "BULK1", Amount: decimal.NewFromInt(100), BankCode: "008", TransactionTime: now},
+				{TransactionID: "TX2", TerminalRRN: "BULK1", Amount: decimal.NewFromInt(50), BankCode: "008", TransactionTime: now},
+				{TransactionID: "TX3", TerminalRRN: "RRN3", Amount: decimal.NewFromInt(300), BankCode: "008", TransactionTime: now},
+				{TransactionID: "TX4", TerminalRRN: "RRN4", Amount: decimal.NewFromInt(10), BankCode: "008", TransactionTime: later},
+				{TransactionID: "TX5", TerminalRRN: "RRN5", Amount: decimal.NewFromInt(20), BankCode: "008", TransactionTime: later},
+				{TransactionID: "TX6", TerminalRRN: "RRN6", Amount: decimal.NewFromInt(60), BankCode: "008", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "BULK1", Amount: decimal.NewFromInt(150), BankCode: "008", Date: now},
+				{UniqueID: "TX3", Amount: decimal.NewFromInt(100), BankCode: "008", Date: now},
+				{UniqueID: "TX3", Amount: decimal.NewFromInt(200), BankCode: "008", Date: now},
+				{UniqueID: "DAILY", Amount: decimal.NewFromInt(30), BankCode: "008", Date: later},
+				{UniqueID: "TX6", Amount: decimal.NewFromInt(65), BankCode: "008", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, 3, result.TotalNumberOfGroupMatches)
+		assert.Equal(t, 5, result.TotalNumberOfMatchesTransactions)
+		assert.Equal(t, 5, result.TotalNumberOfExactMatches)
+		assert.Equal(t, 1, result.TotalNumberOfMismatchedPairs)
+		assert.Equal(t, "5", result.TotalAmountDiscrepancies.String())
+
+		groups := result.ResultReconciliationDetails.GroupMatched
+		assert.Len(t, groups, 3)
+		assert.Equal(t, "TERMINAL_RRN", groups[0].Rule)
+		assert.Len(t, groups[0].Transactions, 2)
+		assert.Len(t, groups[0].BankStatements, 1)
+		assert.Equal(t, "TRANSACTION_ID", groups[1].Rule)
+		assert.Len(t, groups[1].Transactions, 1)
+		assert.Len(t, groups[1].BankStatements, 2)
+		assert.Equal(t, "DATE", groups[2].Rule)
+		assert.Len(t, groups[2].Transactions, 2)
+
+		assert.Empty(t, result.ResultReconciliationDetails.BankStatementMismatched)
+		assert.Len(t, result.ResultReconciliationDetails.TransactionMismatched, 1)
+		assert.Equal(t, "TX6", result.ResultReconciliationDetails.TransactionMismatched[0].TransactionID)
+	})
+
+	t.Run("error invalid tolerance", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", "recon.tolerance.amount.014").Return("-5")
+		cfg.On("GetString", "recon.tolerance.time.014").Return("")
+		cfg.On("GetString", "recon.tolerance.time.default").Return("")
+		cfg.On("GetString", mock.Anything).Return("").Maybe()
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", BankCode: "014"}},
+			nil,
+			startDate,
+			endDate,
+		)
+
+		_, err := svc.Proceed(ctx, file)
+		assert.Equal(t, recon.ErrorInvalidTolerance, err)
+	})
+
+	t.Run("error invalid match rule", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetArray", "recon.match.rules.014").Return([]string{"TRANSACTION_ID+TERMINAL_RRN"})
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", BankCode: "014"}},
+			nil,
+			startDate,
+			endDate,
+		)
+
+		_, err := svc.Proceed(ctx, file)
+		assert.Equal(t, recon.ErrorInvalidMatchRule, err)
+	})
+
+	t.Run("success with system transactions from database", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		repo := mocks.NewRepository(t)
+		repo.On("FindTransaction", ctx, &transaction.Criteria{
+			StartDate: startDate,
+			EndDate:   endDate,
+			BankCodes: []string{"002", "014"},
+			Limit:     101,
+		}).Return([]*transaction.Transaction{
+			{TransactionID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "DEBIT", BankCode: "014", TransactionTime: now},
+			{TransactionID: "TX2", Amount: decimal.NewFromInt(200), TransactionType: "CREDIT", BankCode: "002", TransactionTime: now},
+		}, nil)
+		svc := recon.NewService(cfg, repo, nil, common.NewGenerate())
+
+		file := recon.NewBankUploadFile(
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now},
+				{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "002", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Len(t, res.ResultReconciliation, 2)
+		assert.Equal(t, 1, res.ResultReconciliation[0].TotalNumberOfExactMatches)
+		assert.Equal(t, 1, res.ResultReconciliation[1].TotalNumberOfExactMatches)
+	})
+
+	t.Run("error find transactions from database", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+
+		repo := mocks.NewRepository(t)
+		repo.On("FindTransaction", ctx, mock.Anything).Return(nil, errors.New("db error"))
+		svc := recon.NewService(cfg, repo, nil, common.NewGenerate())
+
+		file := recon.NewBankUploadFile(
+			[]recon.BankStatementUploadFile{{UniqueID: "TX1", BankCode: "014"}},
+			startDate,
+			endDate,
+		)
+
+		_, err := svc.Proceed(ctx, file)
+		assert.EqualError(t, err, "db error")
+	})
+
+	t.Run("success with run stored", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now).Once()
+		generate.On("Time").Return(now.Add(1500 * time.Millisecond)).Once()
+		generate.On("UUID").Return("run-1")
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindLatestExceptions", ctx, mock.Anything).Return(nil, nil)
+		runRepository.On("SaveRun", ctx,
+			mock.MatchedBy(func(run *reconciliation.Run) bool {
+				return run.ID == "run-1" && run.SystemSource == recon.SystemSourceFile &&
+					run.SystemChecksum == "sys" && run.BankChecksum == "bank" &&
+					run.DurationMillis == 1500 && strings.Contains(run.ConfigSnapshot, `"max_chunk":2`)
+			}),
+			mock.MatchedBy(func(summaries []*reconciliation.BankSummary) bool {
+				return len(summaries) == 1 && summaries[0].BankCode == "014" &&
+					summaries[0].TotalNumberOfExactMatches == 1 &&
+					strings.Contains(summaries[0].Breakdown, `"MISSING_IN_SYSTEM":1`)
+			}),
+			mock.MatchedBy(func(exceptions []*reconciliation.Exception) bool {
+				return len(exceptions) == 1 && exceptions[0].Reason == "MISSING_IN_SYSTEM" &&
+					exceptions[0].UniqueID == "TX2" && !exceptions[0].SystemAmount.Valid &&
+					exceptions[0].Status == "OPEN" && len(exceptions[0].Fingerprint) == 64
+			}),
+		).Return(nil)
+		svc := recon.NewService(cfg, nil, runRepository, generate)
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now},
+				{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", Date: now},
+			},
+			startDate,
+			endDate,
+		).WithChecksums("sys", "bank")
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Equal(t, "run-1", res.RunID)
+	})
+
+	t.Run("error store run", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now)
+		generate.On("UUID").Return("run-1")
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindLatestExceptions", ctx, mock.Anything).Return(nil, nil)
+		runRepository.On("SaveRun", ctx, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("db error"))
+		svc := recon.NewService(cfg, nil, runRepository, generate)
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", BankCode: "014"}},
+			nil,
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.EqualError(t, err, "db error")
+		assert.Empty(t, res.RunID)
+	})
+
+	t.Run("success with progress", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		progress := &countingProgress{}
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "002", TransactionTime: now},
+				{TransactionID: "TX2", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now},
+			},
+			nil,
+			startDate,
+			endDate,
+		).WithProgress(progress)
+
+		_, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), progress.banksTotal.Load())
+		assert.Equal(t, int64(2), progress.banksDone.Load())
+	})
+
+	t.Run("error cancelled context", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", mock.Anything).Return(int64(2)).Maybe()
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		// The run must not be stored once the request or job is cancelled
+		runRepository := mocks.NewReconciliationRepository(t)
+		svc := recon.NewService(cfg, nil, runRepository, common.NewGenerate())
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", BankCode: "014"}},
+			nil,
+			startDate,
+			endDate,
+		)
+
+		_, err := svc.Proceed(cancelledCtx, file)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("success with bank reference and empty identifiers", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", "recon.match.rules.014").Return([]string{"TERMINAL_RRN=END_TO_END_ID", "TERMINAL_RRN"})
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{
+				{TransactionID: "TX1", TerminalRRN: "E2E1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now},
+				{TransactionID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", TransactionTime: now},
+			},
+			[]recon.BankStatementUploadFile{
+				{UniqueID: "ACCT1", EndToEndID: "E2E1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now},
+				{UniqueID: "ACCT2", Amount: decimal.NewFromInt(200), BankCode: "014", Date: now},
+			},
+			startDate,
+			endDate,
+		)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, map[string]int{"TERMINAL_RRN=END_TO_END_ID": 1}, result.TotalNumberOfMatchesByRule)
+		// TX2 has no terminal RRN and ACCT2 no end to end id, neither is linked by an empty reference
+		assert.Equal(t, 1, result.TotalNumberOfExceptionsByReason[recon.MismatchReasonMissingInBank])
+		assert.Equal(t, 1, result.TotalNumberOfExceptionsByReason[recon.MismatchReasonMissingInSystem])
+	})
+
+	t.Run("error invalid rows with reject mode", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		rowErrors := []recon.RowError{
+			{File: "bank.csv", Line: 2, Column: "amount", Value: "abc", Reason: recon.RowErrorInvalidAmount},
+			{File: "bank.csv", Line: 2, Column: "date", Value: "x", Reason: recon.RowErrorInvalidDate},
+			{File: "bank.csv", Line: 5, Column: "unique_id", Reason: recon.RowErrorMissingValue},
+		}
+		file := recon.NewUploadFile(nil, nil, startDate, endDate).
+			WithRowErrors(recon.ValidationModeReject, rowErrors)
+
+		res, err := svc.Proceed(ctx, file)
+		assert.ErrorIs(t, err, recon.ErrorInvalidRows)
+		assert.Equal(t, &recon.ValidationReport{
+			Mode:                      recon.ValidationModeReject,
+			TotalNumberOfRejectedRows: 2,
+			RowErrors:                 rowErrors,
+		}, res.Validation)
+		assert.Empty(t, res.ResultReconciliation)
+	})
+
+	t.Run("success with invalid rows with skip mode", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(100))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(100))
+		cfg.On("GetInt", "max.chunk").Return(int64(2))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		svc := recon.NewService(cfg, nil, nil, common.NewGenerate())
+
+		file := recon.NewUploadFile(
+			[]recon.TransactionUploadFile{{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now}},
+			[]recon.BankStatementUploadFile{{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now}},
+			startDate,
+			endDate,
+		).WithRowErrors(recon.ValidationModeSkip, []recon.RowError{
+			{File: "system.csv", Line: 3, Column: "amount", Value: "abc", Reason: recon.RowErrorInvalidAmount},
+		})
+
+		res, err := svc.Proceed(ctx, file)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, res.ResultReconciliation[0].TotalNumberOfMatchesTransactions)
+		assert.Equal(t, 1, res.Validation.TotalNumberOfRejectedRows)
+		assert.Equal(t, recon.ValidationModeSkip, res.Validation.Mode)
+	})
+
 	t.Run("NewUploadFile", func(t *testing.T) {
 		uf := recon.NewUploadFile(nil, nil, startDate, endDate)
 		assert.NotNil(t, uf)
 	})
 }
+
+func TestService_Proceed_ChunkInvariant(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	bankCodes := []string{"002", "008", "014"}
+
+	proceed := func(t *testing.T, maxChunk int64, file *recon.UploadFile) string {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(1000))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(1000))
+		cfg.On("GetInt", "max.chunk").Return(maxChunk)
+		cfg.On("GetArray", "recon.match.rules.002").Return([]string(nil))
+		cfg.On("GetArray", "recon.match.rules.008").Return([]string{"TERMINAL_RRN", "TRANSACTION_ID"}).Maybe()
+		cfg.On("GetArray", "recon.match.rules.014").Return([]string{"TRANSACTION_ID+AMOUNT+DATE", "TERMINAL_RRN"}).Maybe()
+		cfg.On("GetArray", "recon.match.rules.default").Return([]string(nil))
+		cfg.On("GetArray", "recon.group.rules.008").Return([]string{"TERMINAL_RRN"}).Maybe()
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+
+		res, err := recon.NewService(cfg, nil, nil, common.NewGenerate()).Proceed(ctx, file)
+		assert.NoError(t, err)
+
+		raw, err := json.Marshal(res)
+		assert.NoError(t, err)
+		return string(raw)
+	}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(seed))
+
+			var txs []recon.TransactionUploadFile
+			var banks []recon.BankStatementUploadFile
+			for i := 0; i < 50+rnd.Intn(150); i++ {
+				id := fmt.Sprintf("TX%05d", i)
+				rrn := fmt.Sprintf("RRN%05d", i)
+				bankCode := bankCodes[rnd.Intn(len(bankCodes))]
+				amount := decimal.NewFromInt(int64(1 + rnd.Intn(10000))).Shift(-2)
+
+				if rnd.Intn(10) > 0 {
+					txs = append(txs, recon.TransactionUploadFile{
+						TransactionID: id, TerminalRRN: rrn, Amount: amount, BankCode: bankCode, TransactionTime: now,
+					})
+				}
+
+				if rnd.Intn(10) > 0 {
+					bankAmount := amount
+					if rnd.Intn(10) == 0 {
+						bankAmount = amount.Add(decimal.NewFromInt(1))
+					}
+
+					uniqueID := id
+					if rrn != "" && rnd.Intn(2) == 0 {
+						uniqueID = rrn
+					}
+
+					banks = append(banks, recon.BankStatementUploadFile{
+						UniqueID: uniqueID, Amount: bankAmount, BankCode: bankCode, Date: now,
+					})
+				}
+			}
+
+			// Shuffle the bank file so pairs sit at unrelated row positions
+			rnd.Shuffle(len(banks), func(i, j int) { banks[i], banks[j] = banks[j], banks[i] })
+			file := recon.NewUploadFile(txs, banks, now, now)
+
+			expected := proceed(t, 1, file)
+			for _, maxChunk := range []int64{2, 3, 7, 10, 64} {
+				assert.JSONEq(t, expected, proceed(t, maxChunk, file), "max.chunk %d", maxChunk)
+			}
+		})
+	}
+}
+
+func TestService_Proceed_SpillInvariant(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	bankCodes := []string{"002", "008", "014"}
+	types := []string{"D", "C", ""}
+
+	newConfiguration := func(t *testing.T, partitionRows int64) *mocks.Configuration {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(1000))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(1000))
+		cfg.On("GetInt", "max.chunk").Return(int64(3)).Maybe()
+		cfg.On("GetInt", "recon.spill.partition.rows").Return(partitionRows).Maybe()
+		cfg.On("GetBool", "recon.spill.omit.matched").Return(false).Maybe()
+		cfg.On("GetArray", "recon.match.rules.008").Return([]string{"TERMINAL_RRN", "TRANSACTION_ID"}).Maybe()
+		cfg.On("GetArray", "recon.match.rules.014").Return([]string{"TRANSACTION_ID+AMOUNT+DATE", "TERMINAL_RRN"}).Maybe()
+		cfg.On("GetArray", "recon.group.rules.008").Return([]string{"TERMINAL_RRN"}).Maybe()
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", "recon.bank.sign.002").Return("SIGNED").Maybe()
+		cfg.On("GetString", mock.Anything).Return("")
+		return cfg
+	}
+
+	marshal := func(t *testing.T, res recon.ShowResultReconciliation, err error) string {
+		assert.NoError(t, err)
+
+		raw, err := json.Marshal(res)
+		assert.NoError(t, err)
+		return string(raw)
+	}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(seed))
+
+			var txs []recon.TransactionUploadFile
+			var banks []recon.BankStatementUploadFile
+			for i := 0; i < 50+rnd.Intn(150); i++ {
+				id := fmt.Sprintf("TX%05d", i)
+				// Some rows share a reference, for duplicates and groups
+				if i > 0 && rnd.Intn(15) == 0 {
+					id = fmt.Sprintf("TX%05d", rnd.Intn(i))
+				}
+				rrn := fmt.Sprintf("RRN%05d", i/2)
+				// Rows without a key of the RRN rules, and a key shared by many rows
+				switch rnd.Intn(12) {
+				case 0:
+					rrn = ""
+				case 1:
+					rrn = "RRNHOT"
+				}
+				bankCode := bankCodes[rnd.Intn(len(bankCodes))]
+				transactionType := types[rnd.Intn(len(types))]
+				amount := decimal.NewFromInt(int64(1 + rnd.Intn(10000))).Shift(-2)
+
+				if rnd.Intn(10) > 0 {
+					txs = append(txs, recon.TransactionUploadFile{
+						TransactionID: id, TerminalRRN: rrn, Amount: amount, TransactionType: transactionType,
+						BankCode: bankCode, TransactionTime: now,
+					})
+				}
+
+				if rnd.Intn(10) > 0 {
+					bankAmount := amount
+					if rnd.Intn(10) == 0 {
+						bankAmount = amount.Add(decimal.NewFromInt(1))
+					}
+
+					uniqueID := id
+					if rrn != "" && rnd.Intn(2) == 0 {
+						uniqueID = rrn
+					}
+
+					banks = append(banks, recon.BankStatementUploadFile{
+						UniqueID: uniqueID, Amount: bankAmount, TransactionType: transactionType, BankCode: bankCode, Date: now,
+					})
+				}
+			}
+
+			rnd.Shuffle(len(banks), func(i, j int) { banks[i], banks[j] = banks[j], banks[i] })
+			res, err := recon.NewService(newConfiguration(t, 0), nil, nil, common.NewGenerate()).
+				Proceed(ctx, recon.NewUploadFile(txs, banks, now, now))
+			expected := marshal(t, res, err)
+
+			for _, partitionRows := range []int64{1, 7, 40, 100000} {
+				spill, err := recon.NewSpill(t.TempDir())
+				assert.NoError(t, err)
+
+				// The rows are read one side after the other, like an upload
+				for _, tx := range txs {
+					assert.NoError(t, spill.AddTransaction(tx))
+				}
+
+				for _, b := range banks {
+					assert.NoError(t, spill.AddBankStatement(b))
+				}
+
+				res, err := recon.NewService(newConfiguration(t, partitionRows), nil, nil, common.NewGenerate()).
+					Proceed(ctx, recon.NewSpillUploadFile(spill, now, now))
+				assert.JSONEq(t, expected, marshal(t, res, err), "recon.spill.partition.rows %d", partitionRows)
+				assert.NoError(t, spill.Close())
+			}
+		})
+	}
+}
+
+func TestService_Proceed_Spill(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success with system transactions from database", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		// More system transactions than max.rows.transactions, which does not bound a spill either
+		cfg.On("GetInt", "max.rows.transactions").Return(int64(1))
+		cfg.On("GetInt", "max.rows.bank").Return(int64(1))
+		cfg.On("GetInt", "recon.spill.partition.rows").Return(int64(1))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		cfg.On("GetBool", "recon.spill.omit.matched").Return(false)
+
+		repo := mocks.NewRepository(t)
+		repo.On("EachTransaction", ctx, &transaction.Criteria{
+			StartDate: now,
+			EndDate:   now,
+			BankCodes: []string{"002", "014"},
+		}, mock.Anything).Run(func(args mock.Arguments) {
+			fn := args.Get(2).(func(*transaction.Transaction) error)
+			assert.NoError(t, fn(&transaction.Transaction{TransactionID: "TX1", Amount: decimal.NewFromInt(100), TransactionType: "DEBIT", BankCode: "014", TransactionTime: now}))
+			assert.NoError(t, fn(&transaction.Transaction{TransactionID: "TX2", Amount: decimal.NewFromInt(200), TransactionType: "CREDIT", BankCode: "002", TransactionTime: now}))
+		}).Return(nil)
+
+		spill, err := recon.NewSpill(t.TempDir())
+		assert.NoError(t, err)
+		defer spill.Close()
+
+		// More bank statements than max.rows.bank, which does not bound a spill
+		assert.NoError(t, spill.AddBankStatement(recon.BankStatementUploadFile{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now}))
+		assert.NoError(t, spill.AddBankStatement(recon.BankStatementUploadFile{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "002", Date: now}))
+
+		res, err := recon.NewService(cfg, repo, nil, common.NewGenerate()).
+			Proceed(ctx, recon.NewSpillBankUploadFile(spill, now, now))
+		assert.NoError(t, err)
+		assert.Len(t, res.ResultReconciliation, 2)
+		assert.Equal(t, 1, res.ResultReconciliation[0].TotalNumberOfExactMatches)
+		assert.Equal(t, 1, res.ResultReconciliation[1].TotalNumberOfExactMatches)
+	})
+
+	t.Run("success without the matched details", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(0))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		cfg.On("GetBool", "recon.spill.omit.matched").Return(true)
+
+		spill, err := recon.NewSpill(t.TempDir())
+		assert.NoError(t, err)
+		defer spill.Close()
+
+		assert.NoError(t, spill.AddTransaction(recon.TransactionUploadFile{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now}))
+		assert.NoError(t, spill.AddTransaction(recon.TransactionUploadFile{TransactionID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", TransactionTime: now}))
+		assert.NoError(t, spill.AddBankStatement(recon.BankStatementUploadFile{UniqueID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", Date: now}))
+
+		res, err := recon.NewService(cfg, nil, nil, common.NewGenerate()).
+			Proceed(ctx, recon.NewSpillUploadFile(spill, now, now))
+		assert.NoError(t, err)
+		assert.Len(t, res.ResultReconciliation, 1)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, 1, result.TotalNumberOfExactMatches)
+		assert.Equal(t, 1, result.TotalNumberOfMatchesTransactions)
+		assert.Empty(t, result.ResultReconciliationDetails.TransactionMatched)
+		assert.Empty(t, result.ResultReconciliationDetails.GroupMatched)
+		// The rows left to fix are still listed
+		assert.Len(t, result.ResultReconciliationDetails.TransactionMismatched, 1)
+		assert.Equal(t, "TX2", result.ResultReconciliationDetails.TransactionMismatched[0].TransactionID)
+	})
+
+	t.Run("error duplicate rows rejected", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(0))
+		cfg.On("GetArray", mock.Anything).Return([]string(nil))
+		cfg.On("GetString", mock.Anything).Return("")
+		cfg.On("GetBool", "recon.spill.omit.matched").Return(false)
+
+		spill, err := recon.NewSpill(t.TempDir())
+		assert.NoError(t, err)
+		defer spill.Close()
+
+		for range 2 {
+			assert.NoError(t, spill.AddTransaction(recon.TransactionUploadFile{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: now}))
+		}
+
+		file := recon.NewSpillUploadFile(spill, now, now).WithDuplicatePolicy(recon.DuplicatePolicyReject)
+		res, err := recon.NewService(cfg, nil, nil, common.NewGenerate()).Proceed(ctx, file)
+		assert.ErrorIs(t, err, recon.ErrorDuplicateRows)
+		assert.Len(t, res.Duplicates, 1)
+		assert.Equal(t, "TX1", res.Duplicates[0].ID)
+		assert.Len(t, res.Duplicates[0].Transactions, 2)
+	})
+}
+
+func TestParseMatchRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    []recon.MatchField
+		wantErr error
+	}{
+		{name: "single field", expr: "TRANSACTION_ID", want: []recon.MatchField{recon.MatchFieldTransactionID}},
+		{name: "composite", expr: " terminal_rrn + AMOUNT+date ", want: []recon.MatchField{recon.MatchFieldTerminalRRN, recon.MatchFieldAmount, recon.MatchFieldDate}},
+		{name: "empty", expr: " ", wantErr: recon.ErrorInvalidMatchRule},
+		{name: "unknown field", expr: "TERMINAL_ID", wantErr: recon.ErrorInvalidMatchRule},
+		{name: "repeated field", expr: "AMOUNT+AMOUNT", wantErr: recon.ErrorInvalidMatchRule},
+		{name: "two identifiers", expr: "TRANSACTION_ID+TERMINAL_RRN", wantErr: recon.ErrorInvalidMatchRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := recon.ParseMatchRule(tt.expr)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, rule.Fields)
+		})
+	}
+}
+
+func TestParseMatchRule_BankReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    recon.BankReference
+		wantErr error
+	}{
+		{name: "default", expr: "TERMINAL_RRN+AMOUNT", want: recon.BankReferenceUniqueID},
+		{name: "end to end id", expr: "terminal_rrn=end_to_end_id+AMOUNT", want: recon.BankReferenceEndToEndID},
+		{name: "account servicer reference", expr: "TRANSACTION_ID=ACCOUNT_SERVICER_REFERENCE", want: recon.BankReferenceAccountServicerReference},
+		{name: "unknown reference", expr: "TRANSACTION_ID=TX_ID", wantErr: recon.ErrorInvalidMatchRule},
+		{name: "reference on amount", expr: "AMOUNT=END_TO_END_ID", wantErr: recon.ErrorInvalidMatchRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := recon.ParseMatchRule(tt.expr)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, rule.BankReference)
+		})
+	}
+}
+
+func TestParseValidationMode(t *testing.T) {
+	mode, err := recon.ParseValidationMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, recon.ValidationModeReject, mode)
+
+	mode, err = recon.ParseValidationMode(" skip ")
+	assert.NoError(t, err)
+	assert.Equal(t, recon.ValidationModeSkip, mode)
+
+	_, err = recon.ParseValidationMode("IGNORE")
+	assert.ErrorIs(t, err, recon.ErrorInvalidValidationMode)
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	policy, err := recon.ParseDuplicatePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, recon.DuplicatePolicyFlag, policy)
+
+	policy, err = recon.ParseDuplicatePolicy(" reject ")
+	assert.NoError(t, err)
+	assert.Equal(t, recon.DuplicatePolicyReject, policy)
+
+	_, err = recon.ParseDuplicatePolicy("IGNORE")
+	assert.Equal(t, recon.ErrorInvalidDuplicatePolicy, err)
+}
+
+func TestService_FindRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindRunByID", ctx, "run-1").Return(&reconciliation.Run{
+			ID: "run-1", SystemSource: recon.SystemSourceFile, ConfigSnapshot: `{"max_chunk":2}`, DurationMillis: 10,
+		}, nil)
+		runRepository.On("FindBankSummaries", ctx, "run-1").Return([]*reconciliation.BankSummary{
+			{RunID: "run-1", BankCode: "014", TotalNumberOfTransactions: 2, Breakdown: `{"exceptions_by_reason":{"AMOUNT_MISMATCH":1,"MISSING_IN_SYSTEM":1}}`},
+		}, nil)
+		runRepository.On("FindExceptions", ctx, "run-1").Return([]*reconciliation.Exception{
+			{
+				RunID: "run-1", BankCode: "014", Reason: "AMOUNT_MISMATCH", Side: "BOTH", Rule: "TRANSACTION_ID",
+				TransactionID: "TX1", UniqueID: "TX1",
+				SystemAmount:     decimal.NewNullDecimal(decimal.NewFromInt(100)),
+				BankAmount:       decimal.NewNullDecimal(decimal.NewFromInt(90)),
+				AmountDifference: decimal.NewNullDecimal(decimal.NewFromInt(10)),
+				TransactionTime:  sql.NullTime{Time: now, Valid: true},
+				BankDate:         sql.NullTime{Time: now, Valid: true},
+			},
+			{RunID: "run-1", BankCode: "014", Reason: "MISSING_IN_SYSTEM", Side: "BANK", UniqueID: "TX2", BankFile: "2026-01-02.csv"},
+		}, nil)
+		svc := recon.NewService(nil, nil, runRepository, nil)
+
+		res, err := svc.FindRun(ctx, "run-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "run-1", res.RunID)
+		assert.JSONEq(t, `{"max_chunk":2}`, string(res.ConfigSnapshot))
+		assert.Len(t, res.ResultReconciliation, 1)
+
+		result := res.ResultReconciliation[0]
+		assert.Equal(t, 1, result.TotalNumberOfExceptionsByReason[recon.MismatchReasonAmountMismatch])
+		assert.Len(t, result.ResultReconciliationDetails.Exceptions, 2)
+		assert.Equal(t, "10", result.ResultReconciliationDetails.Exceptions[0].AmountDifference.String())
+		assert.Equal(t, "90", result.ResultReconciliationDetails.Exceptions[0].BankStatement.Amount.String())
+		assert.Nil(t, result.ResultReconciliationDetails.Exceptions[1].Transaction)
+		assert.Equal(t, "2026-01-02.csv", result.ResultReconciliationDetails.Exceptions[1].BankStatement.File)
+		assert.Len(t, result.ResultReconciliationDetails.TransactionMismatched, 1)
+		assert.Len(t, result.ResultReconciliationDetails.BankStatementMismatched, 1)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindRunByID", ctx, "run-2").Return(nil, reconciliation.ErrorRunNotFound)
+		svc := recon.NewService(nil, nil, runRepository, nil)
+
+		_, err := svc.FindRun(ctx, "run-2")
+		assert.Equal(t, recon.ErrorRunNotFound, err)
+	})
+
+	t.Run("not found without repository", func(t *testing.T) {
+		svc := recon.NewService(nil, nil, nil, nil)
+
+		_, err := svc.FindRun(ctx, "run-1")
+		assert.Equal(t, recon.ErrorRunNotFound, err)
+	})
+}
+
+func TestService_FindExceptions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "recon.exceptions.page.size.max").Return(int64(0))
+
+		criteria := &reconciliation.ExceptionCriteria{
+			RunID: "run-1", BankCode: "014", Reason: "AMOUNT_MISMATCH",
+			Sort: reconciliation.ExceptionSortAmount, Desc: true, Limit: 2, Offset: 2,
+		}
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindRunByID", ctx, "run-1").Return(&reconciliation.Run{ID: "run-1"}, nil)
+		runRepository.On("CountExceptions", ctx, criteria).Return(5, nil)
+		runRepository.On("FindExceptionPage", ctx, criteria).Return([]*reconciliation.Exception{
+			{
+				ID: 9, RunID: "run-1", BankCode: "014", Reason: "AMOUNT_MISMATCH", Side: "BOTH",
+				TransactionID: "TX9", UniqueID: "TX9",
+				SystemAmount: decimal.NewNullDecimal(decimal.NewFromInt(100)),
+				BankAmount:   decimal.NewNullDecimal(decimal.NewFromInt(90)),
+			},
+		}, nil)
+		svc := recon.NewService(cfg, nil, runRepository, nil)
+
+		res, err := svc.FindExceptions(ctx, recon.ExceptionQuery{
+			RunID: "run-1", BankCode: "014", Reason: "amount_mismatch", SortBy: "amount", Order: "desc", Page: 2, Size: 2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, recon.Pagination{Page: 2, Size: 2, TotalItems: 5, TotalPages: 3}, res.Pagination)
+		assert.Len(t, res.Exceptions, 1)
+		assert.Equal(t, uint64(9), res.Exceptions[0].ID)
+		assert.Equal(t, "014", res.Exceptions[0].BankCode)
+		assert.Equal(t, "90", res.Exceptions[0].BankStatement.Amount.String())
+	})
+
+	t.Run("past the last page", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "recon.exceptions.page.size.max").Return(int64(0))
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindRunByID", ctx, "run-1").Return(&reconciliation.Run{ID: "run-1"}, nil)
+		runRepository.On("CountExceptions", ctx, mock.Anything).Return(3, nil)
+		svc := recon.NewService(cfg, nil, runRepository, nil)
+
+		res, err := svc.FindExceptions(ctx, recon.ExceptionQuery{RunID: "run-1", Page: 4})
+		assert.NoError(t, err)
+		assert.Equal(t, recon.Pagination{Page: 4, Size: 50, TotalItems: 3, TotalPages: 1}, res.Pagination)
+		assert.Empty(t, res.Exceptions)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "recon.exceptions.page.size.max").Return(int64(10)).Maybe()
+		svc := recon.NewService(cfg, nil, mocks.NewReconciliationRepository(t), nil)
+
+		for _, query := range []recon.ExceptionQuery{
+			{RunID: "run-1", Reason: "UNKNOWN"},
+			{RunID: "run-1", Side: "LEFT"},
+			{RunID: "run-1", SortBy: "rule"},
+			{RunID: "run-1", Order: "up"},
+			{RunID: "run-1", Page: -1},
+			{RunID: "run-1", Size: 11},
+		} {
+			_, err := svc.FindExceptions(ctx, query)
+			assert.Equal(t, recon.ErrorInvalidExceptionQuery, err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", "recon.exceptions.page.size.max").Return(int64(0))
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindRunByID", ctx, "run-2").Return(nil, reconciliation.ErrorRunNotFound)
+		svc := recon.NewService(cfg, nil, runRepository, nil)
+
+		_, err := svc.FindExceptions(ctx, recon.ExceptionQuery{RunID: "run-2"})
+		assert.Equal(t, recon.ErrorRunNotFound, err)
+	})
+}
+
+func TestService_Proceed_CarriesStatus(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetInt", mock.Anything).Return(int64(100))
+	cfg.On("GetArray", mock.Anything).Return([]string(nil))
+	cfg.On("GetString", mock.Anything).Return("")
+
+	generate := mocks.NewGenerate(t)
+	generate.On("Time").Return(now)
+	generate.On("UUID").Return("run-2")
+
+	// TX2 was written off in an earlier run, TX3 was never seen
+	var saved []*reconciliation.Exception
+	runRepository := mocks.NewReconciliationRepository(t)
+	runRepository.On("FindLatestExceptions", ctx, mock.MatchedBy(func(fingerprints []string) bool {
+		return len(fingerprints) == 2
+	})).Return(func(_ context.Context, fingerprints []string) []*reconciliation.Exception {
+		return []*reconciliation.Exception{
+			{
+				ID: 7, Fingerprint: fingerprints[0], Status: "WRITTEN_OFF", Assignee: "ops",
+				ResolutionReason: "bank fee", StatusUpdatedAt: sql.NullTime{Time: now, Valid: true},
+				OriginID: sql.NullInt64{Int64: 3, Valid: true},
+			},
+		}
+	}, nil)
+	runRepository.On("SaveRun", ctx, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(3).([]*reconciliation.Exception) }).
+		Return(nil)
+	svc := recon.NewService(cfg, nil, runRepository, generate)
+
+	file := recon.NewUploadFile(
+		nil,
+		[]recon.BankStatementUploadFile{
+			{UniqueID: "TX2", Amount: decimal.NewFromInt(200), BankCode: "014", Date: now},
+			{UniqueID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "014", Date: now},
+		},
+		now,
+		now,
+	)
+
+	_, err := svc.Proceed(ctx, file)
+	assert.NoError(t, err)
+	assert.Len(t, saved, 2)
+
+	assert.Equal(t, "TX2", saved[0].UniqueID)
+	assert.Equal(t, "WRITTEN_OFF", saved[0].Status)
+	assert.Equal(t, "bank fee", saved[0].ResolutionReason)
+	assert.Equal(t, "ops", saved[0].Assignee)
+	assert.Equal(t, int64(3), saved[0].OriginID.Int64)
+
+	assert.Equal(t, "TX3", saved[1].UniqueID)
+	assert.Equal(t, "OPEN", saved[1].Status)
+	assert.False(t, saved[1].OriginID.Valid)
+}
+
+func TestService_UpdateException(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 4, 9, 0, 0, 0, time.UTC)
+	assignee := "budi"
+
+	newStored := func(status string) *reconciliation.Exception {
+		return &reconciliation.Exception{
+			ID: 9, RunID: "run-1", BankCode: "014", Reason: "MISSING_IN_SYSTEM", Side: "BANK", UniqueID: "TX2", Status: status,
+		}
+	}
+
+	t.Run("resolve", func(t *testing.T) {
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now)
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindExceptionByID", ctx, uint64(9)).Return(newStored("INVESTIGATING"), nil)
+		runRepository.On("UpdateExceptionStatus", ctx,
+			mock.MatchedBy(func(exception *reconciliation.Exception) bool {
+				return exception.Status == "RESOLVED" && exception.ResolutionReason == "late settlement" &&
+					exception.Assignee == "budi" && exception.StatusUpdatedAt.Time.Equal(now)
+			}),
+			"INVESTIGATING",
+			&reconciliation.Comment{ExceptionID: 9, Author: "siti", Status: "RESOLVED", Body: "settled on the 4th", CreatedAt: now},
+		).Return(nil)
+		runRepository.On("FindComments", ctx, uint64(9)).Return([]*reconciliation.Comment{
+			{ID: 1, ExceptionID: 9, Author: "siti", Status: "RESOLVED", Body: "settled on the 4th", CreatedAt: now},
+		}, nil)
+		svc := recon.NewService(nil, nil, runRepository, generate)
+
+		res, err := svc.UpdateException(ctx, recon.ExceptionUpdate{
+			ID: 9, Status: "resolved", Assignee: &assignee, ResolutionReason: "late settlement", Author: "siti", Comment: "settled on the 4th",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, recon.ExceptionStatusResolved, res.Status)
+		assert.Equal(t, "late settlement", res.ResolutionReason)
+		assert.Equal(t, now, *res.StatusUpdatedAt)
+		assert.Equal(t, "TX2", res.BankStatement.UniqueID)
+		assert.Len(t, res.Comments, 1)
+		assert.Equal(t, recon.ExceptionStatusResolved, res.Comments[0].Status)
+	})
+
+	t.Run("reopen clears the resolution", func(t *testing.T) {
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now)
+
+		stored := newStored("WRITTEN_OFF")
+		stored.ResolutionReason = "bank fee"
+		stored.OriginID = sql.NullInt64{Int64: 3, Valid: true}
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindExceptionByID", ctx, uint64(9)).Return(stored, nil)
+		runRepository.On("UpdateExceptionStatus", ctx,
+			mock.MatchedBy(func(exception *reconciliation.Exception) bool {
+				return exception.Status == "OPEN" && exception.ResolutionReason == ""
+			}),
+			"WRITTEN_OFF", mock.Anything,
+		).Return(nil)
+		runRepository.On("FindComments", ctx, uint64(3)).Return(nil, nil)
+		svc := recon.NewService(nil, nil, runRepository, generate)
+
+		res, err := svc.UpdateException(ctx, recon.ExceptionUpdate{ID: 9, Status: "OPEN", Author: "siti"})
+		assert.NoError(t, err)
+		assert.Equal(t, recon.ExceptionStatusOpen, res.Status)
+		assert.Equal(t, uint64(3), *res.OriginID)
+		assert.Empty(t, res.Comments)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			status  string
+			update  recon.ExceptionUpdate
+			wantErr error
+		}{
+			{name: "no author", status: "OPEN", update: recon.ExceptionUpdate{ID: 9, Status: "INVESTIGATING"}, wantErr: recon.ErrorIncompleteExceptionUpdate},
+			{name: "unknown status", status: "OPEN", update: recon.ExceptionUpdate{ID: 9, Status: "DONE", Author: "siti"}, wantErr: recon.ErrorInvalidExceptionStatus},
+			{name: "closed", status: "RESOLVED", update: recon.ExceptionUpdate{ID: 9, Status: "WRITTEN_OFF", ResolutionReason: "fee", Author: "siti"}, wantErr: recon.ErrorInvalidStatusTransition},
+			{name: "no reason", status: "OPEN", update: recon.ExceptionUpdate{ID: 9, Status: "RESOLVED", Author: "siti"}, wantErr: recon.ErrorIncompleteExceptionUpdate},
+			{name: "nothing to change", status: "OPEN", update: recon.ExceptionUpdate{ID: 9, Author: "siti"}, wantErr: recon.ErrorIncompleteExceptionUpdate},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				runRepository := mocks.NewReconciliationRepository(t)
+				runRepository.On("FindExceptionByID", ctx, uint64(9)).Return(newStored(tt.status), nil).Maybe()
+				svc := recon.NewService(nil, nil, runRepository, nil)
+
+				_, err := svc.UpdateException(ctx, tt.update)
+				assert.Equal(t, tt.wantErr, err)
+			})
+		}
+	})
+
+	t.Run("changed meanwhile", func(t *testing.T) {
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now)
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindExceptionByID", ctx, uint64(9)).Return(newStored("OPEN"), nil)
+		runRepository.On("UpdateExceptionStatus", ctx, mock.Anything, "OPEN", mock.Anything).Return(reconciliation.ErrorExceptionChanged)
+		svc := recon.NewService(nil, nil, runRepository, generate)
+
+		_, err := svc.UpdateException(ctx, recon.ExceptionUpdate{ID: 9, Status: "INVESTIGATING", Author: "siti"})
+		assert.Equal(t, recon.ErrorExceptionChanged, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindExceptionByID", ctx, uint64(10)).Return(nil, reconciliation.ErrorExceptionNotFound)
+		svc := recon.NewService(nil, nil, runRepository, nil)
+
+		_, err := svc.UpdateException(ctx, recon.ExceptionUpdate{ID: 10, Status: "INVESTIGATING", Author: "siti"})
+		assert.Equal(t, recon.ErrorExceptionNotFound, err)
+	})
+}
+
+func TestService_CommentException(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 4, 9, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		generate := mocks.NewGenerate(t)
+		generate.On("Time").Return(now)
+
+		runRepository := mocks.NewReconciliationRepository(t)
+		runRepository.On("FindExceptionByID", ctx, uint64(9)).Return(&reconciliation.Exception{ID: 9, Status: "INVESTIGATING"}, nil)
+		runRepository.On("SaveComment", ctx,
+			&reconciliation.Comment{ExceptionID: 9, Author: "siti", Status: "INVESTIGATING", Body: "asked the bank", CreatedAt: now},
+		).Return(nil)
+		runRepository.On("FindComments", ctx, uint64(9)).Return([]*reconciliation.Comment{
+			{ID: 1, ExceptionID: 9, Author: "siti", Status: "INVESTIGATING", Body: "asked the bank", CreatedAt: now},
+		}, nil)
+		svc := recon.NewService(nil, nil, runRepository, generate)
+
+		res, err := svc.CommentException(ctx, 9, "siti", " asked the bank ")
+		assert.NoError(t, err)
+		assert.Len(t, res.Comments, 1)
+		assert.Equal(t, "asked the bank", res.Comments[0].Body)
+	})
+
+	t.Run("empty comment", func(t *testing.T) {
+		svc := recon.NewService(nil, nil, mocks.NewReconciliationRepository(t), nil)
+
+		_, err := svc.CommentException(ctx, 9, "siti", " ")
+		assert.Equal(t, recon.ErrorIncompleteExceptionUpdate, err)
+	})
+}
@@ -0,0 +1,108 @@
+package recon
+
+import (
+	"strings"
+)
+
+const (
+	TransactionTypeDebit   = "DEBIT"
+	TransactionTypeCredit  = "CREDIT"
+	TransactionTypeUnknown = "UNKNOWN"
+
+	// SignConventionNone keeps the bank amount as it is.
+	SignConventionNone SignConvention = "NONE"
+	// SignConventionSigned means debits are exported as negative numbers.
+	SignConventionSigned SignConvention = "SIGNED"
+	// SignConventionDCColumn means the direction comes from a D/C column.
+	SignConventionDCColumn SignConvention = "DC_COLUMN"
+)
+
+type (
+	// SignConvention is how a bank tells debits and credits apart.
+	SignConvention string
+)
+
+func ParseSignConvention(value string) (SignConvention, error) {
+	switch convention := SignConvention(strings.ToUpper(strings.TrimSpace(value))); convention {
+	case "":
+		return SignConventionNone, nil
+	case SignConventionNone, SignConventionSigned, SignConventionDCColumn:
+		return convention, nil
+	default:
+		return "", ErrorInvalidSignConvention
+	}
+}
+
+// normalizeTransactionType maps D/C and DEBIT/CREDIT in any case onto
+// DEBIT or CREDIT. Anything else is kept as it is.
+func normalizeTransactionType(value string) string {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "D", "DR", "DB", TransactionTypeDebit:
+		return TransactionTypeDebit
+	case "C", "CR", TransactionTypeCredit:
+		return TransactionTypeCredit
+	default:
+		return strings.TrimSpace(value)
+	}
+}
+
+// normalizeTransaction makes the amount of a system transaction positive,
+// the direction is carried by its type.
+func normalizeTransaction(tx TransactionUploadFile) TransactionUploadFile {
+	tx.TransactionType = normalizeTransactionType(tx.TransactionType)
+	if tx.TransactionType == TransactionTypeDebit || tx.TransactionType == TransactionTypeCredit {
+		tx.Amount = tx.Amount.Abs()
+	}
+
+	return tx
+}
+
+// normalizeBankStatement turns the bank amount into a positive amount with a
+// type, following the convention of the bank.
+func (c SignConvention) normalizeBankStatement(b BankStatementUploadFile) BankStatementUploadFile {
+	switch c {
+	case SignConventionSigned:
+		b.TransactionType = TransactionTypeCredit
+		if b.Amount.IsNegative() {
+			b.TransactionType = TransactionTypeDebit
+		}
+		b.Amount = b.Amount.Abs()
+	case SignConventionDCColumn:
+		b.TransactionType = normalizeTransactionType(b.TransactionType)
+		b.Amount = b.Amount.Abs()
+	default:
+		b.TransactionType = normalizeTransactionType(b.TransactionType)
+	}
+
+	return b
+}
+
+// totalsByType sums both sides of a bank code per DEBIT and CREDIT.
+func totalsByType(txs []TransactionUploadFile, banks []BankStatementUploadFile) map[string]TypeTotal {
+	totals := make(map[string]TypeTotal)
+	for _, tx := range txs {
+		key := typeKey(tx.TransactionType)
+		total := totals[key]
+		total.TotalNumberOfTransactions++
+		total.TotalAmountTransactions = total.TotalAmountTransactions.Add(tx.Amount)
+		totals[key] = total
+	}
+
+	for _, b := range banks {
+		key := typeKey(b.TransactionType)
+		total := totals[key]
+		total.TotalNumberOfBankStatements++
+		total.TotalAmountBankStatements = total.TotalAmountBankStatements.Add(b.Amount)
+		totals[key] = total
+	}
+
+	return totals
+}
+
+func typeKey(transactionType string) string {
+	if transactionType == TransactionTypeDebit || transactionType == TransactionTypeCredit {
+		return transactionType
+	}
+
+	return TransactionTypeUnknown
+}
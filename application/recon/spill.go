@@ -0,0 +1,299 @@
+package recon
+
+import (
+	"amartha-recon-service/configuration"
+	"bufio"
+	"cmp"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+type (
+	// SpillSettings decide whether uploads are spilled to disk while read,
+	// and where to.
+	SpillSettings struct {
+		Enabled bool
+		// Dir is the temporary folder of the system when empty
+		Dir string
+	}
+
+	// Spill keeps the rows of an upload on disk while it is read, one file
+	// per bank code and side, so an upload larger than memory can be
+	// reconciled a partition at a time. It is not safe for concurrent use.
+	Spill struct {
+		dir            string
+		seq            int64
+		files          int
+		transactions   map[string]*spillWriter[TransactionUploadFile]
+		bankStatements map[string]*spillWriter[BankStatementUploadFile]
+	}
+
+	// spillRecord is a row with its position in the upload, rows read back
+	// from a partition are put back in the order they were uploaded.
+	spillRecord[T any] struct {
+		Seq int64
+		Row T
+	}
+
+	spillWriter[T any] struct {
+		path    string
+		file    *os.File
+		buffer  *bufio.Writer
+		encoder *gob.Encoder
+		rows    int
+	}
+
+	// spillPartitions are n spill files a set of rows is hashed onto by key.
+	// A row with an empty key can not be paired by it and goes to the carry
+	// file instead, which is read through and never loaded.
+	spillPartitions[T any] struct {
+		writers []*spillWriter[T]
+		carry   *spillWriter[T]
+		// split is the depth of a partition hashed again, 0 for the first hash
+		split int
+	}
+)
+
+// NewSpillSettings reads recon.spill.enabled and recon.spill.dir.
+func NewSpillSettings(cfg configuration.Configuration) SpillSettings {
+	return SpillSettings{
+		Enabled: cfg.GetBool("recon.spill.enabled"),
+		Dir:     cfg.GetString("recon.spill.dir"),
+	}
+}
+
+// NewSpill creates the spill files in a new folder of dir, the temporary
+// folder of the system when dir is empty. The caller closes it.
+func NewSpill(dir string) (*Spill, error) {
+	spillDir, err := os.MkdirTemp(dir, "recon-spill-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spill{
+		dir:            spillDir,
+		transactions:   make(map[string]*spillWriter[TransactionUploadFile]),
+		bankStatements: make(map[string]*spillWriter[BankStatementUploadFile]),
+	}, nil
+}
+
+func (s *Spill) AddTransaction(tx TransactionUploadFile) error {
+	writer, ok := s.transactions[tx.BankCode]
+	if !ok {
+		var err error
+		if writer, err = newSpillWriter[TransactionUploadFile](s.path()); err != nil {
+			return err
+		}
+		s.transactions[tx.BankCode] = writer
+	}
+
+	s.seq++
+	return writer.write(s.seq, tx)
+}
+
+func (s *Spill) AddBankStatement(b BankStatementUploadFile) error {
+	writer, ok := s.bankStatements[b.BankCode]
+	if !ok {
+		var err error
+		if writer, err = newSpillWriter[BankStatementUploadFile](s.path()); err != nil {
+			return err
+		}
+		s.bankStatements[b.BankCode] = writer
+	}
+
+	s.seq++
+	return writer.write(s.seq, b)
+}
+
+// Close removes the spill files.
+func (s *Spill) Close() error {
+	for _, writer := range s.transactions {
+		writer.close()
+	}
+
+	for _, writer := range s.bankStatements {
+		writer.close()
+	}
+
+	return os.RemoveAll(s.dir)
+}
+
+// path names the next spill file.
+func (s *Spill) path() string {
+	s.files++
+	return filepath.Join(s.dir, fmt.Sprintf("%06d.gob", s.files))
+}
+
+// bankCodes are the bank codes of the bank statements, sorted.
+func (s *Spill) bankCodes() []string {
+	var codes []string
+	for code := range s.bankStatements {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// allBankCodes are the bank codes of either side, sorted.
+func (s *Spill) allBankCodes() []string {
+	codes := s.bankCodes()
+	for code := range s.transactions {
+		if _, ok := s.bankStatements[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// flush writes out what is buffered, the spill files can be read after it.
+func (s *Spill) flush() error {
+	for _, writer := range s.transactions {
+		if err := writer.buffer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for _, writer := range s.bankStatements {
+		if err := writer.buffer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newSpillWriter[T any](path string) (*spillWriter[T], error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bufio.NewWriter(file)
+	return &spillWriter[T]{path: path, file: file, buffer: buffer, encoder: gob.NewEncoder(buffer)}, nil
+}
+
+func (w *spillWriter[T]) write(seq int64, row T) error {
+	w.rows++
+	return w.encoder.Encode(spillRecord[T]{Seq: seq, Row: row})
+}
+
+func (w *spillWriter[T]) close() error {
+	flushErr := w.buffer.Flush()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	return flushErr
+}
+
+// readSpill hands the records of a spill file to fn in the order written.
+// A file which was never created has no records.
+func readSpill[T any](path string, fn func(seq int64, row T) error) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	for {
+		var record spillRecord[T]
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(record.Seq, record.Row); err != nil {
+			return err
+		}
+	}
+}
+
+// loadSpill reads a spill file into memory in upload order.
+func loadSpill[T any](path string) ([]spillRecord[T], error) {
+	var records []spillRecord[T]
+	err := readSpill(path, func(seq int64, row T) error {
+		records = append(records, spillRecord[T]{Seq: seq, Row: row})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(records, func(a, b spillRecord[T]) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+
+	return records, nil
+}
+
+func newSpillPartitions[T any](spill *Spill, n int) (*spillPartitions[T], error) {
+	partitions := &spillPartitions[T]{writers: make([]*spillWriter[T], n)}
+	for i := range partitions.writers {
+		writer, err := newSpillWriter[T](spill.path())
+		if err != nil {
+			partitions.remove()
+			return nil, err
+		}
+		partitions.writers[i] = writer
+	}
+
+	carry, err := newSpillWriter[T](spill.path())
+	if err != nil {
+		partitions.remove()
+		return nil, err
+	}
+	partitions.carry = carry
+
+	return partitions, nil
+}
+
+func (p *spillPartitions[T]) write(key string, seq int64, row T) error {
+	if key == "" || len(p.writers) == 0 {
+		return p.carry.write(seq, row)
+	}
+
+	if p.split > 0 {
+		return p.writers[splitPartitionOf(key, p.split, len(p.writers))].write(seq, row)
+	}
+
+	return p.writers[partitionOf(key, len(p.writers))].write(seq, row)
+}
+
+// close flushes the partitions, they can be read after it.
+func (p *spillPartitions[T]) close() error {
+	for _, writer := range p.all() {
+		if err := writer.close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove deletes the partitions once they were read.
+func (p *spillPartitions[T]) remove() {
+	for _, writer := range p.all() {
+		if writer != nil {
+			writer.file.Close()
+			os.Remove(writer.path)
+		}
+	}
+}
+
+func (p *spillPartitions[T]) all() []*spillWriter[T] {
+	return append(slices.Clip(p.writers), p.carry)
+}
@@ -0,0 +1,610 @@
+package recon
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	defaultSpillPartitionRows = 50000
+
+	// maxSpillSplits bounds how many times a partition is hashed again
+	maxSpillSplits = 8
+)
+
+type (
+	// spillBank is one bank code of a spilled upload, with its rows hashed
+	// onto the partitions of the first round.
+	spillBank struct {
+		code          string
+		policy        bankPolicy
+		rounds        []round
+		partitions    int
+		partitionRows int
+		// omitMatched leaves the matched pairs and groups out of the details,
+		// only their totals are kept
+		omitMatched bool
+		// result carries the totals and the duplicates, known before matching
+		result         ResultReconciliation
+		duplicates     []DuplicateGroup
+		transactions   *spillPartitions[TransactionUploadFile]
+		bankStatements *spillPartitions[BankStatementUploadFile]
+	}
+
+	// spillDuplicate is a duplicate group with the position its id first
+	// shows up at, the groups of all partitions are put back in that order.
+	spillDuplicate struct {
+		seq   int64
+		group DuplicateGroup
+	}
+)
+
+// proceedSpill reconciles an upload spilled to disk. The bank codes are
+// reconciled one after the other, and every round of a bank code a
+// partition of recon.spill.partition.rows rows at a time, so the result is
+// the only thing growing with the upload. The rows a round can not pair by
+// its key, and the ones no round paired, are read through instead of
+// loaded. The rounds and the order of the rows are the ones of
+// reconcileBank, which gives the same result.
+func (s *service) proceedSpill(
+	ctx context.Context,
+	file *UploadFile,
+	validation *ValidationReport,
+	startedAt time.Time) (ShowResultReconciliation, error) {
+	spill := file.spill
+	maxRowsTransaction := int(s.cfg.GetInt("max.rows.transactions"))
+	maxRowsBank := int(s.cfg.GetInt("max.rows.bank"))
+
+	// The transactions table is read a row at a time into the spill, like an
+	// uploaded system file, so max.rows.transactions does not bound it either
+	if file.systemFromStore {
+		bankCodes, err := file.storeBankCodes(spill.bankCodes())
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+
+		if err := s.eachTransaction(ctx, file, bankCodes, spill.AddTransaction); err != nil {
+			return ShowResultReconciliation{}, err
+		}
+	}
+
+	if err := spill.flush(); err != nil {
+		return ShowResultReconciliation{}, err
+	}
+
+	partitionRows := int(s.cfg.GetInt("recon.spill.partition.rows"))
+	if partitionRows < 1 {
+		partitionRows = defaultSpillPartitionRows
+	}
+	omitMatched := s.cfg.GetBool("recon.spill.omit.matched")
+
+	policies := make(map[string]bankPolicy)
+	for _, code := range spill.allBankCodes() {
+		policy, err := s.bankPolicy(code)
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+		policies[code] = policy
+	}
+
+	// Duplicates of every bank code are known before any of them is matched,
+	// like an upload held in memory
+	var banks []*spillBank
+	duplicatesByBank := make(map[string][]DuplicateGroup)
+	for _, code := range spill.allBankCodes() {
+		bank, err := s.prepareSpillBank(ctx, spill, code, policies[code], partitionRows)
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+
+		bank.omitMatched = omitMatched
+		duplicatesByBank[code] = bank.duplicates
+		banks = append(banks, bank)
+	}
+
+	if file.duplicatePolicy == DuplicatePolicyReject {
+		if duplicates := rejectedDuplicates(duplicatesByBank); len(duplicates) > 0 {
+			return ShowResultReconciliation{Duplicates: duplicates}, ErrorDuplicateRows
+		}
+	}
+
+	file.progress.SetBanksTotal(len(banks))
+	var finalResults []ResultReconciliation
+	for _, bank := range banks {
+		result, err := s.reconcileSpillBank(ctx, spill, bank)
+		if err != nil {
+			return ShowResultReconciliation{}, err
+		}
+
+		finalResults = append(finalResults, result)
+		file.progress.AddBanksDone(1)
+	}
+
+	snapshot := newConfigSnapshot(file, policies, maxRowsTransaction, maxRowsBank, 0)
+	snapshot.SpillPartitionRows = partitionRows
+	snapshot.SpillOmitMatched = omitMatched
+	return s.complete(ctx, file, finalResults, validation, snapshot, startedAt)
+}
+
+// prepareSpillBank normalizes the rows of a bank code, and hashes them by id
+// to find the duplicates and then by the key of the first round.
+func (s *service) prepareSpillBank(
+	ctx context.Context,
+	spill *Spill,
+	code string,
+	policy bankPolicy,
+	partitionRows int) (*spillBank, error) {
+	bank := &spillBank{
+		code:          code,
+		policy:        policy,
+		rounds:        s.rounds(code, policy),
+		partitionRows: partitionRows,
+		result:        newResultReconciliation(code),
+	}
+
+	var txPath, bankPath string
+	rows := 0
+	if writer, ok := spill.transactions[code]; ok {
+		txPath = writer.path
+		rows += writer.rows
+		bank.result.TotalNumberOfTransactions = writer.rows
+	}
+
+	if writer, ok := spill.bankStatements[code]; ok {
+		bankPath = writer.path
+		rows += writer.rows
+	}
+	bank.partitions = max(1, (rows+partitionRows-1)/partitionRows)
+
+	txByID, err := newSpillPartitions[TransactionUploadFile](spill, bank.partitions)
+	if err != nil {
+		return nil, err
+	}
+	defer txByID.remove()
+
+	bankByID, err := newSpillPartitions[BankStatementUploadFile](spill, bank.partitions)
+	if err != nil {
+		return nil, err
+	}
+	defer bankByID.remove()
+
+	err = readSpill(txPath, func(seq int64, tx TransactionUploadFile) error {
+		tx = normalizeTransaction(tx)
+		return txByID.write(tx.TransactionID, seq, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readSpill(bankPath, func(seq int64, b BankStatementUploadFile) error {
+		b = policy.sign.normalizeBankStatement(b)
+		return bankByID.write(b.UniqueID, seq, b)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := txByID.close(); err != nil {
+		return nil, err
+	}
+
+	if err := bankByID.close(); err != nil {
+		return nil, err
+	}
+
+	if bank.transactions, bank.bankStatements, err = bank.roundInput(spill, 0); err != nil {
+		return nil, err
+	}
+
+	var systemDuplicates, bankDuplicates []spillDuplicate
+	prepare := func(txs []spillRecord[TransactionUploadFile], banks []spillRecord[BankStatementUploadFile]) error {
+		txRows, bankRows := spillRows(txs), spillRows(banks)
+		totals := newResultReconciliation(code)
+		totals.TotalsByTransactionType = totalsByType(txRows, bankRows)
+		bank.result.merge(totals)
+
+		// Every row of an id is in the same partition, its first one is in front
+		firstSeq := make(map[ExceptionSide]map[string]int64)
+		firstSeq[ExceptionSideSystem] = make(map[string]int64)
+		firstSeq[ExceptionSideBank] = make(map[string]int64)
+		for j := len(txs) - 1; j >= 0; j-- {
+			firstSeq[ExceptionSideSystem][txs[j].Row.TransactionID] = txs[j].Seq
+		}
+
+		for j := len(banks) - 1; j >= 0; j-- {
+			firstSeq[ExceptionSideBank][banks[j].Row.UniqueID] = banks[j].Seq
+		}
+
+		for _, group := range detectDuplicates(txRows, bankRows) {
+			duplicate := spillDuplicate{seq: firstSeq[group.Side][group.ID], group: group}
+			if group.Side == ExceptionSideSystem {
+				systemDuplicates = append(systemDuplicates, duplicate)
+			} else {
+				bankDuplicates = append(bankDuplicates, duplicate)
+			}
+		}
+
+		return bank.writeRound(0, bank.transactions, bank.bankStatements, txs, banks)
+	}
+
+	for i := 0; i < bank.partitions; i++ {
+		err := eachSpillPartition(ctx, spill, txByID.writers[i], bankByID.writers[i], partitionRows,
+			transactionID, uniqueID, 0, prepare)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bank.transactions.close(); err != nil {
+		return nil, err
+	}
+
+	if err := bank.bankStatements.close(); err != nil {
+		return nil, err
+	}
+
+	for _, duplicates := range [][]spillDuplicate{systemDuplicates, bankDuplicates} {
+		slices.SortFunc(duplicates, func(a, b spillDuplicate) int {
+			return cmp.Compare(a.seq, b.seq)
+		})
+
+		for _, duplicate := range duplicates {
+			bank.duplicates = append(bank.duplicates, duplicate.group)
+		}
+	}
+
+	return bank, nil
+}
+
+// reconcileSpillBank runs the rounds of a bank code on its partitions, the
+// rows left by a partition are hashed onto the partitions of the next round.
+func (s *service) reconcileSpillBank(ctx context.Context, spill *Spill, bank *spillBank) (ResultReconciliation, error) {
+	result := bank.result
+	txIDs, bankIDs := result.addDuplicates(bank.duplicates)
+
+	txIn, bankIn := bank.transactions, bank.bankStatements
+	defer func() {
+		txIn.remove()
+		bankIn.remove()
+	}()
+
+	for k, round := range bank.rounds {
+		txOut, bankOut, err := bank.roundInput(spill, k+1)
+		if err != nil {
+			return ResultReconciliation{}, err
+		}
+
+		txKey, bankKey := bank.keys(k)
+		match := func(txs []spillRecord[TransactionUploadFile], banks []spillRecord[BankStatementUploadFile]) error {
+			txLeft, bankLeft := reconcileSpillPartition(&result, round, txs, banks, bank.omitMatched)
+			return bank.writeRound(k+1, txOut, bankOut, txLeft, bankLeft)
+		}
+
+		for i := range txIn.writers {
+			err = eachSpillPartition(ctx, spill, txIn.writers[i], bankIn.writers[i], bank.partitionRows, txKey, bankKey, 0, match)
+			if err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = bank.carryOver(k+1, txIn, bankIn, txOut, bankOut)
+		}
+
+		if err != nil {
+			txOut.remove()
+			bankOut.remove()
+			return ResultReconciliation{}, err
+		}
+
+		txIn.remove()
+		bankIn.remove()
+		txIn, bankIn = txOut, bankOut
+		if err := txIn.close(); err != nil {
+			return ResultReconciliation{}, err
+		}
+
+		if err := bankIn.close(); err != nil {
+			return ResultReconciliation{}, err
+		}
+	}
+
+	// The rows no round paired are all carried over, they are reported as
+	// they are read
+	err := readSpill(txIn.carry.path, func(_ int64, tx TransactionUploadFile) error {
+		result.addLeftovers([]TransactionUploadFile{tx}, nil, txIDs, bankIDs)
+		return nil
+	})
+	if err != nil {
+		return ResultReconciliation{}, err
+	}
+
+	err = readSpill(bankIn.carry.path, func(_ int64, b BankStatementUploadFile) error {
+		result.addLeftovers(nil, []BankStatementUploadFile{b}, txIDs, bankIDs)
+		return nil
+	})
+	if err != nil {
+		return ResultReconciliation{}, err
+	}
+
+	sortDetails(&result.ResultReconciliationDetails)
+	return result, nil
+}
+
+// reconcileSpillPartition runs a round on one partition and returns the rows
+// it could not pair.
+func reconcileSpillPartition(
+	result *ResultReconciliation,
+	round round,
+	txs []spillRecord[TransactionUploadFile],
+	banks []spillRecord[BankStatementUploadFile],
+	omitMatched bool) ([]spillRecord[TransactionUploadFile], []spillRecord[BankStatementUploadFile]) {
+	if len(txs) == 0 || len(banks) == 0 {
+		// Nothing to pair with, carry the rows over to the next round
+		return txs, banks
+	}
+
+	outcome := round.match(spillRows(txs), positions(len(txs)), spillRows(banks), positions(len(banks)))
+	if omitMatched {
+		outcome.result.ResultReconciliationDetails.TransactionMatched = nil
+		outcome.result.ResultReconciliationDetails.GroupMatched = nil
+	}
+	result.merge(outcome.result)
+
+	txLeft := make([]spillRecord[TransactionUploadFile], 0, len(outcome.txLeft))
+	for _, i := range outcome.txLeft {
+		txLeft = append(txLeft, txs[i])
+	}
+
+	bankLeft := make([]spillRecord[BankStatementUploadFile], 0, len(outcome.bankLeft))
+	for _, i := range outcome.bankLeft {
+		bankLeft = append(bankLeft, banks[i])
+	}
+
+	return txLeft, bankLeft
+}
+
+// eachSpillPartition hands the rows of a partition to fn, loaded in upload
+// order. Keys do not spread evenly, so a partition above partitionRows rows
+// is hashed again onto partitions of its own by a salted hash of the same
+// keys, the rows of a key stay together. Only the rows of a single key,
+// which can not be paired apart, are loaded above partitionRows.
+func eachSpillPartition(
+	ctx context.Context,
+	spill *Spill,
+	tx *spillWriter[TransactionUploadFile],
+	bank *spillWriter[BankStatementUploadFile],
+	partitionRows int,
+	txKey func(TransactionUploadFile) string,
+	bankKey func(BankStatementUploadFile) string,
+	depth int,
+	fn func([]spillRecord[TransactionUploadFile], []spillRecord[BankStatementUploadFile]) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if rows := tx.rows + bank.rows; rows > partitionRows && depth < maxSpillSplits {
+		single, err := singleSpillKey(tx, bank, txKey, bankKey)
+		if err != nil {
+			return err
+		}
+
+		// Hashing a single key again would not split it
+		if !single {
+			return splitSpillPartition(ctx, spill, tx, bank, (rows+partitionRows-1)/partitionRows,
+				partitionRows, txKey, bankKey, depth, fn)
+		}
+	}
+
+	txs, err := loadSpill[TransactionUploadFile](tx.path)
+	if err != nil {
+		return err
+	}
+
+	banks, err := loadSpill[BankStatementUploadFile](bank.path)
+	if err != nil {
+		return err
+	}
+
+	return fn(txs, banks)
+}
+
+// splitSpillPartition hashes a partition onto n partitions of depth+1 and
+// hands each of them to eachSpillPartition.
+func splitSpillPartition(
+	ctx context.Context,
+	spill *Spill,
+	tx *spillWriter[TransactionUploadFile],
+	bank *spillWriter[BankStatementUploadFile],
+	n, partitionRows int,
+	txKey func(TransactionUploadFile) string,
+	bankKey func(BankStatementUploadFile) string,
+	depth int,
+	fn func([]spillRecord[TransactionUploadFile], []spillRecord[BankStatementUploadFile]) error) error {
+	txParts, err := newSpillPartitions[TransactionUploadFile](spill, n)
+	if err != nil {
+		return err
+	}
+	defer txParts.remove()
+
+	bankParts, err := newSpillPartitions[BankStatementUploadFile](spill, n)
+	if err != nil {
+		return err
+	}
+	defer bankParts.remove()
+
+	txParts.split, bankParts.split = depth+1, depth+1
+	err = readSpill(tx.path, func(seq int64, row TransactionUploadFile) error {
+		return txParts.write(txKey(row), seq, row)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readSpill(bank.path, func(seq int64, row BankStatementUploadFile) error {
+		return bankParts.write(bankKey(row), seq, row)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := txParts.close(); err != nil {
+		return err
+	}
+
+	if err := bankParts.close(); err != nil {
+		return err
+	}
+
+	for i := range txParts.writers {
+		err := eachSpillPartition(ctx, spill, txParts.writers[i], bankParts.writers[i], partitionRows, txKey, bankKey, depth+1, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// singleSpillKey tells whether every row of a partition has the same key.
+// The keys of a partition are never empty, those rows are carried over.
+func singleSpillKey(
+	tx *spillWriter[TransactionUploadFile],
+	bank *spillWriter[BankStatementUploadFile],
+	txKey func(TransactionUploadFile) string,
+	bankKey func(BankStatementUploadFile) string) (bool, error) {
+	errMixed := errors.New("mixed keys")
+	first := ""
+	track := func(key string) error {
+		if first == "" {
+			first = key
+		} else if key != first {
+			return errMixed
+		}
+		return nil
+	}
+
+	err := readSpill(tx.path, func(_ int64, row TransactionUploadFile) error {
+		return track(txKey(row))
+	})
+	if err == nil {
+		err = readSpill(bank.path, func(_ int64, row BankStatementUploadFile) error {
+			return track(bankKey(row))
+		})
+	}
+
+	if errors.Is(err, errMixed) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// roundInput creates the partitions of round k, past the last round the
+// rows none of them paired are all carried over.
+func (b *spillBank) roundInput(
+	spill *Spill,
+	k int) (*spillPartitions[TransactionUploadFile], *spillPartitions[BankStatementUploadFile], error) {
+	n := b.partitions
+	if k >= len(b.rounds) {
+		n = 0
+	}
+
+	txs, err := newSpillPartitions[TransactionUploadFile](spill, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	banks, err := newSpillPartitions[BankStatementUploadFile](spill, n)
+	if err != nil {
+		txs.remove()
+		return nil, nil, err
+	}
+
+	return txs, banks, nil
+}
+
+// keys are the ones of the rule of round k, past the last round every key is
+// empty.
+func (b *spillBank) keys(k int) (func(TransactionUploadFile) string, func(BankStatementUploadFile) string) {
+	if k < len(b.rounds) {
+		return b.rounds[k].rule.transactionKey, b.rounds[k].rule.bankKey
+	}
+
+	return func(TransactionUploadFile) string { return "" }, func(BankStatementUploadFile) string { return "" }
+}
+
+// writeRound hashes rows onto the partitions of round k by the key of its
+// rule.
+func (b *spillBank) writeRound(
+	k int,
+	txPartitions *spillPartitions[TransactionUploadFile],
+	bankPartitions *spillPartitions[BankStatementUploadFile],
+	txs []spillRecord[TransactionUploadFile],
+	banks []spillRecord[BankStatementUploadFile]) error {
+	txKey, bankKey := b.keys(k)
+
+	for _, tx := range txs {
+		if err := txPartitions.write(txKey(tx.Row), tx.Seq, tx.Row); err != nil {
+			return err
+		}
+	}
+
+	for _, bank := range banks {
+		if err := bankPartitions.write(bankKey(bank.Row), bank.Seq, bank.Row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// carryOver hashes the rows carried over from the round before k onto the
+// partitions of round k, as they are read.
+func (b *spillBank) carryOver(
+	k int,
+	txIn *spillPartitions[TransactionUploadFile],
+	bankIn *spillPartitions[BankStatementUploadFile],
+	txOut *spillPartitions[TransactionUploadFile],
+	bankOut *spillPartitions[BankStatementUploadFile]) error {
+	txKey, bankKey := b.keys(k)
+	err := readSpill(txIn.carry.path, func(seq int64, tx TransactionUploadFile) error {
+		return txOut.write(txKey(tx), seq, tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return readSpill(bankIn.carry.path, func(seq int64, bank BankStatementUploadFile) error {
+		return bankOut.write(bankKey(bank), seq, bank)
+	})
+}
+
+func transactionID(tx TransactionUploadFile) string {
+	return tx.TransactionID
+}
+
+func uniqueID(b BankStatementUploadFile) string {
+	return b.UniqueID
+}
+
+func spillRows[T any](records []spillRecord[T]) []T {
+	rows := make([]T, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, record.Row)
+	}
+
+	return rows
+}
+
+func positions(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+
+	return idx
+}
@@ -0,0 +1,95 @@
+package recon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_eachSpillPartition(t *testing.T) {
+	ctx := context.Background()
+
+	newPartition := func(t *testing.T, spill *Spill, keys ...string) (*spillPartitions[TransactionUploadFile], *spillPartitions[BankStatementUploadFile]) {
+		txs, err := newSpillPartitions[TransactionUploadFile](spill, 1)
+		assert.NoError(t, err)
+		banks, err := newSpillPartitions[BankStatementUploadFile](spill, 1)
+		assert.NoError(t, err)
+
+		for i, key := range keys {
+			assert.NoError(t, txs.write(key, int64(2*i), TransactionUploadFile{TransactionID: key}))
+			assert.NoError(t, banks.write(key, int64(2*i+1), BankStatementUploadFile{UniqueID: key}))
+		}
+		assert.NoError(t, txs.close())
+		assert.NoError(t, banks.close())
+		return txs, banks
+	}
+
+	t.Run("success split above the rows of a partition", func(t *testing.T) {
+		spill, err := NewSpill(t.TempDir())
+		assert.NoError(t, err)
+		defer spill.Close()
+
+		var keys []string
+		for i := 0; i < 200; i++ {
+			keys = append(keys, fmt.Sprintf("TX%03d", i))
+		}
+		// A key shared by more rows than a partition holds stays whole
+		for i := 0; i < 15; i++ {
+			keys = append(keys, "HOT")
+		}
+		// Rows without a key are carried over, never loaded
+		keys = append(keys, "", "")
+
+		txs, banks := newPartition(t, spill, keys...)
+		assert.Equal(t, 2, txs.carry.rows)
+		assert.Equal(t, 2, banks.carry.rows)
+
+		rows, hot := 0, 0
+		err = eachSpillPartition(ctx, spill, txs.writers[0], banks.writers[0], 10, transactionID, uniqueID, 0,
+			func(txs []spillRecord[TransactionUploadFile], banks []spillRecord[BankStatementUploadFile]) error {
+				rows += len(txs) + len(banks)
+				for _, tx := range txs {
+					if tx.Row.TransactionID == "HOT" {
+						hot++
+					}
+				}
+
+				if hot > 0 && hot == len(txs) {
+					assert.Len(t, banks, 15)
+					return nil
+				}
+
+				assert.LessOrEqual(t, len(txs)+len(banks), 10)
+				// The rows of a key stay together, in upload order
+				for i := range txs {
+					assert.Equal(t, txs[i].Row.TransactionID, banks[i].Row.UniqueID)
+					if i > 0 {
+						assert.Less(t, txs[i-1].Seq, txs[i].Seq)
+					}
+				}
+				return nil
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, 2*215, rows)
+		assert.Equal(t, 15, hot)
+	})
+
+	t.Run("error cancelled", func(t *testing.T) {
+		spill, err := NewSpill(t.TempDir())
+		assert.NoError(t, err)
+		defer spill.Close()
+
+		txs, banks := newPartition(t, spill, "TX1", "TX2")
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err = eachSpillPartition(cancelled, spill, txs.writers[0], banks.writers[0], 10, transactionID, uniqueID, 0,
+			func([]spillRecord[TransactionUploadFile], []spillRecord[BankStatementUploadFile]) error {
+				t.Fatal("a cancelled partition is not loaded")
+				return nil
+			})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,136 @@
+package recon
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	MatchClassExact           MatchClass = "EXACT"
+	MatchClassWithinTolerance MatchClass = "WITHIN_TOLERANCE"
+	MatchClassMismatched      MatchClass = "MISMATCHED"
+
+	percentSuffix = "%"
+)
+
+var (
+	hundred = decimal.NewFromInt(100)
+)
+
+type (
+	MatchClass string
+
+	// Tolerance decides how far a paired bank statement may drift from the
+	// system transaction. Amount is either absolute, or a percentage of the
+	// transaction amount when AmountPercent is set. The time is only compared
+	// when CheckTime is set, then TimeDrift is the max allowed difference.
+	Tolerance struct {
+		Amount        decimal.Decimal
+		AmountPercent bool
+		CheckTime     bool
+		TimeDrift     time.Duration
+	}
+)
+
+// ParseTolerance parses an amount such as "500" or "0.5%" and a time drift
+// such as "24h". Empty values mean no tolerance on amount, and time is ignored.
+func ParseTolerance(amount, drift string) (Tolerance, error) {
+	var tolerance Tolerance
+
+	amount = strings.TrimSpace(amount)
+	if amount != "" {
+		if strings.HasSuffix(amount, percentSuffix) {
+			tolerance.AmountPercent = true
+			amount = strings.TrimSpace(strings.TrimSuffix(amount, percentSuffix))
+		}
+
+		value, err := decimal.NewFromString(amount)
+		if err != nil || value.IsNegative() {
+			return Tolerance{}, ErrorInvalidTolerance
+		}
+		tolerance.Amount = value
+	}
+
+	drift = strings.TrimSpace(drift)
+	if drift != "" {
+		value, err := time.ParseDuration(drift)
+		if err != nil || value < 0 {
+			return Tolerance{}, ErrorInvalidTolerance
+		}
+		tolerance.CheckTime = true
+		tolerance.TimeDrift = value
+	}
+
+	return tolerance, nil
+}
+
+// classify compares a paired transaction and bank statement. The reason is
+// only set when the pair is mismatched.
+func (t Tolerance) classify(tx TransactionUploadFile, b BankStatementUploadFile) (MatchClass, MismatchReason) {
+	return t.classifyGroup([]TransactionUploadFile{tx}, []BankStatementUploadFile{b})
+}
+
+// classifyGroup compares the sum of a settlement group on both sides. The
+// time drift is the largest one between any transaction and any bank line.
+func (t Tolerance) classifyGroup(txs []TransactionUploadFile, banks []BankStatementUploadFile) (MatchClass, MismatchReason) {
+	var drift time.Duration
+	for _, tx := range txs {
+		for _, b := range banks {
+			// The type is compared only when both sides have it
+			if tx.TransactionType != "" && b.TransactionType != "" &&
+				normalizeTransactionType(tx.TransactionType) != normalizeTransactionType(b.TransactionType) {
+				return MatchClassMismatched, MismatchReasonTypeMismatch
+			}
+
+			d := tx.TransactionTime.Sub(b.Date)
+			if d < 0 {
+				d = -d
+			}
+
+			if d > drift {
+				drift = d
+			}
+		}
+	}
+
+	txAmount := sumTransactions(txs)
+	diff := txAmount.Sub(sumBankStatements(banks)).Abs()
+	if diff.IsZero() && (!t.CheckTime || drift == 0) {
+		return MatchClassExact, ""
+	}
+
+	allowed := t.Amount
+	if t.AmountPercent {
+		allowed = txAmount.Abs().Mul(t.Amount).Div(hundred)
+	}
+
+	if diff.GreaterThan(allowed) {
+		return MatchClassMismatched, MismatchReasonAmountMismatch
+	}
+
+	if t.CheckTime && drift > t.TimeDrift {
+		return MatchClassMismatched, MismatchReasonDateMismatch
+	}
+
+	return MatchClassWithinTolerance, ""
+}
+
+func sumTransactions(txs []TransactionUploadFile) decimal.Decimal {
+	total := decimal.Zero
+	for _, tx := range txs {
+		total = total.Add(tx.Amount)
+	}
+
+	return total
+}
+
+func sumBankStatements(banks []BankStatementUploadFile) decimal.Decimal {
+	total := decimal.Zero
+	for _, b := range banks {
+		total = total.Add(b.Amount)
+	}
+
+	return total
+}
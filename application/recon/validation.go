@@ -0,0 +1,83 @@
+package recon
+
+import (
+	"strings"
+)
+
+const (
+	// ValidationModeReject fails the whole upload when a row is invalid.
+	ValidationModeReject ValidationMode = "REJECT"
+	// ValidationModeSkip reconciles the valid rows and lists the invalid ones.
+	ValidationModeSkip ValidationMode = "SKIP"
+
+	RowErrorMissingValue  RowErrorReason = "MISSING_VALUE"
+	RowErrorInvalidAmount RowErrorReason = "INVALID_AMOUNT"
+	RowErrorInvalidDate   RowErrorReason = "INVALID_DATE"
+	RowErrorMalformedRow  RowErrorReason = "MALFORMED_ROW"
+	// RowErrorBalanceMismatch is a statement whose entries do not add up
+	// from its opening to its closing balance.
+	RowErrorBalanceMismatch RowErrorReason = "BALANCE_MISMATCH"
+
+	// maxListedRowErrors keeps the report of a broken file readable, the
+	// total still counts every rejected row
+	maxListedRowErrors = 1000
+)
+
+type (
+	ValidationMode string
+
+	// RowErrorReason tells why a row of an uploaded file is invalid.
+	RowErrorReason string
+
+	// RowError is one invalid field of an uploaded file, a row may have several.
+	RowError struct {
+		File   string         `json:"file"`
+		Line   int            `json:"line"`
+		Column string         `json:"column,omitempty"`
+		Value  string         `json:"value,omitempty"`
+		Reason RowErrorReason `json:"reason"`
+	}
+
+	ValidationReport struct {
+		Mode                      ValidationMode `json:"mode"`
+		TotalNumberOfRejectedRows int            `json:"total_number_of_rejected_rows"`
+		RowErrors                 []RowError     `json:"row_errors"`
+	}
+)
+
+// ParseValidationMode parses the mode sent on the request, REJECT by default.
+func ParseValidationMode(value string) (ValidationMode, error) {
+	switch mode := ValidationMode(strings.ToUpper(strings.TrimSpace(value))); mode {
+	case "":
+		return ValidationModeReject, nil
+	case ValidationModeReject, ValidationModeSkip:
+		return mode, nil
+	default:
+		return "", ErrorInvalidValidationMode
+	}
+}
+
+// newValidationReport counts the rejected rows, the row errors are expected
+// in the order they were found.
+func newValidationReport(mode ValidationMode, rowErrors []RowError) *ValidationReport {
+	type row struct {
+		file string
+		line int
+	}
+
+	rejected := make(map[row]bool)
+	for _, rowError := range rowErrors {
+		rejected[row{file: rowError.File, line: rowError.Line}] = true
+	}
+
+	listed := rowErrors
+	if len(listed) > maxListedRowErrors {
+		listed = listed[:maxListedRowErrors]
+	}
+
+	return &ValidationReport{
+		Mode:                      mode,
+		TotalNumberOfRejectedRows: len(rejected),
+		RowErrors:                 append([]RowError{}, listed...),
+	}
+}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	"amartha-recon-service/application/recon"
+	"encoding/csv"
+	"io"
+)
+
+// writeCSV lists the exceptions of every bank code, the reason column tells
+// their category.
+func writeCSV(w io.Writer, results []recon.ResultReconciliation) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exceptionHeader); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		for _, exception := range result.ResultReconciliationDetails.Exceptions {
+			if err := writer.Write(csvRecord(exceptionRow(result.BankCode, exception))); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvRecord(row []cell) []string {
+	record := make([]string, 0, len(row))
+	for _, value := range row {
+		record = append(record, value.text)
+	}
+
+	return record
+}
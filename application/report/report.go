@@ -0,0 +1,260 @@
+package report
+
+import (
+	"amartha-recon-service/application/recon"
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	FormatCSV  Format = "CSV"
+	FormatXLSX Format = "XLSX"
+
+	csvContentType  = "text/csv"
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	summarySheet = "Summary"
+)
+
+var (
+	ErrorInvalidFormat = errors.New("format laporan tidak dikenal")
+
+	// Categories are the sheets of the exceptions, one per reason
+	Categories = []recon.MismatchReason{
+		recon.MismatchReasonMissingInBank,
+		recon.MismatchReasonMissingInSystem,
+		recon.MismatchReasonAmountMismatch,
+		recon.MismatchReasonDateMismatch,
+		recon.MismatchReasonTypeMismatch,
+		recon.MismatchReasonDuplicateID,
+	}
+
+	summaryHeader = []string{
+		"bank_code",
+		"total_number_of_transactions",
+		"total_number_of_matches_transactions",
+		"total_number_of_exact_matches",
+		"total_number_of_matches_within_tolerance",
+		"total_number_of_group_matches",
+		"total_number_of_mismatched_pairs",
+		"total_number_of_unmatched_transactions",
+		"total_number_of_duplicate_rows",
+		"total_amount_discrepancies",
+		"total_amount_discrepancies_within_tolerance",
+		"total_amount_discrepancies_mismatched",
+	}
+
+	exceptionHeader = []string{
+		"bank_code",
+		"reason",
+		"side",
+		"rule",
+		"transaction_id",
+		"terminal_rrn",
+		"system_amount",
+		"system_transaction_type",
+		"transaction_time",
+		"system_file",
+		"unique_id",
+		"bank_amount",
+		"bank_transaction_type",
+		"bank_date",
+		"bank_file",
+		"amount_difference",
+		"time_difference",
+	}
+)
+
+type (
+	// Format is how a report is downloaded, empty when the result is answered
+	// as JSON.
+	Format string
+
+	// cell is a value of a report, written as a number or a date when the
+	// format knows them.
+	cell struct {
+		text   string
+		number *decimal.Decimal
+		date   *time.Time
+	}
+)
+
+// ParseFormat parses the format query parameter, json or empty is the JSON
+// result.
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToUpper(strings.TrimSpace(value))); format {
+	case "", "JSON":
+		return "", nil
+	case FormatCSV, FormatXLSX:
+		return format, nil
+	default:
+		return "", ErrorInvalidFormat
+	}
+}
+
+// Negotiate picks the format of the format query parameter, else the first
+// media type of the Accept header it knows.
+func Negotiate(query, accept string) (Format, error) {
+	if strings.TrimSpace(query) != "" {
+		return ParseFormat(query)
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case csvContentType:
+			return FormatCSV, nil
+		case xlsxContentType:
+			return FormatXLSX, nil
+		case "application/json":
+			return "", nil
+		}
+	}
+
+	return "", nil
+}
+
+// ContentType is the media type of a report in format.
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return xlsxContentType
+	}
+
+	return csvContentType
+}
+
+// FileName names the report of name in format.
+func (f Format) FileName(name string) string {
+	return name + "." + strings.ToLower(string(f))
+}
+
+// Write writes the exceptions of results in format. A CSV lists all of them
+// with their reason, a workbook has a summary sheet with a row per bank code
+// and a sheet per reason.
+func Write(w io.Writer, format Format, results []recon.ResultReconciliation) error {
+	if format == FormatXLSX {
+		return writeXLSX(w, results)
+	}
+
+	return writeCSV(w, results)
+}
+
+// summaryColumns are the totals of a bank code, and its exceptions counted by
+// category.
+func summaryColumns() []string {
+	header := slices.Clone(summaryHeader)
+	for _, category := range Categories {
+		header = append(header, "exceptions_"+strings.ToLower(string(category)))
+	}
+
+	return header
+}
+
+func summaryRow(result recon.ResultReconciliation) []cell {
+	row := []cell{
+		textCell(result.BankCode),
+		intCell(result.TotalNumberOfTransactions),
+		intCell(result.TotalNumberOfMatchesTransactions),
+		intCell(result.TotalNumberOfExactMatches),
+		intCell(result.TotalNumberOfMatchesWithinTolerance),
+		intCell(result.TotalNumberOfGroupMatches),
+		intCell(result.TotalNumberOfMismatchedPairs),
+		intCell(result.TotalNumberOfUnmatchedTransactions),
+		intCell(result.TotalNumberOfDuplicateRows),
+		numberCell(result.TotalAmountDiscrepancies),
+		numberCell(result.TotalAmountDiscrepanciesWithinTolerance),
+		numberCell(result.TotalAmountDiscrepanciesMismatched),
+	}
+
+	for _, category := range Categories {
+		row = append(row, intCell(result.TotalNumberOfExceptionsByReason[category]))
+	}
+
+	return row
+}
+
+// exceptionRow puts the system and the bank side of an exception next to
+// each other, with their differences when both are known.
+func exceptionRow(bankCode string, exception recon.Exception) []cell {
+	row := []cell{
+		textCell(bankCode),
+		textCell(string(exception.Reason)),
+		textCell(string(exception.Side)),
+		textCell(exception.Rule),
+	}
+
+	if tx := exception.Transaction; tx != nil {
+		row = append(row,
+			textCell(tx.TransactionID),
+			textCell(tx.TerminalRRN),
+			numberCell(tx.Amount),
+			textCell(tx.TransactionType),
+			dateCell(tx.TransactionTime),
+			textCell(tx.File),
+		)
+	} else {
+		row = append(row, make([]cell, 6)...)
+	}
+
+	if b := exception.BankStatement; b != nil {
+		row = append(row,
+			textCell(b.UniqueID),
+			numberCell(b.Amount),
+			textCell(b.TransactionType),
+			dateCell(b.Date),
+			textCell(b.File),
+		)
+	} else {
+		row = append(row, make([]cell, 5)...)
+	}
+
+	switch {
+	case exception.AmountDifference != nil:
+		row = append(row, numberCell(*exception.AmountDifference))
+	case exception.Transaction != nil && exception.BankStatement != nil:
+		row = append(row, numberCell(exception.Transaction.Amount.Sub(exception.BankStatement.Amount)))
+	default:
+		row = append(row, cell{})
+	}
+
+	// Like the time tolerance, the bank date minus the transaction time
+	if exception.Transaction != nil && exception.BankStatement != nil {
+		row = append(row, textCell(exception.BankStatement.Date.Sub(exception.Transaction.TransactionTime).String()))
+	} else {
+		row = append(row, cell{})
+	}
+
+	return row
+}
+
+func textCell(value string) cell {
+	return cell{text: value}
+}
+
+func intCell(value int) cell {
+	number := decimal.NewFromInt(int64(value))
+	return cell{text: strconv.Itoa(value), number: &number}
+}
+
+func numberCell(value decimal.Decimal) cell {
+	return cell{text: value.String(), number: &value}
+}
+
+func dateCell(value time.Time) cell {
+	if value.IsZero() {
+		return cell{}
+	}
+
+	return cell{text: value.Format(time.RFC3339), date: &value}
+}
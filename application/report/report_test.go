@@ -0,0 +1,156 @@
+package report_test
+
+import (
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/report"
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func newResults() []recon.ResultReconciliation {
+	now := time.Date(2026, 1, 3, 10, 0, 0, 0, time.UTC)
+	difference := decimal.RequireFromString("-0.50")
+
+	return []recon.ResultReconciliation{
+		{
+			BankCode:                           "014",
+			TotalNumberOfTransactions:          3,
+			TotalNumberOfMatchesTransactions:   1,
+			TotalNumberOfExactMatches:          1,
+			TotalNumberOfMismatchedPairs:       1,
+			TotalNumberOfUnmatchedTransactions: 2,
+			TotalAmountDiscrepancies:           decimal.RequireFromString("0.50"),
+			TotalAmountDiscrepanciesMismatched: decimal.RequireFromString("0.50"),
+			TotalNumberOfExceptionsByReason: map[recon.MismatchReason]int{
+				recon.MismatchReasonAmountMismatch: 1,
+				recon.MismatchReasonMissingInBank:  1,
+			},
+			ResultReconciliationDetails: recon.ResultReconciliationDetails{
+				Exceptions: []recon.Exception{
+					{
+						Reason:           recon.MismatchReasonAmountMismatch,
+						Side:             recon.ExceptionSideBoth,
+						Rule:             "TRANSACTION_ID",
+						Transaction:      &recon.TransactionUploadFile{TransactionID: "TX1", Amount: decimal.RequireFromString("100.00"), TransactionType: "DEBIT", TransactionTime: now},
+						BankStatement:    &recon.BankStatementUploadFile{UniqueID: "TX1", Amount: decimal.RequireFromString("100.50"), TransactionType: "DEBIT", Date: now.Add(2 * time.Hour)},
+						AmountDifference: &difference,
+					},
+					{
+						Reason:      recon.MismatchReasonMissingInBank,
+						Side:        recon.ExceptionSideSystem,
+						Transaction: &recon.TransactionUploadFile{TransactionID: "TX2", TerminalRRN: "RRN2", Amount: decimal.RequireFromString("25"), TransactionType: "CREDIT", TransactionTime: now},
+					},
+				},
+			},
+		},
+		{
+			BankCode: "002",
+			TotalNumberOfExceptionsByReason: map[recon.MismatchReason]int{
+				recon.MismatchReasonMissingInSystem: 1,
+			},
+			ResultReconciliationDetails: recon.ResultReconciliationDetails{
+				Exceptions: []recon.Exception{
+					{
+						Reason:        recon.MismatchReasonMissingInSystem,
+						Side:          recon.ExceptionSideBank,
+						BankStatement: &recon.BankStatementUploadFile{UniqueID: "BK1", Amount: decimal.RequireFromString("7.25"), Date: now, File: "day-1.csv"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWrite_CSV(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, report.Write(&out, report.FormatCSV, newResults()))
+
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"bank_code", "reason", "side", "rule", "transaction_id", "terminal_rrn", "system_amount", "system_transaction_type", "transaction_time", "system_file",
+			"unique_id", "bank_amount", "bank_transaction_type", "bank_date", "bank_file", "amount_difference", "time_difference"},
+		{"014", "AMOUNT_MISMATCH", "BOTH", "TRANSACTION_ID", "TX1", "", "100", "DEBIT", "2026-01-03T10:00:00Z", "",
+			"TX1", "100.5", "DEBIT", "2026-01-03T12:00:00Z", "", "-0.5", "2h0m0s"},
+		{"014", "MISSING_IN_BANK", "SYSTEM", "", "TX2", "RRN2", "25", "CREDIT", "2026-01-03T10:00:00Z", "",
+			"", "", "", "", "", "", ""},
+		{"002", "MISSING_IN_SYSTEM", "BANK", "", "", "", "", "", "", "",
+			"BK1", "7.25", "", "2026-01-03T10:00:00Z", "day-1.csv", "", ""},
+	}, records)
+}
+
+func TestWrite_XLSX(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, report.Write(&out, report.FormatXLSX, newResults()))
+
+	file, err := excelize.OpenReader(&out)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	assert.Equal(t, []string{"Summary", "MISSING_IN_BANK", "MISSING_IN_SYSTEM", "AMOUNT_MISMATCH", "DATE_MISMATCH", "TYPE_MISMATCH", "DUPLICATE_ID"},
+		file.GetSheetList())
+
+	summary, err := file.GetRows("Summary", excelize.Options{RawCellValue: true})
+	assert.NoError(t, err)
+	assert.Len(t, summary, 3)
+	assert.Equal(t, "exceptions_missing_in_bank", summary[0][12])
+	assert.Equal(t, []string{"014", "3", "1", "1", "0", "0", "1", "2", "0", "0.5", "0", "0.5", "1", "0", "1", "0", "0", "0"}, summary[1])
+	assert.Equal(t, "002", summary[2][0])
+
+	// Amounts are numbers and dates are dates, the counterpart sits on the same row
+	mismatched, err := file.GetRows("AMOUNT_MISMATCH", excelize.Options{RawCellValue: true})
+	assert.NoError(t, err)
+	assert.Len(t, mismatched, 2)
+	assert.Equal(t, "TX1", mismatched[1][4])
+	assert.Equal(t, "100.5", mismatched[1][11])
+	assert.Equal(t, "-0.5", mismatched[1][15])
+
+	cellType, err := file.GetCellType("AMOUNT_MISMATCH", "G2")
+	assert.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeSharedString, cellType)
+
+	transactionTime, err := file.GetCellValue("AMOUNT_MISMATCH", "I2")
+	assert.NoError(t, err)
+	assert.Equal(t, "1/3/26 10:00", transactionTime)
+
+	missing, err := file.GetRows("MISSING_IN_SYSTEM")
+	assert.NoError(t, err)
+	assert.Len(t, missing, 2)
+	assert.Equal(t, "day-1.csv", missing[1][14])
+
+	empty, err := file.GetRows("DUPLICATE_ID")
+	assert.NoError(t, err)
+	assert.Len(t, empty, 1)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    report.Format
+		wantErr error
+	}{
+		{name: "json by default"},
+		{name: "query", query: "xlsx", accept: "text/csv", want: report.FormatXLSX},
+		{name: "query json", query: "JSON", want: ""},
+		{name: "accept csv", accept: "text/csv; charset=utf-8", want: report.FormatCSV},
+		{name: "accept json first", accept: "application/json, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", want: ""},
+		{name: "accept first known", accept: "text/html, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", want: report.FormatXLSX},
+		{name: "invalid query", query: "pdf", wantErr: report.ErrorInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := report.Negotiate(tt.query, tt.accept)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"amartha-recon-service/application/recon"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// excelDateTimeFormat is the built in number format "m/d/yy h:mm"
+	excelDateTimeFormat = 22
+)
+
+// writeXLSX writes the summary sheet and a sheet per reason. The sheets are
+// streamed, a sheet is not held in memory as cells.
+func writeXLSX(w io.Writer, results []recon.ResultReconciliation) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	dateStyle, err := file.NewStyle(&excelize.Style{NumFmt: excelDateTimeFormat})
+	if err != nil {
+		return err
+	}
+
+	// A new workbook comes with Sheet1, it becomes the summary
+	if err := file.SetSheetName(file.GetSheetName(0), summarySheet); err != nil {
+		return err
+	}
+
+	var summary [][]cell
+	for _, result := range results {
+		summary = append(summary, summaryRow(result))
+	}
+
+	if err := writeSheet(file, summarySheet, summaryColumns(), summary, dateStyle); err != nil {
+		return err
+	}
+
+	for _, category := range Categories {
+		var rows [][]cell
+		for _, result := range results {
+			for _, exception := range result.ResultReconciliationDetails.Exceptions {
+				if exception.Reason == category {
+					rows = append(rows, exceptionRow(result.BankCode, exception))
+				}
+			}
+		}
+
+		if _, err := file.NewSheet(string(category)); err != nil {
+			return err
+		}
+
+		if err := writeSheet(file, string(category), exceptionHeader, rows, dateStyle); err != nil {
+			return err
+		}
+	}
+
+	return file.Write(w)
+}
+
+func writeSheet(file *excelize.File, sheet string, header []string, rows [][]cell, dateStyle int) error {
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, 0, len(header))
+	for _, title := range header {
+		values = append(values, title)
+	}
+
+	if err := stream.SetRow("A1", values); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		cellName, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.SetRow(cellName, xlsxValues(row, dateStyle)); err != nil {
+			return err
+		}
+	}
+
+	return stream.Flush()
+}
+
+// xlsxValues writes amounts as numbers, so they can be summed, and dates as
+// dates.
+func xlsxValues(row []cell, dateStyle int) []interface{} {
+	values := make([]interface{}, 0, len(row))
+	for _, value := range row {
+		switch {
+		case value.number != nil:
+			number, _ := value.number.Float64()
+			values = append(values, number)
+		case value.date != nil:
+			values = append(values, excelize.Cell{StyleID: dateStyle, Value: *value.date})
+		case value.text != "":
+			values = append(values, value.text)
+		default:
+			values = append(values, nil)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// Background runs the loops of a long running process, the scheduler and
+	// the inbox ingester share it. Its context is cancelled once Shutdown
+	// gives up on the running recons, Stopped is closed when the loops should
+	// no longer wait for more work.
+	Background struct {
+		ctx    context.Context
+		cancel context.CancelFunc
+		stop   chan struct{}
+		once   sync.Once
+		wg     sync.WaitGroup
+	}
+)
+
+func NewBackground() *Background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Background{
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Go runs loop until it returns, Shutdown waits for it.
+func (b *Background) Go(loop func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		loop()
+	}()
+}
+
+// Context is the context of the recons run by the loops.
+func (b *Background) Context() context.Context {
+	return b.ctx
+}
+
+// Stopped is closed once Shutdown is called.
+func (b *Background) Stopped() <-chan struct{} {
+	return b.stop
+}
+
+// Shutdown ends the waits of the loops and lets their running recons
+// finish. The recons still going when ctx is done are cancelled.
+func (b *Background) Shutdown(ctx context.Context) error {
+	b.once.Do(func() { close(b.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	b.cancel()
+	<-done
+	return ctx.Err()
+}
@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cronFieldSeparator = " "
+	cronListSeparator  = ","
+	cronRangeSeparator = "-"
+	cronStepSeparator  = "/"
+	cronWildcard       = "*"
+
+	// maxCronYears bounds the search of Next, an expression like "0 0 30 2 *"
+	// never fires
+	maxCronYears = 5
+)
+
+var (
+	ErrorInvalidCron = errors.New("jadwal cron tidak valid")
+
+	// cronBounds are the values of minute, hour, day of month, month and day
+	// of week. A day of week of 7 is Sunday like 0.
+	cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+)
+
+type (
+	// Cron is a standard five fields expression, "minute hour day-of-month
+	// month day-of-week", with lists, ranges and steps such as "0 6 * * 1-5".
+	// When both days are restricted, a day matching either of them fires.
+	Cron struct {
+		expr       string
+		minutes    uint64
+		hours      uint64
+		days       uint64
+		months     uint64
+		weekdays   uint64
+		anyDay     bool
+		anyWeekday bool
+	}
+)
+
+func ParseCron(expr string) (Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronBounds) {
+		return Cron{}, ErrorInvalidCron
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return Cron{}, err
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return Cron{
+		expr:       strings.Join(fields, cronFieldSeparator),
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     strings.HasPrefix(fields[2], cronWildcard),
+		anyWeekday: strings.HasPrefix(fields[4], cronWildcard),
+	}, nil
+}
+
+// parseCronField returns the values of a field as bits of a set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, cronListSeparator) {
+		valueRange, stepValue, hasStep := strings.Cut(part, cronStepSeparator)
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step < 1 {
+				return 0, ErrorInvalidCron
+			}
+		}
+
+		low, high := min, max
+		if valueRange != cronWildcard {
+			first, last, isRange := strings.Cut(valueRange, cronRangeSeparator)
+
+			var err error
+			if low, err = strconv.Atoi(first); err != nil {
+				return 0, ErrorInvalidCron
+			}
+
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(last); err != nil {
+					return 0, ErrorInvalidCron
+				}
+			} else if hasStep {
+				// "5/15" is every 15 from 5 on
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, ErrorInvalidCron
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+
+	return set, nil
+}
+
+// Next returns the first time after t the expression fires, in the location
+// of t. It is zero when the expression never fires.
+func (c Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxCronYears, 0, 0)
+
+	for t.Before(limit) {
+		if c.months&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.hours&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (c Cron) matchDay(t time.Time) bool {
+	day := c.days&(1<<t.Day()) != 0
+	weekday := c.weekdays&(1<<int(t.Weekday())) != 0
+
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func (c Cron) String() string {
+	return c.expr
+}
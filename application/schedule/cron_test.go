@@ -0,0 +1,58 @@
+package schedule_test
+
+import (
+	"amartha-recon-service/application/schedule"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"0 6 * * *", "*/15 * * * *", "0 6,18 1-15 * 1-5", "30 5 * 1/3 7", "0  6 *  * *"} {
+		t.Run("success "+expr, func(t *testing.T) {
+			_, err := schedule.ParseCron(expr)
+			assert.NoError(t, err)
+		})
+	}
+
+	for _, expr := range []string{"", "0 6 * *", "0 6 * * * *", "60 6 * * *", "0 24 * * *", "0 6 0 * *", "0 6 * 13 *", "0 6 * * 8",
+		"0 6 5-1 * *", "*/0 * * * *", "a 6 * * *", "0 6 * * MON"} {
+		t.Run("error "+expr, func(t *testing.T) {
+			_, err := schedule.ParseCron(expr)
+			assert.ErrorIs(t, err, schedule.ErrorInvalidCron)
+		})
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	// 2026-10-18 is a Sunday
+	now := time.Date(2026, 10, 18, 6, 30, 20, 0, jakarta)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "0 6 * * *", want: time.Date(2026, 10, 19, 6, 0, 0, 0, jakarta)},
+		{expr: "45 6 * * *", want: time.Date(2026, 10, 18, 6, 45, 0, 0, jakarta)},
+		{expr: "*/15 * * * *", want: time.Date(2026, 10, 18, 6, 45, 0, 0, jakarta)},
+		{expr: "0 6 * * 1-5", want: time.Date(2026, 10, 19, 6, 0, 0, 0, jakarta)},
+		{expr: "0 6 * * 0", want: time.Date(2026, 10, 25, 6, 0, 0, 0, jakarta)},
+		{expr: "0 6 * * 7", want: time.Date(2026, 10, 25, 6, 0, 0, 0, jakarta)},
+		{expr: "0 0 1 * *", want: time.Date(2026, 11, 1, 0, 0, 0, 0, jakarta)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, jakarta)},
+		// Either day matches when both are restricted
+		{expr: "0 6 31 * 3", want: time.Date(2026, 10, 21, 6, 0, 0, 0, jakarta)},
+		{expr: "30 6 * * *", want: time.Date(2026, 10, 19, 6, 30, 0, 0, jakarta)},
+		{expr: "0 0 30 2 *", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := schedule.ParseCron(tt.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, cron.Next(now))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/infrastructure/repository/reconlock"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	lockPrefix = "recon-schedule"
+)
+
+var (
+	ErrorUnreadableFile = errors.New("file bank tidak dapat dibaca")
+)
+
+type (
+	// Runner reconciles the bank file of one bank code and day against the
+	// transactions table, the cron of the scheduler and the inbox ingester
+	// share it so their runs of the same bank and date never overlap.
+	Runner struct {
+		service         recon.Service
+		loader          parser.Loader
+		locks           reconlock.Repository
+		duplicatePolicy recon.DuplicatePolicy
+		validationMode  recon.ValidationMode
+	}
+)
+
+func NewRunner(
+	service recon.Service,
+	loader parser.Loader,
+	locks reconlock.Repository,
+	duplicatePolicy recon.DuplicatePolicy,
+	validationMode recon.ValidationMode) Runner {
+	return Runner{
+		service:         service,
+		loader:          loader,
+		locks:           locks,
+		duplicatePolicy: duplicatePolicy,
+		validationMode:  validationMode,
+	}
+}
+
+// Run reconciles the bank file at path for the whole day of date, unless a
+// run of the same bank and date is going on in any process. The run is
+// stored. A file that can not be parsed returns ErrorUnreadableFile. The
+// system transactions of bankCode are reconciled even when the file has no
+// row of the day, and a file with rows of another bank code is refused.
+func (r Runner) Run(ctx context.Context, bankCode string, date time.Time, path, template string) (recon.ShowResultReconciliation, error) {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	lock, err := r.locks.TryLock(ctx, strings.Join([]string{lockPrefix, bankCode, date.Format(time.DateOnly)}, "-"))
+	if errors.Is(err, reconlock.ErrorLockTaken) {
+		return recon.ShowResultReconciliation{}, ErrorRunOverlap
+	}
+
+	if err != nil {
+		return recon.ShowResultReconciliation{}, err
+	}
+
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("[schedule] error releasing lock of bank %s: %v", bankCode, err)
+		}
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return recon.ShowResultReconciliation{}, err
+	}
+	defer file.Close()
+
+	uploadFile, err := r.loader.Load(ctx, nil, parser.Source{
+		Reader:   file,
+		FileName: filepath.Base(path),
+		Template: template,
+	}, parser.LoadOptions{
+		StartDate:       date,
+		EndDate:         date,
+		BankCodes:       []string{bankCode},
+		DuplicatePolicy: r.duplicatePolicy,
+		ValidationMode:  r.validationMode,
+	}, recon.NoProgress)
+	if err != nil {
+		if ctx.Err() != nil {
+			return recon.ShowResultReconciliation{}, err
+		}
+
+		return recon.ShowResultReconciliation{}, fmt.Errorf("%w: %w", ErrorUnreadableFile, err)
+	}
+	defer uploadFile.Close()
+
+	result, err := r.service.Proceed(ctx, uploadFile)
+	if err != nil {
+		return result, err
+	}
+
+	exceptions := 0
+	for _, rr := range result.ResultReconciliation {
+		exceptions += len(rr.ResultReconciliationDetails.Exceptions)
+	}
+	log.Printf("[schedule] recon of bank %s for %s stored as run %s from %s, %d exceptions",
+		bankCode, date.Format(time.DateOnly), result.RunID, filepath.Base(path), exceptions)
+
+	return result, nil
+}
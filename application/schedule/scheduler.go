@@ -0,0 +1,255 @@
+package schedule
+
+import (
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
+	"amartha-recon-service/configuration"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLagDays     = 1
+	defaultFilePattern = "{bank_code}_{date}.*"
+
+	placeholderBankCode = "{bank_code}"
+	placeholderDate     = "{date}"
+	fileDateLayout      = "20060102"
+)
+
+var (
+	ErrorBankNotScheduled  = errors.New("bank tidak memiliki jadwal rekonsiliasi")
+	ErrorBankFileNotFound  = errors.New("file bank tidak ditemukan di inbox")
+	ErrorAmbiguousBankFile = errors.New("lebih dari satu file bank ditemukan di inbox")
+	ErrorRunOverlap        = errors.New("rekonsiliasi bank dan tanggal ini sedang berjalan")
+	ErrorInvalidSchedule   = errors.New("konfigurasi jadwal rekonsiliasi tidak valid")
+	ErrorSchedulerShutdown = errors.New("scheduler sedang berhenti")
+)
+
+type (
+	// Bank is when and from which inbox file a bank code is reconciled.
+	Bank struct {
+		Code string
+		Cron Cron
+		// FilePattern is a glob in the inbox, {bank_code} and {date} (the
+		// reconciled day as YYYYMMDD) are replaced before it is matched
+		FilePattern string
+		// Template of the bank file, detected from its header when empty
+		Template string
+	}
+
+	// Settings are read from recon.schedule.*, a bank falls back to the
+	// default cron, file pattern and template.
+	Settings struct {
+		InboxDir        string
+		Banks           []Bank
+		LagDays         int
+		Location        *time.Location
+		DuplicatePolicy recon.DuplicatePolicy
+		ValidationMode  recon.ValidationMode
+	}
+
+	scheduler struct {
+		settings   Settings
+		runner     Runner
+		generate   common.Generate
+		background *Background
+	}
+
+	Scheduler interface {
+		Start()
+		Run(ctx context.Context, bankCode string, date time.Time) (recon.ShowResultReconciliation, error)
+		Shutdown(ctx context.Context) error
+	}
+)
+
+// NewSettings reads the schedule of every bank on recon.schedule.banks.
+func NewSettings(cfg configuration.Configuration) (Settings, error) {
+	settings := Settings{
+		InboxDir: cfg.GetString("recon.schedule.inbox.dir"),
+		LagDays:  int(cfg.GetInt("recon.schedule.lag.days")),
+		Location: time.Local,
+	}
+
+	if settings.InboxDir == "" {
+		return Settings{}, fmt.Errorf("%w: recon.schedule.inbox.dir is required", ErrorInvalidSchedule)
+	}
+
+	if settings.LagDays < 1 {
+		settings.LagDays = defaultLagDays
+	}
+
+	if timezone := cfg.GetString("recon.schedule.timezone"); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Settings{}, fmt.Errorf("%w: recon.schedule.timezone %v", ErrorInvalidSchedule, err)
+		}
+		settings.Location = location
+	}
+
+	var err error
+	if settings.DuplicatePolicy, err = recon.ParseDuplicatePolicy(cfg.GetString("recon.schedule.duplicate.policy")); err != nil {
+		return Settings{}, err
+	}
+
+	if settings.ValidationMode, err = recon.ParseValidationMode(cfg.GetString("recon.schedule.validation.mode")); err != nil {
+		return Settings{}, err
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range cfg.GetArray("recon.schedule.banks") {
+		code = strings.TrimSpace(code)
+		if code == "" || seen[code] {
+			return Settings{}, fmt.Errorf("%w: bank code %q on recon.schedule.banks", ErrorInvalidSchedule, code)
+		}
+		seen[code] = true
+
+		expr := bankSetting(cfg, "recon.schedule.cron.", code)
+		cron, err := ParseCron(expr)
+		if err != nil {
+			return Settings{}, fmt.Errorf("%w: cron %q of bank %s", err, expr, code)
+		}
+
+		bank := Bank{
+			Code:        code,
+			Cron:        cron,
+			FilePattern: bankSetting(cfg, "recon.schedule.file.", code),
+			Template:    bankSetting(cfg, "recon.schedule.template.", code),
+		}
+		if bank.FilePattern == "" {
+			bank.FilePattern = defaultFilePattern
+		}
+
+		settings.Banks = append(settings.Banks, bank)
+	}
+
+	if len(settings.Banks) == 0 {
+		return Settings{}, fmt.Errorf("%w: recon.schedule.banks is empty", ErrorInvalidSchedule)
+	}
+
+	return settings, nil
+}
+
+func bankSetting(cfg configuration.Configuration, prefix, bankCode string) string {
+	if value := cfg.GetString(prefix + bankCode); value != "" {
+		return value
+	}
+
+	return cfg.GetString(prefix + "default")
+}
+
+// NewScheduler reconciles every bank of settings on its cron through runner.
+func NewScheduler(settings Settings, runner Runner, generate common.Generate) Scheduler {
+	return &scheduler{
+		settings:   settings,
+		runner:     runner,
+		generate:   generate,
+		background: NewBackground(),
+	}
+}
+
+// Start waits for the cron of every bank in the background, it is called
+// once.
+func (s *scheduler) Start() {
+	for _, bank := range s.settings.Banks {
+		s.background.Go(func() { s.loop(bank) })
+	}
+}
+
+func (s *scheduler) loop(bank Bank) {
+	for {
+		now := s.generate.Time().In(s.settings.Location)
+		next := bank.Cron.Next(now)
+		if next.IsZero() {
+			log.Printf("[schedule] cron %q of bank %s never fires", bank.Cron, bank.Code)
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-s.background.Stopped():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// T+1: the run of this morning reconciles yesterday
+		date := time.Date(next.Year(), next.Month(), next.Day()-s.settings.LagDays, 0, 0, 0, 0, time.UTC)
+		if _, err := s.Run(s.background.Context(), bank.Code, date); err != nil {
+			log.Printf("[schedule] recon of bank %s for %s stopped: %v", bank.Code, date.Format(time.DateOnly), err)
+		}
+	}
+}
+
+// Run reconciles the inbox file of bankCode for date now, see Runner.Run.
+func (s *scheduler) Run(ctx context.Context, bankCode string, date time.Time) (recon.ShowResultReconciliation, error) {
+	bank, ok := s.bank(bankCode)
+	if !ok {
+		return recon.ShowResultReconciliation{}, ErrorBankNotScheduled
+	}
+
+	select {
+	case <-s.background.Stopped():
+		return recon.ShowResultReconciliation{}, ErrorSchedulerShutdown
+	default:
+	}
+
+	path, err := s.findFile(bank, date)
+	if err != nil {
+		return recon.ShowResultReconciliation{}, err
+	}
+
+	return s.runner.Run(ctx, bank.Code, date, path, bank.Template)
+}
+
+func (s *scheduler) bank(code string) (Bank, bool) {
+	for _, bank := range s.settings.Banks {
+		if bank.Code == code {
+			return bank, true
+		}
+	}
+
+	return Bank{}, false
+}
+
+// findFile returns the one file of the inbox matching the pattern of bank
+// for date.
+func (s *scheduler) findFile(bank Bank, date time.Time) (string, error) {
+	pattern := strings.NewReplacer(
+		placeholderBankCode, bank.Code,
+		placeholderDate, date.Format(fileDateLayout),
+	).Replace(bank.FilePattern)
+
+	paths, err := filepath.Glob(filepath.Join(s.settings.InboxDir, pattern))
+	if err != nil {
+		return "", fmt.Errorf("%w: file pattern %q", ErrorInvalidSchedule, bank.FilePattern)
+	}
+
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+
+	switch len(files) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrorBankFileNotFound, pattern)
+	case 1:
+		return files[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrorAmbiguousBankFile, strings.Join(files, ", "))
+	}
+}
+
+// Shutdown stops waiting for the next runs and lets the running ones finish.
+// Runs still going when ctx is done are cancelled.
+func (s *scheduler) Shutdown(ctx context.Context) error {
+	return s.background.Shutdown(ctx)
+}
@@ -0,0 +1,280 @@
+package schedule_test
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/schedule"
+	"amartha-recon-service/common"
+	"amartha-recon-service/infrastructure/repository/reconlock"
+	"amartha-recon-service/infrastructure/repository/transaction"
+	"amartha-recon-service/mocks"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newConfiguration(t *testing.T, values map[string]string, banks []string) *mocks.Configuration {
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetString", mock.Anything).Return(func(key string) string { return values[key] }).Maybe()
+	cfg.On("GetInt", mock.Anything).Return(int64(0)).Maybe()
+	cfg.On("GetArray", "recon.schedule.banks").Return(banks).Maybe()
+	return cfg
+}
+
+func TestNewSettings(t *testing.T) {
+	t.Run("success with fallbacks", func(t *testing.T) {
+		cfg := newConfiguration(t, map[string]string{
+			"recon.schedule.inbox.dir":    "/inbox",
+			"recon.schedule.timezone":     "Asia/Jakarta",
+			"recon.schedule.cron.default": "0 6 * * *",
+			"recon.schedule.cron.008":     "30 7 * * 1-5",
+			"recon.schedule.file.008":     "BRI_{date}.csv",
+			"recon.schedule.template.014": "BCA",
+		}, []string{"014", " 008"})
+
+		settings, err := schedule.NewSettings(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "/inbox", settings.InboxDir)
+		assert.Equal(t, 1, settings.LagDays)
+		assert.Equal(t, "Asia/Jakarta", settings.Location.String())
+		assert.Equal(t, recon.DuplicatePolicyFlag, settings.DuplicatePolicy)
+		assert.Equal(t, recon.ValidationModeReject, settings.ValidationMode)
+		assert.Len(t, settings.Banks, 2)
+
+		assert.Equal(t, "014", settings.Banks[0].Code)
+		assert.Equal(t, "0 6 * * *", settings.Banks[0].Cron.String())
+		assert.Equal(t, "{bank_code}_{date}.*", settings.Banks[0].FilePattern)
+		assert.Equal(t, "BCA", settings.Banks[0].Template)
+
+		assert.Equal(t, "008", settings.Banks[1].Code)
+		assert.Equal(t, "30 7 * * 1-5", settings.Banks[1].Cron.String())
+		assert.Equal(t, "BRI_{date}.csv", settings.Banks[1].FilePattern)
+		assert.Empty(t, settings.Banks[1].Template)
+	})
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		banks  []string
+		err    error
+	}{
+		{name: "no inbox", values: map[string]string{"recon.schedule.cron.default": "0 6 * * *"}, banks: []string{"014"}, err: schedule.ErrorInvalidSchedule},
+		{name: "no banks", values: map[string]string{"recon.schedule.inbox.dir": "/inbox"}, err: schedule.ErrorInvalidSchedule},
+		{name: "same bank twice", values: map[string]string{"recon.schedule.inbox.dir": "/inbox", "recon.schedule.cron.default": "0 6 * * *"},
+			banks: []string{"014", "014"}, err: schedule.ErrorInvalidSchedule},
+		{name: "no cron", values: map[string]string{"recon.schedule.inbox.dir": "/inbox"}, banks: []string{"014"}, err: schedule.ErrorInvalidCron},
+		{name: "timezone", values: map[string]string{"recon.schedule.inbox.dir": "/inbox", "recon.schedule.timezone": "Mars/Olympus"},
+			banks: []string{"014"}, err: schedule.ErrorInvalidSchedule},
+		{name: "validation mode", values: map[string]string{"recon.schedule.inbox.dir": "/inbox", "recon.schedule.validation.mode": "IGNORE"},
+			banks: []string{"014"}, err: recon.ErrorInvalidValidationMode},
+	}
+
+	for _, tt := range tests {
+		t.Run("error "+tt.name, func(t *testing.T) {
+			_, err := schedule.NewSettings(newConfiguration(t, tt.values, tt.banks))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	cron, err := schedule.ParseCron("0 6 * * *")
+	assert.NoError(t, err)
+
+	inbox := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "014_20261017.csv"), []byte("unique_id,amount,date,bank_code\n"+
+		"TX1,100,2026-10-17 00:00:00,014\n"+
+		"TX2,200,2026-10-17 21:15:00,014\n"+
+		"TX9,900,2026-10-18 00:00:00,014\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "008_20261017.csv"), nil, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "011_20261017.csv"), []byte("unique_id,amount,date,bank_code\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "022_20261017.csv"), []byte("unique_id,amount,date,bank_code\n"+
+		"TX1,100,2026-10-17 08:00:00,014\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "008_20261017.csv.gz"), nil, 0o644))
+
+	settings := schedule.Settings{
+		InboxDir: inbox,
+		Banks: []schedule.Bank{
+			{Code: "014", Cron: cron, FilePattern: "{bank_code}_{date}.*"},
+			{Code: "008", Cron: cron, FilePattern: "{bank_code}_{date}.*"},
+			{Code: "002", Cron: cron, FilePattern: "{bank_code}_{date}.*"},
+			{Code: "011", Cron: cron, FilePattern: "{bank_code}_{date}.*"},
+			{Code: "022", Cron: cron, FilePattern: "{bank_code}_{date}.*"},
+		},
+		LagDays:         1,
+		Location:        time.UTC,
+		DuplicatePolicy: recon.DuplicatePolicyFlag,
+		ValidationMode:  recon.ValidationModeReject,
+	}
+
+	newScheduler := func(t *testing.T, locks *mocks.ReconLockRepository, transactions *mocks.Repository) schedule.Scheduler {
+		cfg := mocks.NewConfiguration(t)
+		cfg.On("GetInt", mock.Anything).Return(int64(100)).Maybe()
+		cfg.On("GetArray", mock.Anything).Return([]string(nil)).Maybe()
+		cfg.On("GetString", mock.Anything).Return("").Maybe()
+		cfg.On("GetBool", mock.Anything).Return(false).Maybe()
+
+		loader := parser.NewLoader(parser.NewRegistry(cfg), parser.NewUploadLimits(cfg), recon.NewSpillSettings(cfg))
+		service := recon.NewService(cfg, transactions, nil, common.NewGenerate())
+		runner := schedule.NewRunner(service, loader, locks, settings.DuplicatePolicy, settings.ValidationMode)
+		return schedule.NewScheduler(settings, runner, common.NewGenerate())
+	}
+
+	t.Run("success", func(t *testing.T) {
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(lock, nil).Once()
+
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.MatchedBy(func(criteria *transaction.Criteria) bool {
+			return criteria.StartDate.Equal(date) && criteria.EndDate.Format(time.DateOnly) == "2026-10-17" &&
+				len(criteria.BankCodes) == 1 && criteria.BankCodes[0] == "014"
+		})).Return([]*transaction.Transaction{
+			{TransactionID: "TX1", Amount: decimal.NewFromInt(100), BankCode: "014", TransactionTime: date.Add(9 * time.Hour)},
+			{TransactionID: "TX3", Amount: decimal.NewFromInt(300), BankCode: "014", TransactionTime: date.Add(10 * time.Hour)},
+		}, nil).Once()
+
+		// The time of day of the date is ignored
+		result, err := newScheduler(t, locks, transactions).Run(ctx, "014", date.Add(15*time.Hour))
+		assert.NoError(t, err)
+		assert.Len(t, result.ResultReconciliation, 1)
+
+		rr := result.ResultReconciliation[0]
+		assert.Equal(t, 1, rr.TotalNumberOfMatchesTransactions)
+		// TX2 of the evening is on the day, TX9 of the next day is not
+		var exceptions []string
+		for _, exception := range rr.ResultReconciliationDetails.Exceptions {
+			if exception.Transaction != nil {
+				exceptions = append(exceptions, string(exception.Reason)+" "+exception.Transaction.TransactionID)
+			} else {
+				exceptions = append(exceptions, string(exception.Reason)+" "+exception.BankStatement.UniqueID)
+			}
+		}
+		assert.ElementsMatch(t, []string{"MISSING_IN_BANK TX3", "MISSING_IN_SYSTEM TX2"}, exceptions)
+	})
+
+	t.Run("success empty bank file", func(t *testing.T) {
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-011-2026-10-17").Return(lock, nil).Once()
+
+		// The bank file has no row, the transactions of the bank are still loaded
+		transactions := mocks.NewRepository(t)
+		transactions.On("FindTransaction", ctx, mock.MatchedBy(func(criteria *transaction.Criteria) bool {
+			return len(criteria.BankCodes) == 1 && criteria.BankCodes[0] == "011"
+		})).Return([]*transaction.Transaction{
+			{TransactionID: "TX5", Amount: decimal.NewFromInt(500), BankCode: "011", TransactionTime: date.Add(9 * time.Hour)},
+		}, nil).Once()
+
+		result, err := newScheduler(t, locks, transactions).Run(ctx, "011", date)
+		assert.NoError(t, err)
+		assert.Len(t, result.ResultReconciliation, 1)
+
+		rr := result.ResultReconciliation[0]
+		assert.Equal(t, "011", rr.BankCode)
+		assert.Len(t, rr.ResultReconciliationDetails.Exceptions, 1)
+		assert.Equal(t, recon.MismatchReasonMissingInBank, rr.ResultReconciliationDetails.Exceptions[0].Reason)
+		assert.Equal(t, "TX5", rr.ResultReconciliationDetails.Exceptions[0].Transaction.TransactionID)
+	})
+
+	t.Run("error bank code of the rows", func(t *testing.T) {
+		lock := mocks.NewLock(t)
+		lock.On("Release", mock.Anything).Return(nil).Once()
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-022-2026-10-17").Return(lock, nil).Once()
+
+		_, err := newScheduler(t, locks, mocks.NewRepository(t)).Run(ctx, "022", date)
+		assert.ErrorIs(t, err, recon.ErrorUnexpectedBankCode)
+	})
+
+	t.Run("error overlap", func(t *testing.T) {
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(nil, reconlock.ErrorLockTaken).Once()
+
+		_, err := newScheduler(t, locks, nil).Run(ctx, "014", date)
+		assert.ErrorIs(t, err, schedule.ErrorRunOverlap)
+	})
+
+	t.Run("error lock", func(t *testing.T) {
+		locks := mocks.NewReconLockRepository(t)
+		locks.On("TryLock", ctx, "recon-schedule-014-2026-10-17").Return(nil, errors.New("db error")).Once()
+
+		_, err := newScheduler(t, locks, nil).Run(ctx, "014", date)
+		assert.EqualError(t, err, "db error")
+	})
+
+	t.Run("error bank not scheduled", func(t *testing.T) {
+		_, err := newScheduler(t, mocks.NewReconLockRepository(t), nil).Run(ctx, "009", date)
+		assert.ErrorIs(t, err, schedule.ErrorBankNotScheduled)
+	})
+
+	t.Run("error files", func(t *testing.T) {
+		tests := []struct {
+			bankCode string
+			err      error
+		}{
+			{bankCode: "008", err: schedule.ErrorAmbiguousBankFile},
+			{bankCode: "002", err: schedule.ErrorBankFileNotFound},
+		}
+
+		for _, tt := range tests {
+			_, err := newScheduler(t, mocks.NewReconLockRepository(t), nil).Run(ctx, tt.bankCode, date)
+			assert.ErrorIs(t, err, tt.err)
+		}
+	})
+}
+
+func TestScheduler_Start(t *testing.T) {
+	cron, err := schedule.ParseCron("0 6 * * *")
+	assert.NoError(t, err)
+
+	inbox := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(inbox, "014_20261017.csv"), nil, 0o644))
+
+	settings := schedule.Settings{
+		InboxDir: inbox,
+		Banks:    []schedule.Bank{{Code: "014", Cron: cron, FilePattern: "{bank_code}_{date}.*"}},
+		LagDays:  1,
+		Location: time.UTC,
+	}
+
+	// Fires a moment after the first look at the clock, then waits for tomorrow
+	generate := mocks.NewGenerate(t)
+	generate.On("Time").Return(time.Date(2026, 10, 18, 5, 59, 59, 990000000, time.UTC)).Once()
+	generate.On("Time").Return(time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC))
+
+	// The run overlaps one of another process and stops there
+	ran := make(chan string, 1)
+	locks := mocks.NewReconLockRepository(t)
+	locks.On("TryLock", mock.Anything, "recon-schedule-014-2026-10-17").
+		Run(func(args mock.Arguments) { ran <- args.String(1) }).
+		Return(nil, reconlock.ErrorLockTaken).Once()
+
+	scheduler := schedule.NewScheduler(settings, schedule.NewRunner(nil, parser.Loader{}, locks, "", ""), generate)
+	scheduler.Start()
+
+	select {
+	case name := <-ran:
+		assert.Equal(t, "recon-schedule-014-2026-10-17", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cron did not fire")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, scheduler.Shutdown(ctx))
+
+	_, err = scheduler.Run(context.Background(), "014", time.Now())
+	assert.ErrorIs(t, err, schedule.ErrorSchedulerShutdown)
+}
@@ -1,8 +1,17 @@
 package cmd
 
 import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/schedule"
+	"amartha-recon-service/common"
 	"amartha-recon-service/configuration"
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"amartha-recon-service/infrastructure/repository/reconlock"
+	"amartha-recon-service/infrastructure/repository/transaction"
+	"context"
 	"log"
+	"time"
 )
 
 const (
@@ -27,3 +36,39 @@ func fetchConfiguration() (
 
 	return cfg, cre
 }
+
+// newRunner reconciles bank files against the transactions table of the
+// master database and stores the runs, for the scheduler and the ingester.
+func newRunner(
+	cfg, cre configuration.Configuration,
+	duplicatePolicy recon.DuplicatePolicy,
+	validationMode recon.ValidationMode) schedule.Runner {
+	dbMaster, err := configuration.NewStoreImpl(cre).InitDBMaster()
+	if err != nil {
+		panic(err)
+	}
+
+	service := recon.NewService(cfg, transaction.NewTransactionRepository(dbMaster),
+		reconciliation.NewReconciliationRepository(dbMaster), common.NewGenerate())
+	loader := parser.NewLoader(parser.NewRegistry(cfg), parser.NewUploadLimits(cfg), recon.NewSpillSettings(cfg))
+	return schedule.NewRunner(service, loader, reconlock.NewReconLockRepository(dbMaster), duplicatePolicy, validationMode)
+}
+
+// shutdownOnSignal waits for ctx to be done by a signal, then shuts down.
+// A running recon gets job.shutdown.timeout.seconds before it is cancelled.
+func shutdownOnSignal(
+	ctx context.Context,
+	cfg configuration.Configuration,
+	prefix string,
+	shutdown func(ctx context.Context) error) {
+	<-ctx.Done()
+
+	shutdownTimeout := time.Duration(cfg.GetInt("job.shutdown.timeout.seconds")) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := shutdown(shutdownCtx); err != nil {
+		log.Println(prefix+" running recons are interrupted", err)
+	} else {
+		log.Println(prefix + " stopped.")
+	}
+}
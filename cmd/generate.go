@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"amartha-recon-service/application/dataset"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type generateFlags struct {
+	rows      int
+	bankCodes string
+	startDate string
+	endDate   string
+	rates     dataset.Rates
+	dateShift time.Duration
+	seed      int64
+	system    string
+	bank      string
+	manifest  string
+}
+
+var (
+	generateOptions generateFlags
+
+	generate = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a system file and a bank file with known exceptions",
+		Long: "Cobra CLI : generate a system CSV and a bank CSV of the same transactions, with errors injected at the given rates, " +
+			"and a manifest of the exceptions a recon of both is expected to find.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runGenerate(cmd.OutOrStdout(), generateOptions); err != nil {
+				log.Println("[generate] error generating dataset: " + err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	flags := generate.Flags()
+	flags.IntVar(&generateOptions.rows, "rows", 1000, "number of system transactions before the injections")
+	flags.StringVar(&generateOptions.bankCodes, "bank-codes", "014,008,002", "bank codes the transactions are spread over, comma separated")
+	flags.StringVar(&generateOptions.startDate, "start-date", "2026-01-01", "first day of the transactions, YYYY-MM-DD")
+	flags.StringVar(&generateOptions.endDate, "end-date", "2026-01-09", "last day of the transactions, YYYY-MM-DD")
+	flags.Float64Var(&generateOptions.rates.Missing, "missing-rate", 0, "share of the rows left out of one of the files")
+	flags.Float64Var(&generateOptions.rates.AmountDrift, "amount-drift-rate", 0, "share of the rows with a bank amount 1% to 10% off")
+	flags.Float64Var(&generateOptions.rates.Duplicate, "duplicate-rate", 0, "share of the rows written twice in one of the files")
+	flags.Float64Var(&generateOptions.rates.DateShift, "date-shift-rate", 0, "share of the rows with a bank date moved by --date-shift")
+	flags.Float64Var(&generateOptions.rates.SignFlip, "sign-flip-rate", 0, "share of the rows with a bank DEBIT/CREDIT flipped")
+	flags.DurationVar(&generateOptions.dateShift, "date-shift", 48*time.Hour, "how far a shifted bank date moves")
+	flags.Int64Var(&generateOptions.seed, "seed", 0, "seed of the dataset, picked from the clock when 0")
+	flags.StringVar(&generateOptions.system, "system", "system.csv", "system file to write")
+	flags.StringVar(&generateOptions.bank, "bank", "bank.csv", "bank file to write")
+	flags.StringVar(&generateOptions.manifest, "manifest", "manifest.json", "manifest of the expected exceptions to write")
+}
+
+func runGenerate(stdout io.Writer, options generateFlags) error {
+	startDate, err := time.Parse(time.DateOnly, options.startDate)
+	if err != nil {
+		return fmt.Errorf("--start-date: %w", err)
+	}
+
+	endDate, err := time.Parse(time.DateOnly, options.endDate)
+	if err != nil {
+		return fmt.Errorf("--end-date: %w", err)
+	}
+
+	var bankCodes []string
+	for _, bankCode := range strings.Split(options.bankCodes, ",") {
+		bankCodes = append(bankCodes, strings.TrimSpace(bankCode))
+	}
+
+	generated, err := dataset.Generate(dataset.Options{
+		Rows:      options.rows,
+		BankCodes: bankCodes,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Rates:     options.rates,
+		DateShift: options.dateShift,
+		Seed:      options.seed,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = writeFile(options.system, func(w io.Writer) error { return dataset.WriteSystem(w, generated.Transactions) }); err != nil {
+		return err
+	}
+
+	if err = writeFile(options.bank, func(w io.Writer) error { return dataset.WriteBank(w, generated.BankStatements) }); err != nil {
+		return err
+	}
+
+	if err = writeFile(options.manifest, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(generated.Manifest)
+	}); err != nil {
+		return err
+	}
+
+	manifest := generated.Manifest
+	fmt.Fprintf(stdout, "wrote %d system rows to %s and %d bank rows to %s\n", manifest.SystemRows, options.system, manifest.BankRows, options.bank)
+	fmt.Fprintf(stdout, "wrote %d expected exceptions to %s (seed %d)\n", manifest.TotalExceptions, options.manifest, manifest.Seed)
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"amartha-recon-service/application/ingest"
+	"amartha-recon-service/common"
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ingestInbox = &cobra.Command{
+		Use:   "ingest",
+		Short: "Reconcile the bank files dropped in the inboxes as they arrive",
+		Long: "Cobra CLI : poll the inbox of every rule of recon.ingest.rules, reconcile each complete bank file " +
+			"against the transactions table and store the run, then move the file to the archive, or to the quarantine " +
+			"with an error file next to it when it can not be reconciled.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, cre := fetchConfiguration()
+
+			settings, err := ingest.NewSettings(cfg)
+			if err != nil {
+				log.Println("[ingest] error reading ingest rules: " + err.Error())
+				os.Exit(1)
+			}
+
+			runner := newRunner(cfg, cre, settings.DuplicatePolicy, settings.ValidationMode)
+			ingester := ingest.NewIngester(settings, runner, common.NewGenerate())
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			ingester.Start()
+			log.Printf("[ingest] started for %d rules, polling every %s", len(settings.Rules), settings.PollInterval)
+
+			shutdownOnSignal(ctx, cfg, "[ingest]", ingester.Shutdown)
+		},
+	}
+)
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"amartha-recon-service/configuration"
+	"amartha-recon-service/db"
+	"amartha-recon-service/infrastructure/migration"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateDownSteps int
+	migrateNewDir    string
+
+	migrate = &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply the database migrations embedded in the binary",
+		Long: "Cobra CLI : apply the dbmate style migrations of db/migrations, embedded in the binary. " +
+			"The applied versions are tracked on schema_migrations.",
+	}
+
+	migrateUp = &cobra.Command{
+		Use:   "up",
+		Short: "Apply every pending migration",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			applied, err := newMigrator().Up(context.Background())
+			for _, m := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "applied %s_%s\n", m.Version, m.Name)
+			}
+
+			if err != nil {
+				log.Println("[migrate] error applying migrations: " + err.Error())
+				os.Exit(1)
+			}
+
+			if len(applied) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "nothing to apply")
+			}
+		},
+	}
+
+	migrateDown = &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the last applied migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if migrateDownSteps < 1 {
+				log.Println("[migrate] --steps should be at least 1")
+				os.Exit(1)
+			}
+
+			rolledBack, err := newMigrator().Down(context.Background(), migrateDownSteps)
+			for _, m := range rolledBack {
+				fmt.Fprintf(cmd.OutOrStdout(), "rolled back %s_%s\n", m.Version, m.Name)
+			}
+
+			if err != nil {
+				log.Println("[migrate] error rolling back migrations: " + err.Error())
+				os.Exit(1)
+			}
+
+			if len(rolledBack) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "nothing to roll back")
+			}
+		},
+	}
+
+	migrateStatus = &cobra.Command{
+		Use:   "status",
+		Short: "List the migrations and whether they are applied",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			statuses, err := newMigrator().Status(context.Background())
+			if err != nil {
+				log.Println("[migrate] error reading migrations: " + err.Error())
+				os.Exit(1)
+			}
+
+			table := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(table, "VERSION\tNAME\tSTATUS")
+			pending := 0
+			for _, status := range statuses {
+				state := "pending"
+				switch {
+				case status.Missing:
+					state = "applied, file missing"
+				case status.Applied:
+					state = "applied"
+				default:
+					pending++
+				}
+				fmt.Fprintf(table, "%s\t%s\t%s\n", status.Version, status.Name, state)
+			}
+			table.Flush()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "\n%d pending\n", pending)
+		},
+	}
+
+	migrateNew = &cobra.Command{
+		Use:   "new <name>",
+		Short: "Create an empty migration in db/migrations",
+		Long:  "Cobra CLI : create an empty migration in db/migrations. It is embedded once the binary is built again.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := migration.NewFile(migrateNewDir, args[0], time.Now())
+			if err != nil {
+				log.Println("[migrate] error creating migration: " + err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "created "+path)
+		},
+	}
+)
+
+func init() {
+	migrateDown.Flags().IntVar(&migrateDownSteps, "steps", 1, "number of migrations to roll back")
+	migrateNew.Flags().StringVar(&migrateNewDir, "dir", "db/migrations", "directory of the migrations")
+
+	migrate.AddCommand(migrateUp, migrateDown, migrateStatus, migrateNew)
+}
+
+// newMigrator connects to the master database with the migrations embedded
+// in the binary.
+func newMigrator() migration.Migrator {
+	migrations, err := migration.Load(db.Migrations, "migrations")
+	if err != nil {
+		log.Println("[migrate] error reading migrations: " + err.Error())
+		os.Exit(1)
+	}
+
+	_, cre := fetchConfiguration()
+	dbMaster, err := configuration.NewStoreImpl(cre).InitDBMigration()
+	if err != nil {
+		log.Println("[migrate] error connecting to database: " + err.Error())
+		os.Exit(1)
+	}
+
+	return migration.NewMigrator(dbMaster, migrations)
+}
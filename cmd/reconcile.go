@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/report"
+	"amartha-recon-service/common"
+	"amartha-recon-service/configuration"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// exitMatched, exitMismatched and exitFailed are the exit codes of
+	// reconcile, so a script can tell a clean recon from a failed one.
+	exitMatched    = 0
+	exitMismatched = 1
+	exitFailed     = 2
+)
+
+type (
+	reconcileFlags struct {
+		config          string
+		system          string
+		bank            string
+		startDate       string
+		endDate         string
+		systemTemplate  string
+		bankTemplate    string
+		bankFormat      string
+		systemSheet     string
+		bankSheet       string
+		duplicatePolicy string
+		validationMode  string
+		csv             string
+		xlsx            string
+		json            string
+	}
+
+	// fileException is an exception of the JSON file, with its bank code.
+	fileException struct {
+		BankCode string `json:"bank_code"`
+		recon.Exception
+	}
+)
+
+var (
+	reconcileOptions reconcileFlags
+
+	reconcile = &cobra.Command{
+		Use:   "reconcile",
+		Short: "Reconcile a system file against a bank file offline",
+		Long: "Cobra CLI : reconcile a system file against a bank file without the HTTP API nor MySQL. " +
+			"The summary is written to stdout and the exceptions to the --csv, --xlsx and --json files. " +
+			"Exits 0 when everything matched, 1 when exceptions were found and 2 when the recon failed.",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runReconcile(cmd.OutOrStdout(), reconcileOptions))
+		},
+	}
+)
+
+func init() {
+	flags := reconcile.Flags()
+	flags.StringVar(&reconcileOptions.config, "config", "configuration", "configuration file, with or without its .json extension")
+	flags.StringVar(&reconcileOptions.system, "system", "", "system file, CSV, XLSX, gzip or zip (required)")
+	flags.StringVar(&reconcileOptions.bank, "bank", "", "bank file, CSV, XLSX, MT940, camt.053/054, gzip or zip (required)")
+	flags.StringVar(&reconcileOptions.startDate, "start-date", "", "first day of the recon, YYYY-MM-DD (required)")
+	flags.StringVar(&reconcileOptions.endDate, "end-date", "", "last day of the recon, YYYY-MM-DD (required)")
+	flags.StringVar(&reconcileOptions.systemTemplate, "system-template", "", "template of the system file, detected from its header when empty")
+	flags.StringVar(&reconcileOptions.bankTemplate, "bank-template", "", "template of the bank file, detected from its header when empty")
+	flags.StringVar(&reconcileOptions.bankFormat, "bank-format", "", "format of the bank file over the one of its template")
+	flags.StringVar(&reconcileOptions.systemSheet, "system-sheet", "", "sheet of an XLSX system file")
+	flags.StringVar(&reconcileOptions.bankSheet, "bank-sheet", "", "sheet of an XLSX bank file")
+	flags.StringVar(&reconcileOptions.duplicatePolicy, "duplicate-policy", "", "FLAG (default) or REJECT")
+	flags.StringVar(&reconcileOptions.validationMode, "validation-mode", "", "REJECT (default) or SKIP")
+	flags.StringVar(&reconcileOptions.csv, "csv", "", "write the exceptions to this CSV file")
+	flags.StringVar(&reconcileOptions.xlsx, "xlsx", "", "write the summary and the exceptions to this workbook")
+	flags.StringVar(&reconcileOptions.json, "json", "", "write the exceptions to this JSON file")
+
+	// A mistyped flag is a failed recon, not a mismatch
+	reconcile.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		log.Println("error parsing reconcile flags: " + err.Error())
+		os.Exit(exitFailed)
+		return err
+	})
+}
+
+// runReconcile reconciles the files of options and returns the exit code.
+func runReconcile(stdout io.Writer, options reconcileFlags) int {
+	cfg, err := configuration.FindConfiguration(strings.TrimSuffix(options.config, ".json"))
+	if err != nil {
+		log.Println("[reconcile] error retrieving configuration: " + err.Error())
+		return exitFailed
+	}
+
+	templates := parser.NewRegistry(cfg)
+	sources, loadOptions, err := parseReconcileFlags(options, templates)
+	if err != nil {
+		log.Println("[reconcile] error parsing flags: " + err.Error())
+		return exitFailed
+	}
+
+	for _, source := range sources {
+		defer source.Reader.(io.Closer).Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	loader := parser.NewLoader(templates, parser.NewUploadLimits(cfg), recon.NewSpillSettings(cfg))
+	uploadFile, err := loader.Load(ctx, &sources[0], sources[1], loadOptions, recon.NoProgress)
+	if err != nil {
+		log.Println("[reconcile] error parsing files: " + err.Error())
+		return exitFailed
+	}
+	defer uploadFile.Close()
+
+	// Without a reconciliation repository the run is not stored
+	service := recon.NewService(cfg, nil, nil, common.NewGenerate())
+	result, err := service.Proceed(ctx, uploadFile)
+	if errors.Is(err, recon.ErrorInvalidRows) && result.Validation != nil {
+		for _, rowError := range result.Validation.RowErrors {
+			log.Printf("[reconcile] %s:%d %s %s %q", rowError.File, rowError.Line, rowError.Reason, rowError.Column, rowError.Value)
+		}
+	}
+
+	for _, duplicate := range result.Duplicates {
+		log.Printf("[reconcile] duplicate %s id %q, %d rows", duplicate.Side, duplicate.ID,
+			len(duplicate.Transactions)+len(duplicate.BankStatements))
+	}
+
+	if err != nil {
+		log.Println("[reconcile] error reconciling: " + err.Error())
+		return exitFailed
+	}
+
+	if err := writeReconcileFiles(options, result.ResultReconciliation); err != nil {
+		log.Println("[reconcile] error writing exceptions: " + err.Error())
+		return exitFailed
+	}
+
+	exceptions, err := writeSummary(stdout, result)
+	if err != nil {
+		log.Println("[reconcile] error writing summary: " + err.Error())
+		return exitFailed
+	}
+
+	if exceptions > 0 {
+		return exitMismatched
+	}
+
+	return exitMatched
+}
+
+// parseReconcileFlags checks the flags and opens the system and the bank
+// file, in that order. The caller closes them.
+func parseReconcileFlags(options reconcileFlags, templates parser.Registry) ([]parser.Source, parser.LoadOptions, error) {
+	var (
+		loadOptions parser.LoadOptions
+		err         error
+	)
+
+	if options.system == "" || options.bank == "" {
+		return nil, loadOptions, errors.New("--system and --bank are required")
+	}
+
+	if loadOptions.StartDate, err = time.Parse(time.DateOnly, options.startDate); err != nil {
+		return nil, loadOptions, fmt.Errorf("--start-date: %w", err)
+	}
+
+	if loadOptions.EndDate, err = time.Parse(time.DateOnly, options.endDate); err != nil {
+		return nil, loadOptions, fmt.Errorf("--end-date: %w", err)
+	}
+
+	if loadOptions.DuplicatePolicy, err = recon.ParseDuplicatePolicy(options.duplicatePolicy); err != nil {
+		return nil, loadOptions, err
+	}
+
+	if loadOptions.ValidationMode, err = recon.ParseValidationMode(options.validationMode); err != nil {
+		return nil, loadOptions, err
+	}
+
+	if _, err = templates.Find(options.systemTemplate, parser.SideSystem); err != nil {
+		return nil, loadOptions, err
+	}
+
+	if _, err = templates.Find(options.bankTemplate, parser.SideBank); err != nil {
+		return nil, loadOptions, err
+	}
+
+	bankFormat, err := parser.ParseFormat(options.bankFormat)
+	if err != nil {
+		return nil, loadOptions, err
+	}
+
+	system, err := os.Open(options.system)
+	if err != nil {
+		return nil, loadOptions, err
+	}
+
+	bank, err := os.Open(options.bank)
+	if err != nil {
+		system.Close()
+		return nil, loadOptions, err
+	}
+
+	return []parser.Source{
+		{
+			Reader:   system,
+			FileName: filepath.Base(options.system),
+			Template: options.systemTemplate,
+			Sheet:    options.systemSheet,
+		},
+		{
+			Reader:   bank,
+			FileName: filepath.Base(options.bank),
+			Template: options.bankTemplate,
+			Sheet:    options.bankSheet,
+			Format:   bankFormat,
+		},
+	}, loadOptions, nil
+}
+
+func writeReconcileFiles(options reconcileFlags, results []recon.ResultReconciliation) error {
+	if options.csv != "" {
+		if err := writeFile(options.csv, func(w io.Writer) error { return report.Write(w, report.FormatCSV, results) }); err != nil {
+			return err
+		}
+	}
+
+	if options.xlsx != "" {
+		if err := writeFile(options.xlsx, func(w io.Writer) error { return report.Write(w, report.FormatXLSX, results) }); err != nil {
+			return err
+		}
+	}
+
+	if options.json != "" {
+		exceptions := []fileException{}
+		for _, result := range results {
+			for _, exception := range result.ResultReconciliationDetails.Exceptions {
+				exceptions = append(exceptions, fileException{BankCode: result.BankCode, Exception: exception})
+			}
+		}
+
+		return writeFile(options.json, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(exceptions)
+		})
+	}
+
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(file)
+	if err := write(buffered); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// writeSummary writes a row per bank code and its exceptions by reason, and
+// returns the number of exceptions.
+func writeSummary(w io.Writer, result recon.ShowResultReconciliation) (int, error) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "BANK CODE\tTRANSACTIONS\tMATCHED\tUNMATCHED\tEXCEPTIONS\tAMOUNT DISCREPANCIES")
+
+	var (
+		exceptions    int
+		discrepancies decimal.Decimal
+		byReason      []string
+	)
+	for _, rr := range result.ResultReconciliation {
+		bankExceptions := len(rr.ResultReconciliationDetails.Exceptions)
+		exceptions += bankExceptions
+		discrepancies = discrepancies.Add(rr.TotalAmountDiscrepancies)
+
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%s\n", rr.BankCode, rr.TotalNumberOfTransactions, rr.TotalNumberOfMatchesTransactions,
+			rr.TotalNumberOfUnmatchedTransactions, bankExceptions, rr.TotalAmountDiscrepancies.StringFixed(2))
+
+		for _, reason := range report.Categories {
+			if count := rr.TotalNumberOfExceptionsByReason[reason]; count > 0 {
+				byReason = append(byReason, fmt.Sprintf("%s\t%s\t%d", rr.BankCode, reason, count))
+			}
+		}
+	}
+
+	fmt.Fprintf(table, "TOTAL\t\t\t\t%d\t%s\n", exceptions, discrepancies.StringFixed(2))
+	if len(byReason) > 0 {
+		fmt.Fprintln(table, "\nBANK CODE\tREASON\tEXCEPTIONS")
+		for _, line := range byReason {
+			fmt.Fprintln(table, line)
+		}
+	}
+
+	if validation := result.Validation; validation != nil && validation.TotalNumberOfRejectedRows > 0 {
+		fmt.Fprintf(table, "\nrejected rows: %d (validation mode %s)\n", validation.TotalNumberOfRejectedRows, validation.Mode)
+	}
+
+	return exceptions, table.Flush()
+}
@@ -30,6 +30,11 @@ func init() {
 
 	rootCmd.AddCommand(
 		serveHttp,
+		reconcile,
+		migrate,
+		generate,
+		scheduleRecon,
+		ingestInbox,
 	)
 }
 
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"amartha-recon-service/application/schedule"
+	"amartha-recon-service/common"
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleBank string
+	scheduleDate string
+
+	scheduleRecon = &cobra.Command{
+		Use:   "schedule",
+		Short: "Reconcile every bank on its cron against the transactions table",
+		Long: "Cobra CLI : reconcile the bank files of the inbox on the cron of every bank of recon.schedule.banks, " +
+			"against the transactions table, and store the runs. With --bank and --date one run is done at once instead.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, cre := fetchConfiguration()
+
+			settings, err := schedule.NewSettings(cfg)
+			if err != nil {
+				log.Println("[schedule] error reading schedule: " + err.Error())
+				os.Exit(1)
+			}
+
+			runner := newRunner(cfg, cre, settings.DuplicatePolicy, settings.ValidationMode)
+			scheduler := schedule.NewScheduler(settings, runner, common.NewGenerate())
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if scheduleBank != "" || scheduleDate != "" {
+				runScheduleOnce(ctx, scheduler)
+				return
+			}
+
+			scheduler.Start()
+			log.Printf("[schedule] started for %d banks, inbox %s", len(settings.Banks), settings.InboxDir)
+
+			shutdownOnSignal(ctx, cfg, "[schedule]", scheduler.Shutdown)
+		},
+	}
+)
+
+func init() {
+	scheduleRecon.Flags().StringVar(&scheduleBank, "bank", "", "run the recon of this bank code once, now")
+	scheduleRecon.Flags().StringVar(&scheduleDate, "date", "", "day reconciled by --bank, YYYY-MM-DD")
+}
+
+func runScheduleOnce(ctx context.Context, scheduler schedule.Scheduler) {
+	date, err := time.Parse(time.DateOnly, scheduleDate)
+	if scheduleBank == "" || err != nil {
+		log.Println("[schedule] --bank and --date (YYYY-MM-DD) are both required for a single run")
+		os.Exit(1)
+	}
+
+	if _, err = scheduler.Run(ctx, scheduleBank, date); err != nil {
+		log.Printf("[schedule] recon of bank %s for %s stopped: %v", scheduleBank, scheduleDate, err)
+		os.Exit(1)
+	}
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"amartha-recon-service/application/job"
+	"amartha-recon-service/application/parser"
 	"amartha-recon-service/application/recon"
+	"amartha-recon-service/common"
 	"amartha-recon-service/configuration"
 	"amartha-recon-service/delivery/http"
+	"amartha-recon-service/infrastructure/repository/reconciliation"
+	"amartha-recon-service/infrastructure/repository/reconjob"
 	"amartha-recon-service/infrastructure/repository/transaction"
 	"context"
 	"errors"
@@ -35,8 +40,13 @@ var serveHttp = &cobra.Command{
 		}
 
 		transactionRepository := transaction.NewTransactionRepository(dbMaster)
-		transactionService := recon.NewService(cfg, transactionRepository)
-		transactionController := http.NewController(transactionService)
+		reconciliationRepository := reconciliation.NewReconciliationRepository(dbMaster)
+		transactionService := recon.NewService(cfg, transactionRepository, reconciliationRepository, common.NewGenerate())
+		reconJobRepository := reconjob.NewReconJobRepository(dbMaster)
+		jobManager := job.NewManager(cfg, reconJobRepository, common.NewGenerate())
+		jobManager.Start()
+		templateRegistry := parser.NewRegistry(cfg)
+		transactionController := http.NewController(transactionService, jobManager, templateRegistry, parser.NewUploadLimits(cfg), recon.NewSpillSettings(cfg))
 
 		reconHttpServerAddress := cfg.GetString("server.address.http")
 		router := mux.NewRouter()
@@ -69,5 +79,15 @@ var serveHttp = &cobra.Command{
 		} else {
 			log.Println("[Recon Service HTTP] server stopped.")
 		}
+
+		// Running recon jobs get a grace period, the rest is marked as interrupted
+		shutdownTimeout := time.Duration(cfg.GetInt("job.shutdown.timeout.seconds")) * time.Second
+		jobCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := jobManager.Shutdown(jobCtx); err != nil {
+			log.Println("[Recon Service HTTP], recon jobs are interrupted", err)
+		} else {
+			log.Println("[Recon Service HTTP] recon jobs drained.")
+		}
 	},
 }
@@ -2,14 +2,18 @@ package common
 
 import (
 	constant2 "amartha-recon-service/constant"
+	"bytes"
 	"encoding/json"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 )
 
 const (
-	contentType = "Content-type"
-	application = "application/json"
+	contentType        = "Content-type"
+	contentDisposition = "Content-Disposition"
+	application        = "application/json"
 )
 
 type BillingResponse struct {
@@ -64,3 +68,36 @@ func ToErrorResponse(writer http.ResponseWriter, rc, rcDesc string) {
 		httpRes,
 	)
 }
+
+// ToErrorResponseWithData answers with an error carrying the details the
+// client needs to fix its request.
+func ToErrorResponseWithData(writer http.ResponseWriter, rc, rcDesc string, data interface{}) {
+	httpRes := constant2.BillingCodeToHttpCode[rc]
+
+	responseWrite(
+		writer,
+		NewBillingResponse(rc, rcDesc, nil, data),
+		httpRes,
+	)
+}
+
+// ToFileResponse answers with a file to download. The file is written out
+// before answering, a failing write is answered as a general error.
+func ToFileResponse(writer http.ResponseWriter, fileType, fileName string, write func(w io.Writer) error) {
+	var file bytes.Buffer
+	if err := write(&file); err != nil {
+		log.Println("error during write file response", err)
+		ToErrorResponse(writer,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		return
+	}
+
+	writer.Header().Set(contentType, fileType)
+	writer.Header().Set(contentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	writer.WriteHeader(http.StatusOK)
+	if _, err := writer.Write(file.Bytes()); err != nil {
+		log.Println("error during write file response", err)
+	}
+}
@@ -18,14 +18,20 @@ func NewStoreImpl(credential Configuration) *storeImpl {
 	return &storeImpl{credential: credential}
 }
 
-func (d *storeImpl) initDatabase(configBaseKey string) (*sqlx.DB, error) {
+// dataSourceName is the DSN of the database under configBaseKey, params are
+// appended to its query string.
+func (d *storeImpl) dataSourceName(configBaseKey string, params string) string {
 	dbHost := d.credential.GetString(configBaseKey + ".host")
 	dbPort := d.credential.GetString(configBaseKey + ".port")
 	dbUser := d.credential.GetString(configBaseKey + ".user")
 	dbPass := d.credential.GetString(configBaseKey + ".pass")
 	dbName := d.credential.GetString(configBaseKey + ".name")
-	sourceName := dbUser + ":" + dbPass + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName
-	db, err := sqlx.Open("mysql", sourceName)
+	// parseTime is needed to scan datetime and timestamp columns into time.Time
+	return dbUser + ":" + dbPass + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName + "?parseTime=true" + params
+}
+
+func (d *storeImpl) initDatabase(configBaseKey string, params string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("mysql", d.dataSourceName(configBaseKey, params))
 
 	if err != nil {
 		log.Println("error when init database, because ", err)
@@ -44,13 +50,19 @@ func (d *storeImpl) initDatabase(configBaseKey string) (*sqlx.DB, error) {
 }
 
 func (d *storeImpl) InitDBMaster() (*sqlx.DB, error) {
-	return d.initDatabase("database.master")
+	return d.initDatabase("database.master", "")
+}
+
+// InitDBMigration connects to the master database allowing more than one
+// statement per query, which a migration section is sent as.
+func (d *storeImpl) InitDBMigration() (*sqlx.DB, error) {
+	return d.initDatabase("database.master", "&multiStatements=true")
 }
 
 func (d *storeImpl) InitDbAuditTrail() (*sqlx.DB, error) {
-	return d.initDatabase("database.audittrail")
+	return d.initDatabase("database.audittrail", "")
 }
 
 func (d *storeImpl) InitDBReplica() (*sqlx.DB, error) {
-	return d.initDatabase("database.replica")
+	return d.initDatabase("database.replica", "")
 }
@@ -0,0 +1,40 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_storeImpl_dataSourceName(t *testing.T) {
+	store := NewStoreImpl(&config{data: map[string]interface{}{
+		"database.master.host": "localhost",
+		"database.master.port": "3306",
+		"database.master.user": "recon",
+		"database.master.pass": "secret",
+		"database.master.name": "recon",
+	}})
+
+	tests := []struct {
+		name            string
+		params          string
+		multiStatements bool
+	}{
+		{name: "master", params: ""},
+		{name: "migration", params: "&multiStatements=true", multiStatements: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn := store.dataSourceName("database.master", tt.params)
+			assert.Equal(t, "recon:secret@tcp(localhost:3306)/recon?parseTime=true"+tt.params, dsn)
+
+			// The DSN the driver reads back
+			cfg, err := mysql.ParseDSN(dsn)
+			assert.NoError(t, err)
+			assert.True(t, cfg.ParseTime)
+			assert.Equal(t, tt.multiStatements, cfg.MultiStatements)
+		})
+	}
+}
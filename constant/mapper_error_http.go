@@ -14,6 +14,12 @@ const (
 	PaymentAmountShouldBeEquals
 	ZeroOutstanding
 	ValusIsMismatach
+	DuplicateRows
+	TooManyJobs
+	JobFinished
+	InvalidRows
+	UploadTooLarge
+	ExceptionConflict
 )
 
 var HttpRc = map[BillingSrvHttpError]string{
@@ -23,6 +29,12 @@ var HttpRc = map[BillingSrvHttpError]string{
 	PaymentAmountShouldBeEquals: "0003",
 	ZeroOutstanding:             "0004",
 	ValusIsMismatach:            "0005",
+	DuplicateRows:               "0006",
+	TooManyJobs:                 "0007",
+	JobFinished:                 "0008",
+	InvalidRows:                 "0009",
+	UploadTooLarge:              "0010",
+	ExceptionConflict:           "0011",
 	GeneralError:                "9999",
 }
 
@@ -33,6 +45,12 @@ var HttpRcDescription = map[BillingSrvHttpError]string{
 	PaymentAmountShouldBeEquals: "amount of payment should be exact",
 	ZeroOutstanding:             "Congrats, you are not having any pending outstanding",
 	ValusIsMismatach:            "Value is mismatched",
+	DuplicateRows:               "uploaded file contains duplicate rows",
+	TooManyJobs:                 "too many recon jobs, try again later",
+	JobFinished:                 "recon job is already finished",
+	InvalidRows:                 "uploaded file contains invalid rows",
+	UploadTooLarge:              "uploaded archive is too large or has too many files",
+	ExceptionConflict:           "exception cannot move to the requested status",
 	GeneralError:                "General error",
 }
 
@@ -43,5 +61,11 @@ var BillingCodeToHttpCode = map[string]int{
 	"0003": http.StatusBadRequest,
 	"0004": http.StatusOK,
 	"0005": http.StatusBadRequest,
+	"0006": http.StatusUnprocessableEntity,
+	"0007": http.StatusServiceUnavailable,
+	"0008": http.StatusConflict,
+	"0009": http.StatusUnprocessableEntity,
+	"0010": http.StatusRequestEntityTooLarge,
+	"0011": http.StatusConflict,
 	"9999": http.StatusInternalServerError,
 }
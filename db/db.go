@@ -0,0 +1,10 @@
+// Package db carries the SQL migrations in the binary.
+package db
+
+import "embed"
+
+// Migrations are the dbmate style migrations of db/migrations, applied by
+// the migrate command.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
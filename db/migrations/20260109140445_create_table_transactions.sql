@@ -15,4 +15,4 @@ create index idx_transaction_id_rrn on transactions (transaction_id, terminal_rr
 create index idx_timestamp on transactions (transaction_time, updated_at);
 create index idx_bank_code on transactions (bank_code);
 -- migrate:down
-drop transactions;
\ No newline at end of file
+drop table transactions;
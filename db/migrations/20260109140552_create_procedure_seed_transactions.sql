@@ -27,4 +27,4 @@ END WHILE;
 END;
 CALL seed_transactions(1000);
 -- migrate:down
-
+drop procedure if exists seed_transactions;
@@ -0,0 +1,68 @@
+-- migrate:up
+create table recon_runs
+(
+    id              char(36) primary key,
+    system_source   varchar(16)  not null,
+    system_checksum char(64)     not null,
+    bank_checksum   char(64)     not null,
+    start_date      date         not null,
+    end_date        date         not null,
+    config_snapshot json         not null,
+    started_at      timestamp(3) not null,
+    finished_at     timestamp(3) not null,
+    duration_millis bigint       not null,
+    created_at      timestamp default current_timestamp
+);
+
+create index idx_recon_runs_date on recon_runs (start_date, end_date);
+
+create table recon_bank_summaries
+(
+    id                                          bigint primary key auto_increment,
+    run_id                                      char(36)       not null,
+    bank_code                                   char(3)        not null,
+    total_number_of_transactions                int            not null,
+    total_number_of_matches_transactions        int            not null,
+    total_number_of_unmatched_transactions      int            not null,
+    total_number_of_exact_matches               int            not null,
+    total_number_of_matches_within_tolerance    int            not null,
+    total_number_of_mismatched_pairs            int            not null,
+    total_number_of_group_matches               int            not null,
+    total_number_of_duplicate_rows              int            not null,
+    total_amount_discrepancies                  decimal(19, 2) not null,
+    total_amount_discrepancies_within_tolerance decimal(19, 2) not null,
+    total_amount_discrepancies_mismatched       decimal(19, 2) not null,
+    breakdown                                   json           not null,
+    constraint fk_recon_bank_summaries_run foreign key (run_id) references recon_runs (id)
+);
+
+create unique index idx_recon_bank_summaries_run_bank on recon_bank_summaries (run_id, bank_code);
+
+create table recon_exceptions
+(
+    id                    bigint primary key auto_increment,
+    run_id                char(36)       not null,
+    bank_code             char(3)        not null,
+    reason                varchar(32)    not null,
+    side                  varchar(8)     not null,
+    rule                  varchar(64)    not null default '',
+    transaction_id        varchar(255)   not null default '',
+    terminal_rrn          varchar(255)   not null default '',
+    unique_id             varchar(255)   not null default '',
+    system_amount         decimal(19, 2) null,
+    bank_amount           decimal(19, 2) null,
+    amount_difference     decimal(19, 2) null,
+    transaction_type      varchar(16)    not null default '',
+    bank_transaction_type varchar(16)    not null default '',
+    transaction_time      timestamp      null,
+    bank_date             timestamp      null,
+    constraint fk_recon_exceptions_run foreign key (run_id) references recon_runs (id)
+);
+
+create index idx_recon_exceptions_run_bank_reason on recon_exceptions (run_id, bank_code, reason);
+create index idx_recon_exceptions_transaction_id on recon_exceptions (transaction_id);
+create index idx_recon_exceptions_unique_id on recon_exceptions (unique_id);
+-- migrate:down
+drop table recon_exceptions;
+drop table recon_bank_summaries;
+drop table recon_runs;
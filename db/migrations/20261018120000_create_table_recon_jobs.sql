@@ -0,0 +1,18 @@
+-- migrate:up
+create table recon_jobs
+(
+    id          char(36) primary key,
+    status      varchar(16)   not null,
+    rows_parsed bigint        not null default 0,
+    banks_total int           not null default 0,
+    banks_done  int           not null default 0,
+    run_id      char(36)      not null default '',
+    error       varchar(1024) not null default '',
+    created_at  timestamp(3)  not null,
+    started_at  timestamp(3)  null,
+    finished_at timestamp(3)  null
+);
+
+create index idx_recon_jobs_status on recon_jobs (status);
+-- migrate:down
+drop table recon_jobs;
@@ -0,0 +1,8 @@
+-- migrate:up
+alter table recon_exceptions
+    add column transaction_file varchar(255) not null default '' after bank_date,
+    add column bank_file        varchar(255) not null default '' after transaction_file;
+-- migrate:down
+alter table recon_exceptions
+    drop column bank_file,
+    drop column transaction_file;
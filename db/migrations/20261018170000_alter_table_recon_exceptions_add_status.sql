@@ -0,0 +1,40 @@
+-- migrate:up
+alter table recon_exceptions
+    add column fingerprint       char(64)     not null default '' after bank_file,
+    add column status            varchar(16)  not null default 'OPEN' after fingerprint,
+    add column assignee          varchar(255) not null default '' after status,
+    add column resolution_reason varchar(255) not null default '' after assignee,
+    add column status_updated_at timestamp(3) null after resolution_reason,
+    add column origin_id         bigint       null after status_updated_at;
+
+update recon_exceptions
+set fingerprint = sha2(concat_ws('|', bank_code, reason, side, transaction_id, unique_id), 256);
+
+create index idx_recon_exceptions_fingerprint on recon_exceptions (fingerprint);
+create index idx_recon_exceptions_origin on recon_exceptions (origin_id);
+create index idx_recon_exceptions_run_status on recon_exceptions (run_id, status);
+
+create table recon_exception_comments
+(
+    id           bigint primary key auto_increment,
+    exception_id bigint        not null,
+    author       varchar(255)  not null,
+    status       varchar(16)   not null,
+    body         varchar(4096) not null,
+    created_at   timestamp(3)  not null,
+    constraint fk_recon_exception_comments_exception foreign key (exception_id) references recon_exceptions (id)
+);
+
+create index idx_recon_exception_comments_exception on recon_exception_comments (exception_id);
+-- migrate:down
+drop table recon_exception_comments;
+alter table recon_exceptions
+    drop index idx_recon_exceptions_run_status,
+    drop index idx_recon_exceptions_origin,
+    drop index idx_recon_exceptions_fingerprint,
+    drop column origin_id,
+    drop column status_updated_at,
+    drop column resolution_reason,
+    drop column assignee,
+    drop column status,
+    drop column fingerprint;
@@ -1,50 +1,89 @@
 package http
 
 import (
+	"amartha-recon-service/application/job"
+	"amartha-recon-service/application/parser"
 	"amartha-recon-service/application/recon"
+	"amartha-recon-service/application/report"
 	"amartha-recon-service/common"
 	constant2 "amartha-recon-service/constant"
 	"context"
-	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/shopspring/decimal"
+	"github.com/gorilla/mux"
+)
+
+const (
+	systemSourceFile     = "file"
+	systemSourceDatabase = "database"
 )
 
 type (
 	controller struct {
-		service recon.Service
+		service   recon.Service
+		jobs      job.Manager
+		templates parser.Registry
+		loader    parser.Loader
+	}
+
+	reconForm struct {
+		startDate       time.Time
+		endDate         time.Time
+		duplicatePolicy recon.DuplicatePolicy
+		systemSource    string
+		systemTemplate  string
+		bankTemplate    string
+		// bankFormat is empty when the format of the bank file is detected
+		bankFormat parser.Format
+		// systemSheet and bankSheet choose the sheet of an XLSX over the one of its template
+		systemSheet       string
+		bankSheet         string
+		validationMode    recon.ValidationMode
+		systemFileName    string
+		bankFileName      string
+		systemContentType string
+		bankContentType   string
+		// async runs the recon as a job and answers with the job right away
+		async bool
+		// reportFormat downloads the exceptions instead of the JSON result
+		reportFormat report.Format
 	}
 
 	Controller interface {
 		Proceed(w http.ResponseWriter, r *http.Request)
+		FindRun(w http.ResponseWriter, r *http.Request)
+		DownloadRun(w http.ResponseWriter, r *http.Request)
+		FindExceptions(w http.ResponseWriter, r *http.Request)
+		FindException(w http.ResponseWriter, r *http.Request)
+		UpdateException(w http.ResponseWriter, r *http.Request)
+		CommentException(w http.ResponseWriter, r *http.Request)
+		FindJob(w http.ResponseWriter, r *http.Request)
+		CancelJob(w http.ResponseWriter, r *http.Request)
 	}
 )
 
-func NewController(service recon.Service) Controller {
-	return &controller{service: service}
+func NewController(
+	service recon.Service,
+	jobs job.Manager,
+	templates parser.Registry,
+	limits parser.UploadLimits,
+	spill recon.SpillSettings) Controller {
+	return &controller{service: service, jobs: jobs, templates: templates, loader: parser.NewLoader(templates, limits, spill)}
 }
 
 func (c *controller) Proceed(w http.ResponseWriter, r *http.Request) {
-	startDate := r.FormValue("start_date")
-	startDateParse, err := time.Parse(time.DateOnly, startDate)
-	if err != nil {
-		log.Printf("error parsing startDate: %v", err)
-		common.ToErrorResponse(w,
-			constant2.HttpRc[constant2.ValusIsMismatach],
-			constant2.HttpRcDescription[constant2.ValusIsMismatach],
-		)
-		return
-	}
-
-	endDate := r.FormValue("end_date")
-	endDateParse, err := time.Parse(time.DateOnly, endDate)
+	form, err := c.parseReconForm(r)
 	if err != nil {
-		log.Printf("error parsing endDate: %v", err)
+		log.Printf("error parsing recon form: %v", err)
 		common.ToErrorResponse(w,
 			constant2.HttpRc[constant2.ValusIsMismatach],
 			constant2.HttpRcDescription[constant2.ValusIsMismatach],
@@ -52,22 +91,7 @@ func (c *controller) Proceed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileSystem, _, err := r.FormFile("system")
-	if err != nil {
-		if errors.Is(err, http.ErrMissingFile) {
-			log.Printf("client did not send file system")
-		}
-
-		common.ToErrorResponse(w,
-			constant2.HttpRc[constant2.Validation],
-			constant2.HttpRcDescription[constant2.Validation],
-		)
-		log.Printf("error reading fileSystem: %v", err)
-		return
-	}
-	defer fileSystem.Close()
-
-	fileBank, _, err := r.FormFile("bank")
+	fileBank, fileBankHeader, err := r.FormFile("bank")
 	if err != nil {
 		if errors.Is(err, http.ErrMissingFile) {
 			log.Printf("client did not send file bank")
@@ -80,32 +104,78 @@ func (c *controller) Proceed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer fileBank.Close()
+	form.bankFileName = fileBankHeader.Filename
+	form.bankContentType = fileBankHeader.Header.Get("Content-Type")
+
+	// The system transactions are loaded from the database, only the bank file is needed
+	var fileSystem multipart.File
+	if form.systemSource != systemSourceDatabase {
+		var fileSystemHeader *multipart.FileHeader
+		fileSystem, fileSystemHeader, err = r.FormFile("system")
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				log.Printf("client did not send file system")
+			}
+
+			common.ToErrorResponse(w,
+				constant2.HttpRc[constant2.Validation],
+				constant2.HttpRcDescription[constant2.Validation],
+			)
+			log.Printf("error reading fileSystem: %v", err)
+			return
+		}
+		defer fileSystem.Close()
+		form.systemFileName = fileSystemHeader.Filename
+		form.systemContentType = fileSystemHeader.Header.Get("Content-Type")
+	}
+
+	if form.async {
+		c.submit(w, r, form, fileSystem, fileBank)
+		return
+	}
 
 	ctx := r.Context()
-	readerFileSystem := csv.NewReader(fileSystem)
-	transactionUploadFiles, err := parseTransactionsFromCSV(ctx, readerFileSystem, startDateParse, endDateParse)
-	if err != nil {
+	uploadFile, err := c.buildUploadFile(ctx, form, fileSystem, fileBank, recon.NoProgress)
+	if errors.Is(err, parser.ErrorUploadTooLarge) || errors.Is(err, parser.ErrorTooManyMembers) {
 		common.ToErrorResponse(w,
-			constant2.HttpRc[constant2.Validation],
-			constant2.HttpRcDescription[constant2.Validation],
+			constant2.HttpRc[constant2.UploadTooLarge],
+			constant2.HttpRcDescription[constant2.UploadTooLarge],
 		)
-		log.Printf("error parsing fileSystem: %v", err)
+		log.Printf("error parsing upload: %v", err)
 		return
 	}
 
-	readerBank := csv.NewReader(fileBank)
-	bankStatementUploadFiles, err := parseBankFromCSV(ctx, readerBank, startDateParse, endDateParse)
 	if err != nil {
 		common.ToErrorResponse(w,
 			constant2.HttpRc[constant2.Validation],
 			constant2.HttpRcDescription[constant2.Validation],
 		)
-		log.Printf("error parsing fileBank: %v", err)
+		log.Printf("error parsing upload: %v", err)
 		return
 	}
+	defer uploadFile.Close()
 
-	uploadFile := recon.NewUploadFile(transactionUploadFiles, bankStatementUploadFiles, startDateParse, endDateParse)
 	response, err := c.service.Proceed(ctx, uploadFile)
+	if errors.Is(err, recon.ErrorInvalidRows) {
+		common.ToErrorResponseWithData(w,
+			constant2.HttpRc[constant2.InvalidRows],
+			constant2.HttpRcDescription[constant2.InvalidRows],
+			response.Validation,
+		)
+		log.Printf("error invoke service: %v", err)
+		return
+	}
+
+	if errors.Is(err, recon.ErrorDuplicateRows) {
+		common.ToErrorResponseWithData(w,
+			constant2.HttpRc[constant2.DuplicateRows],
+			constant2.HttpRcDescription[constant2.DuplicateRows],
+			response.Duplicates,
+		)
+		log.Printf("error invoke service: %v", err)
+		return
+	}
+
 	if err != nil {
 		common.ToErrorResponse(w,
 			constant2.HttpRc[constant2.GeneralError],
@@ -115,141 +185,522 @@ func (c *controller) Proceed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if form.reportFormat != "" {
+		name := fmt.Sprintf("recon-%s-%s", form.startDate.Format(time.DateOnly), form.endDate.Format(time.DateOnly))
+		if response.RunID != "" {
+			name = "recon-" + response.RunID
+		}
+
+		writeReport(w, form.reportFormat, name, response.ResultReconciliation)
+		return
+	}
+
 	common.ToSuccessResponse(w, nil, response)
 }
 
-func parseTransactionsFromCSV(
-	ctx context.Context,
-	reader *csv.Reader,
-	startDate, endDate time.Time) ([]recon.TransactionUploadFile, error) {
-	// Skip header
-	if _, err := reader.Read(); err != nil {
-		if err == io.EOF {
-			return nil, nil
+// submit keeps a copy of the uploaded files, the request body is gone once
+// the job runs, and answers with the queued job right away.
+func (c *controller) submit(
+	w http.ResponseWriter,
+	r *http.Request,
+	form reconForm,
+	fileSystem, fileBank multipart.File) {
+	var spooled []string
+	removeSpooled := func() {
+		for _, path := range spooled {
+			if err := os.Remove(path); err != nil {
+				log.Printf("error removing spooled upload: %v", err)
+			}
 		}
-		return nil, err
 	}
 
-	var transactions []recon.TransactionUploadFile
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+	for _, file := range []multipart.File{fileBank, fileSystem} {
+		if file == nil {
+			continue
 		}
 
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
+		path, err := spoolUpload(file)
+		if err != nil {
+			removeSpooled()
+			common.ToErrorResponse(w,
+				constant2.HttpRc[constant2.GeneralError],
+				constant2.HttpRcDescription[constant2.GeneralError],
+			)
+			log.Printf("error spooling upload: %v", err)
+			return
 		}
+		spooled = append(spooled, path)
+	}
 
-		if err != nil {
-			return nil, err
+	response, err := c.jobs.Submit(r.Context(), func(ctx context.Context, progress recon.Progress) (recon.ShowResultReconciliation, error) {
+		readers := make([]io.Reader, 2)
+		for i, path := range spooled {
+			file, err := os.Open(path)
+			if err != nil {
+				return recon.ShowResultReconciliation{}, err
+			}
+			defer file.Close()
+			readers[i] = file
 		}
 
-		parseRow := parseTransactionRow(row)
-		if !parseRow.TransactionTime.Before(startDate) && !parseRow.TransactionTime.After(endDate) {
-			transactions = append(transactions, parseRow)
+		uploadFile, err := c.buildUploadFile(ctx, form, readers[1], readers[0], progress)
+		if err != nil {
+			return recon.ShowResultReconciliation{}, err
 		}
+		defer uploadFile.Close()
+
+		return c.service.Proceed(ctx, uploadFile.WithProgress(progress))
+	}, removeSpooled)
+	if errors.Is(err, job.ErrorQueueFull) || errors.Is(err, job.ErrorShutdown) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.TooManyJobs],
+			constant2.HttpRcDescription[constant2.TooManyJobs],
+		)
+		log.Printf("error submit recon job: %v", err)
+		return
 	}
 
-	return transactions, nil
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error submit recon job: %v", err)
+		return
+	}
+
+	common.ToSuccessResponse(w, nil, response)
 }
 
-func parseTransactionRow(row []string) recon.TransactionUploadFile {
-	tfs := recon.TransactionUploadFile{}
+func (c *controller) FindRun(w http.ResponseWriter, r *http.Request) {
+	runID := mux.Vars(r)["run_id"]
+	response, err := c.service.FindRun(r.Context(), runID)
+	if errors.Is(err, recon.ErrorRunNotFound) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon run is not found: %v", runID)
+		return
+	}
 
-	if len(row) > 0 {
-		tfs.TransactionID = row[0]
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error invoke service: %v", err)
+		return
 	}
 
-	if len(row) > 1 {
-		tfs.TerminalRRN = row[1]
+	common.ToSuccessResponse(w, nil, response)
+}
+
+// DownloadRun answers the exceptions of a stored run as a CSV or a
+// workbook, CSV unless asked otherwise.
+func (c *controller) DownloadRun(w http.ResponseWriter, r *http.Request) {
+	runID := mux.Vars(r)["run_id"]
+	format, err := report.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error parsing report format: %v", err)
+		return
 	}
 
-	if len(row) > 2 {
-		if amount, err := decimal.NewFromString(row[2]); err == nil {
-			tfs.Amount = amount
-		}
+	if format == "" {
+		format = report.FormatCSV
 	}
 
-	if len(row) > 3 {
-		tfs.TransactionType = row[3]
+	response, err := c.service.FindRun(r.Context(), runID)
+	if errors.Is(err, recon.ErrorRunNotFound) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon run is not found: %v", runID)
+		return
 	}
 
-	if len(row) > 4 {
-		tfs.BankCode = row[4]
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error invoke service: %v", err)
+		return
 	}
 
-	if len(row) > 5 {
-		if dt, err := time.Parse(time.DateTime, row[5]); err == nil {
-			tfs.TransactionTime = dt
-		}
+	writeReport(w, format, "recon-"+response.RunID, response.ResultReconciliation)
+}
+
+// FindExceptions lists the exceptions of a stored run a page at a time.
+func (c *controller) FindExceptions(w http.ResponseWriter, r *http.Request) {
+	query, err := parseExceptionQuery(r)
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error parsing exception query: %v", err)
+		return
+	}
+
+	response, err := c.service.FindExceptions(r.Context(), query)
+	if errors.Is(err, recon.ErrorInvalidExceptionQuery) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error invoke service: %v", err)
+		return
+	}
+
+	if errors.Is(err, recon.ErrorRunNotFound) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon run is not found: %v", query.RunID)
+		return
+	}
+
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error invoke service: %v", err)
+		return
 	}
 
-	return tfs
+	common.ToSuccessResponse(w, response.Pagination, response.Exceptions)
 }
 
-func parseBankFromCSV(
-	ctx context.Context,
-	reader *csv.Reader,
-	startDate, endDate time.Time) ([]recon.BankStatementUploadFile, error) {
-	// Skip header
-	if _, err := reader.Read(); err != nil {
-		if err == io.EOF {
-			return nil, nil
-		}
-		return nil, err
+// FindException answers a stored exception with its comment thread.
+func (c *controller) FindException(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["exception_id"], 10, 64)
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error parsing exception id: %v", err)
+		return
 	}
 
-	var bankStatementUploadFiles []recon.BankStatementUploadFile
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
+	response, err := c.service.FindException(r.Context(), id)
+	if err != nil {
+		writeExceptionError(w, id, err)
+		return
+	}
 
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
+	common.ToSuccessResponse(w, nil, response)
+}
 
-		if err != nil {
-			return nil, err
-		}
+// UpdateException moves an exception to the status of the form, assigns it
+// when the assignee field is sent and records the change on its thread.
+func (c *controller) UpdateException(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["exception_id"], 10, 64)
+	if err == nil {
+		err = r.ParseForm()
+	}
+
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error parsing exception update: %v", err)
+		return
+	}
+
+	update := recon.ExceptionUpdate{
+		ID:               id,
+		Status:           r.PostForm.Get("status"),
+		ResolutionReason: r.PostForm.Get("resolution_reason"),
+		Author:           r.PostForm.Get("author"),
+		Comment:          r.PostForm.Get("comment"),
+	}
+
+	// An empty assignee unassigns, a missing one keeps the assignee
+	if r.PostForm.Has("assignee") {
+		assignee := r.PostForm.Get("assignee")
+		update.Assignee = &assignee
+	}
+
+	response, err := c.service.UpdateException(r.Context(), update)
+	if err != nil {
+		writeExceptionError(w, id, err)
+		return
+	}
+
+	common.ToSuccessResponse(w, nil, response)
+}
+
+// CommentException adds a comment to the thread of an exception.
+func (c *controller) CommentException(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["exception_id"], 10, 64)
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error parsing exception id: %v", err)
+		return
+	}
 
-		parseRow := parseBankRow(row)
-		if !parseRow.Date.Before(startDate) && !parseRow.Date.After(endDate) {
-			bankStatementUploadFiles = append(bankStatementUploadFiles, parseRow)
+	response, err := c.service.CommentException(r.Context(), id, r.PostFormValue("author"), r.PostFormValue("comment"))
+	if err != nil {
+		writeExceptionError(w, id, err)
+		return
+	}
+
+	common.ToSuccessResponse(w, nil, response)
+}
+
+func (c *controller) FindJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["job_id"]
+	response, err := c.jobs.Find(r.Context(), jobID)
+	if errors.Is(err, job.ErrorJobNotFound) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon job is not found: %v", jobID)
+		return
+	}
+
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error find recon job: %v", err)
+		return
+	}
+
+	common.ToSuccessResponse(w, nil, response)
+}
+
+func (c *controller) CancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["job_id"]
+	response, err := c.jobs.Cancel(r.Context(), jobID)
+	if errors.Is(err, job.ErrorJobNotFound) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon job is not found: %v", jobID)
+		return
+	}
+
+	if errors.Is(err, job.ErrorJobFinished) {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.JobFinished],
+			constant2.HttpRcDescription[constant2.JobFinished],
+		)
+		log.Printf("recon job is already finished: %v", jobID)
+		return
+	}
+
+	if err != nil {
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error cancel recon job: %v", err)
+		return
+	}
+
+	common.ToSuccessResponse(w, nil, response)
+}
+
+// parseReconForm reads the form values of a recon request.
+func (c *controller) parseReconForm(r *http.Request) (reconForm, error) {
+	var (
+		form reconForm
+		err  error
+	)
+
+	if form.startDate, err = time.Parse(time.DateOnly, r.FormValue("start_date")); err != nil {
+		return form, err
+	}
+
+	if form.endDate, err = time.Parse(time.DateOnly, r.FormValue("end_date")); err != nil {
+		return form, err
+	}
+
+	if form.duplicatePolicy, err = recon.ParseDuplicatePolicy(r.FormValue("duplicate_policy")); err != nil {
+		return form, err
+	}
+
+	form.systemSource = strings.ToLower(strings.TrimSpace(r.FormValue("system_source")))
+	if form.systemSource != "" && form.systemSource != systemSourceFile && form.systemSource != systemSourceDatabase {
+		return form, fmt.Errorf("unknown system source %q", form.systemSource)
+	}
+
+	// Without a template the layout is detected from the header row of the file
+	form.systemTemplate = strings.TrimSpace(r.FormValue("system_template"))
+	if _, err = c.templates.Find(form.systemTemplate, parser.SideSystem); err != nil {
+		return form, err
+	}
+
+	form.bankTemplate = strings.TrimSpace(r.FormValue("bank_template"))
+	if _, err = c.templates.Find(form.bankTemplate, parser.SideBank); err != nil {
+		return form, err
+	}
+
+	if form.bankFormat, err = parser.ParseFormat(r.FormValue("bank_format")); err != nil {
+		return form, err
+	}
+
+	if form.reportFormat, err = report.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept")); err != nil {
+		return form, err
+	}
+
+	form.systemSheet = strings.TrimSpace(r.FormValue("system_sheet"))
+	form.bankSheet = strings.TrimSpace(r.FormValue("bank_sheet"))
+
+	if form.validationMode, err = recon.ParseValidationMode(r.FormValue("validation_mode")); err != nil {
+		return form, err
+	}
+
+	if async := r.FormValue("async"); async != "" {
+		if form.async, err = strconv.ParseBool(async); err != nil {
+			return form, err
 		}
 	}
 
-	return bankStatementUploadFiles, nil
+	// A job is answered with its id, its report is downloaded from the run once done
+	if form.async && form.reportFormat != "" {
+		return form, fmt.Errorf("async recon can not be answered as a %s report", form.reportFormat)
+	}
+
+	return form, nil
 }
 
-func parseBankRow(row []string) recon.BankStatementUploadFile {
-	bsu := recon.BankStatementUploadFile{}
+// buildUploadFile parses the uploaded files, system is nil when the system
+// transactions are loaded from the database. The caller closes the upload.
+func (c *controller) buildUploadFile(
+	ctx context.Context,
+	form reconForm,
+	system, bank io.Reader,
+	progress recon.Progress) (*recon.UploadFile, error) {
+	bankSource := parser.Source{
+		Reader:      bank,
+		FileName:    form.bankFileName,
+		ContentType: form.bankContentType,
+		Template:    form.bankTemplate,
+		Sheet:       form.bankSheet,
+		Format:      form.bankFormat,
+	}
+
+	var systemSource *parser.Source
+	if system != nil {
+		systemSource = &parser.Source{
+			Reader:      system,
+			FileName:    form.systemFileName,
+			ContentType: form.systemContentType,
+			Template:    form.systemTemplate,
+			Sheet:       form.systemSheet,
+		}
+	}
+
+	return c.loader.Load(ctx, systemSource, bankSource, parser.LoadOptions{
+		StartDate:       form.startDate,
+		EndDate:         form.endDate,
+		DuplicatePolicy: form.duplicatePolicy,
+		ValidationMode:  form.validationMode,
+	}, progress)
+}
 
-	if len(row) > 0 {
-		bsu.UniqueID = row[0]
+// parseExceptionQuery reads the filters, the sorting and the page of an
+// exception listing from the query string.
+func parseExceptionQuery(r *http.Request) (recon.ExceptionQuery, error) {
+	values := r.URL.Query()
+	query := recon.ExceptionQuery{
+		RunID:    mux.Vars(r)["run_id"],
+		BankCode: values.Get("bank_code"),
+		Reason:   values.Get("reason"),
+		Side:     values.Get("side"),
+		Status:   values.Get("status"),
+		SortBy:   values.Get("sort"),
+		Order:    values.Get("order"),
 	}
 
-	if len(row) > 1 {
-		if amount, err := decimal.NewFromString(row[1]); err == nil {
-			bsu.Amount = amount
+	var err error
+	if page := values.Get("page"); page != "" {
+		if query.Page, err = strconv.Atoi(page); err != nil {
+			return query, err
 		}
 	}
 
-	if len(row) > 2 {
-		if dt, err := time.Parse(time.DateTime, row[2]); err == nil {
-			bsu.Date = dt
+	if size := values.Get("size"); size != "" {
+		if query.Size, err = strconv.Atoi(size); err != nil {
+			return query, err
 		}
 	}
 
-	if len(row) > 3 {
-		bsu.BankCode = row[3]
+	return query, nil
+}
+
+// writeExceptionError answers an error of the exception workflow.
+func writeExceptionError(w http.ResponseWriter, id uint64, err error) {
+	switch {
+	case errors.Is(err, recon.ErrorExceptionNotFound):
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.DataNotFound],
+			constant2.HttpRcDescription[constant2.DataNotFound],
+		)
+		log.Printf("recon exception is not found: %v", id)
+	case errors.Is(err, recon.ErrorInvalidExceptionStatus):
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ValusIsMismatach],
+			constant2.HttpRcDescription[constant2.ValusIsMismatach],
+		)
+		log.Printf("error invoke service: %v", err)
+	case errors.Is(err, recon.ErrorIncompleteExceptionUpdate):
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.Validation],
+			constant2.HttpRcDescription[constant2.Validation],
+		)
+		log.Printf("error invoke service: %v", err)
+	case errors.Is(err, recon.ErrorInvalidStatusTransition), errors.Is(err, recon.ErrorExceptionChanged):
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.ExceptionConflict],
+			constant2.HttpRcDescription[constant2.ExceptionConflict],
+		)
+		log.Printf("recon exception %v cannot be changed: %v", id, err)
+	default:
+		common.ToErrorResponse(w,
+			constant2.HttpRc[constant2.GeneralError],
+			constant2.HttpRcDescription[constant2.GeneralError],
+		)
+		log.Printf("error invoke service: %v", err)
+	}
+}
+
+func writeReport(w http.ResponseWriter, format report.Format, name string, results []recon.ResultReconciliation) {
+	common.ToFileResponse(w, format.ContentType(), format.FileName(name), func(file io.Writer) error {
+		return report.Write(file, format, results)
+	})
+}
+
+// spoolUpload copies an uploaded file to a temporary file owned by the caller.
+func spoolUpload(file multipart.File) (string, error) {
+	spool, err := os.CreateTemp("", "recon-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer spool.Close()
+
+	if _, err = io.Copy(spool, file); err != nil {
+		os.Remove(spool.Name())
+		return "", err
 	}
 
-	return bsu
+	return spool.Name(), nil
 }
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"amartha-recon-service/application/parser"
+	"amartha-recon-service/application/report"
+	"amartha-recon-service/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_controller_parseReconForm(t *testing.T) {
+	cfg := mocks.NewConfiguration(t)
+	cfg.On("GetArray", "recon.templates").Return([]string(nil)).Maybe()
+	c := &controller{templates: parser.NewRegistry(cfg)}
+
+	newRequest := func(query string, values url.Values) *http.Request {
+		values.Set("start_date", "2026-01-01")
+		values.Set("end_date", "2026-01-03")
+		r := httptest.NewRequest(http.MethodPost, "/v1/internal/recon"+query, strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	t.Run("success bank format and report format", func(t *testing.T) {
+		form, err := c.parseReconForm(newRequest("?format=xlsx", url.Values{"bank_format": {"MT940"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, parser.FormatMT940, form.bankFormat)
+		assert.Equal(t, report.FormatXLSX, form.reportFormat)
+	})
+
+	t.Run("success format of the body is not the bank format", func(t *testing.T) {
+		form, err := c.parseReconForm(newRequest("", url.Values{"format": {"csv"}}))
+		assert.NoError(t, err)
+		assert.Empty(t, form.bankFormat)
+		assert.Empty(t, form.reportFormat)
+	})
+
+	t.Run("error bank format", func(t *testing.T) {
+		_, err := c.parseReconForm(newRequest("", url.Values{"bank_format": {"PDF"}}))
+		assert.Error(t, err)
+	})
+
+	t.Run("error async report", func(t *testing.T) {
+		_, err := c.parseReconForm(newRequest("?format=csv", url.Values{"async": {"true"}}))
+		assert.Error(t, err)
+	})
+}
@@ -8,4 +8,12 @@ import (
 
 func (b *reconHandler) routeRecon(r *mux.Router) {
 	r.HandleFunc("/v1/internal/recon", b.controller.Proceed).Methods(http.MethodPost)
+	r.HandleFunc("/v1/internal/recon/jobs/{job_id}", b.controller.FindJob).Methods(http.MethodGet)
+	r.HandleFunc("/v1/internal/recon/jobs/{job_id}", b.controller.CancelJob).Methods(http.MethodDelete)
+	r.HandleFunc("/v1/internal/recon/exceptions/{exception_id}", b.controller.FindException).Methods(http.MethodGet)
+	r.HandleFunc("/v1/internal/recon/exceptions/{exception_id}", b.controller.UpdateException).Methods(http.MethodPatch)
+	r.HandleFunc("/v1/internal/recon/exceptions/{exception_id}/comments", b.controller.CommentException).Methods(http.MethodPost)
+	r.HandleFunc("/v1/internal/recon/{run_id}", b.controller.FindRun).Methods(http.MethodGet)
+	r.HandleFunc("/v1/internal/recon/{run_id}/report", b.controller.DownloadRun).Methods(http.MethodGet)
+	r.HandleFunc("/v1/internal/recon/{run_id}/exceptions", b.controller.FindExceptions).Methods(http.MethodGet)
 }
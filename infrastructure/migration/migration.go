@@ -0,0 +1,209 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	upMarker   = "-- migrate:up"
+	downMarker = "-- migrate:down"
+
+	// versionLayout names a new migration by the time it was created, like dbmate
+	versionLayout = "20060102150405"
+)
+
+var (
+	ErrorInvalidMigration = errors.New("migration file is not valid")
+	ErrorMigrationMissing = errors.New("migration file of an applied version is not exist")
+
+	fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+	namePattern     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type (
+	// Migration is a dbmate style file, its version is the number its file
+	// name starts with.
+	Migration struct {
+		Version string
+		Name    string
+		Up      Section
+		Down    Section
+	}
+
+	// Section is the up or the down of a migration. It runs in a transaction
+	// unless its marker says transaction:false, a DDL statement still commits
+	// implicitly on MySQL.
+	Section struct {
+		SQL         string
+		Transaction bool
+	}
+
+	Status struct {
+		Version string
+		Name    string
+		Applied bool
+		// Missing is an applied version without a migration file
+		Missing bool
+	}
+
+	Migrator interface {
+		Up(ctx context.Context) ([]Migration, error)
+		Down(ctx context.Context, steps int) ([]Migration, error)
+		Status(ctx context.Context) ([]Status, error)
+	}
+)
+
+// Load parses the migrations of dir in fsys, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	versions := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, err := Parse(entry.Name(), string(content))
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := versions[migration.Version]; ok {
+			return nil, fmt.Errorf("%w: %s and %s have the same version", ErrorInvalidMigration, other, entry.Name())
+		}
+		versions[migration.Version] = entry.Name()
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Parse splits a migration file in its up and down sections. Blank lines and
+// comments may come before the up marker, nothing else.
+func Parse(fileName, content string) (Migration, error) {
+	match := fileNamePattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return Migration{}, fmt.Errorf("%w: %s is not named <version>_<name>.sql", ErrorInvalidMigration, fileName)
+	}
+
+	migration := Migration{Version: match[1], Name: match[2]}
+
+	var (
+		current  *Section
+		sections = make(map[string]bool)
+		builder  strings.Builder
+	)
+	flush := func() {
+		if current != nil {
+			current.SQL = strings.TrimSpace(builder.String())
+		}
+		builder.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		marker := ""
+		switch {
+		case strings.HasPrefix(trimmed, upMarker):
+			marker = upMarker
+		case strings.HasPrefix(trimmed, downMarker):
+			marker = downMarker
+		}
+
+		if marker == "" {
+			if current == nil && trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return Migration{}, fmt.Errorf("%w: %s has statements before %s", ErrorInvalidMigration, fileName, upMarker)
+			}
+			builder.WriteString(line)
+			continue
+		}
+
+		if sections[marker] || (marker == downMarker && !sections[upMarker]) {
+			return Migration{}, fmt.Errorf("%w: %s has a misplaced %s", ErrorInvalidMigration, fileName, marker)
+		}
+		sections[marker] = true
+
+		flush()
+		current = &migration.Up
+		if marker == downMarker {
+			current = &migration.Down
+		}
+
+		transaction, err := parseOptions(strings.TrimPrefix(trimmed, marker))
+		if err != nil {
+			return Migration{}, fmt.Errorf("%w: %s %v", ErrorInvalidMigration, fileName, err)
+		}
+		current.Transaction = transaction
+	}
+	flush()
+
+	if !sections[upMarker] {
+		return Migration{}, fmt.Errorf("%w: %s has no %s", ErrorInvalidMigration, fileName, upMarker)
+	}
+
+	return migration, nil
+}
+
+// parseOptions reads the options of a marker, transaction:false is the only
+// one dbmate knows besides its default.
+func parseOptions(options string) (bool, error) {
+	transaction := true
+	for _, option := range strings.Fields(options) {
+		switch option {
+		case "transaction:true":
+			transaction = true
+		case "transaction:false":
+			transaction = false
+		default:
+			return false, fmt.Errorf("unknown option %q", option)
+		}
+	}
+
+	return transaction, nil
+}
+
+// NewFile creates an empty migration named name in dir, versioned by now,
+// and returns its path.
+func NewFile(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(namePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", fmt.Errorf("%w: the name has no letters nor digits", ErrorInvalidMigration)
+	}
+
+	filePath := filepath.Join(dir, now.UTC().Format(versionLayout)+"_"+name+".sql")
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = file.WriteString(upMarker + "\n\n" + downMarker + "\n\n"); err != nil {
+		file.Close()
+		return "", err
+	}
+
+	return filePath, file.Close()
+}
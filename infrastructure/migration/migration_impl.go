@@ -0,0 +1,170 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// The schema table of dbmate, so a database migrated by either is known to both
+	queryCreateSchemaTable = "create table if not exists schema_migrations (version varchar(128) primary key)"
+	queryFindApplied       = "select version FROM schema_migrations ORDER BY version"
+	queryInsertVersion     = "insert into schema_migrations (version) values (?)"
+	queryDeleteVersion     = "delete FROM schema_migrations WHERE version = ?"
+)
+
+type migrator struct {
+	masterConnection *sqlx.DB
+	migrations       []Migration
+}
+
+// NewMigrator applies migrations on connectionDB. A section is sent as a
+// whole, the connection needs multiStatements=true for sections with more
+// than one statement.
+func NewMigrator(connectionDB *sqlx.DB, migrations []Migration) Migrator {
+	return &migrator{masterConnection: connectionDB, migrations: migrations}
+}
+
+// Up applies every migration not applied yet, oldest first, and returns
+// them. It stops at the first one failing.
+func (m *migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if slices.Contains(applied, migration.Version) {
+			continue
+		}
+
+		if err := m.run(ctx, migration.Up, queryInsertVersion, migration.Version); err != nil {
+			log.Println("error when applying migration -> ", migration.Version, err)
+			return done, fmt.Errorf("%s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations, latest first, and
+// returns them.
+func (m *migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		index := slices.IndexFunc(m.migrations, func(migration Migration) bool {
+			return migration.Version == applied[i]
+		})
+		if index < 0 {
+			return done, fmt.Errorf("%w: %s", ErrorMigrationMissing, applied[i])
+		}
+
+		migration := m.migrations[index]
+		if err := m.run(ctx, migration.Down, queryDeleteVersion, migration.Version); err != nil {
+			log.Println("error when rolling back migration -> ", migration.Version, err)
+			return done, fmt.Errorf("%s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status lists every migration with whether it is applied, and the applied
+// versions whose file is gone, by version.
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+			Applied: slices.Contains(applied, migration.Version),
+		})
+	}
+
+	for _, version := range applied {
+		if !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+			statuses = append(statuses, Status{Version: version, Applied: true, Missing: true})
+		}
+	}
+
+	slices.SortStableFunc(statuses, func(a, b Status) int {
+		return strings.Compare(a.Version, b.Version)
+	})
+
+	return statuses, nil
+}
+
+// applied creates the schema table when needed and returns the applied
+// versions, oldest first.
+func (m *migrator) applied(ctx context.Context) ([]string, error) {
+	if _, err := m.masterConnection.ExecContext(ctx, queryCreateSchemaTable); err != nil {
+		log.Println("error when creating schema_migrations -> ", err)
+		return nil, err
+	}
+
+	var versions []string
+	if err := m.masterConnection.SelectContext(ctx, &versions, queryFindApplied); err != nil {
+		log.Println("error when selecting schema_migrations -> ", err)
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// run executes a section and records its version with queryVersion, both in
+// one transaction unless the section opts out.
+func (m *migrator) run(ctx context.Context, section Section, queryVersion, version string) error {
+	if !section.Transaction {
+		if err := execSection(ctx, m.masterConnection, section); err != nil {
+			return err
+		}
+
+		_, err := m.masterConnection.ExecContext(ctx, queryVersion, version)
+		return err
+	}
+
+	tx, err := m.masterConnection.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = execSection(ctx, tx, section); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, queryVersion, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execSection runs the statements of a section, an empty section does
+// nothing.
+func execSection(ctx context.Context, execer sqlx.ExecerContext, section Section) error {
+	if section.SQL == "" {
+		return nil
+	}
+
+	_, err := execer.ExecContext(ctx, section.SQL)
+	return err
+}
@@ -0,0 +1,128 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var migrations = []Migration{
+	{Version: "1", Name: "create_table_a", Up: Section{SQL: "create table a (id int);", Transaction: true}, Down: Section{SQL: "drop table a;", Transaction: true}},
+	{Version: "2", Name: "seed_a", Up: Section{SQL: "CALL seed_a();"}, Down: Section{Transaction: true}},
+	{Version: "3", Name: "create_table_b", Up: Section{SQL: "create table b (id int);", Transaction: true}, Down: Section{SQL: "drop table b;", Transaction: true}},
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...string) {
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, version := range versions {
+		rows.AddRow(version)
+	}
+
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version FROM schema_migrations ORDER BY version").WillReturnRows(rows)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"), migrations)
+
+	ctx := context.Background()
+
+	t.Run("success pending only", func(t *testing.T) {
+		expectApplied(mock, "1")
+		mock.ExpectExec("CALL seed_a\\(\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("insert into schema_migrations").WithArgs("2").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("create table b").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("insert into schema_migrations").WithArgs("3").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		applied, err := m.Up(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, applied, 2)
+		assert.Equal(t, "2", applied[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error stops and rolls back", func(t *testing.T) {
+		expectApplied(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec("create table a").WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		applied, err := m.Up(ctx)
+		assert.EqualError(t, err, "1_create_table_a: db error")
+		assert.Empty(t, applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing to apply", func(t *testing.T) {
+		expectApplied(mock, "1", "2", "3")
+
+		applied, err := m.Up(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"), migrations)
+
+	ctx := context.Background()
+
+	t.Run("success latest first", func(t *testing.T) {
+		expectApplied(mock, "1", "2", "3")
+		mock.ExpectBegin()
+		mock.ExpectExec("drop table b").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("delete FROM schema_migrations WHERE version = \\?").WithArgs("3").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// An empty down only forgets the version
+		mock.ExpectBegin()
+		mock.ExpectExec("delete FROM schema_migrations WHERE version = \\?").WithArgs("2").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		rolledBack, err := m.Down(ctx, 2)
+		assert.NoError(t, err)
+		assert.Len(t, rolledBack, 2)
+		assert.Equal(t, "3", rolledBack[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error missing file", func(t *testing.T) {
+		expectApplied(mock, "1", "4")
+
+		rolledBack, err := m.Down(ctx, 1)
+		assert.ErrorIs(t, err, ErrorMigrationMissing)
+		assert.Empty(t, rolledBack)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"), migrations)
+
+	expectApplied(mock, "1", "25")
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Status{
+		{Version: "1", Name: "create_table_a", Applied: true},
+		{Version: "2", Name: "seed_a"},
+		{Version: "25", Applied: true, Missing: true},
+		{Version: "3", Name: "create_table_b"},
+	}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"amartha-recon-service/db"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		migration, err := Parse("20260101000000_create_table_a.sql",
+			"-- create a\n\n-- migrate:up\ncreate table a (id int);\ncreate index idx_a on a (id);\n-- migrate:down\ndrop table a;\n")
+		assert.NoError(t, err)
+		assert.Equal(t, "20260101000000", migration.Version)
+		assert.Equal(t, "create_table_a", migration.Name)
+		assert.Equal(t, Section{SQL: "create table a (id int);\ncreate index idx_a on a (id);", Transaction: true}, migration.Up)
+		assert.Equal(t, Section{SQL: "drop table a;", Transaction: true}, migration.Down)
+	})
+
+	t.Run("success without transaction and down", func(t *testing.T) {
+		migration, err := Parse("1_seed.sql", "-- migrate:up transaction:false\nCALL seed(10);\n-- migrate:down\n")
+		assert.NoError(t, err)
+		assert.Equal(t, Section{SQL: "CALL seed(10);"}, migration.Up)
+		assert.Equal(t, Section{Transaction: true}, migration.Down)
+	})
+
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{name: "file name", fileName: "create_table_a.sql", content: "-- migrate:up\n"},
+		{name: "no up", fileName: "1_a.sql", content: "create table a (id int);\n"},
+		{name: "statement before up", fileName: "1_a.sql", content: "drop table a;\n-- migrate:up\n"},
+		{name: "down before up", fileName: "1_a.sql", content: "-- migrate:down\n-- migrate:up\n"},
+		{name: "two ups", fileName: "1_a.sql", content: "-- migrate:up\n-- migrate:up\n"},
+		{name: "unknown option", fileName: "1_a.sql", content: "-- migrate:up transaction:maybe\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run("error "+tt.name, func(t *testing.T) {
+			_, err := Parse(tt.fileName, tt.content)
+			assert.True(t, errors.Is(err, ErrorInvalidMigration), err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("success ordered by version", func(t *testing.T) {
+		migrations, err := Load(fstest.MapFS{
+			"migrations/2_b.sql":   {Data: []byte("-- migrate:up\nselect 2;\n")},
+			"migrations/1_a.sql":   {Data: []byte("-- migrate:up\nselect 1;\n")},
+			"migrations/README.md": {Data: []byte("not a migration")},
+		}, "migrations")
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, "1", migrations[0].Version)
+		assert.Equal(t, "2", migrations[1].Version)
+	})
+
+	t.Run("error same version", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{
+			"migrations/1_a.sql": {Data: []byte("-- migrate:up\n")},
+			"migrations/1_b.sql": {Data: []byte("-- migrate:up\n")},
+		}, "migrations")
+		assert.ErrorIs(t, err, ErrorInvalidMigration)
+	})
+
+	t.Run("success embedded migrations", func(t *testing.T) {
+		migrations, err := Load(db.Migrations, "migrations")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, migrations)
+
+		for _, migration := range migrations {
+			assert.NotEmpty(t, migration.Up.SQL, migration.Name)
+		}
+		assert.Equal(t, "drop table transactions;", migrations[0].Down.SQL)
+	})
+}
+
+func TestNewFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	path, err := NewFile(dir, "Add Index to Runs", now)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "20261018093000_add_index_to_runs.sql"), path)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	migration, err := Parse(filepath.Base(path), string(content))
+	assert.NoError(t, err)
+	assert.Empty(t, migration.Up.SQL)
+
+	_, err = NewFile(dir, "add index to runs", now)
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	_, err = NewFile(dir, "!!", now)
+	assert.ErrorIs(t, err, ErrorInvalidMigration)
+}
@@ -0,0 +1,126 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ExceptionSortAmount ExceptionSort = "amount"
+	ExceptionSortTime   ExceptionSort = "time"
+)
+
+var (
+	ErrorRunNotFound       = errors.New("recon run is not exist")
+	ErrorExceptionNotFound = errors.New("recon exception is not exist")
+	ErrorExceptionChanged  = errors.New("recon exception was changed by someone else")
+)
+
+type (
+	Run struct {
+		ID             string    `db:"id"`
+		SystemSource   string    `db:"system_source"`
+		SystemChecksum string    `db:"system_checksum"`
+		BankChecksum   string    `db:"bank_checksum"`
+		StartDate      time.Time `db:"start_date"`
+		EndDate        time.Time `db:"end_date"`
+		ConfigSnapshot string    `db:"config_snapshot"`
+		StartedAt      time.Time `db:"started_at"`
+		FinishedAt     time.Time `db:"finished_at"`
+		DurationMillis int64     `db:"duration_millis"`
+	}
+
+	BankSummary struct {
+		ID                                      uint64          `db:"id"`
+		RunID                                   string          `db:"run_id"`
+		BankCode                                string          `db:"bank_code"`
+		TotalNumberOfTransactions               int             `db:"total_number_of_transactions"`
+		TotalNumberOfMatchesTransactions        int             `db:"total_number_of_matches_transactions"`
+		TotalNumberOfUnmatchedTransactions      int             `db:"total_number_of_unmatched_transactions"`
+		TotalNumberOfExactMatches               int             `db:"total_number_of_exact_matches"`
+		TotalNumberOfMatchesWithinTolerance     int             `db:"total_number_of_matches_within_tolerance"`
+		TotalNumberOfMismatchedPairs            int             `db:"total_number_of_mismatched_pairs"`
+		TotalNumberOfGroupMatches               int             `db:"total_number_of_group_matches"`
+		TotalNumberOfDuplicateRows              int             `db:"total_number_of_duplicate_rows"`
+		TotalAmountDiscrepancies                decimal.Decimal `db:"total_amount_discrepancies"`
+		TotalAmountDiscrepanciesWithinTolerance decimal.Decimal `db:"total_amount_discrepancies_within_tolerance"`
+		TotalAmountDiscrepanciesMismatched      decimal.Decimal `db:"total_amount_discrepancies_mismatched"`
+		// Breakdown holds the per rule, per reason and per type counters as JSON
+		Breakdown string `db:"breakdown"`
+	}
+
+	Exception struct {
+		ID                  uint64              `db:"id"`
+		RunID               string              `db:"run_id"`
+		BankCode            string              `db:"bank_code"`
+		Reason              string              `db:"reason"`
+		Side                string              `db:"side"`
+		Rule                string              `db:"rule"`
+		TransactionID       string              `db:"transaction_id"`
+		TerminalRRN         string              `db:"terminal_rrn"`
+		UniqueID            string              `db:"unique_id"`
+		SystemAmount        decimal.NullDecimal `db:"system_amount"`
+		BankAmount          decimal.NullDecimal `db:"bank_amount"`
+		AmountDifference    decimal.NullDecimal `db:"amount_difference"`
+		TransactionType     string              `db:"transaction_type"`
+		BankTransactionType string              `db:"bank_transaction_type"`
+		TransactionTime     sql.NullTime        `db:"transaction_time"`
+		BankDate            sql.NullTime        `db:"bank_date"`
+		TransactionFile     string              `db:"transaction_file"`
+		BankFile            string              `db:"bank_file"`
+		// Fingerprint is the same for the same pair in every run, it is how
+		// a later run finds what happened to an exception
+		Fingerprint      string       `db:"fingerprint"`
+		Status           string       `db:"status"`
+		Assignee         string       `db:"assignee"`
+		ResolutionReason string       `db:"resolution_reason"`
+		StatusUpdatedAt  sql.NullTime `db:"status_updated_at"`
+		// OriginID is the first exception of the pair, the one its comment
+		// thread started on
+		OriginID sql.NullInt64 `db:"origin_id"`
+	}
+
+	Comment struct {
+		ID          uint64    `db:"id"`
+		ExceptionID uint64    `db:"exception_id"`
+		Author      string    `db:"author"`
+		Status      string    `db:"status"`
+		Body        string    `db:"body"`
+		CreatedAt   time.Time `db:"created_at"`
+	}
+
+	// ExceptionSort orders a page of exceptions, by id when empty.
+	ExceptionSort string
+
+	// ExceptionCriteria filters the exceptions of a run, an empty field does
+	// not filter.
+	ExceptionCriteria struct {
+		RunID    string
+		BankCode string
+		Reason   string
+		Side     string
+		Status   string
+		Sort     ExceptionSort
+		Desc     bool
+		Limit    int
+		Offset   int
+	}
+
+	Repository interface {
+		SaveRun(ctx context.Context, run *Run, summaries []*BankSummary, exceptions []*Exception) error
+		FindRunByID(ctx context.Context, id string) (*Run, error)
+		FindBankSummaries(ctx context.Context, runID string) ([]*BankSummary, error)
+		FindExceptions(ctx context.Context, runID string) ([]*Exception, error)
+		FindExceptionPage(ctx context.Context, criteria *ExceptionCriteria) ([]*Exception, error)
+		CountExceptions(ctx context.Context, criteria *ExceptionCriteria) (int, error)
+		FindExceptionByID(ctx context.Context, id uint64) (*Exception, error)
+		FindLatestExceptions(ctx context.Context, fingerprints []string) ([]*Exception, error)
+		UpdateExceptionStatus(ctx context.Context, exception *Exception, fromStatus string, comment *Comment) error
+		SaveComment(ctx context.Context, comment *Comment) error
+		FindComments(ctx context.Context, originID uint64) ([]*Comment, error)
+	}
+)
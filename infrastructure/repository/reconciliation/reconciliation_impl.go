@@ -0,0 +1,314 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// insertBatchSize keeps a batch insert far below the placeholder limit of MySQL
+	insertBatchSize = 1000
+
+	queryInsertRun = "insert into recon_runs (id, system_source, system_checksum, bank_checksum, start_date, end_date, config_snapshot, started_at, finished_at, duration_millis) " +
+		"values (:id, :system_source, :system_checksum, :bank_checksum, :start_date, :end_date, :config_snapshot, :started_at, :finished_at, :duration_millis)"
+	queryInsertBankSummary = "insert into recon_bank_summaries (run_id, bank_code, total_number_of_transactions, total_number_of_matches_transactions, total_number_of_unmatched_transactions, " +
+		"total_number_of_exact_matches, total_number_of_matches_within_tolerance, total_number_of_mismatched_pairs, total_number_of_group_matches, total_number_of_duplicate_rows, " +
+		"total_amount_discrepancies, total_amount_discrepancies_within_tolerance, total_amount_discrepancies_mismatched, breakdown) " +
+		"values (:run_id, :bank_code, :total_number_of_transactions, :total_number_of_matches_transactions, :total_number_of_unmatched_transactions, " +
+		":total_number_of_exact_matches, :total_number_of_matches_within_tolerance, :total_number_of_mismatched_pairs, :total_number_of_group_matches, :total_number_of_duplicate_rows, " +
+		":total_amount_discrepancies, :total_amount_discrepancies_within_tolerance, :total_amount_discrepancies_mismatched, :breakdown)"
+	queryInsertException = "insert into recon_exceptions (run_id, bank_code, reason, side, rule, transaction_id, terminal_rrn, unique_id, system_amount, bank_amount, amount_difference, " +
+		"transaction_type, bank_transaction_type, transaction_time, bank_date, transaction_file, bank_file, fingerprint, status, assignee, resolution_reason, status_updated_at, origin_id) " +
+		"values (:run_id, :bank_code, :reason, :side, :rule, :transaction_id, :terminal_rrn, :unique_id, :system_amount, :bank_amount, :amount_difference, " +
+		":transaction_type, :bank_transaction_type, :transaction_time, :bank_date, :transaction_file, :bank_file, :fingerprint, :status, :assignee, :resolution_reason, :status_updated_at, :origin_id)"
+	queryInsertComment = "insert into recon_exception_comments (exception_id, author, status, body, created_at) values (:exception_id, :author, :status, :body, :created_at)"
+
+	queryFindRun         = "select id, system_source, system_checksum, bank_checksum, start_date, end_date, config_snapshot, started_at, finished_at, duration_millis FROM recon_runs WHERE id = ?"
+	queryFindBankSummary = "select id, run_id, bank_code, total_number_of_transactions, total_number_of_matches_transactions, total_number_of_unmatched_transactions, " +
+		"total_number_of_exact_matches, total_number_of_matches_within_tolerance, total_number_of_mismatched_pairs, total_number_of_group_matches, total_number_of_duplicate_rows, " +
+		"total_amount_discrepancies, total_amount_discrepancies_within_tolerance, total_amount_discrepancies_mismatched, breakdown FROM recon_bank_summaries WHERE run_id = ? ORDER BY bank_code"
+	queryFindException     = "select " + exceptionColumns + " FROM recon_exceptions WHERE run_id = ? ORDER BY id"
+	queryFindExceptionPage = "select " + exceptionColumns + " FROM recon_exceptions "
+	queryCountException    = "select count(*) FROM recon_exceptions "
+	queryFindExceptionByID = "select " + exceptionColumns + " FROM recon_exceptions WHERE id = ?"
+	// The latest exception of a fingerprint carries what was last decided on the pair
+	queryFindLatestException = "select " + exceptionColumns + " FROM recon_exceptions WHERE id IN " +
+		"(select max(id) FROM recon_exceptions WHERE fingerprint IN (?) GROUP BY fingerprint)"
+	queryUpdateExceptionStatus = "update recon_exceptions SET status = ?, assignee = ?, resolution_reason = ?, status_updated_at = ? WHERE id = ? AND status = ?"
+	queryFindComment           = "select c.id, c.exception_id, c.author, c.status, c.body, c.created_at FROM recon_exception_comments c " +
+		"JOIN recon_exceptions e ON e.id = c.exception_id WHERE e.id = ? OR e.origin_id = ? ORDER BY c.created_at, c.id"
+
+	exceptionColumns = "id, run_id, bank_code, reason, side, rule, transaction_id, terminal_rrn, unique_id, system_amount, bank_amount, amount_difference, " +
+		"transaction_type, bank_transaction_type, transaction_time, bank_date, transaction_file, bank_file, " +
+		"fingerprint, status, assignee, resolution_reason, status_updated_at, origin_id"
+)
+
+var (
+	// exceptionSortColumns are what an exception is sorted by, the amount or
+	// time of whichever side it has
+	exceptionSortColumns = map[ExceptionSort]string{
+		ExceptionSortAmount: "coalesce(system_amount, bank_amount)",
+		ExceptionSortTime:   "coalesce(transaction_time, bank_date)",
+	}
+)
+
+type reconciliationRepository struct {
+	masterConnection *sqlx.DB
+}
+
+func NewReconciliationRepository(connectionDB *sqlx.DB) Repository {
+	return &reconciliationRepository{masterConnection: connectionDB}
+}
+
+// SaveRun stores a run with its summaries and exceptions in one transaction,
+// so a run is never found half written.
+func (r *reconciliationRepository) SaveRun(
+	ctx context.Context,
+	run *Run,
+	summaries []*BankSummary,
+	exceptions []*Exception) error {
+	tx, err := r.masterConnection.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Println("error when begin save recon run -> ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.NamedExecContext(ctx, queryInsertRun, run); err != nil {
+		log.Println("error when inserting recon run -> ", err)
+		return err
+	}
+
+	for start := 0; start < len(summaries); start += insertBatchSize {
+		end := min(start+insertBatchSize, len(summaries))
+		if _, err = tx.NamedExecContext(ctx, queryInsertBankSummary, summaries[start:end]); err != nil {
+			log.Println("error when inserting recon bank summaries -> ", err)
+			return err
+		}
+	}
+
+	for start := 0; start < len(exceptions); start += insertBatchSize {
+		end := min(start+insertBatchSize, len(exceptions))
+		if _, err = tx.NamedExecContext(ctx, queryInsertException, exceptions[start:end]); err != nil {
+			log.Println("error when inserting recon exceptions -> ", err)
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("error when commit save recon run -> ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *reconciliationRepository) FindRunByID(ctx context.Context, id string) (*Run, error) {
+	var run Run
+	if err := r.masterConnection.GetContext(ctx, &run, queryFindRun, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrorRunNotFound
+		}
+
+		log.Println("error when selecting recon run -> ", err)
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (r *reconciliationRepository) FindBankSummaries(ctx context.Context, runID string) ([]*BankSummary, error) {
+	var summaries []*BankSummary
+	if err := r.masterConnection.SelectContext(ctx, &summaries, queryFindBankSummary, runID); err != nil {
+		log.Println("error when selecting recon bank summaries -> ", err)
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+func (r *reconciliationRepository) FindExceptions(ctx context.Context, runID string) ([]*Exception, error) {
+	var exceptions []*Exception
+	if err := r.masterConnection.SelectContext(ctx, &exceptions, queryFindException, runID); err != nil {
+		log.Println("error when selecting recon exceptions -> ", err)
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+func (r *reconciliationRepository) FindExceptionPage(ctx context.Context, criteria *ExceptionCriteria) ([]*Exception, error) {
+	where, queryParams := exceptionWhere(criteria)
+	queryFull := queryFindExceptionPage + where + " ORDER BY "
+
+	// The id breaks ties, so a page never repeats nor skips a row
+	if column, ok := exceptionSortColumns[criteria.Sort]; ok {
+		queryFull += column
+		if criteria.Desc {
+			queryFull += " DESC"
+		}
+		queryFull += ", "
+	}
+
+	queryFull += "id"
+	if criteria.Desc {
+		queryFull += " DESC"
+	}
+
+	if criteria.Limit > 0 {
+		queryFull += " LIMIT ? OFFSET ?"
+		queryParams = append(queryParams, criteria.Limit, criteria.Offset)
+	}
+
+	var exceptions []*Exception
+	if err := r.masterConnection.SelectContext(ctx, &exceptions, r.masterConnection.Rebind(queryFull), queryParams...); err != nil {
+		log.Println("error when selecting recon exception page -> ", err)
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+func (r *reconciliationRepository) CountExceptions(ctx context.Context, criteria *ExceptionCriteria) (int, error) {
+	where, queryParams := exceptionWhere(criteria)
+
+	var total int
+	if err := r.masterConnection.GetContext(ctx, &total, r.masterConnection.Rebind(queryCountException+where), queryParams...); err != nil {
+		log.Println("error when counting recon exceptions -> ", err)
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func exceptionWhere(criteria *ExceptionCriteria) (string, []interface{}) {
+	where := "WHERE run_id = ?"
+	queryParams := []interface{}{criteria.RunID}
+
+	for _, filter := range []struct {
+		column string
+		value  string
+	}{
+		{column: "bank_code", value: criteria.BankCode},
+		{column: "reason", value: criteria.Reason},
+		{column: "side", value: criteria.Side},
+		{column: "status", value: criteria.Status},
+	} {
+		if filter.value != "" {
+			where += " AND " + filter.column + " = ?"
+			queryParams = append(queryParams, filter.value)
+		}
+	}
+
+	return where, queryParams
+}
+
+func (r *reconciliationRepository) FindExceptionByID(ctx context.Context, id uint64) (*Exception, error) {
+	var exception Exception
+	if err := r.masterConnection.GetContext(ctx, &exception, queryFindExceptionByID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrorExceptionNotFound
+		}
+
+		log.Println("error when selecting recon exception -> ", err)
+		return nil, err
+	}
+
+	return &exception, nil
+}
+
+// FindLatestExceptions returns the last stored exception of every
+// fingerprint that was seen before.
+func (r *reconciliationRepository) FindLatestExceptions(ctx context.Context, fingerprints []string) ([]*Exception, error) {
+	var exceptions []*Exception
+	for start := 0; start < len(fingerprints); start += insertBatchSize {
+		end := min(start+insertBatchSize, len(fingerprints))
+		queryFull, queryParams, err := sqlx.In(queryFindLatestException, fingerprints[start:end])
+		if err != nil {
+			log.Println("error when building latest recon exceptions query -> ", err)
+			return nil, err
+		}
+
+		var batch []*Exception
+		if err = r.masterConnection.SelectContext(ctx, &batch, r.masterConnection.Rebind(queryFull), queryParams...); err != nil {
+			log.Println("error when selecting latest recon exceptions -> ", err)
+			return nil, err
+		}
+		exceptions = append(exceptions, batch...)
+	}
+
+	return exceptions, nil
+}
+
+// UpdateExceptionStatus stores the status, assignee and resolution of an
+// exception that is still in fromStatus, with the comment of the change when
+// there is one. ErrorExceptionChanged is returned when it moved meanwhile.
+func (r *reconciliationRepository) UpdateExceptionStatus(
+	ctx context.Context,
+	exception *Exception,
+	fromStatus string,
+	comment *Comment) error {
+	tx, err := r.masterConnection.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Println("error when begin update recon exception -> ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// status_updated_at always changes, so a matched row is an affected row
+	result, err := tx.ExecContext(ctx, queryUpdateExceptionStatus,
+		exception.Status, exception.Assignee, exception.ResolutionReason, exception.StatusUpdatedAt, exception.ID, fromStatus)
+	if err != nil {
+		log.Println("error when updating recon exception -> ", err)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Println("error when updating recon exception -> ", err)
+		return err
+	}
+
+	if affected == 0 {
+		return ErrorExceptionChanged
+	}
+
+	if comment != nil {
+		if _, err = tx.NamedExecContext(ctx, queryInsertComment, comment); err != nil {
+			log.Println("error when inserting recon exception comment -> ", err)
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("error when commit update recon exception -> ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *reconciliationRepository) SaveComment(ctx context.Context, comment *Comment) error {
+	if _, err := r.masterConnection.NamedExecContext(ctx, queryInsertComment, comment); err != nil {
+		log.Println("error when inserting recon exception comment -> ", err)
+		return err
+	}
+
+	return nil
+}
+
+// FindComments returns the thread of the exceptions of a pair, the one with
+// originID and every later one carried from it, oldest first.
+func (r *reconciliationRepository) FindComments(ctx context.Context, originID uint64) ([]*Comment, error) {
+	var comments []*Comment
+	if err := r.masterConnection.SelectContext(ctx, &comments, queryFindComment, originID, originID); err != nil {
+		log.Println("error when selecting recon exception comments -> ", err)
+		return nil, err
+	}
+
+	return comments, nil
+}
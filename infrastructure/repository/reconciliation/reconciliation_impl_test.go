@@ -0,0 +1,427 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReconciliationRepository(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	repo := NewReconciliationRepository(sqlxDB)
+	assert.NotNil(t, repo)
+}
+
+func TestReconciliationRepository_SaveRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+	now := time.Now()
+	run := &Run{ID: "run-1", SystemSource: "FILE", StartDate: now, EndDate: now, ConfigSnapshot: "{}", StartedAt: now, FinishedAt: now}
+	summaries := []*BankSummary{
+		{RunID: "run-1", BankCode: "002", Breakdown: "{}"},
+		{RunID: "run-1", BankCode: "014", Breakdown: "{}"},
+	}
+	exceptions := []*Exception{
+		{RunID: "run-1", BankCode: "002", Reason: "MISSING_IN_BANK", Side: "SYSTEM", TransactionID: "TX1", SystemAmount: decimal.NewNullDecimal(decimal.NewFromInt(100))},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("insert into recon_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("insert into recon_bank_summaries .* values \\(.*\\),\\(.*\\)").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("insert into recon_exceptions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.SaveRun(ctx, run, summaries, exceptions)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("insert into recon_runs").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("insert into recon_bank_summaries").WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		err := repo.SaveRun(ctx, run, summaries, exceptions)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error begin", func(t *testing.T) {
+		mock.ExpectBegin().WillReturnError(errors.New("db error"))
+
+		err := repo.SaveRun(ctx, run, summaries, exceptions)
+		assert.Error(t, err)
+	})
+}
+
+func TestReconciliationRepository_FindRunByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+	columns := []string{"id", "system_source", "system_checksum", "bank_checksum", "start_date", "end_date", "config_snapshot", "started_at", "finished_at", "duration_millis"}
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow("run-1", "FILE", "abc", "def", time.Now(), time.Now(), "{}", time.Now(), time.Now(), 15)
+
+		mock.ExpectQuery("FROM recon_runs WHERE id = \\?").WithArgs("run-1").WillReturnRows(rows)
+
+		result, err := repo.FindRunByID(ctx, "run-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "run-1", result.ID)
+		assert.Equal(t, int64(15), result.DurationMillis)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_runs WHERE id = \\?").WithArgs("run-2").WillReturnError(sql.ErrNoRows)
+
+		result, err := repo.FindRunByID(ctx, "run-2")
+		assert.Equal(t, ErrorRunNotFound, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_runs WHERE id = \\?").WithArgs("run-3").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindRunByID(ctx, "run-3")
+		assert.EqualError(t, err, "db error")
+		assert.Nil(t, result)
+	})
+}
+
+func TestReconciliationRepository_FindBankSummaries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "run_id", "bank_code", "total_number_of_transactions", "total_amount_discrepancies", "breakdown"}).
+			AddRow(1, "run-1", "002", 10, "12.50", "{}")
+
+		mock.ExpectQuery("FROM recon_bank_summaries WHERE run_id = \\?").WithArgs("run-1").WillReturnRows(rows)
+
+		result, err := repo.FindBankSummaries(ctx, "run-1")
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "12.5", result[0].TotalAmountDiscrepancies.String())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_bank_summaries WHERE run_id = \\?").WithArgs("run-1").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindBankSummaries(ctx, "run-1")
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestReconciliationRepository_FindExceptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "run_id", "bank_code", "reason", "side", "transaction_id", "system_amount", "bank_amount", "transaction_file"}).
+			AddRow(1, "run-1", "002", "MISSING_IN_BANK", "SYSTEM", "TX1", "100.00", nil, "2026-01-02.csv")
+
+		mock.ExpectQuery("FROM recon_exceptions WHERE run_id = \\?").WithArgs("run-1").WillReturnRows(rows)
+
+		result, err := repo.FindExceptions(ctx, "run-1")
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.True(t, result[0].SystemAmount.Valid)
+		assert.False(t, result[0].BankAmount.Valid)
+		assert.Equal(t, "2026-01-02.csv", result[0].TransactionFile)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_exceptions WHERE run_id = \\?").WithArgs("run-1").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindExceptions(ctx, "run-1")
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestReconciliationRepository_FindExceptionPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "run_id", "bank_code", "reason", "side", "unique_id", "bank_amount"}).
+			AddRow(7, "run-1", "002", "MISSING_IN_SYSTEM", "BANK", "BK1", "50.00")
+
+		mock.ExpectQuery("FROM recon_exceptions WHERE run_id = \\? AND bank_code = \\? AND side = \\? "+
+			"ORDER BY coalesce\\(system_amount, bank_amount\\) DESC, id DESC LIMIT \\? OFFSET \\?").
+			WithArgs("run-1", "002", "BANK", 10, 20).WillReturnRows(rows)
+
+		result, err := repo.FindExceptionPage(ctx, &ExceptionCriteria{
+			RunID:    "run-1",
+			BankCode: "002",
+			Side:     "BANK",
+			Sort:     ExceptionSortAmount,
+			Desc:     true,
+			Limit:    10,
+			Offset:   20,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, uint64(7), result[0].ID)
+		assert.Equal(t, "BK1", result[0].UniqueID)
+	})
+
+	t.Run("sorted by id", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "run_id", "bank_code", "reason", "side"})
+
+		mock.ExpectQuery("FROM recon_exceptions WHERE run_id = \\? AND reason = \\? ORDER BY id$").
+			WithArgs("run-1", "DUPLICATE_ID").WillReturnRows(rows)
+
+		result, err := repo.FindExceptionPage(ctx, &ExceptionCriteria{RunID: "run-1", Reason: "DUPLICATE_ID"})
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_exceptions WHERE run_id = \\?").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindExceptionPage(ctx, &ExceptionCriteria{RunID: "run-1", Sort: ExceptionSortTime, Limit: 10})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReconciliationRepository_CountExceptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("select count\\(\\*\\) FROM recon_exceptions WHERE run_id = \\? AND reason = \\?").
+			WithArgs("run-1", "AMOUNT_MISMATCH").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+		total, err := repo.CountExceptions(ctx, &ExceptionCriteria{RunID: "run-1", Reason: "AMOUNT_MISMATCH"})
+		assert.NoError(t, err)
+		assert.Equal(t, 12, total)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("select count\\(\\*\\) FROM recon_exceptions").WillReturnError(errors.New("db error"))
+
+		total, err := repo.CountExceptions(ctx, &ExceptionCriteria{RunID: "run-1"})
+		assert.Error(t, err)
+		assert.Zero(t, total)
+	})
+}
+
+func TestReconciliationRepository_FindExceptionByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "run_id", "bank_code", "reason", "side", "status", "assignee", "origin_id"}).
+			AddRow(9, "run-1", "014", "MISSING_IN_SYSTEM", "BANK", "INVESTIGATING", "budi", 3)
+
+		mock.ExpectQuery("FROM recon_exceptions WHERE id = \\?").WithArgs(uint64(9)).WillReturnRows(rows)
+
+		result, err := repo.FindExceptionByID(ctx, 9)
+		assert.NoError(t, err)
+		assert.Equal(t, "INVESTIGATING", result.Status)
+		assert.Equal(t, "budi", result.Assignee)
+		assert.Equal(t, sql.NullInt64{Int64: 3, Valid: true}, result.OriginID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_exceptions WHERE id = \\?").WithArgs(uint64(10)).WillReturnError(sql.ErrNoRows)
+
+		result, err := repo.FindExceptionByID(ctx, 10)
+		assert.Equal(t, ErrorExceptionNotFound, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestReconciliationRepository_FindLatestExceptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "fingerprint", "status"}).AddRow(7, "fp-1", "RESOLVED")
+
+		mock.ExpectQuery("WHERE id IN \\(select max\\(id\\) FROM recon_exceptions WHERE fingerprint IN \\(\\?, \\?\\) GROUP BY fingerprint\\)").
+			WithArgs("fp-1", "fp-2").WillReturnRows(rows)
+
+		result, err := repo.FindLatestExceptions(ctx, []string{"fp-1", "fp-2"})
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "RESOLVED", result[0].Status)
+	})
+
+	t.Run("nothing to find", func(t *testing.T) {
+		result, err := repo.FindLatestExceptions(ctx, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("fingerprint IN").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindLatestExceptions(ctx, []string{"fp-1"})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReconciliationRepository_UpdateExceptionStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+	now := time.Now()
+	exception := &Exception{ID: 9, Status: "RESOLVED", ResolutionReason: "late settlement", StatusUpdatedAt: sql.NullTime{Time: now, Valid: true}}
+	comment := &Comment{ExceptionID: 9, Author: "siti", Status: "RESOLVED", CreatedAt: now}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("update recon_exceptions SET status = \\?, assignee = \\?, resolution_reason = \\?, status_updated_at = \\? WHERE id = \\? AND status = \\?").
+			WithArgs("RESOLVED", "", "late settlement", sqlmock.AnyArg(), uint64(9), "INVESTIGATING").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("insert into recon_exception_comments").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.UpdateExceptionStatus(ctx, exception, "INVESTIGATING", comment)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("update recon_exceptions").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.UpdateExceptionStatus(ctx, exception, "OPEN", comment)
+		assert.Equal(t, ErrorExceptionChanged, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error comment", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("update recon_exceptions").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("insert into recon_exception_comments").WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		err := repo.UpdateExceptionStatus(ctx, exception, "INVESTIGATING", comment)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReconciliationRepository_SaveComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+	comment := &Comment{ExceptionID: 9, Author: "siti", Status: "OPEN", Body: "asked the bank", CreatedAt: time.Now()}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec("insert into recon_exception_comments").
+			WithArgs(uint64(9), "siti", "OPEN", "asked the bank", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		assert.NoError(t, repo.SaveComment(ctx, comment))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectExec("insert into recon_exception_comments").WillReturnError(errors.New("db error"))
+
+		assert.Error(t, repo.SaveComment(ctx, comment))
+	})
+}
+
+func TestReconciliationRepository_FindComments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconciliationRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "exception_id", "author", "status", "body", "created_at"}).
+			AddRow(1, 3, "siti", "INVESTIGATING", "asked the bank", time.Now()).
+			AddRow(2, 9, "budi", "RESOLVED", "", time.Now())
+
+		mock.ExpectQuery("FROM recon_exception_comments c JOIN recon_exceptions e ON e.id = c.exception_id WHERE e.id = \\? OR e.origin_id = \\?").
+			WithArgs(uint64(3), uint64(3)).WillReturnRows(rows)
+
+		result, err := repo.FindComments(ctx, 3)
+		assert.NoError(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, uint64(9), result[1].ExceptionID)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("FROM recon_exception_comments").WillReturnError(errors.New("db error"))
+
+		result, err := repo.FindComments(ctx, 3)
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
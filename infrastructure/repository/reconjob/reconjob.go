@@ -0,0 +1,32 @@
+package reconjob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrorJobNotFound = errors.New("recon job is not exist")
+)
+
+type (
+	Job struct {
+		ID         string       `db:"id"`
+		Status     string       `db:"status"`
+		RowsParsed int64        `db:"rows_parsed"`
+		BanksTotal int          `db:"banks_total"`
+		BanksDone  int          `db:"banks_done"`
+		RunID      string       `db:"run_id"`
+		Error      string       `db:"error"`
+		CreatedAt  time.Time    `db:"created_at"`
+		StartedAt  sql.NullTime `db:"started_at"`
+		FinishedAt sql.NullTime `db:"finished_at"`
+	}
+
+	Repository interface {
+		SaveJob(ctx context.Context, job *Job) error
+		FindJobByID(ctx context.Context, id string) (*Job, error)
+	}
+)
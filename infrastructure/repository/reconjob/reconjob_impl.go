@@ -0,0 +1,49 @@
+package reconjob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	querySaveJob = "insert into recon_jobs (id, status, rows_parsed, banks_total, banks_done, run_id, error, created_at, started_at, finished_at) " +
+		"values (:id, :status, :rows_parsed, :banks_total, :banks_done, :run_id, :error, :created_at, :started_at, :finished_at) " +
+		"on duplicate key update status = values(status), rows_parsed = values(rows_parsed), banks_total = values(banks_total), banks_done = values(banks_done), " +
+		"run_id = values(run_id), error = values(error), started_at = values(started_at), finished_at = values(finished_at)"
+	queryFindJob = "select id, status, rows_parsed, banks_total, banks_done, run_id, error, created_at, started_at, finished_at FROM recon_jobs WHERE id = ?"
+)
+
+type reconJobRepository struct {
+	masterConnection *sqlx.DB
+}
+
+func NewReconJobRepository(connectionDB *sqlx.DB) Repository {
+	return &reconJobRepository{masterConnection: connectionDB}
+}
+
+func (r *reconJobRepository) SaveJob(ctx context.Context, job *Job) error {
+	if _, err := r.masterConnection.NamedExecContext(ctx, querySaveJob, job); err != nil {
+		log.Println("error when saving recon job -> ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *reconJobRepository) FindJobByID(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := r.masterConnection.GetContext(ctx, &job, queryFindJob, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrorJobNotFound
+		}
+
+		log.Println("error when selecting recon job -> ", err)
+		return nil, err
+	}
+
+	return &job, nil
+}
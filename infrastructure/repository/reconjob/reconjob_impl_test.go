@@ -0,0 +1,86 @@
+package reconjob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReconJobRepository(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	repo := NewReconJobRepository(sqlxDB)
+	assert.NotNil(t, repo)
+}
+
+func TestReconJobRepository_SaveJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconJobRepository(sqlxDB)
+
+	ctx := context.Background()
+	job := &Job{ID: "job-1", Status: "QUEUED", CreatedAt: time.Now()}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec("insert into recon_jobs .* on duplicate key update").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.SaveJob(ctx, job)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectExec("insert into recon_jobs").WillReturnError(errors.New("db error"))
+
+		err := repo.SaveJob(ctx, job)
+		assert.Error(t, err)
+	})
+}
+
+func TestReconJobRepository_FindJobByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconJobRepository(sqlxDB)
+
+	ctx := context.Background()
+	columns := []string{"id", "status", "rows_parsed", "banks_total", "banks_done", "run_id", "error", "created_at", "started_at", "finished_at"}
+
+	t.Run("success", func(t *testing.T) {
+		now := time.Now()
+		rows := sqlmock.NewRows(columns).AddRow("job-1", "SUCCEEDED", 10, 2, 2, "run-1", "", now, now, now)
+		mock.ExpectQuery("select (.+) FROM recon_jobs WHERE id = ?").WithArgs("job-1").WillReturnRows(rows)
+
+		job, err := repo.FindJobByID(ctx, "job-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "SUCCEEDED", job.Status)
+		assert.Equal(t, int64(10), job.RowsParsed)
+		assert.Equal(t, "run-1", job.RunID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("select (.+) FROM recon_jobs WHERE id = ?").WithArgs("job-2").WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.FindJobByID(ctx, "job-2")
+		assert.ErrorIs(t, err, ErrorJobNotFound)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("select (.+) FROM recon_jobs WHERE id = ?").WithArgs("job-3").WillReturnError(errors.New("db error"))
+
+		_, err := repo.FindJobByID(ctx, "job-3")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrorJobNotFound)
+	})
+}
@@ -0,0 +1,21 @@
+package reconlock
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrorLockTaken = errors.New("recon lock is taken")
+)
+
+type (
+	// Lock is held until it is released, or until its connection is lost.
+	Lock interface {
+		Release(ctx context.Context) error
+	}
+
+	Repository interface {
+		TryLock(ctx context.Context, name string) (Lock, error)
+	}
+)
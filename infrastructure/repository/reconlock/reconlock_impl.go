@@ -0,0 +1,68 @@
+package reconlock
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// A named lock of MySQL belongs to the session taking it, so it is taken
+	// and released on the same connection. A timeout of 0 does not wait.
+	queryGetLock     = "select coalesce(get_lock(?, 0), 0)"
+	queryReleaseLock = "select release_lock(?)"
+)
+
+type (
+	reconLockRepository struct {
+		masterConnection *sqlx.DB
+	}
+
+	lock struct {
+		name string
+		conn *sqlx.Conn
+	}
+)
+
+func NewReconLockRepository(connectionDB *sqlx.DB) Repository {
+	return &reconLockRepository{masterConnection: connectionDB}
+}
+
+// TryLock takes the lock name for every process sharing the database, it
+// returns ErrorLockTaken at once when someone else holds it.
+func (r *reconLockRepository) TryLock(ctx context.Context, name string) (Lock, error) {
+	conn, err := r.masterConnection.Connx(ctx)
+	if err != nil {
+		log.Println("error when opening lock connection -> ", err)
+		return nil, err
+	}
+
+	var acquired int
+	if err = conn.GetContext(ctx, &acquired, queryGetLock, name); err != nil {
+		log.Println("error when taking recon lock -> ", err)
+		conn.Close()
+		return nil, err
+	}
+
+	if acquired != 1 {
+		conn.Close()
+		return nil, ErrorLockTaken
+	}
+
+	return &lock{name: name, conn: conn}, nil
+}
+
+// Release frees the lock and gives its connection back to the pool.
+func (l *lock) Release(ctx context.Context) error {
+	defer l.conn.Close()
+
+	var released sql.NullInt64
+	if err := l.conn.GetContext(ctx, &released, queryReleaseLock, l.name); err != nil {
+		log.Println("error when releasing recon lock -> ", err)
+		return err
+	}
+
+	return nil
+}
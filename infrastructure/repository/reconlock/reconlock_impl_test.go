@@ -0,0 +1,61 @@
+package reconlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReconLockRepository(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	repo := NewReconLockRepository(sqlxDB)
+	assert.NotNil(t, repo)
+}
+
+func TestReconLockRepository_TryLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewReconLockRepository(sqlxDB)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("select coalesce\\(get_lock\\(\\?, 0\\), 0\\)").WithArgs("recon-014").
+			WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+		mock.ExpectQuery("select release_lock\\(\\?\\)").WithArgs("recon-014").
+			WillReturnRows(sqlmock.NewRows([]string{"release"}).AddRow(1))
+
+		lock, err := repo.TryLock(ctx, "recon-014")
+		assert.NoError(t, err)
+		assert.NoError(t, lock.Release(ctx))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error taken", func(t *testing.T) {
+		mock.ExpectQuery("select coalesce\\(get_lock").WithArgs("recon-014").
+			WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+
+		lock, err := repo.TryLock(ctx, "recon-014")
+		assert.ErrorIs(t, err, ErrorLockTaken)
+		assert.Nil(t, lock)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery("select coalesce\\(get_lock").WithArgs("recon-014").WillReturnError(errors.New("db error"))
+
+		lock, err := repo.TryLock(ctx, "recon-014")
+		assert.Error(t, err)
+		assert.Nil(t, lock)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
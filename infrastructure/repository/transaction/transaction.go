@@ -29,11 +29,16 @@ type (
 	Criteria struct {
 		StartDate time.Time
 		EndDate   time.Time
+		BankCodes []string
+		Limit     int
 	}
 
 	Repository interface {
 		FindDistinctBankCode(ctx context.Context) ([]*Transaction, error)
 		FindTransaction(ctx context.Context, tc *Criteria) ([]*Transaction, error)
+		// EachTransaction reads the transactions of tc one row at a time,
+		// without holding them all, and stops at the first error of fn.
+		EachTransaction(ctx context.Context, tc *Criteria, fn func(*Transaction) error) error
 	}
 )
 
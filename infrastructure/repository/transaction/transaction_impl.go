@@ -31,11 +31,10 @@ func (t *transactionRepository) FindDistinctBankCode(ctx context.Context) ([]*Tr
 }
 
 func (t *transactionRepository) FindTransaction(ctx context.Context, tc *Criteria) ([]*Transaction, error) {
-	queryParams := []interface{}{
-		tc.StartDate,
-		tc.EndDate,
+	queryFull, queryParams, err := t.findTransactionQuery(tc)
+	if err != nil {
+		return nil, err
 	}
-	queryFull := queryFindTransaction + "WHERE date(transaction_time) >= ? AND date(transaction_time) <= ?"
 
 	var transactions []*Transaction
 	if err := t.masterConnection.SelectContext(ctx, &transactions, queryFull, queryParams...); err != nil {
@@ -45,3 +44,57 @@ func (t *transactionRepository) FindTransaction(ctx context.Context, tc *Criteri
 
 	return transactions, nil
 }
+
+func (t *transactionRepository) EachTransaction(ctx context.Context, tc *Criteria, fn func(*Transaction) error) error {
+	queryFull, queryParams, err := t.findTransactionQuery(tc)
+	if err != nil {
+		return err
+	}
+
+	rows, err := t.masterConnection.QueryxContext(ctx, queryFull, queryParams...)
+	if err != nil {
+		log.Println("error when selecting each transaction -> ", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction Transaction
+		if err := rows.StructScan(&transaction); err != nil {
+			log.Println("error when scanning each transaction -> ", err)
+			return err
+		}
+
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (t *transactionRepository) findTransactionQuery(tc *Criteria) (string, []interface{}, error) {
+	queryParams := []interface{}{
+		tc.StartDate,
+		tc.EndDate,
+	}
+	queryFull := queryFindTransaction + "WHERE date(transaction_time) >= ? AND date(transaction_time) <= ?"
+
+	if len(tc.BankCodes) > 0 {
+		queryFull += " AND bank_code IN (?)"
+		queryParams = append(queryParams, tc.BankCodes)
+	}
+
+	if tc.Limit > 0 {
+		queryFull += " LIMIT ?"
+		queryParams = append(queryParams, tc.Limit)
+	}
+
+	queryFull, queryParams, err := sqlx.In(queryFull, queryParams...)
+	if err != nil {
+		log.Println("error when building find transaction -> ", err)
+		return "", nil, err
+	}
+
+	return t.masterConnection.Rebind(queryFull), queryParams, nil
+}
@@ -88,6 +88,91 @@ func TestTransactionRepository_FindTransaction(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, result)
 	})
+
+	t.Run("success with bank codes and limit", func(t *testing.T) {
+		tcBank := &Criteria{
+			StartDate: tc.StartDate,
+			EndDate:   tc.EndDate,
+			BankCodes: []string{"002", "014"},
+			Limit:     100,
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "transaction_id", "terminal_rrn", "amount", "transaction_type", "bank_code", "transaction_time", "updated_at"}).
+			AddRow(1, "TX001", "RRN001", 1000.0, "DEBIT", "002", time.Now(), time.Now())
+
+		mock.ExpectQuery("WHERE date\\(transaction_time\\) >= \\? AND date\\(transaction_time\\) <= \\? AND bank_code IN \\(\\?, \\?\\) LIMIT \\?").
+			WithArgs(tc.StartDate, tc.EndDate, "002", "014", 100).
+			WillReturnRows(rows)
+
+		result, err := repo.FindTransaction(ctx, tcBank)
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "002", result[0].BankCode)
+	})
+}
+
+func TestTransactionRepository_EachTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewTransactionRepository(sqlxDB)
+
+	ctx := context.Background()
+	tc := &Criteria{
+		StartDate: time.Now().AddDate(0, 0, -1),
+		EndDate:   time.Now(),
+		BankCodes: []string{"002", "014"},
+	}
+	query := "WHERE date\\(transaction_time\\) >= \\? AND date\\(transaction_time\\) <= \\? AND bank_code IN \\(\\?, \\?\\)$"
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "transaction_id", "terminal_rrn", "amount", "transaction_type", "bank_code", "transaction_time", "updated_at"}).
+			AddRow(1, "TX001", "RRN001", 1000.0, "DEBIT", "002", time.Now(), time.Now()).
+			AddRow(2, "TX002", "RRN002", 2000.0, "CREDIT", "014", time.Now(), time.Now())
+
+		mock.ExpectQuery(query).
+			WithArgs(tc.StartDate, tc.EndDate, "002", "014").
+			WillReturnRows(rows)
+
+		var ids []string
+		err := repo.EachTransaction(ctx, tc, func(transaction *Transaction) error {
+			ids = append(ids, transaction.TransactionID)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"TX001", "TX002"}, ids)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery(query).
+			WithArgs(tc.StartDate, tc.EndDate, "002", "014").
+			WillReturnError(errors.New("db error"))
+
+		err := repo.EachTransaction(ctx, tc, func(*Transaction) error { return nil })
+		assert.Error(t, err)
+	})
+
+	t.Run("error of fn stops the rows", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "transaction_id", "terminal_rrn", "amount", "transaction_type", "bank_code", "transaction_time", "updated_at"}).
+			AddRow(1, "TX001", "RRN001", 1000.0, "DEBIT", "002", time.Now(), time.Now()).
+			AddRow(2, "TX002", "RRN002", 2000.0, "CREDIT", "014", time.Now(), time.Now())
+
+		mock.ExpectQuery(query).
+			WithArgs(tc.StartDate, tc.EndDate, "002", "014").
+			WillReturnRows(rows).
+			RowsWillBeClosed()
+
+		fnErr := errors.New("spill error")
+		calls := 0
+		err := repo.EachTransaction(ctx, tc, func(*Transaction) error {
+			calls++
+			return fnErr
+		})
+		assert.ErrorIs(t, err, fnErr)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestTransaction_HelperMethods(t *testing.T) {
@@ -13,11 +13,51 @@ type Controller struct {
 	mock.Mock
 }
 
+// CancelJob provides a mock function with given fields: w, r
+func (_m *Controller) CancelJob(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// CommentException provides a mock function with given fields: w, r
+func (_m *Controller) CommentException(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// DownloadRun provides a mock function with given fields: w, r
+func (_m *Controller) DownloadRun(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// FindException provides a mock function with given fields: w, r
+func (_m *Controller) FindException(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// FindExceptions provides a mock function with given fields: w, r
+func (_m *Controller) FindExceptions(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// FindJob provides a mock function with given fields: w, r
+func (_m *Controller) FindJob(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// FindRun provides a mock function with given fields: w, r
+func (_m *Controller) FindRun(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // Proceed provides a mock function with given fields: w, r
 func (_m *Controller) Proceed(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// UpdateException provides a mock function with given fields: w, r
+func (_m *Controller) UpdateException(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // NewController creates a new instance of Controller. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewController(t interface {
@@ -0,0 +1,136 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	job "amartha-recon-service/application/job"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Manager is an autogenerated mock type for the Manager type
+type Manager struct {
+	mock.Mock
+}
+
+// Cancel provides a mock function with given fields: ctx, id
+func (_m *Manager) Cancel(ctx context.Context, id string) (job.ShowJob, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Cancel")
+	}
+
+	var r0 job.ShowJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (job.ShowJob, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) job.ShowJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(job.ShowJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Find provides a mock function with given fields: ctx, id
+func (_m *Manager) Find(ctx context.Context, id string) (job.ShowJob, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Find")
+	}
+
+	var r0 job.ShowJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (job.ShowJob, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) job.ShowJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(job.ShowJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Shutdown provides a mock function with given fields: ctx
+func (_m *Manager) Shutdown(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Shutdown")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Start provides a mock function with no fields
+func (_m *Manager) Start() {
+	_m.Called()
+}
+
+// Submit provides a mock function with given fields: ctx, task, release
+func (_m *Manager) Submit(ctx context.Context, task job.Task, release func()) (job.ShowJob, error) {
+	ret := _m.Called(ctx, task, release)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Submit")
+	}
+
+	var r0 job.ShowJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, job.Task, func()) (job.ShowJob, error)); ok {
+		return rf(ctx, task, release)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, job.Task, func()) job.ShowJob); ok {
+		r0 = rf(ctx, task, release)
+	} else {
+		r0 = ret.Get(0).(job.ShowJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, job.Task, func()) error); ok {
+		r1 = rf(ctx, task, release)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewManager creates a new instance of Manager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewManager(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Manager {
+	mock := &Manager{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,77 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	reconjob "amartha-recon-service/infrastructure/repository/reconjob"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ReconJobRepository is an autogenerated mock type for the Repository type
+type ReconJobRepository struct {
+	mock.Mock
+}
+
+// FindJobByID provides a mock function with given fields: ctx, id
+func (_m *ReconJobRepository) FindJobByID(ctx context.Context, id string) (*reconjob.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindJobByID")
+	}
+
+	var r0 *reconjob.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*reconjob.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *reconjob.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*reconjob.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveJob provides a mock function with given fields: ctx, job
+func (_m *ReconJobRepository) SaveJob(ctx context.Context, job *reconjob.Job) error {
+	ret := _m.Called(ctx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconjob.Job) error); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewReconJobRepository creates a new instance of ReconJobRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReconJobRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReconJobRepository {
+	mock := &ReconJobRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,59 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	reconlock "amartha-recon-service/infrastructure/repository/reconlock"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ReconLockRepository is an autogenerated mock type for the Repository type
+type ReconLockRepository struct {
+	mock.Mock
+}
+
+// TryLock provides a mock function with given fields: ctx, name
+func (_m *ReconLockRepository) TryLock(ctx context.Context, name string) (reconlock.Lock, error) {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TryLock")
+	}
+
+	var r0 reconlock.Lock
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (reconlock.Lock, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) reconlock.Lock); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(reconlock.Lock)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewReconLockRepository creates a new instance of ReconLockRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReconLockRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReconLockRepository {
+	mock := &ReconLockRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
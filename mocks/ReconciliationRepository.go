@@ -0,0 +1,321 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	reconciliation "amartha-recon-service/infrastructure/repository/reconciliation"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ReconciliationRepository is an autogenerated mock type for the Repository type
+type ReconciliationRepository struct {
+	mock.Mock
+}
+
+// CountExceptions provides a mock function with given fields: ctx, criteria
+func (_m *ReconciliationRepository) CountExceptions(ctx context.Context, criteria *reconciliation.ExceptionCriteria) (int, error) {
+	ret := _m.Called(ctx, criteria)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountExceptions")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.ExceptionCriteria) (int, error)); ok {
+		return rf(ctx, criteria)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.ExceptionCriteria) int); ok {
+		r0 = rf(ctx, criteria)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *reconciliation.ExceptionCriteria) error); ok {
+		r1 = rf(ctx, criteria)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindBankSummaries provides a mock function with given fields: ctx, runID
+func (_m *ReconciliationRepository) FindBankSummaries(ctx context.Context, runID string) ([]*reconciliation.BankSummary, error) {
+	ret := _m.Called(ctx, runID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindBankSummaries")
+	}
+
+	var r0 []*reconciliation.BankSummary
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*reconciliation.BankSummary, error)); ok {
+		return rf(ctx, runID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*reconciliation.BankSummary); ok {
+		r0 = rf(ctx, runID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*reconciliation.BankSummary)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, runID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindComments provides a mock function with given fields: ctx, originID
+func (_m *ReconciliationRepository) FindComments(ctx context.Context, originID uint64) ([]*reconciliation.Comment, error) {
+	ret := _m.Called(ctx, originID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindComments")
+	}
+
+	var r0 []*reconciliation.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]*reconciliation.Comment, error)); ok {
+		return rf(ctx, originID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []*reconciliation.Comment); ok {
+		r0 = rf(ctx, originID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*reconciliation.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, originID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExceptionByID provides a mock function with given fields: ctx, id
+func (_m *ReconciliationRepository) FindExceptionByID(ctx context.Context, id uint64) (*reconciliation.Exception, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindExceptionByID")
+	}
+
+	var r0 *reconciliation.Exception
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (*reconciliation.Exception, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) *reconciliation.Exception); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*reconciliation.Exception)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExceptionPage provides a mock function with given fields: ctx, criteria
+func (_m *ReconciliationRepository) FindExceptionPage(ctx context.Context, criteria *reconciliation.ExceptionCriteria) ([]*reconciliation.Exception, error) {
+	ret := _m.Called(ctx, criteria)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindExceptionPage")
+	}
+
+	var r0 []*reconciliation.Exception
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.ExceptionCriteria) ([]*reconciliation.Exception, error)); ok {
+		return rf(ctx, criteria)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.ExceptionCriteria) []*reconciliation.Exception); ok {
+		r0 = rf(ctx, criteria)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*reconciliation.Exception)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *reconciliation.ExceptionCriteria) error); ok {
+		r1 = rf(ctx, criteria)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExceptions provides a mock function with given fields: ctx, runID
+func (_m *ReconciliationRepository) FindExceptions(ctx context.Context, runID string) ([]*reconciliation.Exception, error) {
+	ret := _m.Called(ctx, runID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindExceptions")
+	}
+
+	var r0 []*reconciliation.Exception
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*reconciliation.Exception, error)); ok {
+		return rf(ctx, runID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*reconciliation.Exception); ok {
+		r0 = rf(ctx, runID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*reconciliation.Exception)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, runID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindLatestExceptions provides a mock function with given fields: ctx, fingerprints
+func (_m *ReconciliationRepository) FindLatestExceptions(ctx context.Context, fingerprints []string) ([]*reconciliation.Exception, error) {
+	ret := _m.Called(ctx, fingerprints)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindLatestExceptions")
+	}
+
+	var r0 []*reconciliation.Exception
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]*reconciliation.Exception, error)); ok {
+		return rf(ctx, fingerprints)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []*reconciliation.Exception); ok {
+		r0 = rf(ctx, fingerprints)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*reconciliation.Exception)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, fingerprints)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRunByID provides a mock function with given fields: ctx, id
+func (_m *ReconciliationRepository) FindRunByID(ctx context.Context, id string) (*reconciliation.Run, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindRunByID")
+	}
+
+	var r0 *reconciliation.Run
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*reconciliation.Run, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *reconciliation.Run); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*reconciliation.Run)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveComment provides a mock function with given fields: ctx, comment
+func (_m *ReconciliationRepository) SaveComment(ctx context.Context, comment *reconciliation.Comment) error {
+	ret := _m.Called(ctx, comment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveComment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.Comment) error); ok {
+		r0 = rf(ctx, comment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveRun provides a mock function with given fields: ctx, run, summaries, exceptions
+func (_m *ReconciliationRepository) SaveRun(ctx context.Context, run *reconciliation.Run, summaries []*reconciliation.BankSummary, exceptions []*reconciliation.Exception) error {
+	ret := _m.Called(ctx, run, summaries, exceptions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveRun")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.Run, []*reconciliation.BankSummary, []*reconciliation.Exception) error); ok {
+		r0 = rf(ctx, run, summaries, exceptions)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateExceptionStatus provides a mock function with given fields: ctx, exception, fromStatus, comment
+func (_m *ReconciliationRepository) UpdateExceptionStatus(ctx context.Context, exception *reconciliation.Exception, fromStatus string, comment *reconciliation.Comment) error {
+	ret := _m.Called(ctx, exception, fromStatus, comment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateExceptionStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *reconciliation.Exception, string, *reconciliation.Comment) error); ok {
+		r0 = rf(ctx, exception, fromStatus, comment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewReconciliationRepository creates a new instance of ReconciliationRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewReconciliationRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ReconciliationRepository {
+	mock := &ReconciliationRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,94 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	parser "amartha-recon-service/application/parser"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Registry is an autogenerated mock type for the Registry type
+type Registry struct {
+	mock.Mock
+}
+
+// Find provides a mock function with given fields: key, side
+func (_m *Registry) Find(key string, side parser.Side) (parser.Template, error) {
+	ret := _m.Called(key, side)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Find")
+	}
+
+	var r0 parser.Template
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, parser.Side) (parser.Template, error)); ok {
+		return rf(key, side)
+	}
+	if rf, ok := ret.Get(0).(func(string, parser.Side) parser.Template); ok {
+		r0 = rf(key, side)
+	} else {
+		r0 = ret.Get(0).(parser.Template)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, parser.Side) error); ok {
+		r1 = rf(key, side)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Resolve provides a mock function with given fields: key, side, r
+func (_m *Registry) Resolve(key string, side parser.Side, r io.Reader) (parser.Template, io.Reader, error) {
+	ret := _m.Called(key, side, r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Resolve")
+	}
+
+	var r0 parser.Template
+	var r1 io.Reader
+	var r2 error
+	if rf, ok := ret.Get(0).(func(string, parser.Side, io.Reader) (parser.Template, io.Reader, error)); ok {
+		return rf(key, side, r)
+	}
+	if rf, ok := ret.Get(0).(func(string, parser.Side, io.Reader) parser.Template); ok {
+		r0 = rf(key, side, r)
+	} else {
+		r0 = ret.Get(0).(parser.Template)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, parser.Side, io.Reader) io.Reader); ok {
+		r1 = rf(key, side, r)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(io.Reader)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(string, parser.Side, io.Reader) error); ok {
+		r2 = rf(key, side, r)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewRegistry creates a new instance of Registry. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRegistry(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Registry {
+	mock := &Registry{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
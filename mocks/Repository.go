@@ -14,6 +14,24 @@ type Repository struct {
 	mock.Mock
 }
 
+// EachTransaction provides a mock function with given fields: ctx, tc, fn
+func (_m *Repository) EachTransaction(ctx context.Context, tc *transaction.Criteria, fn func(*transaction.Transaction) error) error {
+	ret := _m.Called(ctx, tc, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EachTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *transaction.Criteria, func(*transaction.Transaction) error) error); ok {
+		r0 = rf(ctx, tc, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // FindDistinctBankCode provides a mock function with given fields: ctx
 func (_m *Repository) FindDistinctBankCode(ctx context.Context) ([]*transaction.Transaction, error) {
 	ret := _m.Called(ctx)
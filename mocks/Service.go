@@ -14,6 +14,118 @@ type Service struct {
 	mock.Mock
 }
 
+// CommentException provides a mock function with given fields: ctx, id, author, body
+func (_m *Service) CommentException(ctx context.Context, id uint64, author string, body string) (recon.ExceptionDetail, error) {
+	ret := _m.Called(ctx, id, author, body)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CommentException")
+	}
+
+	var r0 recon.ExceptionDetail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, string, string) (recon.ExceptionDetail, error)); ok {
+		return rf(ctx, id, author, body)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, string, string) recon.ExceptionDetail); ok {
+		r0 = rf(ctx, id, author, body)
+	} else {
+		r0 = ret.Get(0).(recon.ExceptionDetail)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, string, string) error); ok {
+		r1 = rf(ctx, id, author, body)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindException provides a mock function with given fields: ctx, id
+func (_m *Service) FindException(ctx context.Context, id uint64) (recon.ExceptionDetail, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindException")
+	}
+
+	var r0 recon.ExceptionDetail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (recon.ExceptionDetail, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) recon.ExceptionDetail); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(recon.ExceptionDetail)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindExceptions provides a mock function with given fields: ctx, query
+func (_m *Service) FindExceptions(ctx context.Context, query recon.ExceptionQuery) (recon.ExceptionPage, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindExceptions")
+	}
+
+	var r0 recon.ExceptionPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, recon.ExceptionQuery) (recon.ExceptionPage, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, recon.ExceptionQuery) recon.ExceptionPage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(recon.ExceptionPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, recon.ExceptionQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRun provides a mock function with given fields: ctx, id
+func (_m *Service) FindRun(ctx context.Context, id string) (recon.ShowRun, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindRun")
+	}
+
+	var r0 recon.ShowRun
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (recon.ShowRun, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) recon.ShowRun); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(recon.ShowRun)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Proceed provides a mock function with given fields: ctx, file
 func (_m *Service) Proceed(ctx context.Context, file *recon.UploadFile) (recon.ShowResultReconciliation, error) {
 	ret := _m.Called(ctx, file)
@@ -42,6 +154,34 @@ func (_m *Service) Proceed(ctx context.Context, file *recon.UploadFile) (recon.S
 	return r0, r1
 }
 
+// UpdateException provides a mock function with given fields: ctx, update
+func (_m *Service) UpdateException(ctx context.Context, update recon.ExceptionUpdate) (recon.ExceptionDetail, error) {
+	ret := _m.Called(ctx, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateException")
+	}
+
+	var r0 recon.ExceptionDetail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, recon.ExceptionUpdate) (recon.ExceptionDetail, error)); ok {
+		return rf(ctx, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, recon.ExceptionUpdate) recon.ExceptionDetail); ok {
+		r0 = rf(ctx, update)
+	} else {
+		r0 = ret.Get(0).(recon.ExceptionDetail)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, recon.ExceptionUpdate) error); ok {
+		r1 = rf(ctx, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewService creates a new instance of Service. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewService(t interface {